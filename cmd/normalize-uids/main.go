@@ -0,0 +1,182 @@
+// Command normalize-uids rewrites every card_rfid.uid into the canonical
+// upper-case hex form used since readers can report UIDs in several formats.
+//
+// UIDs made only of digits are ambiguous: they may be hex, or decimal from a
+// legacy reader. They are reported and left untouched unless -decimal says
+// which decimal byte order they were read in. UIDs that are not valid hex
+// are reported and left untouched too.
+//
+// Cards whose UID collapses onto one already used by an older card are
+// blocked, the collision is written to their status history so an admin can
+// decide which card is real, and their UID is set aside as {uid}#{id} so
+// card_rfid.uid stays unique.
+//
+//	go run ./cmd/normalize-uids -dry-run
+//	go run ./cmd/normalize-uids -decimal decimal_le -dry-run
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/dimassfeb-09/smart-library-be/db"
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/repository"
+)
+
+// uidChange is the new uid of a card. For a duplicate, ownerID is the older
+// card that keeps the uid.
+type uidChange struct {
+	card    *entity.Card
+	uid     string
+	ownerID int
+}
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report the changes without writing them")
+	decimalFormat := flag.String("decimal", "", "read all-digit uids as "+helper.UIDFormatDecimalLE+" or "+helper.UIDFormatDecimalBE+" instead of leaving them unchanged")
+	flag.Parse()
+
+	if *decimalFormat != "" && *decimalFormat != helper.UIDFormatDecimalLE && *decimalFormat != helper.UIDFormatDecimalBE {
+		log.Fatalf("-decimal must be %s or %s", helper.UIDFormatDecimalLE, helper.UIDFormatDecimalBE)
+	}
+
+	database, _ := db.Connection()
+	defer database.Close()
+
+	ctx := context.Background()
+	cardRepository := repository.NewCardRepository()
+
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		log.Fatal("transaction start failed: ", err)
+	}
+	defer tx.Rollback()
+
+	cards, errorResponse := cardRepository.GetCardsForUpdate(ctx, tx)
+	if errorResponse != nil {
+		log.Fatal(errorResponse.Message)
+	}
+
+	plan := planUIDChanges(cards, *decimalFormat)
+
+	if !*dryRun {
+		// Duplicates give up their uid first, so the rewrites never collide.
+		for _, duplicate := range plan.duplicates {
+			if errorResponse := retireDuplicate(ctx, cardRepository, tx, duplicate); errorResponse != nil {
+				log.Fatal(errorResponse.Message)
+			}
+		}
+		for _, rewrite := range plan.rewrites {
+			if errorResponse := cardRepository.UpdateCardUID(ctx, tx, rewrite.card.ID, rewrite.uid); errorResponse != nil {
+				log.Fatal(errorResponse.Message)
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			log.Fatal("transaction commit failed: ", err)
+		}
+	}
+
+	fmt.Printf("%d cards checked, %d rewritten, %d duplicates, %d ambiguous, %d invalid\n", len(cards), len(plan.rewrites), len(plan.duplicates), plan.ambiguous, plan.invalid)
+	if *dryRun {
+		fmt.Println("dry run, nothing was written")
+	}
+}
+
+type uidPlan struct {
+	rewrites   []uidChange
+	duplicates []uidChange
+	ambiguous  int
+	invalid    int
+}
+
+// planUIDChanges works out the canonical uid of every card, in id order so
+// the oldest card keeps a shared uid, and reports each decision.
+func planUIDChanges(cards []*entity.Card, decimalFormat string) *uidPlan {
+	var plan uidPlan
+	owners := make(map[string]int)
+	for _, card := range cards {
+		uid := card.UID
+
+		format := helper.UIDFormatHex
+		if isDigits(card.UID) {
+			format = decimalFormat
+		}
+
+		switch normalized, err := helper.NormalizeUID(card.UID, format); {
+		case format == "":
+			plan.ambiguous++
+			fmt.Printf("card %d: uid %q is all digits and may be decimal, left unchanged (see -decimal)\n", card.ID, card.UID)
+		case err != nil:
+			plan.invalid++
+			fmt.Printf("card %d: uid %q is not a valid %s uid, left unchanged\n", card.ID, card.UID, format)
+		default:
+			uid = normalized
+		}
+		if uid == "" {
+			continue
+		}
+
+		// The unique key compares case-insensitively, so must the owners.
+		ownerID, taken := owners[strings.ToUpper(uid)]
+		if !taken {
+			owners[strings.ToUpper(uid)] = card.ID
+			if uid != card.UID {
+				plan.rewrites = append(plan.rewrites, uidChange{card: card, uid: uid})
+				fmt.Printf("card %d: %s -> %s\n", card.ID, card.UID, uid)
+			}
+			continue
+		}
+
+		plan.duplicates = append(plan.duplicates, uidChange{card: card, uid: uid, ownerID: ownerID})
+		fmt.Printf("card %d: duplicate of card %d (%s)\n", card.ID, ownerID, uid)
+	}
+
+	return &plan
+}
+
+// retireDuplicate moves a duplicate card off the shared uid and blocks it
+// unless it is already out of use.
+func retireDuplicate(ctx context.Context, cardRepository *repository.CardRepository, tx *sql.Tx, duplicate uidChange) *entity.ErrorResponse {
+	card := duplicate.card
+
+	errorResponse := cardRepository.UpdateCardUID(ctx, tx, card.ID, fmt.Sprintf("%s#%d", duplicate.uid, card.ID))
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	if card.Status == entity.CardStatusBlocked || card.Status == entity.CardStatusReplaced {
+		return nil
+	}
+
+	if errorResponse := cardRepository.UpdateCardStatus(ctx, tx, card.ID, entity.CardStatusBlocked); errorResponse != nil {
+		return errorResponse
+	}
+
+	return cardRepository.InsertCardStatusHistory(ctx, tx, &entity.CardStatusHistory{
+		CardID:     card.ID,
+		FromStatus: card.Status,
+		ToStatus:   entity.CardStatusBlocked,
+		Reason:     fmt.Sprintf("duplicate uid %s of card %d after uid normalization", duplicate.uid, duplicate.ownerID),
+	})
+}
+
+func isDigits(uid string) bool {
+	uid = strings.TrimSpace(uid)
+	if uid == "" {
+		return false
+	}
+
+	for _, r := range uid {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
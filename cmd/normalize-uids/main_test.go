@@ -0,0 +1,78 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+)
+
+func TestPlanUIDChanges(t *testing.T) {
+	cards := func() []*entity.Card {
+		return []*entity.Card{
+			{ID: 1, UID: "04:a1:b2:c3", Status: entity.CardStatusActive},
+			{ID: 2, UID: "3283263748", Status: entity.CardStatusActive}, // 04A1B2C3 as little-endian decimal
+			{ID: 3, UID: "68266683", Status: entity.CardStatusActive},   // 0411AABB as big-endian decimal
+			{ID: 4, UID: "not-a-uid", Status: entity.CardStatusActive},
+			{ID: 5, UID: "0411AABB", Status: entity.CardStatusActive},
+		}
+	}
+
+	tests := []struct {
+		name          string
+		decimalFormat string
+		wantRewrites  map[int]string
+		wantDuplicate map[int]int
+		wantAmbiguous int
+		wantInvalid   int
+	}{
+		{
+			name:          "decimal uids left alone by default",
+			wantRewrites:  map[int]string{1: "04A1B2C3"},
+			wantDuplicate: map[int]int{},
+			wantAmbiguous: 2,
+			wantInvalid:   1,
+		},
+		{
+			name:          "little-endian decimal",
+			decimalFormat: helper.UIDFormatDecimalLE,
+			wantRewrites:  map[int]string{1: "04A1B2C3", 3: "BBAA1104"},
+			wantDuplicate: map[int]int{2: 1},
+			wantInvalid:   1,
+		},
+		{
+			name:          "big-endian decimal",
+			decimalFormat: helper.UIDFormatDecimalBE,
+			wantRewrites:  map[int]string{1: "04A1B2C3", 2: "C3B2A104", 3: "0411AABB"},
+			wantDuplicate: map[int]int{5: 3},
+			wantInvalid:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := planUIDChanges(cards(), tt.decimalFormat)
+
+			rewrites := make(map[int]string)
+			for _, rewrite := range plan.rewrites {
+				rewrites[rewrite.card.ID] = rewrite.uid
+			}
+			if !reflect.DeepEqual(rewrites, tt.wantRewrites) {
+				t.Errorf("rewrites = %v, want %v", rewrites, tt.wantRewrites)
+			}
+
+			duplicates := make(map[int]int)
+			for _, duplicate := range plan.duplicates {
+				duplicates[duplicate.card.ID] = duplicate.ownerID
+			}
+			if !reflect.DeepEqual(duplicates, tt.wantDuplicate) {
+				t.Errorf("duplicates = %v, want %v", duplicates, tt.wantDuplicate)
+			}
+
+			if plan.ambiguous != tt.wantAmbiguous || plan.invalid != tt.wantInvalid {
+				t.Errorf("ambiguous, invalid = %d, %d, want %d, %d", plan.ambiguous, plan.invalid, tt.wantAmbiguous, tt.wantInvalid)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package controllers
 import (
 	"github.com/dimassfeb-09/smart-library-be/entity"
 	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/middleware"
 	"github.com/dimassfeb-09/smart-library-be/services"
 	"github.com/gofiber/fiber/v2"
 	"net/http"
@@ -12,10 +13,23 @@ import (
 type AccountControllerInterface interface {
 	RegisterAccount(c *fiber.Ctx) error
 	LoginAccount(c *fiber.Ctx) error
+	RefreshToken(c *fiber.Ctx) error
+	Logout(c *fiber.Ctx) error
+	LogoutAll(c *fiber.Ctx) error
+	VerifyEmail(c *fiber.Ctx) error
+	SendVerificationEmail(c *fiber.Ctx) error
+	MarkEmailVerified(c *fiber.Ctx) error
 	UpdateAccount(c *fiber.Ctx) error
 	DeleteAccount(c *fiber.Ctx) error
 	ChangePassword(c *fiber.Ctx) error
 	GetAccountByID(c *fiber.Ctx) error
+	GetAccounts(c *fiber.Ctx) error
+	GetAccountByName(c *fiber.Ctx) error
+	CreateAdminAccount(c *fiber.Ctx) error
+	ChangeAccountLevel(c *fiber.Ctx) error
+	GetLockedAccounts(c *fiber.Ctx) error
+	UnlockAccount(c *fiber.Ctx) error
+	GetLoginHistory(c *fiber.Ctx) error
 }
 
 type AccountController struct {
@@ -34,6 +48,9 @@ func (c *AccountController) RegisterAccount(ctx *fiber.Ctx) error {
 		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "Invalid request payload")
 		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
 	}
+	if account.Level == "" {
+		account.Level = "student"
+	}
 
 	if errorResponse := helper.ValidateStruct(&account); errorResponse != nil {
 		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
@@ -58,6 +75,8 @@ func (c *AccountController) LoginAccount(ctx *fiber.Ctx) error {
 	if errorResponse := helper.ValidateStruct(&login); errorResponse != nil {
 		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
 	}
+	login.IPAddress = ctx.IP()
+	login.UserAgent = string(ctx.Request().Header.UserAgent())
 
 	userDetail, errorResponse := c.AccountServices.LoginAccount(ctx.Context(), &login)
 	if errorResponse != nil {
@@ -68,6 +87,102 @@ func (c *AccountController) LoginAccount(ctx *fiber.Ctx) error {
 	return ctx.JSON(response)
 }
 
+func (c *AccountController) RefreshToken(ctx *fiber.Ctx) error {
+	var request entity.RefreshTokenRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "Invalid request payload")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	if errorResponse := helper.ValidateStruct(&request); errorResponse != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
+	}
+
+	tokens, errorResponse := c.AccountServices.RefreshToken(ctx.Context(), &request)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusOK, "Token successfully refreshed.", tokens)
+	return ctx.JSON(response)
+}
+
+func (c *AccountController) Logout(ctx *fiber.Ctx) error {
+	account := middleware.GetAuthAccount(ctx)
+
+	errorResponse := c.AccountServices.Logout(ctx.Context(), account.AccountID, account.SessionID)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithoutData(http.StatusOK, "Logout Successfully.")
+	return ctx.JSON(response)
+}
+
+func (c *AccountController) LogoutAll(ctx *fiber.Ctx) error {
+	account := middleware.GetAuthAccount(ctx)
+
+	errorResponse := c.AccountServices.LogoutAll(ctx.Context(), account.AccountID)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithoutData(http.StatusOK, "All sessions successfully logged out.")
+	return ctx.JSON(response)
+}
+
+func (c *AccountController) VerifyEmail(ctx *fiber.Ctx) error {
+	var request entity.VerifyEmailRequest
+	if err := ctx.QueryParser(&request); err != nil {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "Invalid request payload")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	if errorResponse := helper.ValidateStruct(&request); errorResponse != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
+	}
+
+	errorResponse := c.AccountServices.VerifyEmail(ctx.Context(), request.Token)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithoutData(http.StatusOK, "Email successfully verified.")
+	return ctx.JSON(response)
+}
+
+func (c *AccountController) SendVerificationEmail(ctx *fiber.Ctx) error {
+	accountId, err := strconv.Atoi(ctx.Params("accountId"))
+	if err != nil {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "invalid format accountId")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	errorResponse := c.AccountServices.SendVerificationEmail(ctx.Context(), accountId)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithoutData(http.StatusOK, "Verification email successfully sent.")
+	return ctx.JSON(response)
+}
+
+func (c *AccountController) MarkEmailVerified(ctx *fiber.Ctx) error {
+	accountId, err := strconv.Atoi(ctx.Params("accountId"))
+	if err != nil {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "invalid format accountId")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	errorResponse := c.AccountServices.MarkEmailVerified(ctx.Context(), accountId)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithoutData(http.StatusOK, "Account email marked as verified.")
+	return ctx.JSON(response)
+}
+
 func (c *AccountController) UpdateAccount(ctx *fiber.Ctx) error {
 	accountId, err := strconv.Atoi(ctx.Params("accountId"))
 	if err != nil {
@@ -124,12 +239,16 @@ func (c *AccountController) ChangePassword(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
 	}
 
+	if errorResponse := helper.ValidateStruct(&account); errorResponse != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
+	}
+
 	errorResponse := c.AccountServices.ChangePassword(ctx.Context(), &account, accountId)
 	if errorResponse != nil {
-		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
 	}
 
-	response := helper.SuccessResponseWithoutData(http.StatusCreated, "Account password successfully updated")
+	response := helper.SuccessResponseWithoutData(http.StatusOK, "Account password successfully updated")
 	return ctx.JSON(response)
 }
 
@@ -148,3 +267,126 @@ func (c *AccountController) GetAccountByID(ctx *fiber.Ctx) error {
 	response := helper.SuccessResponseWithData(http.StatusCreated, "OK", account)
 	return ctx.JSON(response)
 }
+
+func (c *AccountController) GetLockedAccounts(ctx *fiber.Ctx) error {
+	accounts, errorResponse := c.AccountServices.GetLockedAccounts(ctx.Context())
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusOK, "OK", accounts)
+	return ctx.JSON(response)
+}
+
+func (c *AccountController) UnlockAccount(ctx *fiber.Ctx) error {
+	accountId, err := strconv.Atoi(ctx.Params("accountId"))
+	if err != nil {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "invalid format accountId")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	errorResponse := c.AccountServices.UnlockAccount(ctx.Context(), accountId)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithoutData(http.StatusOK, "Account successfully unlocked.")
+	return ctx.JSON(response)
+}
+
+func (c *AccountController) GetLoginHistory(ctx *fiber.Ctx) error {
+	accountId, err := strconv.Atoi(ctx.Params("accountId"))
+	if err != nil {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "invalid format accountId")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	page, _ := strconv.Atoi(ctx.Query("page"))
+	pageSize, _ := strconv.Atoi(ctx.Query("pageSize"))
+
+	attempts, errorResponse := c.AccountServices.GetLoginHistory(ctx.Context(), accountId, page, pageSize)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusOK, "OK", attempts)
+	return ctx.JSON(response)
+}
+
+func (c *AccountController) GetAccounts(ctx *fiber.Ctx) error {
+	var filter entity.AccountFilter
+	if err := ctx.QueryParser(&filter); err != nil {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "Invalid query parameters")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	if errorResponse := helper.ValidateStruct(&filter); errorResponse != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
+	}
+
+	accounts, errorResponse := c.AccountServices.GetAccounts(ctx.Context(), &filter)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusOK, "OK", accounts)
+	return ctx.JSON(response)
+}
+
+func (c *AccountController) GetAccountByName(ctx *fiber.Ctx) error {
+	accounts, errorResponse := c.AccountServices.GetAccountByName(ctx.Context(), ctx.Query("name"))
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusOK, "OK", accounts)
+	return ctx.JSON(response)
+}
+
+func (c *AccountController) CreateAdminAccount(ctx *fiber.Ctx) error {
+	var account entity.AccountRequest
+	if err := ctx.BodyParser(&account); err != nil {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "Invalid request payload")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+	account.Level = "admin"
+
+	if errorResponse := helper.ValidateStruct(&account); errorResponse != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
+	}
+
+	errorResponse := c.AccountServices.CreateAdminAccount(ctx.Context(), &account)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithoutData(http.StatusCreated, "Admin account successfully created.")
+	return ctx.JSON(response)
+}
+
+func (c *AccountController) ChangeAccountLevel(ctx *fiber.Ctx) error {
+	accountId, err := strconv.Atoi(ctx.Params("accountId"))
+	if err != nil {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "invalid format accountId")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	var request entity.AccountLevelRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "Invalid request payload")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	if errorResponse := helper.ValidateStruct(&request); errorResponse != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
+	}
+
+	actor := middleware.GetAuthAccount(ctx)
+	errorResponse := c.AccountServices.ChangeAccountLevel(ctx.Context(), actor.AccountID, accountId, request.Level)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithoutData(http.StatusOK, "Account level successfully updated.")
+	return ctx.JSON(response)
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/dimassfeb-09/smart-library-be/entity"
 	"github.com/dimassfeb-09/smart-library-be/helper"
 	"github.com/dimassfeb-09/smart-library-be/services"
 	"github.com/gofiber/fiber/v2"
@@ -33,7 +34,7 @@ func (c *BookController) GetBookByID(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusBadRequest).JSON(helper.ErrorResponse(http.StatusBadRequest, "Invalid book id"))
 	}
 
-	book, errorResponse := c.service.GetBookByID(ctx.Context(), id)
+	book, errorResponse := c.service.GetBookWithAvailability(ctx.Context(), id)
 	if errorResponse != nil {
 		return ctx.Status(errorResponse.Code).JSON(errorResponse)
 	}
@@ -46,8 +47,13 @@ func (c *BookController) GetBooks(ctx *fiber.Ctx) error {
 
 	page, _ := strconv.Atoi(ctx.Query("page"))
 	pageSize, _ := strconv.Atoi(ctx.Query("pageSize"))
+	available, _ := strconv.ParseBool(ctx.Query("available"))
 
-	books, errorResponse := c.service.GetBooks(ctx.Context(), page, pageSize)
+	books, errorResponse := c.service.GetBooks(ctx.Context(), &entity.BookFilter{
+		Page:      page,
+		PageSize:  pageSize,
+		Available: available,
+	})
 	if errorResponse != nil {
 		return ctx.Status(errorResponse.Code).JSON(errorResponse)
 	}
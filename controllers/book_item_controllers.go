@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type BookItemControllerInterface interface {
+	GetBookItems(ctx *fiber.Ctx) error
+	InsertBookItem(ctx *fiber.Ctx) error
+	UpdateBookItem(ctx *fiber.Ctx) error
+	DeleteBookItem(ctx *fiber.Ctx) error
+}
+
+type BookItemController struct {
+	*services.BookItemServices
+}
+
+func NewBookItemController(bis *services.BookItemServices) *BookItemController {
+	return &BookItemController{
+		BookItemServices: bis,
+	}
+}
+
+func (c *BookItemController) GetBookItems(ctx *fiber.Ctx) error {
+	bookID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil || bookID <= 0 {
+		response := helper.ErrorResponse(http.StatusBadRequest, "Invalid book id")
+		return ctx.Status(http.StatusBadRequest).JSON(response)
+	}
+
+	items, errorResponse := c.BookItemServices.GetBookItems(ctx.Context(), bookID)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusOK, "OK", items)
+	return ctx.JSON(response)
+}
+
+func (c *BookItemController) InsertBookItem(ctx *fiber.Ctx) error {
+	bookID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil || bookID <= 0 {
+		response := helper.ErrorResponse(http.StatusBadRequest, "Invalid book id")
+		return ctx.Status(http.StatusBadRequest).JSON(response)
+	}
+
+	var request entity.BookItemRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		response := helper.ErrorResponse(http.StatusBadRequest, "Invalid request")
+		return ctx.Status(http.StatusBadRequest).JSON(response)
+	}
+
+	if errorResponse := helper.ValidateStruct(&request); errorResponse != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
+	}
+
+	item, errorResponse := c.BookItemServices.InsertBookItem(ctx.Context(), bookID, &request)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusCreated, "Book item successfully created.", item)
+	return ctx.Status(http.StatusCreated).JSON(response)
+}
+
+func (c *BookItemController) UpdateBookItem(ctx *fiber.Ctx) error {
+	bookID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil || bookID <= 0 {
+		response := helper.ErrorResponse(http.StatusBadRequest, "Invalid book id")
+		return ctx.Status(http.StatusBadRequest).JSON(response)
+	}
+
+	itemID, err := strconv.Atoi(ctx.Params("itemId"))
+	if err != nil || itemID <= 0 {
+		response := helper.ErrorResponse(http.StatusBadRequest, "Invalid book item id")
+		return ctx.Status(http.StatusBadRequest).JSON(response)
+	}
+
+	var request entity.BookItemRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		response := helper.ErrorResponse(http.StatusBadRequest, "Invalid request")
+		return ctx.Status(http.StatusBadRequest).JSON(response)
+	}
+
+	if errorResponse := helper.ValidateStruct(&request); errorResponse != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
+	}
+
+	item, errorResponse := c.BookItemServices.UpdateBookItem(ctx.Context(), bookID, itemID, &request)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusOK, "Book item successfully updated.", item)
+	return ctx.JSON(response)
+}
+
+func (c *BookItemController) DeleteBookItem(ctx *fiber.Ctx) error {
+	bookID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil || bookID <= 0 {
+		response := helper.ErrorResponse(http.StatusBadRequest, "Invalid book id")
+		return ctx.Status(http.StatusBadRequest).JSON(response)
+	}
+
+	itemID, err := strconv.Atoi(ctx.Params("itemId"))
+	if err != nil || itemID <= 0 {
+		response := helper.ErrorResponse(http.StatusBadRequest, "Invalid book item id")
+		return ctx.Status(http.StatusBadRequest).JSON(response)
+	}
+
+	errorResponse := c.BookItemServices.DeleteBookItem(ctx.Context(), bookID, itemID)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithoutData(http.StatusOK, "Book item successfully deleted.")
+	return ctx.JSON(response)
+}
@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/middleware"
+	"github.com/dimassfeb-09/smart-library-be/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type BookReturnControllerInterface interface {
+	ReturnBook(ctx *fiber.Ctx) error
+}
+
+type BookReturnController struct {
+	*services.BookReturnServices
+}
+
+func NewBookReturnController(brs *services.BookReturnServices) *BookReturnController {
+	return &BookReturnController{
+		BookReturnServices: brs,
+	}
+}
+
+func (c *BookReturnController) ReturnBook(ctx *fiber.Ctx) error {
+	var request entity.BookReturnRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		response := helper.ErrorResponse(http.StatusBadRequest, "Invalid request")
+		return ctx.Status(http.StatusBadRequest).JSON(response)
+	}
+
+	if errorResponse := helper.ValidateStruct(&request); errorResponse != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
+	}
+
+	device := middleware.GetAuthDevice(ctx)
+	result, errorResponse := c.BookReturnServices.ReturnBook(ctx.Context(), device, request.UID)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusOK, "Book returned", result)
+	return ctx.JSON(response)
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/dimassfeb-09/smart-library-be/entity"
 	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/middleware"
 	"github.com/dimassfeb-09/smart-library-be/services"
 	"github.com/gofiber/fiber/v2"
 )
@@ -17,17 +18,20 @@ type CardControllerInterface interface {
 	InsertCard(ctx *fiber.Ctx) error
 	UpdateCard(ctx *fiber.Ctx) error
 	DeleteCard(ctx *fiber.Ctx) error
-	InsertContainerCard(ctx *fiber.Ctx) error
-	GetOnceContainerCardByUID(ctx *fiber.Ctx) error
+	ChangeCardStatus(ctx *fiber.Ctx) error
+	ReplaceCard(ctx *fiber.Ctx) error
+	GetCardStatusHistory(ctx *fiber.Ctx) error
 }
 
 type CardController struct {
-	service *services.CardServices
+	service    *services.CardServices
+	scanEvents *services.ScanEventServices
 }
 
-func NewCardController(service *services.CardServices) *CardController {
+func NewCardController(service *services.CardServices, scanEvents *services.ScanEventServices) *CardController {
 	return &CardController{
-		service: service,
+		service:    service,
+		scanEvents: scanEvents,
 	}
 }
 
@@ -62,9 +66,7 @@ func (c *CardController) GetCardByID(ctx *fiber.Ctx) error {
 }
 
 func (c *CardController) GetCardTypeByUID(ctx *fiber.Ctx) error {
-	uid := ctx.Query("uid")
-
-	id, cardType, errorResponse := c.service.GetCardTypeByUID(ctx.Context(), uid)
+	id, cardType, errorResponse := c.scanEvents.LookupCard(ctx.Context(), middleware.GetAuthDevice(ctx), ctx.Query("uid"))
 	if errorResponse != nil {
 		return ctx.Status(errorResponse.Code).JSON(errorResponse)
 	}
@@ -143,34 +145,74 @@ func (c *CardController) DeleteCard(ctx *fiber.Ctx) error {
 	return ctx.JSON(response)
 }
 
-func (c *CardController) InsertContainerCard(ctx *fiber.Ctx) error {
-	var containerCard entity.ContainerCard
-	if err := ctx.BodyParser(&containerCard); err != nil {
-		response := helper.ErrorResponse(fiber.StatusBadRequest, "Invalid request")
+func (c *CardController) ChangeCardStatus(ctx *fiber.Ctx) error {
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil || id <= 0 {
+		response := helper.ErrorResponse(fiber.StatusBadRequest, "invalid card id")
 		return ctx.Status(fiber.StatusBadRequest).JSON(response)
 	}
 
-	if errorResponse := helper.ValidateStruct(&containerCard); errorResponse != nil {
-		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
+	var request entity.CardStatusRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		response := helper.ErrorResponse(fiber.StatusBadRequest, "request invalid")
+		return ctx.Status(fiber.StatusBadRequest).JSON(response)
 	}
 
-	if errorResponse := c.service.InsertContainerCard(ctx.Context(), containerCard.UID); errorResponse != nil {
+	if errorResponse := helper.ValidateStruct(&request); errorResponse != nil {
 		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
 	}
 
-	response := helper.SuccessResponseWithoutData(http.StatusOK, "Container card inserted successfully")
+	account := middleware.GetAuthAccount(ctx)
+	errorResponse := c.service.ChangeCardStatus(ctx.Context(), id, account.AccountID, &request)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithoutData(http.StatusOK, "Card status updated successfully")
 	return ctx.JSON(response)
 }
 
-func (c *CardController) GetOnceContainerCardByUID(ctx *fiber.Ctx) error {
+func (c *CardController) ReplaceCard(ctx *fiber.Ctx) error {
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil || id <= 0 {
+		response := helper.ErrorResponse(fiber.StatusBadRequest, "invalid card id")
+		return ctx.Status(fiber.StatusBadRequest).JSON(response)
+	}
 
-	uid, errorResponse := c.service.GetOnceContainerCard(ctx.Context())
-	if errorResponse != nil {
+	var request entity.CardReplaceRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		response := helper.ErrorResponse(fiber.StatusBadRequest, "request invalid")
+		return ctx.Status(fiber.StatusBadRequest).JSON(response)
+	}
+
+	if errorResponse := helper.ValidateStruct(&request); errorResponse != nil {
 		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
 	}
 
-	response := helper.SuccessResponseWithData(http.StatusOK, "Container card retrived successfully", entity.ContainerCard{
-		UID: uid,
+	account := middleware.GetAuthAccount(ctx)
+	newCardID, errorResponse := c.service.ReplaceCard(ctx.Context(), id, account.AccountID, &request)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusCreated, "Card replaced successfully", fiber.Map{
+		"id": newCardID,
 	})
+	return ctx.Status(http.StatusCreated).JSON(response)
+}
+
+func (c *CardController) GetCardStatusHistory(ctx *fiber.Ctx) error {
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil || id <= 0 {
+		response := helper.ErrorResponse(http.StatusBadRequest, "invalid card id")
+		return ctx.Status(http.StatusBadRequest).JSON(response)
+	}
+
+	histories, errorResponse := c.service.GetCardStatusHistory(ctx.Context(), id)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusOK, "OK", histories)
 	return ctx.JSON(response)
 }
@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type DeviceControllerInterface interface {
+	CreateDevice(c *fiber.Ctx) error
+	GetDevices(c *fiber.Ctx) error
+	GetDeviceByID(c *fiber.Ctx) error
+	UpdateDevice(c *fiber.Ctx) error
+	DeleteDevice(c *fiber.Ctx) error
+	CreateDeviceAPIKey(c *fiber.Ctx) error
+	GetDeviceAPIKeys(c *fiber.Ctx) error
+	RevokeDeviceAPIKey(c *fiber.Ctx) error
+	GetDeviceCalls(c *fiber.Ctx) error
+}
+
+type DeviceController struct {
+	*services.DeviceServices
+}
+
+func NewDeviceController(ds *services.DeviceServices) *DeviceController {
+	return &DeviceController{
+		DeviceServices: ds,
+	}
+}
+
+func (c *DeviceController) CreateDevice(ctx *fiber.Ctx) error {
+	var device entity.Device
+	if err := ctx.BodyParser(&device); err != nil {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "Invalid request payload")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	if errorResponse := helper.ValidateStruct(&device); errorResponse != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
+	}
+
+	id, errorResponse := c.DeviceServices.CreateDevice(ctx.Context(), &device)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusCreated, "Device created successfully", map[string]any{
+		"id": id,
+	})
+	return ctx.Status(http.StatusCreated).JSON(response)
+}
+
+func (c *DeviceController) GetDevices(ctx *fiber.Ctx) error {
+	devices, errorResponse := c.DeviceServices.GetDevices(ctx.Context())
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusOK, "OK", devices)
+	return ctx.JSON(response)
+}
+
+func (c *DeviceController) GetDeviceByID(ctx *fiber.Ctx) error {
+	deviceId, err := strconv.Atoi(ctx.Params("deviceId"))
+	if err != nil || deviceId <= 0 {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "invalid device id")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	device, errorResponse := c.DeviceServices.GetDeviceByID(ctx.Context(), deviceId)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusOK, "OK", device)
+	return ctx.JSON(response)
+}
+
+func (c *DeviceController) UpdateDevice(ctx *fiber.Ctx) error {
+	deviceId, err := strconv.Atoi(ctx.Params("deviceId"))
+	if err != nil || deviceId <= 0 {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "invalid device id")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	var device entity.Device
+	if err := ctx.BodyParser(&device); err != nil {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "Invalid request payload")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+	device.ID = deviceId
+
+	if errorResponse := helper.ValidateStruct(&device); errorResponse != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
+	}
+
+	errorResponse := c.DeviceServices.UpdateDevice(ctx.Context(), &device)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithoutData(http.StatusOK, "Device updated successfully")
+	return ctx.JSON(response)
+}
+
+func (c *DeviceController) DeleteDevice(ctx *fiber.Ctx) error {
+	deviceId, err := strconv.Atoi(ctx.Params("deviceId"))
+	if err != nil || deviceId <= 0 {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "invalid device id")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	errorResponse := c.DeviceServices.DeleteDevice(ctx.Context(), deviceId)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithoutData(http.StatusOK, "Device deleted successfully")
+	return ctx.JSON(response)
+}
+
+func (c *DeviceController) CreateDeviceAPIKey(ctx *fiber.Ctx) error {
+	deviceId, err := strconv.Atoi(ctx.Params("deviceId"))
+	if err != nil || deviceId <= 0 {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "invalid device id")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	var request entity.DeviceAPIKeyRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "Invalid request payload")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	if errorResponse := helper.ValidateStruct(&request); errorResponse != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
+	}
+
+	key, errorResponse := c.DeviceServices.CreateDeviceAPIKey(ctx.Context(), deviceId, &request)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusCreated, "Store this key now, it won't be shown again.", key)
+	return ctx.Status(http.StatusCreated).JSON(response)
+}
+
+func (c *DeviceController) GetDeviceAPIKeys(ctx *fiber.Ctx) error {
+	deviceId, err := strconv.Atoi(ctx.Params("deviceId"))
+	if err != nil || deviceId <= 0 {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "invalid device id")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	keys, errorResponse := c.DeviceServices.GetDeviceAPIKeys(ctx.Context(), deviceId)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusOK, "OK", keys)
+	return ctx.JSON(response)
+}
+
+func (c *DeviceController) RevokeDeviceAPIKey(ctx *fiber.Ctx) error {
+	deviceId, err := strconv.Atoi(ctx.Params("deviceId"))
+	if err != nil || deviceId <= 0 {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "invalid device id")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	keyId, err := strconv.Atoi(ctx.Params("keyId"))
+	if err != nil || keyId <= 0 {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "invalid key id")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	errorResponse := c.DeviceServices.RevokeDeviceAPIKey(ctx.Context(), deviceId, keyId)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithoutData(http.StatusOK, "Device api key revoked successfully")
+	return ctx.JSON(response)
+}
+
+func (c *DeviceController) GetDeviceCalls(ctx *fiber.Ctx) error {
+	deviceId, err := strconv.Atoi(ctx.Params("deviceId"))
+	if err != nil || deviceId <= 0 {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "invalid device id")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	page, _ := strconv.Atoi(ctx.Query("page"))
+	pageSize, _ := strconv.Atoi(ctx.Query("pageSize"))
+
+	calls, errorResponse := c.DeviceServices.GetDeviceCalls(ctx.Context(), deviceId, page, pageSize)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusOK, "OK", calls)
+	return ctx.JSON(response)
+}
@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/middleware"
+	"github.com/dimassfeb-09/smart-library-be/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type DeviceHealthControllerInterface interface {
+	RecordHeartbeat(ctx *fiber.Ctx) error
+	GetDevicesHealth(ctx *fiber.Ctx) error
+}
+
+type DeviceHealthController struct {
+	*services.DeviceHealthServices
+}
+
+func NewDeviceHealthController(dhs *services.DeviceHealthServices) *DeviceHealthController {
+	return &DeviceHealthController{
+		DeviceHealthServices: dhs,
+	}
+}
+
+func (c *DeviceHealthController) RecordHeartbeat(ctx *fiber.Ctx) error {
+	var heartbeat entity.DeviceHeartbeat
+	if err := ctx.BodyParser(&heartbeat); err != nil {
+		response := helper.ErrorResponse(http.StatusBadRequest, "request invalid")
+		return ctx.Status(http.StatusBadRequest).JSON(response)
+	}
+
+	if errorResponse := helper.ValidateStruct(&heartbeat); errorResponse != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
+	}
+
+	if heartbeat.IPAddress == "" {
+		heartbeat.IPAddress = ctx.IP()
+	}
+
+	device := middleware.GetAuthDevice(ctx)
+	errorResponse := c.DeviceHealthServices.RecordHeartbeat(ctx.Context(), device.DeviceID, &heartbeat)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithoutData(http.StatusOK, "OK")
+	return ctx.JSON(response)
+}
+
+func (c *DeviceHealthController) GetDevicesHealth(ctx *fiber.Ctx) error {
+	devices, errorResponse := c.DeviceHealthServices.GetDevicesHealth(ctx.Context())
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusOK, "OK", devices)
+	return ctx.JSON(response)
+}
@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/middleware"
+	"github.com/dimassfeb-09/smart-library-be/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type DeviceScanControllerInterface interface {
+	InsertScan(c *fiber.Ctx) error
+	GetNextScan(c *fiber.Ctx) error
+	StreamScans(c *fiber.Ctx) error
+}
+
+// scanStreamKeepAlive is how often an idle scan stream sends a comment line,
+// which keeps proxies from closing it and detects clients that went away.
+const scanStreamKeepAlive = 15 * time.Second
+
+type DeviceScanController struct {
+	*services.DeviceScanServices
+}
+
+func NewDeviceScanController(dss *services.DeviceScanServices) *DeviceScanController {
+	return &DeviceScanController{
+		DeviceScanServices: dss,
+	}
+}
+
+// InsertScan queues a card scanned by the reader that made the request.
+func (c *DeviceScanController) InsertScan(ctx *fiber.Ctx) error {
+	var containerCard entity.ContainerCard
+	if err := ctx.BodyParser(&containerCard); err != nil {
+		response := helper.ErrorResponse(fiber.StatusBadRequest, "Invalid request")
+		return ctx.Status(fiber.StatusBadRequest).JSON(response)
+	}
+
+	if errorResponse := helper.ValidateStruct(&containerCard); errorResponse != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
+	}
+
+	device := middleware.GetAuthDevice(ctx)
+	if errorResponse := c.DeviceScanServices.EnqueueScan(ctx.Context(), device, containerCard.UID, containerCard.Purpose); errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithoutData(http.StatusOK, "Container card inserted successfully")
+	return ctx.JSON(response)
+}
+
+// GetNextScan returns the oldest pending scan of a reader. With ?wait=N it
+// holds the request for up to N seconds until a scan arrives.
+func (c *DeviceScanController) GetNextScan(ctx *fiber.Ctx) error {
+	deviceId, err := strconv.Atoi(ctx.Params("deviceId"))
+	if err != nil || deviceId <= 0 {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "invalid device id")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	wait, _ := strconv.Atoi(ctx.Query("wait"))
+
+	scan, errorResponse := c.DeviceScanServices.NextScan(ctx.Context(), deviceId, time.Duration(wait)*time.Second)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusOK, "Container card retrived successfully", scan)
+	return ctx.JSON(response)
+}
+
+// StreamScans pushes the scans of a reader to the client as Server-Sent
+// Events, each with its card type and entity already resolved.
+func (c *DeviceScanController) StreamScans(ctx *fiber.Ctx) error {
+	deviceId, err := strconv.Atoi(ctx.Params("deviceId"))
+	if err != nil || deviceId <= 0 {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "invalid device id")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	scans, unsubscribe, errorResponse := c.DeviceScanServices.SubscribeScans(ctx.Context(), deviceId)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	ctx.Set(fiber.HeaderContentType, "text/event-stream")
+	ctx.Set(fiber.HeaderCacheControl, "no-cache")
+	ctx.Set(fiber.HeaderConnection, "keep-alive")
+	ctx.Set("X-Accel-Buffering", "no")
+
+	done := ctx.Context().Done()
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer unsubscribe()
+
+		keepAlive := time.NewTicker(scanStreamKeepAlive)
+		defer keepAlive.Stop()
+
+		fmt.Fprint(w, ": connected\n\n")
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		for {
+			select {
+			case scan := <-scans:
+				data, err := json.Marshal(scan)
+				if err != nil {
+					continue
+				}
+				fmt.Fprintf(w, "id: %d\nevent: scan\ndata: %s\n\n", scan.ID, data)
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+			case <-done:
+				return
+			}
+
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	})
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/middleware"
+	"github.com/dimassfeb-09/smart-library-be/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type EnrollmentControllerInterface interface {
+	OpenEnrollment(ctx *fiber.Ctx) error
+	GetEnrollment(ctx *fiber.Ctx) error
+	CloseEnrollment(ctx *fiber.Ctx) error
+	MapEnrollmentBooks(ctx *fiber.Ctx) error
+}
+
+type EnrollmentController struct {
+	*services.EnrollmentServices
+}
+
+func NewEnrollmentController(es *services.EnrollmentServices) *EnrollmentController {
+	return &EnrollmentController{
+		EnrollmentServices: es,
+	}
+}
+
+func (c *EnrollmentController) OpenEnrollment(ctx *fiber.Ctx) error {
+	var request entity.EnrollmentRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		response := helper.ErrorResponse(http.StatusBadRequest, "request invalid")
+		return ctx.Status(http.StatusBadRequest).JSON(response)
+	}
+
+	if errorResponse := helper.ValidateStruct(&request); errorResponse != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
+	}
+
+	account := middleware.GetAuthAccount(ctx)
+	id, errorResponse := c.EnrollmentServices.OpenEnrollment(ctx.Context(), account.AccountID, &request)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusCreated, "Enrollment session opened", fiber.Map{
+		"id": id,
+	})
+	return ctx.Status(http.StatusCreated).JSON(response)
+}
+
+func (c *EnrollmentController) GetEnrollment(ctx *fiber.Ctx) error {
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil || id <= 0 {
+		response := helper.ErrorResponse(http.StatusBadRequest, "invalid enrollment id")
+		return ctx.Status(http.StatusBadRequest).JSON(response)
+	}
+
+	session, errorResponse := c.EnrollmentServices.GetEnrollment(ctx.Context(), id)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusOK, "OK", session)
+	return ctx.JSON(response)
+}
+
+func (c *EnrollmentController) CloseEnrollment(ctx *fiber.Ctx) error {
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil || id <= 0 {
+		response := helper.ErrorResponse(http.StatusBadRequest, "invalid enrollment id")
+		return ctx.Status(http.StatusBadRequest).JSON(response)
+	}
+
+	errorResponse := c.EnrollmentServices.CloseEnrollment(ctx.Context(), id)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithoutData(http.StatusOK, "Enrollment session closed")
+	return ctx.JSON(response)
+}
+
+// MapEnrollmentBooks accepts either a JSON list of book ids, applied in scan
+// order, or a "uid,book_id" CSV sent as the "file" form field or as a
+// text/csv body.
+func (c *EnrollmentController) MapEnrollmentBooks(ctx *fiber.Ctx) error {
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil || id <= 0 {
+		response := helper.ErrorResponse(http.StatusBadRequest, "invalid enrollment id")
+		return ctx.Status(http.StatusBadRequest).JSON(response)
+	}
+
+	var assignments []*entity.EnrollmentBookAssignment
+	var errorResponse *entity.ErrorResponse
+
+	contentType := string(ctx.Request().Header.ContentType())
+	switch {
+	case strings.HasPrefix(contentType, fiber.MIMEMultipartForm):
+		fileHeader, err := ctx.FormFile("file")
+		if err != nil {
+			response := helper.ErrorResponse(http.StatusBadRequest, "file is required")
+			return ctx.Status(http.StatusBadRequest).JSON(response)
+		}
+
+		file, err := fileHeader.Open()
+		if err != nil {
+			response := helper.ErrorResponse(http.StatusBadRequest, "file invalid")
+			return ctx.Status(http.StatusBadRequest).JSON(response)
+		}
+		defer file.Close()
+
+		assignments, errorResponse = c.EnrollmentServices.MapBooksFromCSV(ctx.Context(), id, file)
+	case strings.HasPrefix(contentType, "text/csv"):
+		assignments, errorResponse = c.EnrollmentServices.MapBooksFromCSV(ctx.Context(), id, bytes.NewReader(ctx.Body()))
+	default:
+		var request entity.EnrollmentBookMapping
+		if err := ctx.BodyParser(&request); err != nil {
+			response := helper.ErrorResponse(http.StatusBadRequest, "request invalid")
+			return ctx.Status(http.StatusBadRequest).JSON(response)
+		}
+
+		if errorResponse := helper.ValidateStruct(&request); errorResponse != nil {
+			return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
+		}
+
+		assignments, errorResponse = c.EnrollmentServices.MapBooksInOrder(ctx.Context(), id, request.BookIDs)
+	}
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusOK, "Books mapped successfully", assignments)
+	return ctx.JSON(response)
+}
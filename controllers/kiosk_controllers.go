@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/middleware"
+	"github.com/dimassfeb-09/smart-library-be/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type KioskControllerInterface interface {
+	StartKioskSession(ctx *fiber.Ctx) error
+	GetKioskSession(ctx *fiber.Ctx) error
+	AddKioskBook(ctx *fiber.Ctx) error
+	ConfirmKioskSession(ctx *fiber.Ctx) error
+	CancelKioskSession(ctx *fiber.Ctx) error
+}
+
+type KioskController struct {
+	*services.KioskServices
+}
+
+func NewKioskController(ks *services.KioskServices) *KioskController {
+	return &KioskController{
+		KioskServices: ks,
+	}
+}
+
+func (c *KioskController) StartKioskSession(ctx *fiber.Ctx) error {
+	var request entity.KioskTapRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		response := helper.ErrorResponse(http.StatusBadRequest, "Invalid request")
+		return ctx.Status(http.StatusBadRequest).JSON(response)
+	}
+
+	if errorResponse := helper.ValidateStruct(&request); errorResponse != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
+	}
+
+	device := middleware.GetAuthDevice(ctx)
+	session, errorResponse := c.KioskServices.StartKioskSession(ctx.Context(), device, request.UID)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusCreated, "Kiosk session started", session)
+	return ctx.Status(http.StatusCreated).JSON(response)
+}
+
+func (c *KioskController) GetKioskSession(ctx *fiber.Ctx) error {
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil || id <= 0 {
+		response := helper.ErrorResponse(http.StatusBadRequest, "invalid kiosk session id")
+		return ctx.Status(http.StatusBadRequest).JSON(response)
+	}
+
+	device := middleware.GetAuthDevice(ctx)
+	session, errorResponse := c.KioskServices.GetKioskSession(ctx.Context(), device, id)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusOK, "OK", session)
+	return ctx.JSON(response)
+}
+
+func (c *KioskController) AddKioskBook(ctx *fiber.Ctx) error {
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil || id <= 0 {
+		response := helper.ErrorResponse(http.StatusBadRequest, "invalid kiosk session id")
+		return ctx.Status(http.StatusBadRequest).JSON(response)
+	}
+
+	var request entity.KioskTapRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		response := helper.ErrorResponse(http.StatusBadRequest, "Invalid request")
+		return ctx.Status(http.StatusBadRequest).JSON(response)
+	}
+
+	if errorResponse := helper.ValidateStruct(&request); errorResponse != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
+	}
+
+	device := middleware.GetAuthDevice(ctx)
+	session, errorResponse := c.KioskServices.AddKioskBook(ctx.Context(), device, id, request.UID)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusOK, "Book added", session)
+	return ctx.JSON(response)
+}
+
+func (c *KioskController) ConfirmKioskSession(ctx *fiber.Ctx) error {
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil || id <= 0 {
+		response := helper.ErrorResponse(http.StatusBadRequest, "invalid kiosk session id")
+		return ctx.Status(http.StatusBadRequest).JSON(response)
+	}
+
+	device := middleware.GetAuthDevice(ctx)
+	receipt, errorResponse := c.KioskServices.ConfirmKioskSession(ctx.Context(), device, id)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusCreated, "Books borrowed successfully", receipt)
+	return ctx.Status(http.StatusCreated).JSON(response)
+}
+
+func (c *KioskController) CancelKioskSession(ctx *fiber.Ctx) error {
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil || id <= 0 {
+		response := helper.ErrorResponse(http.StatusBadRequest, "invalid kiosk session id")
+		return ctx.Status(http.StatusBadRequest).JSON(response)
+	}
+
+	device := middleware.GetAuthDevice(ctx)
+	errorResponse := c.KioskServices.CancelKioskSession(ctx.Context(), device, id)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithoutData(http.StatusOK, "Kiosk session cancelled")
+	return ctx.JSON(response)
+}
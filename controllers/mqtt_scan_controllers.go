@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/services"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// mqttScanTimeout bounds how long one MQTT scan may spend in the database.
+const mqttScanTimeout = 10 * time.Second
+
+type MQTTScanControllerInterface interface {
+	HandleScan(client mqtt.Client, message mqtt.Message)
+}
+
+// MQTTScanController is the MQTT counterpart of DeviceScanController.InsertScan
+// for readers that publish scans instead of POSTing them.
+type MQTTScanController struct {
+	*services.DeviceScanServices
+	Config *helper.EnvMQTT
+}
+
+func NewMQTTScanController(dss *services.DeviceScanServices, envMQTT *helper.EnvMQTT) *MQTTScanController {
+	return &MQTTScanController{
+		DeviceScanServices: dss,
+		Config:             envMQTT,
+	}
+}
+
+// HandleScan consumes {prefix}/{deviceId}/scan and answers on
+// {prefix}/{deviceId}/scan/ack with the same code the HTTP endpoint would use.
+func (c *MQTTScanController) HandleScan(client mqtt.Client, message mqtt.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), mqttScanTimeout)
+	defer cancel()
+
+	deviceId, err := c.topicDeviceID(message.Topic())
+	if err != nil {
+		log.Println("mqtt scan: ignoring topic", message.Topic())
+		return
+	}
+
+	var scanMessage entity.MQTTScanMessage
+	if err := json.Unmarshal(message.Payload(), &scanMessage); err != nil {
+		c.ack(client, deviceId, "", helper.ErrorResponse(http.StatusBadRequest, "Invalid request"))
+		return
+	}
+
+	if errorResponse := helper.ValidateStruct(&scanMessage); errorResponse != nil {
+		message := strings.Join(append([]string{errorResponse.Message}, errorResponse.Errors...), "; ")
+		c.ack(client, deviceId, scanMessage.MessageID, helper.ErrorResponse(errorResponse.Code, message))
+		return
+	}
+
+	errorResponse := c.insertScan(ctx, deviceId, message.Topic(), &scanMessage)
+	c.ack(client, deviceId, scanMessage.MessageID, errorResponse)
+}
+
+func (c *MQTTScanController) insertScan(ctx context.Context, deviceId int, topic string, scanMessage *entity.MQTTScanMessage) (errorResponse *entity.ErrorResponse) {
+	deviceServices := c.DeviceScanServices.DeviceServices
+
+	device, errorResponse := deviceServices.AuthenticateDevice(ctx, scanMessage.APIKey)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	defer func() {
+		statusCode := http.StatusOK
+		if errorResponse != nil {
+			statusCode = errorResponse.Code
+		}
+		deviceServices.RecordDeviceCall(ctx, &entity.DeviceCall{
+			DeviceID:   device.DeviceID,
+			APIKeyID:   device.APIKeyID,
+			Method:     "MQTT",
+			Path:       topic,
+			StatusCode: statusCode,
+		})
+	}()
+
+	// A key may only publish on its own reader's topic.
+	if device.DeviceID != deviceId {
+		return helper.ErrorResponse(http.StatusForbidden, "Device api key does not belong to this reader")
+	}
+	if !device.HasScope(entity.ScopeScanWrite) {
+		return helper.ErrorResponse(http.StatusForbidden, "Device api key is missing the "+entity.ScopeScanWrite+" scope")
+	}
+
+	return c.DeviceScanServices.EnqueueScan(ctx, device, scanMessage.UID, scanMessage.Purpose)
+}
+
+func (c *MQTTScanController) ack(client mqtt.Client, deviceId int, messageID string, errorResponse *entity.ErrorResponse) {
+	ack := entity.MQTTScanAck{
+		MessageID: messageID,
+		Code:      http.StatusOK,
+		Message:   "Container card inserted successfully",
+	}
+	if errorResponse != nil {
+		ack.Code = errorResponse.Code
+		ack.Message = errorResponse.Message
+	}
+
+	payload, err := json.Marshal(ack)
+	if err != nil {
+		log.Println("mqtt scan:", err)
+		return
+	}
+
+	topic := fmt.Sprintf("%s/%d/scan/ack", c.Config.TopicPrefix, deviceId)
+	client.Publish(topic, c.Config.QoS, false, payload)
+}
+
+func (c *MQTTScanController) topicDeviceID(topic string) (int, error) {
+	rest := strings.TrimPrefix(topic, c.Config.TopicPrefix+"/")
+	deviceId, suffix, found := strings.Cut(rest, "/")
+	if !found || suffix != "scan" {
+		return 0, fmt.Errorf("unexpected topic %q", topic)
+	}
+
+	id, err := strconv.Atoi(deviceId)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("unexpected topic %q", topic)
+	}
+	return id, nil
+}
@@ -0,0 +1,337 @@
+package controllers_test
+
+import (
+	"encoding/json"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dimassfeb-09/smart-library-be/controllers"
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/repository"
+	"github.com/dimassfeb-09/smart-library-be/router"
+	"github.com/dimassfeb-09/smart-library-be/services"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+// testBroker is a minimal in-process MQTT 3.1.1 broker: enough for clients
+// to connect, subscribe and publish at QoS 0 and 1. Messages are delivered
+// to subscribers at QoS 0.
+type testBroker struct {
+	listener net.Listener
+
+	mu      sync.Mutex
+	clients map[*testBrokerClient]struct{}
+}
+
+type testBrokerClient struct {
+	conn    net.Conn
+	writeMu sync.Mutex
+	filters []string
+}
+
+func (c *testBrokerClient) write(packet packets.ControlPacket) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	packet.Write(c.conn)
+}
+
+func startTestBroker(t *testing.T) *testBroker {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &testBroker{listener: listener, clients: make(map[*testBrokerClient]struct{})}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(&testBrokerClient{conn: conn})
+		}
+	}()
+
+	return b
+}
+
+func (b *testBroker) URL() string {
+	return "tcp://" + b.listener.Addr().String()
+}
+
+func (b *testBroker) serve(client *testBrokerClient) {
+	defer func() {
+		b.mu.Lock()
+		delete(b.clients, client)
+		b.mu.Unlock()
+		client.conn.Close()
+	}()
+
+	for {
+		packet, err := packets.ReadPacket(client.conn)
+		if err != nil {
+			return
+		}
+
+		switch p := packet.(type) {
+		case *packets.ConnectPacket:
+			b.mu.Lock()
+			b.clients[client] = struct{}{}
+			b.mu.Unlock()
+			client.write(packets.NewControlPacket(packets.Connack))
+		case *packets.SubscribePacket:
+			suback := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+			suback.MessageID = p.MessageID
+			for range p.Topics {
+				suback.ReturnCodes = append(suback.ReturnCodes, 0)
+			}
+			b.mu.Lock()
+			client.filters = append(client.filters, p.Topics...)
+			b.mu.Unlock()
+			client.write(suback)
+		case *packets.PublishPacket:
+			if p.Qos > 0 {
+				puback := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+				puback.MessageID = p.MessageID
+				client.write(puback)
+			}
+			b.deliver(p.TopicName, p.Payload)
+		case *packets.PingreqPacket:
+			client.write(packets.NewControlPacket(packets.Pingresp))
+		case *packets.DisconnectPacket:
+			return
+		}
+	}
+}
+
+func (b *testBroker) deliver(topic string, payload []byte) {
+	b.mu.Lock()
+	var receivers []*testBrokerClient
+	for client := range b.clients {
+		for _, filter := range client.filters {
+			if topicMatches(filter, topic) {
+				receivers = append(receivers, client)
+				break
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	for _, client := range receivers {
+		publish := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+		publish.TopicName = topic
+		publish.Payload = payload
+		client.write(publish)
+	}
+}
+
+// waitForSubscription blocks until some client subscribed to filter.
+func (b *testBroker) waitForSubscription(t *testing.T, filter string) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		b.mu.Lock()
+		for client := range b.clients {
+			for _, f := range client.filters {
+				if f == filter {
+					b.mu.Unlock()
+					return
+				}
+			}
+		}
+		b.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("nobody subscribed to %s", filter)
+}
+
+func topicMatches(filter, topic string) bool {
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+	for i, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(topicLevels) || (level != "+" && level != topicLevels[i]) {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
+
+func connectTestClient(t *testing.T, client mqtt.Client) {
+	t.Helper()
+
+	if token := client.Connect(); !token.WaitTimeout(5*time.Second) || token.Error() != nil {
+		t.Fatalf("mqtt connect: %v", token.Error())
+	}
+	t.Cleanup(func() { client.Disconnect(0) })
+}
+
+var (
+	authDeviceColumns = []string{"id", "api_key_id", "name", "uid_format", "scopes", "signing_secret"}
+	readerKey         = "sl_reader-three-key"
+)
+
+func TestMQTTHandleScan(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	envMQTT := &helper.EnvMQTT{ClientID: "smart-library-test", TopicPrefix: "library/readers", QoS: 1}
+	envScanQueue := &helper.EnvScanQueue{TTL: 2 * time.Minute, MaxWait: time.Second}
+
+	studentServices := services.NewStudentServices(db, repository.NewStudentRepository())
+	bookServices := services.NewBookServices(repository.NewBookRepository(), repository.NewBookItemRepository(), db)
+	cardServices := services.NewCardServices(db, repository.NewCardRepository(), studentServices, bookServices)
+	scanEventServices := services.NewScanEventServices(db, repository.NewScanEventRepository(), cardServices, helper.GetEnvScanEvents())
+	deviceServices := services.NewDeviceServices(db, repository.NewDeviceRepository())
+	enrollmentServices := services.NewEnrollmentServices(db, repository.NewEnrollmentRepository(), cardServices, deviceServices)
+	deviceScanServices := services.NewDeviceScanServices(db, repository.NewDeviceScanRepository(), deviceServices, scanEventServices, enrollmentServices, envScanQueue)
+	controller := controllers.NewMQTTScanController(deviceScanServices, envMQTT)
+
+	broker := startTestBroker(t)
+	envMQTT.BrokerURL = broker.URL()
+
+	server := helper.NewMQTTClient(envMQTT, func(client mqtt.Client) {
+		router.RegisterMQTTRoutes(envMQTT.TopicPrefix, envMQTT.QoS, client, controller)
+	})
+	connectTestClient(t, server)
+	broker.waitForSubscription(t, "library/readers/+/scan")
+
+	acks := make(chan entity.MQTTScanAck, 1)
+	reader := mqtt.NewClient(mqtt.NewClientOptions().AddBroker(broker.URL()).SetClientID("reader-3"))
+	connectTestClient(t, reader)
+	token := reader.Subscribe("library/readers/3/scan/ack", 1, func(_ mqtt.Client, message mqtt.Message) {
+		var ack entity.MQTTScanAck
+		if err := json.Unmarshal(message.Payload(), &ack); err != nil {
+			t.Errorf("ack payload %q: %v", message.Payload(), err)
+		}
+		acks <- ack
+	})
+	if !token.WaitTimeout(5*time.Second) || token.Error() != nil {
+		t.Fatalf("subscribe: %v", token.Error())
+	}
+
+	expectDeviceCall := func(deviceID, apiKeyID, status int) {
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO device_calls")).
+			WithArgs(deviceID, apiKeyID, "MQTT", "library/readers/3/scan", status, "").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE device_api_keys SET last_used_at")).
+			WithArgs(apiKeyID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE devices SET last_seen_at")).
+			WithArgs(deviceID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	tests := []struct {
+		name    string
+		payload string
+		expect  func()
+		want    entity.MQTTScanAck
+	}{
+		{
+			name:    "queued",
+			payload: `{"message_id":"m1","uid":"04:a1:b2:c3","purpose":"borrow","api_key":"` + readerKey + `"}`,
+			expect: func() {
+				mock.ExpectQuery(regexp.QuoteMeta("FROM device_api_keys k JOIN devices d")).
+					WithArgs(helper.HashToken(readerKey)).
+					WillReturnRows(sqlmock.NewRows(authDeviceColumns).AddRow(3, 9, "Reader 3", "hex", entity.ScopeScanWrite, nil))
+				mock.ExpectQuery(regexp.QuoteMeta("FROM card_rfid WHERE uid = ?")).
+					WithArgs("04A1B2C3").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "uid", "type", "status"}))
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("FROM enrollment_sessions WHERE device_id = ?")).
+					WithArgs(3).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "device_id", "card_type", "status", "account_id", "created_at", "closed_at"}))
+				mock.ExpectExec(regexp.QuoteMeta("DELETE FROM device_scans")).
+					WithArgs(3).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO device_scans")).
+					WithArgs(3, "04A1B2C3", 120).
+					WillReturnResult(sqlmock.NewResult(55, 1))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO scan_events")).
+					WithArgs(3, "04A1B2C3", nil, nil, nil, entity.ScanPurposeBorrow, nil).
+					WillReturnResult(sqlmock.NewResult(300, 1))
+				mock.ExpectCommit()
+				expectDeviceCall(3, 9, 200)
+			},
+			want: entity.MQTTScanAck{MessageID: "m1", Code: 200, Message: "Container card inserted successfully"},
+		},
+		{
+			name:    "malformed payload",
+			payload: `{"message_id":"m2","uid":`,
+			expect:  func() {},
+			want:    entity.MQTTScanAck{Code: 400, Message: "Invalid request"},
+		},
+		{
+			name:    "missing uid",
+			payload: `{"message_id":"m3","api_key":"` + readerKey + `"}`,
+			expect:  func() {},
+			want:    entity.MQTTScanAck{MessageID: "m3", Code: 400},
+		},
+		{
+			name:    "unknown device",
+			payload: `{"message_id":"m4","uid":"04A1B2C3","api_key":"sl_unknown"}`,
+			expect: func() {
+				mock.ExpectQuery(regexp.QuoteMeta("FROM device_api_keys k JOIN devices d")).
+					WithArgs(helper.HashToken("sl_unknown")).
+					WillReturnRows(sqlmock.NewRows(authDeviceColumns))
+			},
+			want: entity.MQTTScanAck{MessageID: "m4", Code: 401, Message: "Invalid or revoked device api key"},
+		},
+		{
+			name:    "key of another reader",
+			payload: `{"message_id":"m5","uid":"04A1B2C3","api_key":"sl_reader-four-key"}`,
+			expect: func() {
+				mock.ExpectQuery(regexp.QuoteMeta("FROM device_api_keys k JOIN devices d")).
+					WithArgs(helper.HashToken("sl_reader-four-key")).
+					WillReturnRows(sqlmock.NewRows(authDeviceColumns).AddRow(4, 12, "Reader 4", "hex", entity.ScopeScanWrite, nil))
+				expectDeviceCall(4, 12, 403)
+			},
+			want: entity.MQTTScanAck{MessageID: "m5", Code: 403, Message: "Device api key does not belong to this reader"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.expect()
+
+			token := reader.Publish("library/readers/3/scan", 1, false, tt.payload)
+			if !token.WaitTimeout(5*time.Second) || token.Error() != nil {
+				t.Fatalf("publish: %v", token.Error())
+			}
+
+			var ack entity.MQTTScanAck
+			select {
+			case ack = <-acks:
+			case <-time.After(5 * time.Second):
+				t.Fatal("no ack received")
+			}
+
+			if ack.MessageID != tt.want.MessageID || ack.Code != tt.want.Code {
+				t.Errorf("ack = %+v, want %+v", ack, tt.want)
+			}
+			if tt.want.Message != "" && ack.Message != tt.want.Message {
+				t.Errorf("ack message = %q, want %q", ack.Message, tt.want.Message)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type PasswordResetControllerInterface interface {
+	ForgotPassword(c *fiber.Ctx) error
+	ResetPassword(c *fiber.Ctx) error
+}
+
+type PasswordResetController struct {
+	*services.PasswordResetServices
+}
+
+func NewPasswordResetController(prs *services.PasswordResetServices) *PasswordResetController {
+	return &PasswordResetController{
+		PasswordResetServices: prs,
+	}
+}
+
+func (c *PasswordResetController) ForgotPassword(ctx *fiber.Ctx) error {
+	var request entity.ForgotPasswordRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "Invalid request payload")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	if errorResponse := helper.ValidateStruct(&request); errorResponse != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
+	}
+
+	c.PasswordResetServices.ForgotPassword(ctx.Context(), &request)
+
+	response := helper.SuccessResponseWithoutData(http.StatusOK, "If the email is registered, a password reset link has been sent.")
+	return ctx.JSON(response)
+}
+
+func (c *PasswordResetController) ResetPassword(ctx *fiber.Ctx) error {
+	var request entity.ResetPasswordRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "Invalid request payload")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	if errorResponse := helper.ValidateStruct(&request); errorResponse != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
+	}
+
+	errorResponse := c.PasswordResetServices.ResetPassword(ctx.Context(), &request)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithoutData(http.StatusOK, "Password successfully reset.")
+	return ctx.JSON(response)
+}
@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type ScanEventControllerInterface interface {
+	GetScanEvents(c *fiber.Ctx) error
+}
+
+type ScanEventController struct {
+	*services.ScanEventServices
+}
+
+func NewScanEventController(ses *services.ScanEventServices) *ScanEventController {
+	return &ScanEventController{
+		ScanEventServices: ses,
+	}
+}
+
+func (c *ScanEventController) GetScanEvents(ctx *fiber.Ctx) error {
+	var filter entity.ScanEventFilter
+	if err := ctx.QueryParser(&filter); err != nil {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "Invalid query parameters")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	if errorResponse := helper.ValidateStruct(&filter); errorResponse != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
+	}
+
+	events, errorResponse := c.ScanEventServices.GetScanEvents(ctx.Context(), &filter)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusOK, "OK", events)
+	return ctx.JSON(response)
+}
@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/middleware"
+	"github.com/dimassfeb-09/smart-library-be/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// HeaderScanSignature carries the hex HMAC-SHA256 of the request body, keyed
+// with the signing secret issued along with the reader's api key. The api key
+// travels in every request, so it would prove nothing as the HMAC key.
+const HeaderScanSignature = "X-Signature"
+
+type ScanSyncControllerInterface interface {
+	SyncScans(ctx *fiber.Ctx) error
+}
+
+type ScanSyncController struct {
+	*services.ScanSyncServices
+}
+
+func NewScanSyncController(sss *services.ScanSyncServices) *ScanSyncController {
+	return &ScanSyncController{
+		ScanSyncServices: sss,
+	}
+}
+
+// SyncScans accepts the scans a reader buffered while offline. The response
+// is 200 as long as the batch itself is valid; each event has its own status.
+func (c *ScanSyncController) SyncScans(ctx *fiber.Ctx) error {
+	device := middleware.GetAuthDevice(ctx)
+	if device.SigningSecret == "" {
+		response := helper.ErrorResponse(http.StatusUnauthorized, "Device api key has no signing secret, issue a new key")
+		return ctx.Status(http.StatusUnauthorized).JSON(response)
+	}
+
+	body := ctx.Body()
+	if !helper.VerifyPayloadSignature(device.SigningSecret, body, ctx.Get(HeaderScanSignature)) {
+		response := helper.ErrorResponse(http.StatusUnauthorized, "Invalid batch signature")
+		return ctx.Status(http.StatusUnauthorized).JSON(response)
+	}
+
+	var batch entity.ScanSyncBatch
+	if err := json.Unmarshal(body, &batch); err != nil {
+		response := helper.ErrorResponse(http.StatusBadRequest, "Invalid request")
+		return ctx.Status(http.StatusBadRequest).JSON(response)
+	}
+
+	if errorResponse := helper.ValidateStruct(&batch); errorResponse != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
+	}
+
+	results := c.ScanSyncServices.SyncScans(ctx.Context(), device, &batch)
+
+	response := helper.SuccessResponseWithData(http.StatusOK, "OK", results)
+	return ctx.JSON(response)
+}
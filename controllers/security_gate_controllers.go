@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/middleware"
+	"github.com/dimassfeb-09/smart-library-be/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type SecurityGateControllerInterface interface {
+	CheckGate(ctx *fiber.Ctx) error
+	GetSecurityEvents(ctx *fiber.Ctx) error
+}
+
+type SecurityGateController struct {
+	*services.SecurityGateServices
+}
+
+func NewSecurityGateController(sgs *services.SecurityGateServices) *SecurityGateController {
+	return &SecurityGateController{
+		SecurityGateServices: sgs,
+	}
+}
+
+// CheckGate answers an exit gate scan. Alarms are answered with 200 as well;
+// the gate acts on the alarm field.
+func (c *SecurityGateController) CheckGate(ctx *fiber.Ctx) error {
+	var request entity.GateCheckRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		response := helper.ErrorResponse(http.StatusBadRequest, "Invalid request")
+		return ctx.Status(http.StatusBadRequest).JSON(response)
+	}
+
+	if errorResponse := helper.ValidateStruct(&request); errorResponse != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
+	}
+
+	device := middleware.GetAuthDevice(ctx)
+	result, errorResponse := c.SecurityGateServices.CheckGate(ctx.Context(), device, request.UID)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusOK, "OK", result)
+	return ctx.JSON(response)
+}
+
+func (c *SecurityGateController) GetSecurityEvents(ctx *fiber.Ctx) error {
+	var filter entity.SecurityEventFilter
+	if err := ctx.QueryParser(&filter); err != nil {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "Invalid query parameters")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	if errorResponse := helper.ValidateStruct(&filter); errorResponse != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
+	}
+
+	events, errorResponse := c.SecurityGateServices.GetSecurityEvents(ctx.Context(), &filter)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusOK, "OK", events)
+	return ctx.JSON(response)
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/dimassfeb-09/smart-library-be/entity"
 	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/middleware"
 	"github.com/dimassfeb-09/smart-library-be/services"
 	"github.com/gofiber/fiber/v2"
 )
@@ -13,6 +14,7 @@ import (
 type StudentCardControllerInterface interface {
 	InsertStudent(c *fiber.Ctx) error
 	UpdateStudent(c *fiber.Ctx) error
+	ReportCardLost(c *fiber.Ctx) error
 }
 
 type StudentCardController struct {
@@ -72,3 +74,34 @@ func (c *StudentCardController) UpdateStudent(ctx *fiber.Ctx) error {
 	response := helper.SuccessResponseWithoutData(http.StatusOK, "Data student successfully updated.")
 	return ctx.JSON(response)
 }
+
+// ReportCardLost marks the student's card as lost so it stops working at the
+// readers until an admin reactivates or replaces it.
+func (c *StudentCardController) ReportCardLost(ctx *fiber.Ctx) error {
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil || id <= 0 {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "invalid student id")
+		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
+	}
+
+	var request entity.CardReportLostRequest
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(&request); err != nil {
+			errorResponse := helper.ErrorResponse(http.StatusBadRequest, "Invalid request")
+			return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
+		}
+	}
+
+	if errorResponse := helper.ValidateStruct(&request); errorResponse != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
+	}
+
+	account := middleware.GetAuthAccount(ctx)
+	errorResponse := c.StudentCardServices.CardServices.ReportStudentCardLost(ctx.Context(), id, account.AccountID, request.Reason)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithoutData(http.StatusOK, "Card reported lost successfully")
+	return ctx.JSON(response)
+}
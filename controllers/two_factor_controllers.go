@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type TwoFactorControllerInterface interface {
+	LoginTwoFactor(c *fiber.Ctx) error
+	BeginLoginTwoFactorSetup(c *fiber.Ctx) error
+	SetupTwoFactor(c *fiber.Ctx) error
+	ConfirmTwoFactor(c *fiber.Ctx) error
+	DisableTwoFactor(c *fiber.Ctx) error
+	RegenerateRecoveryCodes(c *fiber.Ctx) error
+}
+
+type TwoFactorController struct {
+	*services.AccountServices
+}
+
+func NewTwoFactorController(as *services.AccountServices) *TwoFactorController {
+	return &TwoFactorController{
+		AccountServices: as,
+	}
+}
+
+func (c *TwoFactorController) LoginTwoFactor(ctx *fiber.Ctx) error {
+	var request entity.TwoFactorLoginRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "Invalid request payload")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	if errorResponse := helper.ValidateStruct(&request); errorResponse != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
+	}
+	request.IPAddress = ctx.IP()
+	request.UserAgent = string(ctx.Request().Header.UserAgent())
+
+	userDetail, errorResponse := c.AccountServices.LoginTwoFactor(ctx.Context(), &request)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusCreated, "Login Successfully.", userDetail)
+	return ctx.JSON(response)
+}
+
+func (c *TwoFactorController) BeginLoginTwoFactorSetup(ctx *fiber.Ctx) error {
+	var request entity.TwoFactorSetupRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "Invalid request payload")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	if errorResponse := helper.ValidateStruct(&request); errorResponse != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
+	}
+
+	setup, errorResponse := c.AccountServices.BeginLoginTwoFactorSetup(ctx.Context(), &request)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusOK, "Scan the secret with an authenticator app, then confirm with a code.", setup)
+	return ctx.JSON(response)
+}
+
+func (c *TwoFactorController) SetupTwoFactor(ctx *fiber.Ctx) error {
+	accountId, err := strconv.Atoi(ctx.Params("accountId"))
+	if err != nil {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "invalid format accountId")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	setup, errorResponse := c.AccountServices.SetupTwoFactor(ctx.Context(), accountId)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusOK, "Scan the secret with an authenticator app, then confirm with a code.", setup)
+	return ctx.JSON(response)
+}
+
+func (c *TwoFactorController) ConfirmTwoFactor(ctx *fiber.Ctx) error {
+	accountId, err := strconv.Atoi(ctx.Params("accountId"))
+	if err != nil {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "invalid format accountId")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	var request entity.TwoFactorCodeRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "Invalid request payload")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	if errorResponse := helper.ValidateStruct(&request); errorResponse != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
+	}
+
+	recoveryCodes, errorResponse := c.AccountServices.ConfirmTwoFactorSetup(ctx.Context(), accountId, request.Code)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusOK, "Two-factor authentication successfully enabled.", entity.RecoveryCodesResponse{RecoveryCodes: recoveryCodes})
+	return ctx.JSON(response)
+}
+
+func (c *TwoFactorController) DisableTwoFactor(ctx *fiber.Ctx) error {
+	accountId, err := strconv.Atoi(ctx.Params("accountId"))
+	if err != nil {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "invalid format accountId")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	var request entity.TwoFactorCodeRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "Invalid request payload")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	if errorResponse := helper.ValidateStruct(&request); errorResponse != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
+	}
+
+	errorResponse := c.AccountServices.RemoveTwoFactor(ctx.Context(), accountId, request.Code)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithoutData(http.StatusOK, "Two-factor authentication successfully disabled.")
+	return ctx.JSON(response)
+}
+
+func (c *TwoFactorController) RegenerateRecoveryCodes(ctx *fiber.Ctx) error {
+	accountId, err := strconv.Atoi(ctx.Params("accountId"))
+	if err != nil {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "invalid format accountId")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	var request entity.TwoFactorCodeRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		errorResponse := helper.ErrorResponse(http.StatusBadRequest, "Invalid request payload")
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	if errorResponse := helper.ValidateStruct(&request); errorResponse != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(errorResponse)
+	}
+
+	recoveryCodes, errorResponse := c.AccountServices.RegenerateRecoveryCodes(ctx.Context(), accountId, request.Code)
+	if errorResponse != nil {
+		return ctx.Status(errorResponse.Code).JSON(errorResponse)
+	}
+
+	response := helper.SuccessResponseWithData(http.StatusOK, "Recovery codes successfully regenerated.", entity.RecoveryCodesResponse{RecoveryCodes: recoveryCodes})
+	return ctx.JSON(response)
+}
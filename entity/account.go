@@ -11,17 +11,45 @@ type AccountRequest struct {
 
 type AccountChangePasswordRequest struct {
 	Password    string `json:"password" validate:"required"`
-	NewPassword string `json:"new_password" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,nefield=Password"`
 }
 
 type Account struct {
-	ID    int    `json:"id"`
-	Email string `json:"email" validate:"required"`
-	Level string `json:"level" validate:"required"`
+	ID            int    `json:"id"`
+	Email         string `json:"email" validate:"required"`
+	Level         string `json:"level" validate:"required"`
+	EmailVerified bool   `json:"email_verified"`
 }
 
 type AccountResponse struct {
-	ID    int    `json:"id"`
-	Email string `json:"email" validate:"required"`
-	Level string `json:"level" validate:"required"`
+	ID            int    `json:"id"`
+	Email         string `json:"email" validate:"required"`
+	Level         string `json:"level" validate:"required"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" query:"token" validate:"required"`
+}
+
+type AccountSummary struct {
+	ID            int    `json:"id"`
+	Email         string `json:"email"`
+	Level         string `json:"level"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	NPM           string `json:"npm,omitempty"`
+}
+
+type AccountFilter struct {
+	Page      int    `query:"page" validate:"omitempty,min=1"`
+	PageSize  int    `query:"pageSize" validate:"omitempty,min=1,max=100"`
+	Search    string `query:"search"`
+	Level     string `query:"level" validate:"omitempty,oneof=admin student"`
+	SortBy    string `query:"sortBy" validate:"omitempty,oneof=id email name npm level"`
+	SortOrder string `query:"sortOrder" validate:"omitempty,oneof=asc desc"`
+}
+
+type AccountLevelRequest struct {
+	Level string `json:"level" validate:"required,oneof=admin student"`
 }
@@ -3,4 +3,21 @@ package entity
 type Login struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required"`
+
+	IPAddress string `json:"-"`
+	UserAgent string `json:"-"`
+}
+
+// LoginResponse either carries the issued tokens or, when a second factor is
+// needed, only the challenge token for the two-factor step.
+type LoginResponse struct {
+	AccessToken            string           `json:"access_token,omitempty"`
+	RefreshToken           string           `json:"refresh_token,omitempty"`
+	TokenType              string           `json:"token_type,omitempty"`
+	ExpiresIn              int              `json:"expires_in,omitempty"`
+	Account                *AccountResponse `json:"account,omitempty"`
+	TwoFactorRequired      bool             `json:"two_factor_required,omitempty"`
+	TwoFactorSetupRequired bool             `json:"two_factor_setup_required,omitempty"`
+	ChallengeToken         string           `json:"challenge_token,omitempty"`
+	RecoveryCodes          []string         `json:"recovery_codes,omitempty"`
 }
@@ -1,15 +1,51 @@
 package entity
 
+const (
+	BookAvailable   = "available"
+	BookOnLoan      = "on_loan"
+	BookReserved    = "reserved"
+	BookLost        = "lost"
+	BookUnavailable = "unavailable"
+)
+
 type Book struct {
-	ID            int    `json:"id"`
-	Title         string `json:"title" validate:"required"`
-	Author        string `json:"author" validate:"required"`
-	Publisher     string `json:"publisher" validate:"required"`
-	PublishedDate string `json:"published_date" validate:"required"`
-	ISBN          string `json:"isbn" validate:"required"`
-	Pages         int    `json:"pages" validate:"required,number"`
-	Language      string `json:"language" validate:"required"`
-	Genre         string `json:"genre" validate:"required"`
-	Description   string `json:"description" validate:"required"`
-	CardID        int    `json:"card_id" validate:"required,number"`
+	ID            int               `json:"id"`
+	Title         string            `json:"title" validate:"required"`
+	Author        string            `json:"author" validate:"required"`
+	Publisher     string            `json:"publisher" validate:"required"`
+	PublishedDate string            `json:"published_date" validate:"required"`
+	ISBN          string            `json:"isbn" validate:"required"`
+	Pages         int               `json:"pages" validate:"required,number"`
+	Language      string            `json:"language" validate:"required"`
+	Genre         string            `json:"genre" validate:"required"`
+	Description   string            `json:"description" validate:"required"`
+	CardID        int               `json:"card_id" validate:"required,number"`
+	Copies        *BookCopies       `json:"copies,omitempty"`
+	Availability  *BookAvailability `json:"availability,omitempty"`
+	Stock         *BookStock        `json:"-"`
+}
+
+type BookFilter struct {
+	Page      int
+	PageSize  int
+	Available bool
+}
+
+// BookAvailability tells whether a book can be borrowed now. DueDate is the
+// earliest due date of a book that is on loan.
+type BookAvailability struct {
+	Status  string `json:"status"`
+	DueDate string `json:"due_date,omitempty"`
+}
+
+// BookStock is what availability is worked out from: the book's copies and
+// its open borrow rows.
+type BookStock struct {
+	TotalCopies int
+	FreeCopies  int
+	LostCopies  int
+	OnLoan      int
+	NextDueDate string
+	Reserved    int
+	CardLost    bool
 }
@@ -0,0 +1,39 @@
+package entity
+
+const (
+	BookItemAvailable   = "available"
+	BookItemMaintenance = "maintenance"
+	BookItemLost        = "lost"
+	BookItemWithdrawn   = "withdrawn"
+
+	BookItemConditionGood = "good"
+)
+
+// BookItem is one physical copy of a book. Status is the copy's shelf state;
+// OnLoan tells whether it is out on a pending or borrowed borrow.
+type BookItem struct {
+	ID            int    `json:"id"`
+	BookID        int    `json:"book_id"`
+	CardID        int    `json:"card_id,omitempty"`
+	Barcode       string `json:"barcode"`
+	ShelfLocation string `json:"shelf_location"`
+	Condition     string `json:"condition"`
+	Status        string `json:"status"`
+	OnLoan        bool   `json:"on_loan"`
+	CreatedAt     string `json:"created_at"`
+}
+
+type BookItemRequest struct {
+	CardID        int    `json:"card_id" validate:"omitempty,number"`
+	Barcode       string `json:"barcode" validate:"required,max=64"`
+	ShelfLocation string `json:"shelf_location" validate:"max=100"`
+	Condition     string `json:"condition" validate:"omitempty,oneof=new good fair poor damaged"`
+	Status        string `json:"status" validate:"omitempty,oneof=available maintenance lost withdrawn"`
+}
+
+// BookCopies counts the copies of a book. Available copies are on the shelf
+// and not out on a borrow.
+type BookCopies struct {
+	Total     int `json:"total"`
+	Available int `json:"available"`
+}
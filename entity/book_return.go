@@ -0,0 +1,20 @@
+package entity
+
+type BookReturnRequest struct {
+	UID string `json:"uid" validate:"required"`
+}
+
+// BookReturn is the answer to a drop box scan. TransactionClosed is set once
+// the last book of the borrow transaction is back.
+type BookReturn struct {
+	TransactionID     string `json:"transaction_id"`
+	BookID            int    `json:"book_id"`
+	ItemID            int    `json:"item_id,omitempty"`
+	BookTitle         string `json:"book_title"`
+	StudentID         int    `json:"student_id"`
+	DueDate           string `json:"due_date"`
+	ReturnedAt        string `json:"returned_at"`
+	Overdue           bool   `json:"overdue"`
+	RemainingBooks    int    `json:"remaining_books"`
+	TransactionClosed bool   `json:"transaction_closed"`
+}
@@ -4,11 +4,13 @@ type Borrow struct {
 	TransactionID string `json:"transaction_id"`
 	BookIDS       []int  `json:"book_ids,omitempty" validate:"required"`
 	BookID        int    `json:"book_id,omitempty"`
+	ItemIDS       []int  `json:"item_ids,omitempty"`
+	ItemID        int    `json:"item_id,omitempty"`
 	StudentID     int    `json:"student_id" validate:"required"`
 	BorrowDate    string `json:"borrow_date"`
 	DueDate       string `json:"due_date"`
 	ReturnDate    string `json:"return_date"`
-	Status        string `json:"status"`
+	Status        string `json:"status" validate:"omitempty,oneof=pending borrowed"`
 }
 
 type BorrowUpdate struct {
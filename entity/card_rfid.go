@@ -1,7 +1,41 @@
 package entity
 
+const (
+	CardStatusActive   = "active"
+	CardStatusLost     = "lost"
+	CardStatusBlocked  = "blocked"
+	CardStatusReplaced = "replaced"
+)
+
 type Card struct {
-	ID   int    `json:"id"`
-	UID  string `json:"uid" validate:"required"`
-	Type string `json:"type" validate:"required"`
+	ID     int    `json:"id"`
+	UID    string `json:"uid" validate:"required"`
+	Type   string `json:"type" validate:"required"`
+	Status string `json:"status"`
+}
+
+// CardStatusRequest moves a card to another state. Replaced is only reached
+// through CardReplaceRequest.
+type CardStatusRequest struct {
+	Status string `json:"status" validate:"required,oneof=active lost blocked"`
+	Reason string `json:"reason" validate:"max=255"`
+}
+
+type CardReplaceRequest struct {
+	UID    string `json:"uid" validate:"required"`
+	Reason string `json:"reason" validate:"max=255"`
+}
+
+type CardReportLostRequest struct {
+	Reason string `json:"reason" validate:"max=255"`
+}
+
+type CardStatusHistory struct {
+	ID         int    `json:"id"`
+	CardID     int    `json:"card_id"`
+	FromStatus string `json:"from_status,omitempty"`
+	ToStatus   string `json:"to_status"`
+	Reason     string `json:"reason,omitempty"`
+	AccountID  int    `json:"account_id,omitempty"`
+	CreatedAt  string `json:"created_at"`
 }
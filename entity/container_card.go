@@ -1,5 +1,6 @@
 package entity
 
 type ContainerCard struct {
-	UID string `json:"uid" validate:"required"`
+	UID     string `json:"uid" validate:"required"`
+	Purpose string `json:"purpose" validate:"omitempty,oneof=borrow return lookup unknown"`
 }
@@ -0,0 +1,149 @@
+package entity
+
+const (
+	ScopeScanWrite  = "scan:write"
+	ScopeCardLookup = "card:lookup"
+	ScopeHeartbeat  = "device:heartbeat"
+	ScopeGateCheck  = "gate:check"
+	ScopeKiosk      = "kiosk:checkout"
+	ScopeBookReturn = "book:return"
+
+	DeviceHealthOnline  = "online"
+	DeviceHealthOffline = "offline"
+	DeviceHealthUnknown = "unknown"
+)
+
+type Device struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name" validate:"required,max=50"`
+	Location   string `json:"location" validate:"max=100"`
+	UIDFormat  string `json:"uid_format" validate:"omitempty,oneof=hex decimal_le decimal_be"`
+	CreatedAt  string `json:"created_at"`
+	LastSeenAt string `json:"last_seen_at,omitempty"`
+}
+
+type DeviceAPIKey struct {
+	ID         int      `json:"id"`
+	DeviceID   int      `json:"device_id"`
+	Prefix     string   `json:"prefix"`
+	Scopes     []string `json:"scopes"`
+	CreatedAt  string   `json:"created_at"`
+	LastUsedAt string   `json:"last_used_at,omitempty"`
+	RevokedAt  string   `json:"revoked_at,omitempty"`
+}
+
+type DeviceAPIKeyRequest struct {
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,oneof=scan:write card:lookup device:heartbeat gate:check kiosk:checkout book:return"`
+}
+
+// DeviceAPIKeyResponse is the only time the plain key and its signing secret
+// are returned. The secret signs offline sync batches and, unlike the key, is
+// never sent with a request.
+type DeviceAPIKeyResponse struct {
+	DeviceAPIKey
+	Key           string `json:"key"`
+	SigningSecret string `json:"signing_secret"`
+}
+
+// AuthDevice is the reader identified by an API key on the current request.
+// SigningSecret is empty for keys issued before signing secrets existed.
+type AuthDevice struct {
+	DeviceID      int
+	APIKeyID      int
+	Name          string
+	UIDFormat     string
+	Scopes        []string
+	SigningSecret string
+}
+
+func (d *AuthDevice) HasScope(scope string) bool {
+	for _, granted := range d.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type DeviceCall struct {
+	ID         int    `json:"id"`
+	DeviceID   int    `json:"device_id"`
+	APIKeyID   int    `json:"api_key_id"`
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	StatusCode int    `json:"status_code"`
+	IPAddress  string `json:"ip_address"`
+	CreatedAt  string `json:"created_at"`
+}
+
+// DeviceHeartbeat is what a reader reports about itself on every heartbeat.
+// The IP address falls back to the address the request came from.
+type DeviceHeartbeat struct {
+	FirmwareVersion string `json:"firmware_version" validate:"max=50"`
+	IPAddress       string `json:"ip_address" validate:"omitempty,ip"`
+	UptimeSeconds   int64  `json:"uptime_seconds" validate:"gte=0"`
+	SignalStrength  *int   `json:"signal_strength"`
+}
+
+// DeviceHealth is one row of the reader health dashboard. A reader is offline
+// once its last heartbeat is older than the configured timeout, and unknown
+// if it has never sent one.
+type DeviceHealth struct {
+	DeviceID              int    `json:"device_id"`
+	Name                  string `json:"name"`
+	Location              string `json:"location"`
+	Status                string `json:"status"`
+	FirmwareVersion       string `json:"firmware_version,omitempty"`
+	IPAddress             string `json:"ip_address,omitempty"`
+	UptimeSeconds         int64  `json:"uptime_seconds,omitempty"`
+	SignalStrength        *int   `json:"signal_strength,omitempty"`
+	LastHeartbeatAt       string `json:"last_heartbeat_at,omitempty"`
+	SecondsSinceHeartbeat *int64 `json:"seconds_since_heartbeat,omitempty"`
+	AlertedAt             string `json:"alerted_at,omitempty"`
+}
+
+// DeviceOfflineAlert is the payload posted to the alert webhook.
+type DeviceOfflineAlert struct {
+	Event           string `json:"event"`
+	DeviceID        int    `json:"device_id"`
+	Name            string `json:"name"`
+	Location        string `json:"location"`
+	LastHeartbeatAt string `json:"last_heartbeat_at"`
+	OfflineSeconds  int64  `json:"offline_seconds"`
+}
+
+type DeviceScan struct {
+	ID        int    `json:"id"`
+	DeviceID  int    `json:"device_id"`
+	UID       string `json:"uid"`
+	ScannedAt string `json:"scanned_at"`
+}
+
+// ResolvedScan is a scan pushed to live subscribers of a reader, with the
+// card already looked up. Error is set when the UID is not a usable card.
+type ResolvedScan struct {
+	DeviceScan
+	CardType string `json:"card_type,omitempty"`
+	EntityID int    `json:"entity_id,omitempty"`
+	Purpose  string `json:"purpose"`
+	Error    string `json:"error,omitempty"`
+
+	// Enrollment is set when the reader has an open enrollment session and
+	// the scan registered a card instead of being queued.
+	Enrollment *EnrollmentItem `json:"enrollment,omitempty"`
+}
+
+// MQTTScanMessage is the payload a reader publishes on its scan topic. The
+// message id is echoed back on the ack topic so the reader can match them.
+type MQTTScanMessage struct {
+	MessageID string `json:"message_id"`
+	UID       string `json:"uid" validate:"required"`
+	Purpose   string `json:"purpose" validate:"omitempty,oneof=borrow return lookup unknown"`
+	APIKey    string `json:"api_key" validate:"required"`
+}
+
+type MQTTScanAck struct {
+	MessageID string `json:"message_id,omitempty"`
+	Code      int    `json:"code"`
+	Message   string `json:"message"`
+}
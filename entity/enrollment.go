@@ -0,0 +1,54 @@
+package entity
+
+const (
+	EnrollmentStatusOpen   = "open"
+	EnrollmentStatusClosed = "closed"
+
+	EnrollmentResultEnrolled  = "enrolled"
+	EnrollmentResultDuplicate = "duplicate"
+)
+
+// EnrollmentSession is a batch of tags scanned on one reader. While it is
+// open every scan from that reader registers a card instead of being queued.
+type EnrollmentSession struct {
+	ID         int               `json:"id"`
+	DeviceID   int               `json:"device_id"`
+	CardType   string            `json:"card_type"`
+	Status     string            `json:"status"`
+	AccountID  int               `json:"account_id,omitempty"`
+	CreatedAt  string            `json:"created_at"`
+	ClosedAt   string            `json:"closed_at,omitempty"`
+	Enrolled   int               `json:"enrolled"`
+	Duplicates int               `json:"duplicates"`
+	Items      []*EnrollmentItem `json:"items"`
+}
+
+type EnrollmentItem struct {
+	ID        int    `json:"id"`
+	SessionID int    `json:"session_id"`
+	UID       string `json:"uid"`
+	CardID    int    `json:"card_id,omitempty"`
+	Result    string `json:"result"`
+	Message   string `json:"message,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+type EnrollmentRequest struct {
+	DeviceID int    `json:"device_id" validate:"required"`
+	CardType string `json:"card_type" validate:"required,oneof=book student"`
+}
+
+// EnrollmentBookMapping assigns the enrolled cards of a session to books in
+// scan order: the first enrolled card goes to the first book id and so on.
+type EnrollmentBookMapping struct {
+	BookIDs []int `json:"book_ids" validate:"required,min=1,dive,gt=0"`
+}
+
+// EnrollmentBookAssignment is one card to book link made from a session,
+// either from EnrollmentBookMapping or a row of an uploaded CSV.
+type EnrollmentBookAssignment struct {
+	Row    int    `json:"row"`
+	UID    string `json:"uid"`
+	CardID int    `json:"card_id"`
+	BookID int    `json:"book_id"`
+}
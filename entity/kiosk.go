@@ -0,0 +1,42 @@
+package entity
+
+const (
+	KioskStatusOpen      = "open"
+	KioskStatusConfirmed = "confirmed"
+	KioskStatusCancelled = "cancelled"
+	KioskStatusExpired   = "expired"
+)
+
+type KioskTapRequest struct {
+	UID string `json:"uid" validate:"required"`
+}
+
+// KioskSession is one self-checkout at a kiosk: started by a student card
+// tap, filled with book taps and ended by confirming or cancelling it.
+type KioskSession struct {
+	ID            int          `json:"id"`
+	DeviceID      int          `json:"device_id"`
+	StudentID     int          `json:"student_id"`
+	StudentName   string       `json:"student_name"`
+	Status        string       `json:"status"`
+	TransactionID string       `json:"transaction_id,omitempty"`
+	CreatedAt     string       `json:"created_at"`
+	ExpiresAt     string       `json:"expires_at"`
+	Expired       bool         `json:"-"`
+	Books         []*KioskBook `json:"books"`
+}
+
+type KioskBook struct {
+	ID     int    `json:"id"`
+	ItemID int    `json:"item_id,omitempty"`
+	Title  string `json:"title"`
+}
+
+type KioskReceipt struct {
+	TransactionID string       `json:"transaction_id"`
+	StudentID     int          `json:"student_id"`
+	StudentName   string       `json:"student_name"`
+	Books         []*KioskBook `json:"books"`
+	BorrowDate    string       `json:"borrow_date"`
+	DueDate       string       `json:"due_date"`
+}
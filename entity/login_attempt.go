@@ -0,0 +1,20 @@
+package entity
+
+type LoginAttempt struct {
+	ID        int    `json:"id"`
+	AccountID int    `json:"account_id,omitempty"`
+	Email     string `json:"email"`
+	IPAddress string `json:"ip_address"`
+	UserAgent string `json:"user_agent"`
+	Success   bool   `json:"success"`
+	Reason    string `json:"reason,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+type LockedAccount struct {
+	ID                  int    `json:"id"`
+	Email               string `json:"email"`
+	Level               string `json:"level"`
+	FailedLoginAttempts int    `json:"failed_login_attempts"`
+	LockedUntil         string `json:"locked_until"`
+}
@@ -6,3 +6,19 @@ type Notification struct {
 	DueDate       string `json:"due_date"`
 	Message       string `json:"message"`
 }
+
+const (
+	NotificationPending = "pending"
+	NotificationSent    = "sent"
+	NotificationFailed  = "failed"
+)
+
+// QueuedNotification is an email waiting in the notification queue to be
+// sent by the background sender.
+type QueuedNotification struct {
+	ID       int64
+	Email    string
+	Subject  string
+	Body     string
+	Attempts int
+}
@@ -8,7 +8,7 @@ type ResponseWebWithData struct {
 }
 
 type ResponseWebWithoutData struct {
-	Error   bool   `json:"error`
+	Error   bool   `json:"error"`
 	Code    int    `json:"code"`
 	Message string `json:"message"`
 }
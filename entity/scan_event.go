@@ -0,0 +1,35 @@
+package entity
+
+const (
+	ScanPurposeBorrow  = "borrow"
+	ScanPurposeReturn  = "return"
+	ScanPurposeLookup  = "lookup"
+	ScanPurposeUnknown = "unknown"
+	ScanPurposeEnroll  = "enroll"
+	ScanPurposeGate    = "gate"
+)
+
+// ScanEvent is one row of the append-only scan log. Card and entity are the
+// ones the UID resolved to when it was scanned and are empty if it did not.
+type ScanEvent struct {
+	ID        int64  `json:"id"`
+	DeviceID  int    `json:"device_id,omitempty"`
+	UID       string `json:"uid"`
+	CardID    int    `json:"card_id,omitempty"`
+	CardType  string `json:"card_type,omitempty"`
+	EntityID  int    `json:"entity_id,omitempty"`
+	Purpose   string `json:"purpose"`
+	CreatedAt string `json:"created_at"`
+}
+
+type ScanEventFilter struct {
+	Page     int    `query:"page"`
+	PageSize int    `query:"pageSize"`
+	DeviceID int    `query:"deviceId"`
+	UID      string `query:"uid"`
+	CardType string `query:"cardType" validate:"omitempty,oneof=book student"`
+	EntityID int    `query:"entityId"`
+	Purpose  string `query:"purpose" validate:"omitempty,oneof=borrow return lookup unknown enroll gate"`
+	From     string `query:"from" validate:"omitempty,datetime=2006-01-02"`
+	To       string `query:"to" validate:"omitempty,datetime=2006-01-02"`
+}
@@ -0,0 +1,37 @@
+package entity
+
+const (
+	ScanSyncApplied   = "applied"
+	ScanSyncRejected  = "rejected"
+	ScanSyncDuplicate = "duplicate"
+	ScanSyncFailed    = "failed"
+
+	ScanSyncActionBorrowed = "borrowed"
+	ScanSyncActionReturned = "returned"
+)
+
+// ScanSyncBatch is a reader's local buffer of scans taken while it was
+// offline, uploaded in one signed request.
+type ScanSyncBatch struct {
+	Events []*ScanSyncEvent `json:"events" validate:"required,min=1,max=500,dive"`
+}
+
+// ScanSyncEvent is one buffered scan. EventID is generated by the reader and
+// is what makes re-uploading the same buffer safe.
+type ScanSyncEvent struct {
+	EventID   string `json:"event_id" validate:"required,max=64"`
+	UID       string `json:"uid" validate:"required"`
+	Purpose   string `json:"purpose" validate:"omitempty,oneof=borrow return lookup unknown"`
+	ScannedAt string `json:"scanned_at" validate:"required,datetime=2006-01-02T15:04:05Z07:00"`
+}
+
+// ScanSyncResult tells the reader what happened to one event. Applied,
+// rejected and duplicate events can be dropped from the buffer; failed ones
+// should be sent again.
+type ScanSyncResult struct {
+	EventID     string `json:"event_id"`
+	Status      string `json:"status"`
+	ScanEventID int64  `json:"scan_event_id,omitempty"`
+	Action      string `json:"action,omitempty"`
+	Message     string `json:"message,omitempty"`
+}
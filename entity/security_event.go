@@ -0,0 +1,43 @@
+package entity
+
+const (
+	GateAllowed = "allowed"
+	GateAlarm   = "alarm"
+	GateIgnored = "ignored"
+	GateTimeout = "timeout"
+)
+
+type GateCheckRequest struct {
+	UID string `json:"uid" validate:"required"`
+}
+
+// GateCheckResult is the answer to an exit gate scan. Alarm is what the gate
+// acts on; Status and Reason explain it.
+type GateCheckResult struct {
+	Alarm         bool   `json:"alarm"`
+	Status        string `json:"status"`
+	Reason        string `json:"reason,omitempty"`
+	BookID        int    `json:"book_id,omitempty"`
+	TransactionID string `json:"transaction_id,omitempty"`
+	ElapsedMs     int64  `json:"elapsed_ms"`
+}
+
+// SecurityEvent is an alarm raised at an exit gate, kept for later review.
+type SecurityEvent struct {
+	ID        int64  `json:"id"`
+	DeviceID  int    `json:"device_id,omitempty"`
+	UID       string `json:"uid"`
+	CardID    int    `json:"card_id,omitempty"`
+	BookID    int    `json:"book_id,omitempty"`
+	Reason    string `json:"reason"`
+	CreatedAt string `json:"created_at"`
+}
+
+type SecurityEventFilter struct {
+	Page     int    `query:"page"`
+	PageSize int    `query:"pageSize"`
+	DeviceID int    `query:"deviceId"`
+	BookID   int    `query:"bookId"`
+	From     string `query:"from" validate:"omitempty,datetime=2006-01-02"`
+	To       string `query:"to" validate:"omitempty,datetime=2006-01-02"`
+}
@@ -0,0 +1,176 @@
+package helper
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type AccessTokenClaims struct {
+	AccountID int    `json:"account_id"`
+	Email     string `json:"email"`
+	Level     string `json:"level"`
+	SessionID int    `json:"sid"`
+	jwt.RegisteredClaims
+}
+
+func GenerateAccessToken(env *EnvJWT, account *entity.AccountResponse, sessionID int) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(env.AccessTokenTTL)
+
+	claims := AccessTokenClaims{
+		AccountID: account.ID,
+		Email:     account.Email,
+		Level:     account.Level,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    env.Issuer,
+			Subject:   strconv.Itoa(account.ID),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	signed, err := signToken(env, claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return signed, expiresAt, nil
+}
+
+func ParseAccessToken(env *EnvJWT, tokenString string) (*AccessTokenClaims, error) {
+	var claims AccessTokenClaims
+
+	token, err := parseToken(env, tokenString, &claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid || claims.AccountID <= 0 || claims.SessionID <= 0 || len(claims.Audience) > 0 {
+		return nil, errors.New("invalid access token")
+	}
+
+	return &claims, nil
+}
+
+const emailVerificationAudience = "email-verification"
+
+type EmailVerificationClaims struct {
+	AccountID int    `json:"account_id"`
+	Email     string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+func GenerateEmailVerificationToken(env *EnvJWT, account *entity.AccountResponse, ttl time.Duration) (string, error) {
+	now := time.Now()
+
+	claims := EmailVerificationClaims{
+		AccountID: account.ID,
+		Email:     account.Email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    env.Issuer,
+			Subject:   strconv.Itoa(account.ID),
+			Audience:  jwt.ClaimStrings{emailVerificationAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+
+	return signToken(env, claims)
+}
+
+func ParseEmailVerificationToken(env *EnvJWT, tokenString string) (*EmailVerificationClaims, error) {
+	var claims EmailVerificationClaims
+
+	token, err := parseToken(env, tokenString, &claims, jwt.WithAudience(emailVerificationAudience))
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid || claims.AccountID <= 0 {
+		return nil, errors.New("invalid email verification token")
+	}
+
+	return &claims, nil
+}
+
+const twoFactorChallengeAudience = "two-factor"
+
+// TwoFactorChallengeClaims prove the password step of a login succeeded. Setup
+// is set when the account still has to enroll before it may sign in.
+type TwoFactorChallengeClaims struct {
+	AccountID int  `json:"account_id"`
+	Setup     bool `json:"setup,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func GenerateTwoFactorChallengeToken(env *EnvJWT, accountID int, setup bool, ttl time.Duration) (string, error) {
+	now := time.Now()
+
+	claims := TwoFactorChallengeClaims{
+		AccountID: accountID,
+		Setup:     setup,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    env.Issuer,
+			Subject:   strconv.Itoa(accountID),
+			Audience:  jwt.ClaimStrings{twoFactorChallengeAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+
+	return signToken(env, claims)
+}
+
+func ParseTwoFactorChallengeToken(env *EnvJWT, tokenString string) (*TwoFactorChallengeClaims, error) {
+	var claims TwoFactorChallengeClaims
+
+	token, err := parseToken(env, tokenString, &claims, jwt.WithAudience(twoFactorChallengeAudience))
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid || claims.AccountID <= 0 {
+		return nil, errors.New("invalid two-factor challenge token")
+	}
+
+	return &claims, nil
+}
+
+func signToken(env *EnvJWT, claims jwt.Claims) (string, error) {
+	switch env.Algorithm {
+	case "EDDSA":
+		return jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims).SignedString(env.PrivateKey)
+	default:
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(env.Secret)
+	}
+}
+
+func parseToken(env *EnvJWT, tokenString string, claims jwt.Claims, options ...jwt.ParserOption) (*jwt.Token, error) {
+	var method string
+	var key any
+	switch env.Algorithm {
+	case "EDDSA":
+		method = jwt.SigningMethodEdDSA.Alg()
+		key = env.PrivateKey.Public().(ed25519.PublicKey)
+	default:
+		method = jwt.SigningMethodHS256.Alg()
+		key = env.Secret
+	}
+
+	options = append(options,
+		jwt.WithValidMethods([]string{method}),
+		jwt.WithIssuer(env.Issuer),
+		jwt.WithExpirationRequired(),
+	)
+
+	return jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
+		return key, nil
+	}, options...)
+}
@@ -1,8 +1,13 @@
 package helper
 
 import (
+	"crypto/ed25519"
+	"encoding/base64"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -29,3 +34,440 @@ func GetEnvDatabase() *EnvDatabase {
 		Name:     os.Getenv("DB_NAME"),
 	}
 }
+
+type EnvJWT struct {
+	Algorithm       string
+	Secret          []byte
+	PrivateKey      ed25519.PrivateKey
+	Issuer          string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+}
+
+func GetEnvJWT() *EnvJWT {
+	env := &EnvJWT{
+		Algorithm:       strings.ToUpper(os.Getenv("JWT_ALGORITHM")),
+		Issuer:          os.Getenv("JWT_ISSUER"),
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: 30 * 24 * time.Hour,
+	}
+
+	if env.Algorithm == "" {
+		env.Algorithm = "HS256"
+	}
+	if env.Issuer == "" {
+		env.Issuer = "smart-library"
+	}
+
+	if ttl := os.Getenv("JWT_ACCESS_TOKEN_TTL"); ttl != "" {
+		duration, err := time.ParseDuration(ttl)
+		if err != nil || duration <= 0 {
+			log.Fatalf("Invalid JWT_ACCESS_TOKEN_TTL %q", ttl)
+		}
+		env.AccessTokenTTL = duration
+	}
+
+	if ttl := os.Getenv("JWT_REFRESH_TOKEN_TTL"); ttl != "" {
+		duration, err := time.ParseDuration(ttl)
+		if err != nil || duration <= 0 {
+			log.Fatalf("Invalid JWT_REFRESH_TOKEN_TTL %q", ttl)
+		}
+		env.RefreshTokenTTL = duration
+	}
+
+	switch env.Algorithm {
+	case "HS256":
+		secret := os.Getenv("JWT_SECRET")
+		if len(secret) < 32 {
+			log.Fatalf("JWT_SECRET must be at least 32 characters")
+		}
+		env.Secret = []byte(secret)
+	case "EDDSA":
+		seed, err := base64.StdEncoding.DecodeString(os.Getenv("JWT_PRIVATE_KEY"))
+		if err != nil || len(seed) != ed25519.SeedSize {
+			log.Fatalf("JWT_PRIVATE_KEY must be a base64 encoded ed25519 seed")
+		}
+		env.PrivateKey = ed25519.NewKeyFromSeed(seed)
+	default:
+		log.Fatalf("Unsupported JWT_ALGORITHM %q", env.Algorithm)
+	}
+
+	return env
+}
+
+type EnvMail struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+func GetEnvMail() *EnvMail {
+	port, err := strconv.Atoi(os.Getenv("MAIL_PORT"))
+	if err != nil {
+		port = 587
+	}
+
+	return &EnvMail{
+		Host:     os.Getenv("MAIL_HOST"),
+		Port:     port,
+		Username: os.Getenv("MAIL_USERNAME"),
+		Password: os.Getenv("MAIL_PASSWORD"),
+		From:     os.Getenv("MAIL_FROM"),
+	}
+}
+
+type EnvPasswordReset struct {
+	URL           string
+	TokenTTL      time.Duration
+	MaxPerAccount int
+	RateWindow    time.Duration
+}
+
+func GetEnvPasswordReset() *EnvPasswordReset {
+	env := &EnvPasswordReset{
+		URL:           os.Getenv("PASSWORD_RESET_URL"),
+		TokenTTL:      30 * time.Minute,
+		MaxPerAccount: 3,
+		RateWindow:    time.Hour,
+	}
+
+	if ttl, err := time.ParseDuration(os.Getenv("PASSWORD_RESET_TOKEN_TTL")); err == nil && ttl > 0 {
+		env.TokenTTL = ttl
+	}
+	if limit, err := strconv.Atoi(os.Getenv("PASSWORD_RESET_MAX_PER_HOUR")); err == nil && limit > 0 {
+		env.MaxPerAccount = limit
+	}
+
+	return env
+}
+
+type EnvEmailVerification struct {
+	URL      string
+	TokenTTL time.Duration
+}
+
+func GetEnvEmailVerification() *EnvEmailVerification {
+	env := &EnvEmailVerification{
+		URL:      os.Getenv("EMAIL_VERIFICATION_URL"),
+		TokenTTL: 24 * time.Hour,
+	}
+
+	if ttl, err := time.ParseDuration(os.Getenv("EMAIL_VERIFICATION_TOKEN_TTL")); err == nil && ttl > 0 {
+		env.TokenTTL = ttl
+	}
+
+	return env
+}
+
+type EnvLoginProtection struct {
+	MaxFailedAttempts int
+	LockoutBase       time.Duration
+	LockoutMax        time.Duration
+	IPMaxFailed       int
+	IPWindow          time.Duration
+	IPBackoffBase     time.Duration
+	IPBackoffMax      time.Duration
+}
+
+func GetEnvLoginProtection() *EnvLoginProtection {
+	env := &EnvLoginProtection{
+		MaxFailedAttempts: 5,
+		LockoutBase:       time.Minute,
+		LockoutMax:        24 * time.Hour,
+		IPMaxFailed:       20,
+		IPWindow:          15 * time.Minute,
+		IPBackoffBase:     time.Second,
+		IPBackoffMax:      15 * time.Minute,
+	}
+
+	if limit, err := strconv.Atoi(os.Getenv("LOGIN_MAX_FAILED_ATTEMPTS")); err == nil && limit > 0 {
+		env.MaxFailedAttempts = limit
+	}
+	if duration, err := time.ParseDuration(os.Getenv("LOGIN_LOCKOUT_BASE")); err == nil && duration > 0 {
+		env.LockoutBase = duration
+	}
+	if duration, err := time.ParseDuration(os.Getenv("LOGIN_LOCKOUT_MAX")); err == nil && duration > 0 {
+		env.LockoutMax = duration
+	}
+	if limit, err := strconv.Atoi(os.Getenv("LOGIN_IP_MAX_FAILED")); err == nil && limit > 0 {
+		env.IPMaxFailed = limit
+	}
+	if duration, err := time.ParseDuration(os.Getenv("LOGIN_IP_WINDOW")); err == nil && duration > 0 {
+		env.IPWindow = duration
+	}
+	if duration, err := time.ParseDuration(os.Getenv("LOGIN_IP_BACKOFF_BASE")); err == nil && duration > 0 {
+		env.IPBackoffBase = duration
+	}
+	if duration, err := time.ParseDuration(os.Getenv("LOGIN_IP_BACKOFF_MAX")); err == nil && duration > 0 {
+		env.IPBackoffMax = duration
+	}
+
+	return env
+}
+
+type EnvTwoFactor struct {
+	RequiredForAdmin bool
+	Issuer           string
+	ChallengeTTL     time.Duration
+}
+
+func GetEnvTwoFactor() *EnvTwoFactor {
+	env := &EnvTwoFactor{
+		Issuer:       os.Getenv("TWO_FACTOR_ISSUER"),
+		ChallengeTTL: 5 * time.Minute,
+	}
+
+	if required, err := strconv.ParseBool(os.Getenv("TWO_FACTOR_REQUIRED_FOR_ADMIN")); err == nil {
+		env.RequiredForAdmin = required
+	}
+	if env.Issuer == "" {
+		env.Issuer = "Smart Library"
+	}
+	if ttl, err := time.ParseDuration(os.Getenv("TWO_FACTOR_CHALLENGE_TTL")); err == nil && ttl > 0 {
+		env.ChallengeTTL = ttl
+	}
+
+	return env
+}
+
+type EnvPasswordPolicy struct {
+	MinLength        int
+	RequireUppercase bool
+	RequireLowercase bool
+	RequireDigit     bool
+	RequireSymbol    bool
+	HistorySize      int
+}
+
+func GetEnvPasswordPolicy() *EnvPasswordPolicy {
+	env := &EnvPasswordPolicy{
+		MinLength:        8,
+		RequireUppercase: true,
+		RequireLowercase: true,
+		RequireDigit:     true,
+		HistorySize:      5,
+	}
+
+	if length, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH")); err == nil && length > 0 {
+		env.MinLength = length
+	}
+	if required, err := strconv.ParseBool(os.Getenv("PASSWORD_REQUIRE_UPPERCASE")); err == nil {
+		env.RequireUppercase = required
+	}
+	if required, err := strconv.ParseBool(os.Getenv("PASSWORD_REQUIRE_LOWERCASE")); err == nil {
+		env.RequireLowercase = required
+	}
+	if required, err := strconv.ParseBool(os.Getenv("PASSWORD_REQUIRE_DIGIT")); err == nil {
+		env.RequireDigit = required
+	}
+	if required, err := strconv.ParseBool(os.Getenv("PASSWORD_REQUIRE_SYMBOL")); err == nil {
+		env.RequireSymbol = required
+	}
+	if size, err := strconv.Atoi(os.Getenv("PASSWORD_HISTORY_SIZE")); err == nil && size >= 0 {
+		env.HistorySize = size
+	}
+
+	return env
+}
+
+type EnvScanQueue struct {
+	TTL     time.Duration
+	MaxWait time.Duration
+}
+
+func GetEnvScanQueue() *EnvScanQueue {
+	env := &EnvScanQueue{
+		TTL:     time.Minute,
+		MaxWait: 30 * time.Second,
+	}
+
+	if ttl, err := time.ParseDuration(os.Getenv("SCAN_QUEUE_TTL")); err == nil && ttl > 0 {
+		env.TTL = ttl
+	}
+	if wait, err := time.ParseDuration(os.Getenv("SCAN_QUEUE_MAX_WAIT")); err == nil && wait >= 0 {
+		env.MaxWait = wait
+	}
+
+	return env
+}
+
+type EnvMQTT struct {
+	Enabled     bool
+	BrokerURL   string
+	ClientID    string
+	Username    string
+	Password    string
+	TopicPrefix string
+	QoS         byte
+}
+
+func GetEnvMQTT() *EnvMQTT {
+	env := &EnvMQTT{
+		BrokerURL:   "tcp://localhost:1883",
+		ClientID:    "smart-library-be",
+		Username:    os.Getenv("MQTT_USERNAME"),
+		Password:    os.Getenv("MQTT_PASSWORD"),
+		TopicPrefix: "library/readers",
+		QoS:         1,
+	}
+
+	if enabled, err := strconv.ParseBool(os.Getenv("MQTT_ENABLED")); err == nil {
+		env.Enabled = enabled
+	}
+	if brokerURL := os.Getenv("MQTT_BROKER_URL"); brokerURL != "" {
+		env.BrokerURL = brokerURL
+	}
+	if clientID := os.Getenv("MQTT_CLIENT_ID"); clientID != "" {
+		env.ClientID = clientID
+	}
+	if prefix := strings.Trim(os.Getenv("MQTT_TOPIC_PREFIX"), "/"); prefix != "" {
+		env.TopicPrefix = prefix
+	}
+	if qos, err := strconv.Atoi(os.Getenv("MQTT_QOS")); err == nil && qos >= 0 && qos <= 2 {
+		env.QoS = byte(qos)
+	}
+
+	return env
+}
+
+type EnvScanEvents struct {
+	Retention     time.Duration
+	PruneInterval time.Duration
+}
+
+// GetEnvScanEvents reads how long the scan log is kept. A retention of 0
+// keeps events forever.
+func GetEnvScanEvents() *EnvScanEvents {
+	env := &EnvScanEvents{
+		Retention:     90 * 24 * time.Hour,
+		PruneInterval: 24 * time.Hour,
+	}
+
+	if retention, err := time.ParseDuration(os.Getenv("SCAN_EVENT_RETENTION")); err == nil && retention >= 0 {
+		env.Retention = retention
+	}
+	if interval, err := time.ParseDuration(os.Getenv("SCAN_EVENT_PRUNE_INTERVAL")); err == nil && interval > 0 {
+		env.PruneInterval = interval
+	}
+
+	return env
+}
+
+type EnvDeviceHealth struct {
+	HeartbeatTimeout time.Duration
+	CheckInterval    time.Duration
+	AlertEmails      []string
+	AlertWebhookURL  string
+}
+
+// GetEnvDeviceHealth reads when a reader counts as offline and where offline
+// alerts go. With no email and no webhook set, offline readers are only
+// shown on the health dashboard.
+func GetEnvDeviceHealth() *EnvDeviceHealth {
+	env := &EnvDeviceHealth{
+		HeartbeatTimeout: 5 * time.Minute,
+		CheckInterval:    time.Minute,
+		AlertWebhookURL:  os.Getenv("DEVICE_ALERT_WEBHOOK_URL"),
+	}
+
+	if timeout, err := time.ParseDuration(os.Getenv("DEVICE_HEARTBEAT_TIMEOUT")); err == nil && timeout > 0 {
+		env.HeartbeatTimeout = timeout
+	}
+	if interval, err := time.ParseDuration(os.Getenv("DEVICE_HEALTH_CHECK_INTERVAL")); err == nil && interval > 0 {
+		env.CheckInterval = interval
+	}
+	for _, email := range strings.Split(os.Getenv("DEVICE_ALERT_EMAILS"), ",") {
+		if email = strings.TrimSpace(email); email != "" {
+			env.AlertEmails = append(env.AlertEmails, email)
+		}
+	}
+
+	return env
+}
+
+type EnvScanSync struct {
+	ApplyBorrows bool
+	MaxClockSkew time.Duration
+}
+
+// GetEnvScanSync reads how offline scan batches are applied. Borrow and
+// return processing is off unless SCAN_SYNC_APPLY_BORROWS is set.
+func GetEnvScanSync() *EnvScanSync {
+	env := &EnvScanSync{
+		MaxClockSkew: 5 * time.Minute,
+	}
+
+	if apply, err := strconv.ParseBool(os.Getenv("SCAN_SYNC_APPLY_BORROWS")); err == nil {
+		env.ApplyBorrows = apply
+	}
+	if skew, err := time.ParseDuration(os.Getenv("SCAN_SYNC_MAX_CLOCK_SKEW")); err == nil && skew >= 0 {
+		env.MaxClockSkew = skew
+	}
+
+	return env
+}
+
+type EnvSecurityGate struct {
+	Timeout        time.Duration
+	AlarmOnTimeout bool
+}
+
+// GetEnvSecurityGate reads the latency budget of a gate check. When a check
+// runs over it, the gate gets AlarmOnTimeout as the answer.
+func GetEnvSecurityGate() *EnvSecurityGate {
+	env := &EnvSecurityGate{
+		Timeout: 300 * time.Millisecond,
+	}
+
+	if timeout, err := time.ParseDuration(os.Getenv("SECURITY_GATE_TIMEOUT")); err == nil && timeout > 0 {
+		env.Timeout = timeout
+	}
+	if alarm, err := strconv.ParseBool(os.Getenv("SECURITY_GATE_ALARM_ON_TIMEOUT")); err == nil {
+		env.AlarmOnTimeout = alarm
+	}
+
+	return env
+}
+
+type EnvKiosk struct {
+	SessionTimeout time.Duration
+}
+
+// GetEnvKiosk reads how long a self-checkout session may sit idle. Every tap
+// restarts the timeout.
+func GetEnvKiosk() *EnvKiosk {
+	env := &EnvKiosk{
+		SessionTimeout: 2 * time.Minute,
+	}
+
+	if timeout, err := time.ParseDuration(os.Getenv("KIOSK_SESSION_TIMEOUT")); err == nil && timeout > 0 {
+		env.SessionTimeout = timeout
+	}
+
+	return env
+}
+
+type EnvNotificationQueue struct {
+	SendInterval time.Duration
+	MaxAttempts  int
+}
+
+// GetEnvNotificationQueue reads how often queued emails are sent and how many
+// times a failing one is retried before it is given up on.
+func GetEnvNotificationQueue() *EnvNotificationQueue {
+	env := &EnvNotificationQueue{
+		SendInterval: 30 * time.Second,
+		MaxAttempts:  5,
+	}
+
+	if interval, err := time.ParseDuration(os.Getenv("NOTIFICATION_QUEUE_INTERVAL")); err == nil && interval > 0 {
+		env.SendInterval = interval
+	}
+	if attempts, err := strconv.Atoi(os.Getenv("NOTIFICATION_MAX_ATTEMPTS")); err == nil && attempts > 0 {
+		env.MaxAttempts = attempts
+	}
+
+	return env
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/dimassfeb-09/smart-library-be/entity"
 	"gopkg.in/mail.v2"
+	"html"
 	"log"
 )
 
@@ -66,7 +67,7 @@ func htmlBody(notification *entity.Notification) string {
             .container {
                 padding: 0 !important;
                 padding-top: 8px !important;
-                width: 100% !important;
+                width: 100%% !important;
             }
             .main {
                 border-left-width: 0 !important;
@@ -74,18 +75,18 @@ func htmlBody(notification *entity.Notification) string {
                 border-right-width: 0 !important;
             }
             .btn table {
-                max-width: 100% !important;
-                width: 100% !important;
+                max-width: 100%% !important;
+                width: 100%% !important;
             }
             .btn a {
                 font-size: 16px !important;
-                max-width: 100% !important;
-                width: 100% !important;
+                max-width: 100%% !important;
+                width: 100%% !important;
             }
         }
         @media all {
             .ExternalClass {
-                width: 100%;
+                width: 100%%;
             }
             .ExternalClass,
             .ExternalClass p,
@@ -93,7 +94,7 @@ func htmlBody(notification *entity.Notification) string {
             .ExternalClass font,
             .ExternalClass td,
             .ExternalClass div {
-                line-height: 100%;
+                line-height: 100%%;
             }
             .apple-link a {
                 color: inherit !important;
@@ -114,8 +115,8 @@ func htmlBody(notification *entity.Notification) string {
         }
     </style>
 </head>
-<body style="font-family: Helvetica, sans-serif; -webkit-font-smoothing: antialiased; font-size: 16px; line-height: 1.3; -ms-text-size-adjust: 100%; -webkit-text-size-adjust: 100%; background-color: #f4f5f6; margin: 0; padding: 0;">
-<table role="presentation" border="0" cellpadding="0" cellspacing="0" class="body" style="border-collapse: separate; mso-table-lspace: 0pt; mso-table-rspace: 0pt; background-color: #f4f5f6; width: 100%;" width="100%" bgcolor="#f4f5f6">
+<body style="font-family: Helvetica, sans-serif; -webkit-font-smoothing: antialiased; font-size: 16px; line-height: 1.3; -ms-text-size-adjust: 100%%; -webkit-text-size-adjust: 100%%; background-color: #f4f5f6; margin: 0; padding: 0;">
+<table role="presentation" border="0" cellpadding="0" cellspacing="0" class="body" style="border-collapse: separate; mso-table-lspace: 0pt; mso-table-rspace: 0pt; background-color: #f4f5f6; width: 100%%;" width="100%%" bgcolor="#f4f5f6">
     <tr>
         <td style="font-family: Helvetica, sans-serif; font-size: 16px; vertical-align: top;" valign="top">&nbsp;</td>
         <td class="container" style="font-family: Helvetica, sans-serif; font-size: 16px; vertical-align: top; max-width: 600px; padding: 0; padding-top: 24px; width: 600px; margin: 0 auto;" width="600" valign="top">
@@ -123,7 +124,7 @@ func htmlBody(notification *entity.Notification) string {
 
                 <!-- START CENTERED WHITE CONTAINER -->
                 <span class="preheader" style="color: transparent; display: none; height: 0; max-height: 0; max-width: 0; opacity: 0; overflow: hidden; mso-hide: all; visibility: hidden; width: 0;">This is preheader text. Some clients will show this text as a preview.</span>
-                <table role="presentation" border="0" cellpadding="0" cellspacing="0" class="main" style="border-collapse: separate; mso-table-lspace: 0pt; mso-table-rspace: 0pt; background: #ffffff; border: 1px solid #eaebed; border-radius: 16px; width: 100%;" width="100%">
+                <table role="presentation" border="0" cellpadding="0" cellspacing="0" class="main" style="border-collapse: separate; mso-table-lspace: 0pt; mso-table-rspace: 0pt; background: #ffffff; border: 1px solid #eaebed; border-radius: 16px; width: 100%%;" width="100%%">
 
                     <!-- START MAIN CONTENT AREA -->
                     <tr>
@@ -139,8 +140,8 @@ func htmlBody(notification *entity.Notification) string {
                 </table>
 
                 <!-- START FOOTER -->
-                <div class="footer" style="clear: both; padding-top: 24px; text-align: center; width: 100%;">
-                    <table role="presentation" border="0" cellpadding="0" cellspacing="0" style="border-collapse: separate; mso-table-lspace: 0pt; mso-table-rspace: 0pt; width: 100%;" width="100%">
+                <div class="footer" style="clear: both; padding-top: 24px; text-align: center; width: 100%%;">
+                    <table role="presentation" border="0" cellpadding="0" cellspacing="0" style="border-collapse: separate; mso-table-lspace: 0pt; mso-table-rspace: 0pt; width: 100%%;" width="100%%">
                         <tr>
                             <td class="content-block" style="font-family: Helvetica, sans-serif; vertical-align: top; color: #9a9ea6; font-size: 16px; text-align: center;" valign="top" align="center">
                                 <span class="apple-link" style="color: #9a9ea6; font-size: 16px; text-align: center;">Smart Library - Pengagum Rahasia, 2024.</span>
@@ -163,3 +164,77 @@ func htmlBody(notification *entity.Notification) string {
 
 	return body
 }
+
+// Mailer sends a single HTML email. Services take a Mailer rather than
+// calling SendHTMLMail so they can run without an SMTP server.
+type Mailer interface {
+	SendHTMLMail(to string, subject string, body string) error
+}
+
+// SMTPMailer is the Mailer backed by the server configured in EnvMail.
+type SMTPMailer struct {
+	Config *EnvMail
+}
+
+func NewSMTPMailer(env *EnvMail) *SMTPMailer {
+	return &SMTPMailer{Config: env}
+}
+
+func (m *SMTPMailer) SendHTMLMail(to string, subject string, body string) error {
+	return SendHTMLMail(m.Config, to, subject, body)
+}
+
+func SendHTMLMail(env *EnvMail, to string, subject string, body string) error {
+	m := mail.NewMessage()
+
+	m.SetHeader("From", env.From)
+	m.SetHeader("To", to)
+	m.SetHeader("Subject", subject)
+	m.SetBody("text/html", body)
+
+	d := mail.NewDialer(env.Host, env.Port, env.Username, env.Password)
+
+	return d.DialAndSend(m)
+}
+
+func ActionMailBody(message string, actionLabel string, actionURL string) string {
+	return fmt.Sprintf(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Smart Library</title>
+</head>
+<body style="font-family: Helvetica, sans-serif; font-size: 16px; line-height: 1.3; background-color: #f4f5f6; margin: 0; padding: 24px;">
+<div style="max-width: 600px; margin: 0 auto; background: #ffffff; border: 1px solid #eaebed; border-radius: 16px; padding: 24px;">
+    <p style="margin: 0; margin-bottom: 16px;">Halo!</p>
+    <p style="margin: 0; margin-bottom: 20px;">%s</p>
+    <a href="%s" style="background-color: blue; padding: 10px; color: white; text-decoration: none; border-radius: 5px; font-family: Arial, sans-serif;">%s</a>
+    <p style="margin: 0; margin-top: 20px; font-size: 14px; color: #9a9ea6;">Jika tombol tidak berfungsi, buka tautan berikut: %s</p>
+</div>
+<p style="text-align: center; color: #9a9ea6; font-size: 16px;">Smart Library - Pengagum Rahasia, 2024.</p>
+</body>
+</html>
+`, html.EscapeString(message), html.EscapeString(actionURL), html.EscapeString(actionLabel), html.EscapeString(actionURL))
+}
+
+func MessageMailBody(message string) string {
+	return fmt.Sprintf(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Smart Library</title>
+</head>
+<body style="font-family: Helvetica, sans-serif; font-size: 16px; line-height: 1.3; background-color: #f4f5f6; margin: 0; padding: 24px;">
+<div style="max-width: 600px; margin: 0 auto; background: #ffffff; border: 1px solid #eaebed; border-radius: 16px; padding: 24px;">
+    <p style="margin: 0; margin-bottom: 16px;">Halo!</p>
+    <p style="margin: 0;">%s</p>
+</div>
+<p style="text-align: center; color: #9a9ea6; font-size: 16px;">Smart Library - Pengagum Rahasia, 2024.</p>
+</body>
+</html>
+`, html.EscapeString(message))
+}
@@ -0,0 +1,168 @@
+package helper
+
+import (
+	"bufio"
+	"io"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpMessage is what the test SMTP server received for one message.
+type smtpMessage struct {
+	From string
+	To   []string
+	Data string
+}
+
+// serveSMTP accepts one connection on l and plays a minimal SMTP server
+// without authentication or TLS. Recipients in reject are refused with 550.
+// The received message is sent on the returned channel when the client quits.
+func serveSMTP(t *testing.T, l net.Listener, reject map[string]bool) <-chan smtpMessage {
+	t.Helper()
+
+	received := make(chan smtpMessage, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+		var message smtpMessage
+		reply("220 localhost ESMTP test")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			command := strings.ToUpper(line)
+
+			switch {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(command, "MAIL FROM:"):
+				message.From = strings.Trim(line[len("MAIL FROM:"):], "<> ")
+				reply("250 OK")
+			case strings.HasPrefix(command, "RCPT TO:"):
+				to := strings.Trim(line[len("RCPT TO:"):], "<> ")
+				if reject[to] {
+					reply("550 no such user")
+					continue
+				}
+				message.To = append(message.To, to)
+				reply("250 OK")
+			case command == "DATA":
+				reply("354 end data with <CR><LF>.<CR><LF>")
+				var data strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					data.WriteString(strings.TrimPrefix(line, "."))
+				}
+				message.Data = data.String()
+				reply("250 OK")
+			case command == "QUIT":
+				reply("221 bye")
+				received <- message
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+
+	return received
+}
+
+func TestSMTPMailerSendHTMLMail(t *testing.T) {
+	tests := []struct {
+		name    string
+		to      string
+		reject  map[string]bool
+		wantErr bool
+	}{
+		{name: "delivered", to: "budi@student.test"},
+		{name: "recipient refused", to: "nobody@student.test", reject: map[string]bool{"nobody@student.test": true}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { l.Close() })
+			received := serveSMTP(t, l, tt.reject)
+
+			addr := l.Addr().(*net.TCPAddr)
+			mailer := NewSMTPMailer(&EnvMail{Host: "127.0.0.1", Port: addr.Port, From: "library@library.test"})
+
+			body := MessageMailBody("Buku telah dikembalikan.")
+			err = mailer.SendHTMLMail(tt.to, "Bukti Pengembalian Buku", body)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("SendHTMLMail succeeded for a refused recipient")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SendHTMLMail: %v", err)
+			}
+
+			var message smtpMessage
+			select {
+			case message = <-received:
+			case <-time.After(2 * time.Second):
+				t.Fatal("SMTP server did not see the client quit")
+			}
+			if message.From != "library@library.test" {
+				t.Errorf("envelope from = %q, want library@library.test", message.From)
+			}
+			if len(message.To) != 1 || message.To[0] != tt.to {
+				t.Errorf("envelope to = %v, want [%s]", message.To, tt.to)
+			}
+
+			parsed, err := mail.ReadMessage(strings.NewReader(message.Data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for header, want := range map[string]string{
+				"From":    "library@library.test",
+				"To":      tt.to,
+				"Subject": "Bukti Pengembalian Buku",
+			} {
+				if got := parsed.Header.Get(header); got != want {
+					t.Errorf("%s header = %q, want %q", header, got, want)
+				}
+			}
+			if contentType := parsed.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/html") {
+				t.Errorf("Content-Type = %q, want text/html", contentType)
+			}
+
+			var content io.Reader = parsed.Body
+			if parsed.Header.Get("Content-Transfer-Encoding") == "quoted-printable" {
+				content = quotedprintable.NewReader(content)
+			}
+			got, err := io.ReadAll(content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.TrimSpace(strings.ReplaceAll(string(got), "\r\n", "\n")) != strings.TrimSpace(body) {
+				t.Errorf("body was not delivered as sent:\n%s", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package helper
+
+import (
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// NewMQTTClient builds a client that reconnects on its own. onConnect runs
+// after every (re)connect, so subscriptions made there survive broker restarts.
+func NewMQTTClient(env *EnvMQTT, onConnect func(client mqtt.Client)) mqtt.Client {
+	options := mqtt.NewClientOptions().
+		AddBroker(env.BrokerURL).
+		SetClientID(env.ClientID).
+		SetUsername(env.Username).
+		SetPassword(env.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(5 * time.Second).
+		SetOnConnectHandler(onConnect)
+
+	return mqtt.NewClient(options)
+}
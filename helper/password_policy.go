@@ -0,0 +1,78 @@
+package helper
+
+import (
+	"bufio"
+	_ "embed"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+//go:embed breached_passwords.txt
+var breachedPasswordList string
+
+var breachedPasswords = loadBreachedPasswords(breachedPasswordList)
+
+func loadBreachedPasswords(list string) map[string]struct{} {
+	passwords := make(map[string]struct{})
+
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		passwords[strings.ToLower(line)] = struct{}{}
+	}
+
+	return passwords
+}
+
+// IsBreachedPassword reports whether password is on the bundled list of
+// passwords known from public breaches.
+func IsBreachedPassword(password string) bool {
+	_, found := breachedPasswords[strings.ToLower(password)]
+	return found
+}
+
+// CheckPasswordPolicy returns every rule of the policy that password breaks,
+// or nil when it is acceptable.
+func CheckPasswordPolicy(env *EnvPasswordPolicy, password string) []string {
+	var violations []string
+
+	if len([]rune(password)) < env.MinLength {
+		violations = append(violations, "must be at least "+strconv.Itoa(env.MinLength)+" characters long")
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+
+	if env.RequireUppercase && !hasUpper {
+		violations = append(violations, "must contain an uppercase letter")
+	}
+	if env.RequireLowercase && !hasLower {
+		violations = append(violations, "must contain a lowercase letter")
+	}
+	if env.RequireDigit && !hasDigit {
+		violations = append(violations, "must contain a digit")
+	}
+	if env.RequireSymbol && !hasSymbol {
+		violations = append(violations, "must contain a symbol")
+	}
+	if IsBreachedPassword(password) {
+		violations = append(violations, "appears in a list of breached passwords")
+	}
+
+	return violations
+}
@@ -0,0 +1,79 @@
+package helper
+
+import (
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// Input formats a reader can report a tag UID in. Hex also accepts bytes
+// separated by colons, dashes or spaces.
+const (
+	UIDFormatHex       = "hex"
+	UIDFormatDecimalLE = "decimal_le"
+	UIDFormatDecimalBE = "decimal_be"
+)
+
+// uidLengths are the byte lengths of ISO 14443 UIDs. A decimal UID is padded
+// to the next of these, since leading zero bytes do not survive as a number.
+var uidLengths = []int{4, 7, 10}
+
+var ErrInvalidUID = errors.New("invalid uid")
+
+// NormalizeUID converts a UID reported in format to the canonical form stored
+// in card_rfid: upper-case hex bytes in card order with no separators. An
+// empty format means hex, and canonical UIDs are returned unchanged.
+func NormalizeUID(uid, format string) (string, error) {
+	uid = strings.TrimSpace(uid)
+	if uid == "" {
+		return "", ErrInvalidUID
+	}
+
+	switch format {
+	case "", UIDFormatHex:
+		return normalizeHexUID(uid)
+	case UIDFormatDecimalLE, UIDFormatDecimalBE:
+		value, ok := new(big.Int).SetString(uid, 10)
+		if !ok || value.Sign() < 0 {
+			return "", ErrInvalidUID
+		}
+
+		bytes := padUID(value.Bytes())
+		if bytes == nil {
+			return "", ErrInvalidUID
+		}
+		if format == UIDFormatDecimalLE {
+			for i, j := 0, len(bytes)-1; i < j; i, j = i+1, j-1 {
+				bytes[i], bytes[j] = bytes[j], bytes[i]
+			}
+		}
+		return strings.ToUpper(hex.EncodeToString(bytes)), nil
+	default:
+		return "", errors.New("unknown uid format " + format)
+	}
+}
+
+func normalizeHexUID(uid string) (string, error) {
+	uid = strings.NewReplacer(":", "", "-", "", " ", "").Replace(uid)
+	uid = strings.TrimPrefix(strings.TrimPrefix(uid, "0x"), "0X")
+	if uid == "" || len(uid)%2 != 0 {
+		return "", ErrInvalidUID
+	}
+
+	if _, err := hex.DecodeString(uid); err != nil {
+		return "", ErrInvalidUID
+	}
+	return strings.ToUpper(uid), nil
+}
+
+func padUID(bytes []byte) []byte {
+	for _, length := range uidLengths {
+		if len(bytes) <= length {
+			padded := make([]byte, length)
+			copy(padded[length-len(bytes):], bytes)
+			return padded
+		}
+	}
+	return nil
+}
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"github.com/dimassfeb-09/smart-library-be/controllers"
 	"github.com/dimassfeb-09/smart-library-be/db"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/middleware"
 	"github.com/dimassfeb-09/smart-library-be/repository"
 	"github.com/dimassfeb-09/smart-library-be/router"
 	"github.com/dimassfeb-09/smart-library-be/services"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
@@ -18,19 +22,38 @@ import (
 )
 
 type App struct {
-	BookController         *controllers.BookController
-	StudentController      *controllers.StudentController
-	CardController         *controllers.CardController
-	BorrowController       *controllers.BorrowController
-	BookCardController     *controllers.BookCardController
-	StudentCardController  *controllers.StudentCardController
-	AccountController      *controllers.AccountController
-	NotificationController *controllers.NotificationController
+	BookController          *controllers.BookController
+	StudentController       *controllers.StudentController
+	CardController          *controllers.CardController
+	BorrowController        *controllers.BorrowController
+	BookCardController      *controllers.BookCardController
+	BookItemController      *controllers.BookItemController
+	StudentCardController   *controllers.StudentCardController
+	AccountController       *controllers.AccountController
+	TwoFactorController     *controllers.TwoFactorController
+	NotificationController  *controllers.NotificationController
+	PasswordResetController *controllers.PasswordResetController
+	DeviceController        *controllers.DeviceController
+	DeviceHealthController  *controllers.DeviceHealthController
+	DeviceScanController    *controllers.DeviceScanController
+	MQTTScanController      *controllers.MQTTScanController
+	ScanEventController     *controllers.ScanEventController
+	ScanSyncController      *controllers.ScanSyncController
+	SecurityGateController  *controllers.SecurityGateController
+	KioskController         *controllers.KioskController
+	BookReturnController    *controllers.BookReturnController
+	EnrollmentController    *controllers.EnrollmentController
+	Auth                    *middleware.Auth
+	DeviceAuth              *middleware.DeviceAuth
 }
 
 func NewApp(database *sql.DB) *App {
+	envJWT := helper.GetEnvJWT()
+	mailer := helper.NewSMTPMailer(helper.GetEnvMail())
+
 	bookRepository := repository.NewBookRepository()
-	bookService := services.NewBookServices(bookRepository, database)
+	bookItemRepository := repository.NewBookItemRepository()
+	bookService := services.NewBookServices(bookRepository, bookItemRepository, database)
 	bookController := controllers.NewBookController(bookService)
 
 	studentRepository := repository.NewStudentRepository()
@@ -39,34 +62,104 @@ func NewApp(database *sql.DB) *App {
 
 	cardRepository := repository.NewCardRepository()
 	cardService := services.NewCardServices(database, cardRepository, studentService, bookService)
-	cardController := controllers.NewCardController(cardService)
 
-	borrowRepository := repository.NewBorrowRepository()
-	borrowService := services.NewBorrowServices(database, borrowRepository, studentService, bookService)
-	borrowController := controllers.NewBorrowController(borrowService)
+	scanEventRepository := repository.NewScanEventRepository()
+	scanEventService := services.NewScanEventServices(database, scanEventRepository, cardService, helper.GetEnvScanEvents())
+	scanEventController := controllers.NewScanEventController(scanEventService)
+	cardController := controllers.NewCardController(cardService, scanEventService)
 
 	bookCardService := services.NewBookCardServices(database, bookService, cardService)
 	bookCardController := controllers.NewBookCardController(bookCardService)
 
+	bookItemService := services.NewBookItemServices(database, bookService, cardService)
+	bookItemController := controllers.NewBookItemController(bookItemService)
+
 	studentCardService := services.NewStudentCardServices(database, cardService, studentService)
 	studentCardController := controllers.NewStudentCardController(studentCardService)
 
+	sessionRepository := repository.NewSessionRepository()
+	sessionService := services.NewSessionServices(database, sessionRepository, envJWT)
+
+	loginAttemptRepository := repository.NewLoginAttemptRepository()
+	loginAttemptService := services.NewLoginAttemptServices(database, loginAttemptRepository, helper.GetEnvLoginProtection())
+
+	twoFactorRepository := repository.NewTwoFactorRepository()
+	twoFactorService := services.NewTwoFactorServices(database, twoFactorRepository, helper.GetEnvTwoFactor())
+
 	accountRepository := repository.NewAccountsRepository()
-	accountService := services.NewAccountServices(database, accountRepository, studentService)
+	passwordHistoryRepository := repository.NewPasswordHistoryRepository()
+	accountService := services.NewAccountServices(database, accountRepository, passwordHistoryRepository, studentService, sessionService, loginAttemptService, twoFactorService, envJWT, mailer, helper.GetEnvEmailVerification(), helper.GetEnvPasswordPolicy())
 	accountController := controllers.NewAccountController(accountService)
+	twoFactorController := controllers.NewTwoFactorController(accountService)
+
+	borrowRepository := repository.NewBorrowRepository()
+	borrowService := services.NewBorrowServices(database, borrowRepository, studentService, bookService, accountService, cardService)
+	borrowController := controllers.NewBorrowController(borrowService)
+
+	passwordResetRepository := repository.NewPasswordResetRepository()
+	passwordResetService := services.NewPasswordResetServices(database, passwordResetRepository, accountService, mailer, helper.GetEnvPasswordReset())
+	passwordResetController := controllers.NewPasswordResetController(passwordResetService)
+
+	deviceRepository := repository.NewDeviceRepository()
+	deviceService := services.NewDeviceServices(database, deviceRepository)
+	deviceController := controllers.NewDeviceController(deviceService)
+	deviceHealthService := services.NewDeviceHealthServices(database, deviceRepository, helper.GetEnvDeviceHealth(), mailer)
+	deviceHealthController := controllers.NewDeviceHealthController(deviceHealthService)
+
+	enrollmentRepository := repository.NewEnrollmentRepository()
+	enrollmentService := services.NewEnrollmentServices(database, enrollmentRepository, cardService, deviceService)
+	enrollmentController := controllers.NewEnrollmentController(enrollmentService)
+
+	deviceScanRepository := repository.NewDeviceScanRepository()
+	deviceScanService := services.NewDeviceScanServices(database, deviceScanRepository, deviceService, scanEventService, enrollmentService, helper.GetEnvScanQueue())
+	deviceScanController := controllers.NewDeviceScanController(deviceScanService)
+	mqttScanController := controllers.NewMQTTScanController(deviceScanService, helper.GetEnvMQTT())
+
+	scanSyncRepository := repository.NewScanSyncRepository()
+	scanSyncService := services.NewScanSyncServices(database, scanSyncRepository, scanEventService, borrowService, helper.GetEnvScanSync())
+	scanSyncController := controllers.NewScanSyncController(scanSyncService)
+
+	securityEventRepository := repository.NewSecurityEventRepository()
+	securityGateService := services.NewSecurityGateServices(database, securityEventRepository, cardService, borrowService, scanEventService, helper.GetEnvSecurityGate())
+	securityGateController := controllers.NewSecurityGateController(securityGateService)
+
+	kioskRepository := repository.NewKioskRepository()
+	kioskService := services.NewKioskServices(database, kioskRepository, cardService, borrowService, scanEventService, helper.GetEnvKiosk())
+	kioskController := controllers.NewKioskController(kioskService)
+
+	notificationQueueRepository := repository.NewNotificationQueueRepository()
+	notificationQueueService := services.NewNotificationQueueServices(database, notificationQueueRepository, helper.GetEnvNotificationQueue(), mailer)
+
+	bookReturnService := services.NewBookReturnServices(database, cardService, borrowService, notificationQueueService, scanEventService)
+	bookReturnController := controllers.NewBookReturnController(bookReturnService)
 
 	notificationService := services.NewNotificationServices(database, studentService, accountService, borrowService)
 	notificationController := controllers.NewNotificationController(notificationService)
 
 	return &App{
-		BookController:         bookController,
-		StudentController:      studentController,
-		CardController:         cardController,
-		BorrowController:       borrowController,
-		BookCardController:     bookCardController,
-		StudentCardController:  studentCardController,
-		AccountController:      accountController,
-		NotificationController: notificationController,
+		BookController:          bookController,
+		StudentController:       studentController,
+		CardController:          cardController,
+		BorrowController:        borrowController,
+		BookCardController:      bookCardController,
+		BookItemController:      bookItemController,
+		StudentCardController:   studentCardController,
+		AccountController:       accountController,
+		TwoFactorController:     twoFactorController,
+		NotificationController:  notificationController,
+		PasswordResetController: passwordResetController,
+		DeviceController:        deviceController,
+		DeviceHealthController:  deviceHealthController,
+		DeviceScanController:    deviceScanController,
+		MQTTScanController:      mqttScanController,
+		ScanEventController:     scanEventController,
+		ScanSyncController:      scanSyncController,
+		SecurityGateController:  securityGateController,
+		KioskController:         kioskController,
+		BookReturnController:    bookReturnController,
+		EnrollmentController:    enrollmentController,
+		Auth:                    middleware.NewAuth(envJWT, sessionService, studentService, borrowService),
+		DeviceAuth:              middleware.NewDeviceAuth(deviceService),
 	}
 }
 
@@ -86,16 +179,38 @@ func main() {
 		return ctx.SendString("Server ON!")
 	})
 
-	router.RegisterBookRoutes("books", app, controller.BookController, controller.BookCardController)
-	router.RegisterCardRoutes("cards", app, controller.CardController)
-	router.RegisterStudentRoutes("students", app, controller.StudentController, controller.StudentCardController)
-	router.RegisterBorrowRoutes("borrows", app, controller.BorrowController)
-	router.RegisterAccountRoutes("accounts", app, controller.AccountController, controller.NotificationController)
-	router.RegisterAuthRoutes("auth", app, controller.AccountController)
+	router.RegisterBookRoutes("books", app, controller.Auth, controller.BookController, controller.BookCardController, controller.BookItemController)
+	router.RegisterCardRoutes("cards", app, controller.Auth, controller.DeviceAuth, controller.CardController, controller.DeviceScanController, controller.ScanSyncController)
+	router.RegisterStudentRoutes("students", app, controller.Auth, controller.StudentController, controller.StudentCardController)
+	router.RegisterBorrowRoutes("borrows", app, controller.Auth, controller.BorrowController)
+	router.RegisterAccountRoutes("accounts", app, controller.Auth, controller.AccountController, controller.NotificationController, controller.TwoFactorController)
+	router.RegisterDeviceHealthRoutes("devices", app, controller.Auth, controller.DeviceAuth, controller.DeviceHealthController)
+	router.RegisterDeviceRoutes("devices", app, controller.Auth, controller.DeviceController, controller.DeviceScanController)
+	router.RegisterScanRoutes("scans", app, controller.Auth, controller.ScanEventController)
+	router.RegisterSecurityRoutes("security", app, controller.Auth, controller.DeviceAuth, controller.SecurityGateController)
+	router.RegisterKioskRoutes("kiosk", app, controller.DeviceAuth, controller.KioskController)
+	router.RegisterBookReturnRoutes("returns", app, controller.DeviceAuth, controller.BookReturnController)
+	router.RegisterEnrollmentRoutes("enrollments", app, controller.Auth, controller.EnrollmentController)
+	router.RegisterAuthRoutes("auth", app, controller.Auth, controller.AccountController, controller.PasswordResetController, controller.TwoFactorController)
 
 	err := godotenv.Load()
 	if err != nil {
 		log.Fatal("File .env not found")
 	}
+
+	go controller.ScanEventController.ScanEventServices.RunRetention(context.Background())
+	go controller.DeviceHealthController.DeviceHealthServices.RunHealthMonitor(context.Background())
+	go controller.BookReturnController.BookReturnServices.NotificationQueueServices.RunNotificationQueue(context.Background())
+
+	if envMQTT := controller.MQTTScanController.Config; envMQTT.Enabled {
+		client := helper.NewMQTTClient(envMQTT, func(client mqtt.Client) {
+			router.RegisterMQTTRoutes(envMQTT.TopicPrefix, envMQTT.QoS, client, controller.MQTTScanController)
+		})
+		if token := client.Connect(); token.Wait() && token.Error() != nil {
+			log.Fatal("mqtt: ", token.Error())
+		}
+		defer client.Disconnect(250)
+	}
+
 	log.Fatal(app.Listen(fmt.Sprintf(":" + os.Getenv("PORT"))))
 }
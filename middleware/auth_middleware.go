@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+const authAccountKey = "authAccount"
+
+type Auth struct {
+	JWT *helper.EnvJWT
+	*services.SessionServices
+	*services.StudentServices
+	*services.BorrowServices
+}
+
+func NewAuth(envJWT *helper.EnvJWT, sess *services.SessionServices, ss *services.StudentServices, bs *services.BorrowServices) *Auth {
+	return &Auth{
+		JWT:             envJWT,
+		SessionServices: sess,
+		StudentServices: ss,
+		BorrowServices:  bs,
+	}
+}
+
+func (a *Auth) Authenticate() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		return a.authenticate(ctx, bearerToken(ctx))
+	}
+}
+
+// AuthenticateEventStream also accepts the access token in the access_token
+// query parameter, because browsers cannot set headers on an EventSource.
+func (a *Auth) AuthenticateEventStream() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		token := bearerToken(ctx)
+		if token == "" {
+			token = ctx.Query("access_token")
+		}
+		return a.authenticate(ctx, token)
+	}
+}
+
+func (a *Auth) authenticate(ctx *fiber.Ctx, token string) error {
+	if token == "" {
+		errorResponse := helper.ErrorResponse(http.StatusUnauthorized, "Missing or malformed access token")
+		return ctx.Status(http.StatusUnauthorized).JSON(errorResponse)
+	}
+
+	claims, err := helper.ParseAccessToken(a.JWT, token)
+	if err != nil {
+		errorResponse := helper.ErrorResponse(http.StatusUnauthorized, "Invalid or expired access token")
+		return ctx.Status(http.StatusUnauthorized).JSON(errorResponse)
+	}
+
+	if !a.SessionServices.IsSessionActive(ctx.Context(), claims.SessionID, claims.AccountID) {
+		errorResponse := helper.ErrorResponse(http.StatusUnauthorized, "Session has been revoked")
+		return ctx.Status(http.StatusUnauthorized).JSON(errorResponse)
+	}
+
+	ctx.Locals(authAccountKey, claims)
+	return ctx.Next()
+}
+
+func bearerToken(ctx *fiber.Ctx) string {
+	scheme, token, found := strings.Cut(ctx.Get(fiber.HeaderAuthorization), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+func GetAuthAccount(ctx *fiber.Ctx) *helper.AccessTokenClaims {
+	claims, _ := ctx.Locals(authAccountKey).(*helper.AccessTokenClaims)
+	return claims
+}
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	LevelAdmin   = "admin"
+	LevelStudent = "student"
+)
+
+// Policy reports whether the authenticated account may access the route.
+// A non-nil error response aborts the request with that response instead.
+type Policy func(a *Auth, ctx *fiber.Ctx, account *helper.AccessTokenClaims) (bool, *entity.ErrorResponse)
+
+// Authorize allows the request when any of the given policies allows it.
+func (a *Auth) Authorize(policies ...Policy) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		account := GetAuthAccount(ctx)
+		if account == nil {
+			errorResponse := helper.ErrorResponse(http.StatusUnauthorized, "Missing or malformed access token")
+			return ctx.Status(http.StatusUnauthorized).JSON(errorResponse)
+		}
+
+		for _, policy := range policies {
+			allowed, errorResponse := policy(a, ctx, account)
+			if errorResponse != nil {
+				return ctx.Status(errorResponse.Code).JSON(errorResponse)
+			}
+			if allowed {
+				return ctx.Next()
+			}
+		}
+
+		errorResponse := helper.ErrorResponse(http.StatusForbidden, "You don't have permission to access this resource")
+		return ctx.Status(http.StatusForbidden).JSON(errorResponse)
+	}
+}
+
+func Admin(_ *Auth, _ *fiber.Ctx, account *helper.AccessTokenClaims) (bool, *entity.ErrorResponse) {
+	return account.Level == LevelAdmin, nil
+}
+
+func Student(_ *Auth, _ *fiber.Ctx, account *helper.AccessTokenClaims) (bool, *entity.ErrorResponse) {
+	return account.Level == LevelStudent, nil
+}
+
+// AccountOwner allows the account whose ID is in the given route param.
+func AccountOwner(param string) Policy {
+	return func(_ *Auth, ctx *fiber.Ctx, account *helper.AccessTokenClaims) (bool, *entity.ErrorResponse) {
+		accountID, err := strconv.Atoi(ctx.Params(param))
+		if err != nil {
+			return false, nil
+		}
+		return accountID == account.AccountID, nil
+	}
+}
+
+// StudentOwner allows the account linked to the student whose ID is in the given route param.
+func StudentOwner(param string) Policy {
+	return func(a *Auth, ctx *fiber.Ctx, account *helper.AccessTokenClaims) (bool, *entity.ErrorResponse) {
+		studentID, err := strconv.Atoi(ctx.Params(param))
+		if err != nil {
+			return false, nil
+		}
+
+		student, errorResponse := a.StudentServices.GetStudentByAccountID(ctx.Context(), account.AccountID)
+		if errorResponse != nil {
+			return false, nil
+		}
+
+		return student.ID == studentID, nil
+	}
+}
+
+// TransactionOwner allows the student who made the borrow transaction in the given route param.
+func TransactionOwner(param string) Policy {
+	return func(a *Auth, ctx *fiber.Ctx, account *helper.AccessTokenClaims) (bool, *entity.ErrorResponse) {
+		student, errorResponse := a.StudentServices.GetStudentByAccountID(ctx.Context(), account.AccountID)
+		if errorResponse != nil {
+			return false, nil
+		}
+
+		borrow, errorResponse := a.BorrowServices.GetBorrowByTransactionID(ctx.Context(), ctx.Params(param))
+		if errorResponse != nil {
+			if errorResponse.Code == http.StatusNotFound {
+				return false, nil
+			}
+			return false, errorResponse
+		}
+
+		return borrow.StudentID == student.ID, nil
+	}
+}
@@ -3,7 +3,9 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/dimassfeb-09/smart-library-be/entity"
 	"github.com/dimassfeb-09/smart-library-be/helper"
@@ -13,9 +15,17 @@ type AccountsRepositoryInterface interface {
 	InsertAccount(ctx context.Context, tx *sql.Tx, account *entity.AccountRequest) (accountId int, error *entity.ErrorResponse)
 	UpdateAccount(ctx context.Context, tx *sql.Tx, account *entity.AccountRequest) *entity.ErrorResponse
 	DeleteAccount(ctx context.Context, tx *sql.Tx, accountID int) *entity.ErrorResponse
-	ChangePassword(ctx context.Context, tx *sql.Tx, account *entity.AccountChangePasswordRequest, accountID int) *entity.ErrorResponse
+	UpdatePasswordHash(ctx context.Context, tx *sql.Tx, accountID int, passwordHash string) *entity.ErrorResponse
+	GetAccountPasswordHashByID(ctx context.Context, db *sql.DB, accountID int) (string, *entity.ErrorResponse)
+	GetAccountPasswordHashForUpdate(ctx context.Context, tx *sql.Tx, accountID int) (string, *entity.ErrorResponse)
+	LockAccountByID(ctx context.Context, tx *sql.Tx, accountID int) *entity.ErrorResponse
 	GetAccountByID(ctx context.Context, db *sql.DB, accountID int) (*entity.AccountResponse, *entity.ErrorResponse)
 	GetAccountByEmail(ctx context.Context, db *sql.DB, level string) (*entity.AccountResponse, *entity.ErrorResponse)
+	GetAccountByStudentID(ctx context.Context, db *sql.DB, studentID int) (*entity.AccountResponse, *entity.ErrorResponse)
+	GetAccounts(ctx context.Context, db *sql.DB, filter *entity.AccountFilter) ([]*entity.AccountSummary, *entity.ErrorResponse)
+	GetAccountByName(ctx context.Context, db *sql.DB, name string) ([]*entity.AccountResponse, *entity.ErrorResponse)
+	MarkEmailVerified(ctx context.Context, tx *sql.Tx, accountID int) *entity.ErrorResponse
+	UpdateAccountLevel(ctx context.Context, tx *sql.Tx, accountID int, level string) *entity.ErrorResponse
 }
 
 type AccountsRepository struct{}
@@ -56,8 +66,8 @@ func (*AccountsRepository) DeleteAccount(ctx context.Context, tx *sql.Tx, accoun
 	return nil
 }
 
-func (*AccountsRepository) ChangePassword(ctx context.Context, tx *sql.Tx, account *entity.AccountChangePasswordRequest, accountID int) *entity.ErrorResponse {
-	_, err := tx.ExecContext(ctx, "UPDATE accounts SET password = ? WHERE id = ?", account.Password, account.NewPassword, accountID)
+func (*AccountsRepository) UpdatePasswordHash(ctx context.Context, tx *sql.Tx, accountID int, passwordHash string) *entity.ErrorResponse {
+	_, err := tx.ExecContext(ctx, "UPDATE accounts SET password = ? WHERE id = ?", passwordHash, accountID)
 	if err != nil {
 		return helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
 	}
@@ -66,41 +76,166 @@ func (*AccountsRepository) ChangePassword(ctx context.Context, tx *sql.Tx, accou
 }
 
 func (*AccountsRepository) GetAccountByID(ctx context.Context, db *sql.DB, accountID int) (*entity.AccountResponse, *entity.ErrorResponse) {
-	rows := db.QueryRowContext(ctx, "SELECT id, email, level FROM accounts WHERE id = ?", accountID)
+	rows := db.QueryRowContext(ctx, "SELECT id, email, level, email_verified_at IS NOT NULL FROM accounts WHERE id = ?", accountID)
 
 	var account entity.Account
-	err := rows.Scan(&account.ID, &account.Email, &account.Level)
+	err := rows.Scan(&account.ID, &account.Email, &account.Level, &account.EmailVerified)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, helper.ErrorResponse(http.StatusNotFound, "account not found")
+		}
 		return nil, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
 	}
 
 	var response entity.AccountResponse = entity.AccountResponse{
-		ID:    account.ID,
-		Email: account.Email,
-		Level: account.Level,
+		ID:            account.ID,
+		Email:         account.Email,
+		Level:         account.Level,
+		EmailVerified: account.EmailVerified,
 	}
 
 	return &response, nil
 }
 
 func (*AccountsRepository) GetAccountByEmail(ctx context.Context, db *sql.DB, email string) (*entity.AccountResponse, *entity.ErrorResponse) {
-	rows := db.QueryRowContext(ctx, "SELECT id, email, level FROM accounts WHERE email = ?", email)
+	rows := db.QueryRowContext(ctx, "SELECT id, email, level, email_verified_at IS NOT NULL FROM accounts WHERE email = ?", email)
 
 	var account entity.Account
-	err := rows.Scan(&account.ID, &account.Email, &account.Level)
+	err := rows.Scan(&account.ID, &account.Email, &account.Level, &account.EmailVerified)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, helper.ErrorResponse(http.StatusNotFound, "account not found")
+		}
 		return nil, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
 	}
 
 	var response entity.AccountResponse = entity.AccountResponse{
-		ID:    account.ID,
-		Email: account.Email,
-		Level: account.Level,
+		ID:            account.ID,
+		Email:         account.Email,
+		Level:         account.Level,
+		EmailVerified: account.EmailVerified,
 	}
 
 	return &response, nil
 }
 
+func (*AccountsRepository) GetAccountByStudentID(ctx context.Context, db *sql.DB, studentID int) (*entity.AccountResponse, *entity.ErrorResponse) {
+	row := db.QueryRowContext(ctx, "SELECT a.id, a.email, a.level, a.email_verified_at IS NOT NULL FROM accounts a JOIN students s ON s.account_id = a.id WHERE s.id = ?", studentID)
+
+	var account entity.AccountResponse
+	err := row.Scan(&account.ID, &account.Email, &account.Level, &account.EmailVerified)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, helper.ErrorResponse(http.StatusNotFound, "account not found")
+		}
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return &account, nil
+}
+
+func (*AccountsRepository) MarkEmailVerified(ctx context.Context, tx *sql.Tx, accountID int) *entity.ErrorResponse {
+	_, err := tx.ExecContext(ctx, "UPDATE accounts SET email_verified_at = NOW() WHERE id = ? AND email_verified_at IS NULL", accountID)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return nil
+}
+
+var accountSortColumns = map[string]string{
+	"id":    "a.id",
+	"email": "a.email",
+	"name":  "s.name",
+	"npm":   "s.npm",
+	"level": "a.level",
+}
+
+func (*AccountsRepository) GetAccounts(ctx context.Context, db *sql.DB, filter *entity.AccountFilter) ([]*entity.AccountSummary, *entity.ErrorResponse) {
+	var conditions []string
+	var args []any
+
+	if filter.Search != "" {
+		pattern := "%" + filter.Search + "%"
+		conditions = append(conditions, "(a.email LIKE ? OR s.name LIKE ? OR s.npm LIKE ?)")
+		args = append(args, pattern, pattern, pattern)
+	}
+	if filter.Level != "" {
+		conditions = append(conditions, "a.level = ?")
+		args = append(args, filter.Level)
+	}
+
+	query := "SELECT a.id, a.email, a.level, a.email_verified_at IS NOT NULL, s.name, s.npm FROM accounts a LEFT JOIN students s ON s.account_id = a.id"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	sortColumn, ok := accountSortColumns[filter.SortBy]
+	if !ok {
+		sortColumn = "a.id"
+	}
+	sortOrder := "ASC"
+	if strings.EqualFold(filter.SortOrder, "desc") {
+		sortOrder = "DESC"
+	}
+	query += fmt.Sprintf(" ORDER BY %s %s, a.id %s", sortColumn, sortOrder, sortOrder)
+
+	if filter.Page != 0 && filter.PageSize != 0 {
+		offset := (filter.Page - 1) * filter.PageSize
+		query += fmt.Sprintf(" LIMIT %d OFFSET %d", filter.PageSize, offset)
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to get accounts")
+	}
+	defer rows.Close()
+
+	var accounts []*entity.AccountSummary
+	for rows.Next() {
+		var account entity.AccountSummary
+		var name, npm sql.NullString
+		err := rows.Scan(&account.ID, &account.Email, &account.Level, &account.EmailVerified, &name, &npm)
+		if err != nil {
+			return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to scan account")
+		}
+		account.Name = name.String
+		account.NPM = npm.String
+		accounts = append(accounts, &account)
+	}
+
+	return accounts, nil
+}
+
+func (*AccountsRepository) GetAccountByName(ctx context.Context, db *sql.DB, name string) ([]*entity.AccountResponse, *entity.ErrorResponse) {
+	rows, err := db.QueryContext(ctx, "SELECT a.id, a.email, a.level, a.email_verified_at IS NOT NULL FROM accounts a JOIN students s ON s.account_id = a.id WHERE s.name LIKE ? ORDER BY s.name", "%"+name+"%")
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to get accounts")
+	}
+	defer rows.Close()
+
+	var accounts []*entity.AccountResponse
+	for rows.Next() {
+		var account entity.AccountResponse
+		err := rows.Scan(&account.ID, &account.Email, &account.Level, &account.EmailVerified)
+		if err != nil {
+			return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to scan account")
+		}
+		accounts = append(accounts, &account)
+	}
+
+	return accounts, nil
+}
+
+func (*AccountsRepository) UpdateAccountLevel(ctx context.Context, tx *sql.Tx, accountID int, level string) *entity.ErrorResponse {
+	_, err := tx.ExecContext(ctx, "UPDATE accounts SET level = ? WHERE id = ?", level, accountID)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return nil
+}
+
 func getAccountPasswordHash(ctx context.Context, db *sql.DB, email string) (string, *entity.ErrorResponse) {
 	rows := db.QueryRowContext(ctx, "SELECT password FROM accounts WHERE email = ? LIMIT 1", email)
 
@@ -113,6 +248,52 @@ func getAccountPasswordHash(ctx context.Context, db *sql.DB, email string) (stri
 	return passwordHash, nil
 }
 
+// GetAccountPasswordHashForUpdate reads the password hash and locks the
+// account row until the transaction ends.
+func (*AccountsRepository) GetAccountPasswordHashForUpdate(ctx context.Context, tx *sql.Tx, accountID int) (string, *entity.ErrorResponse) {
+	row := tx.QueryRowContext(ctx, "SELECT password FROM accounts WHERE id = ? FOR UPDATE", accountID)
+
+	var passwordHash string
+	if err := row.Scan(&passwordHash); err != nil {
+		if err == sql.ErrNoRows {
+			return "", helper.ErrorResponse(http.StatusNotFound, "account not found")
+		}
+		return "", helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return passwordHash, nil
+}
+
+// LockAccountByID locks the account row until the transaction ends, so work
+// done per account is serialized.
+func (*AccountsRepository) LockAccountByID(ctx context.Context, tx *sql.Tx, accountID int) *entity.ErrorResponse {
+	row := tx.QueryRowContext(ctx, "SELECT id FROM accounts WHERE id = ? FOR UPDATE", accountID)
+
+	var id int
+	if err := row.Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return helper.ErrorResponse(http.StatusNotFound, "account not found")
+		}
+		return helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return nil
+}
+
 func (*AccountsRepository) GetAccountPasswordHash(ctx context.Context, db *sql.DB, email string) (string, *entity.ErrorResponse) {
 	return getAccountPasswordHash(ctx, db, email)
 }
+
+func (*AccountsRepository) GetAccountPasswordHashByID(ctx context.Context, db *sql.DB, accountID int) (string, *entity.ErrorResponse) {
+	row := db.QueryRowContext(ctx, "SELECT password FROM accounts WHERE id = ?", accountID)
+
+	var passwordHash string
+	if err := row.Scan(&passwordHash); err != nil {
+		if err == sql.ErrNoRows {
+			return "", helper.ErrorResponse(http.StatusNotFound, "account not found")
+		}
+		return "", helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return passwordHash, nil
+}
@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+)
+
+type BookItemRepositoryInterface interface {
+	InsertBookItem(ctx context.Context, tx *sql.Tx, bookID int, item *entity.BookItemRequest) (int, *entity.ErrorResponse)
+	UpdateBookItem(ctx context.Context, tx *sql.Tx, itemID int, item *entity.BookItemRequest) *entity.ErrorResponse
+	DeleteBookItem(ctx context.Context, tx *sql.Tx, itemID int) *entity.ErrorResponse
+	GetBookItemByID(ctx context.Context, db *sql.DB, itemID int) (*entity.BookItem, *entity.ErrorResponse)
+	GetBookItemByCardID(ctx context.Context, db *sql.DB, cardID int) (*entity.BookItem, *entity.ErrorResponse)
+	GetBookItemsByBookID(ctx context.Context, db *sql.DB, bookID int) ([]*entity.BookItem, *entity.ErrorResponse)
+	GetBookItemForUpdate(ctx context.Context, tx *sql.Tx, itemID int) (*entity.BookItem, *entity.ErrorResponse)
+	GetFreeBookItemsForUpdate(ctx context.Context, tx *sql.Tx, bookID int) ([]int, bool, *entity.ErrorResponse)
+	CountBookCopies(ctx context.Context, db *sql.DB, bookID int) (*entity.BookCopies, *entity.ErrorResponse)
+}
+
+type BookItemRepository struct{}
+
+func NewBookItemRepository() *BookItemRepository {
+	return &BookItemRepository{}
+}
+
+// openBorrowOfItem matches the pending or borrowed rows of the item i.
+const openBorrowOfItem = "SELECT 1 FROM borrows b WHERE b.item_id = i.id AND b.status IN ('pending', 'borrowed')"
+
+const bookItemQuery = "SELECT i.id, i.book_id, i.card_id, i.barcode, i.shelf_location, i.`condition`, i.status, EXISTS(" + openBorrowOfItem + "), i.created_at FROM book_items i"
+
+func (*BookItemRepository) InsertBookItem(ctx context.Context, tx *sql.Tx, bookID int, item *entity.BookItemRequest) (int, *entity.ErrorResponse) {
+	result, err := tx.ExecContext(ctx, "INSERT INTO book_items (book_id, card_id, barcode, shelf_location, `condition`, status) VALUES (?, ?, ?, ?, ?, ?)",
+		bookID,
+		nullInt(item.CardID),
+		item.Barcode,
+		item.ShelfLocation,
+		item.Condition,
+		item.Status,
+	)
+	if err != nil {
+		return 0, bookItemWriteError(err, "failed to insert book item")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return int(id), nil
+}
+
+func (*BookItemRepository) UpdateBookItem(ctx context.Context, tx *sql.Tx, itemID int, item *entity.BookItemRequest) *entity.ErrorResponse {
+	_, err := tx.ExecContext(ctx, "UPDATE book_items SET card_id = ?, barcode = ?, shelf_location = ?, `condition` = ?, status = ? WHERE id = ?",
+		nullInt(item.CardID),
+		item.Barcode,
+		item.ShelfLocation,
+		item.Condition,
+		item.Status,
+		itemID,
+	)
+	if err != nil {
+		return bookItemWriteError(err, "failed to update book item")
+	}
+
+	return nil
+}
+
+func (*BookItemRepository) DeleteBookItem(ctx context.Context, tx *sql.Tx, itemID int) *entity.ErrorResponse {
+	_, err := tx.ExecContext(ctx, "DELETE FROM book_items WHERE id = ?", itemID)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "failed to delete book item")
+	}
+
+	return nil
+}
+
+func (*BookItemRepository) GetBookItemByID(ctx context.Context, db *sql.DB, itemID int) (*entity.BookItem, *entity.ErrorResponse) {
+	return scanBookItem(db.QueryRowContext(ctx, bookItemQuery+" WHERE i.id = ?", itemID))
+}
+
+func (*BookItemRepository) GetBookItemByCardID(ctx context.Context, db *sql.DB, cardID int) (*entity.BookItem, *entity.ErrorResponse) {
+	return scanBookItem(db.QueryRowContext(ctx, bookItemQuery+" WHERE i.card_id = ?", cardID))
+}
+
+func (*BookItemRepository) GetBookItemsByBookID(ctx context.Context, db *sql.DB, bookID int) ([]*entity.BookItem, *entity.ErrorResponse) {
+	rows, err := db.QueryContext(ctx, bookItemQuery+" WHERE i.book_id = ? ORDER BY i.id", bookID)
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+	defer rows.Close()
+
+	items := []*entity.BookItem{}
+	for rows.Next() {
+		item, errorResponse := scanBookItem(rows)
+		if errorResponse != nil {
+			return nil, errorResponse
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// GetBookItemForUpdate locks one copy for a borrow that asked for it.
+func (*BookItemRepository) GetBookItemForUpdate(ctx context.Context, tx *sql.Tx, itemID int) (*entity.BookItem, *entity.ErrorResponse) {
+	return scanBookItem(tx.QueryRowContext(ctx, bookItemQuery+" WHERE i.id = ? FOR UPDATE", itemID))
+}
+
+// GetFreeBookItemsForUpdate locks every copy of the book and returns the ones
+// that can be lent. hasItems is false for a book that has no copies
+// registered at all.
+func (*BookItemRepository) GetFreeBookItemsForUpdate(ctx context.Context, tx *sql.Tx, bookID int) (free []int, hasItems bool, errorResponse *entity.ErrorResponse) {
+	rows, err := tx.QueryContext(ctx, "SELECT i.id, i.status = 'available' AND NOT EXISTS("+openBorrowOfItem+") FROM book_items i WHERE i.book_id = ? ORDER BY i.id FOR UPDATE", bookID)
+	if err != nil {
+		return nil, false, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var lendable bool
+		if err := rows.Scan(&id, &lendable); err != nil {
+			return nil, false, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+		}
+		hasItems = true
+		if lendable {
+			free = append(free, id)
+		}
+	}
+
+	return free, hasItems, nil
+}
+
+func (*BookItemRepository) CountBookCopies(ctx context.Context, db *sql.DB, bookID int) (*entity.BookCopies, *entity.ErrorResponse) {
+	var copies entity.BookCopies
+	err := db.QueryRowContext(ctx, "SELECT COUNT(*), COALESCE(SUM(i.status = 'available' AND NOT EXISTS("+openBorrowOfItem+")), 0) FROM book_items i WHERE i.book_id = ?", bookID).Scan(&copies.Total, &copies.Available)
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return &copies, nil
+}
+
+func scanBookItem(row rowScanner) (*entity.BookItem, *entity.ErrorResponse) {
+	var item entity.BookItem
+	var cardID sql.NullInt64
+	err := row.Scan(&item.ID, &item.BookID, &cardID, &item.Barcode, &item.ShelfLocation, &item.Condition, &item.Status, &item.OnLoan, &item.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, helper.ErrorResponse(http.StatusNotFound, "book item not found")
+		}
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to scan book item")
+	}
+	item.CardID = int(cardID.Int64)
+
+	return &item, nil
+}
+
+func bookItemWriteError(err error, message string) *entity.ErrorResponse {
+	switch {
+	case strings.Contains(err.Error(), "Duplicate entry"):
+		return helper.ErrorResponse(http.StatusConflict, "barcode or card is already used by another copy")
+	case strings.Contains(err.Error(), "a foreign key constraint fails"):
+		return helper.ErrorResponse(http.StatusUnprocessableEntity, fmt.Sprintf("%s, data card_id not valid.", message))
+	default:
+		return helper.ErrorResponse(http.StatusInternalServerError, message)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
 
 	"github.com/dimassfeb-09/smart-library-be/entity"
@@ -14,9 +15,13 @@ import (
 type BookRepositoryInterface interface {
 	GetBookByID(ctx context.Context, db *sql.DB, bookID int) (*entity.Book, *entity.ErrorResponse)
 	GetAllBooks(ctx context.Context, db *sql.DB) ([]*entity.Book, *entity.ErrorResponse)
+	GetBooks(ctx context.Context, db *sql.DB, filter *entity.BookFilter) ([]*entity.Book, *entity.ErrorResponse)
+	GetBookWithStockByID(ctx context.Context, db *sql.DB, bookID int) (*entity.Book, *entity.ErrorResponse)
 	GetBookByCardID(ctx context.Context, db *sql.DB, cardID int) (*entity.Book, *entity.ErrorResponse)
 	DeleteBookByID(ctx context.Context, tx *sql.Tx, bookID int) *entity.ErrorResponse
 	DeleteCardIDFromBook(ctx context.Context, tx *sql.Tx, cardID int) *entity.ErrorResponse
+	SetBookCardID(ctx context.Context, tx *sql.Tx, bookID, cardID int) *entity.ErrorResponse
+	LockBooks(ctx context.Context, tx *sql.Tx, bookIDs []int) *entity.ErrorResponse
 	UpdateBook(ctx context.Context, tx *sql.Tx, book *entity.Book) *entity.ErrorResponse
 	InsertBook(ctx context.Context, tx *sql.Tx, book *entity.Book) *entity.ErrorResponse
 }
@@ -27,14 +32,28 @@ func NewBookRepository() *BookRepository {
 	return &BookRepository{}
 }
 
-func (*BookRepository) GetBooks(ctx context.Context, db *sql.DB, page, pageSize int) ([]*entity.Book, *entity.ErrorResponse) {
+// bookStockColumns and bookStockJoins add the copies and open borrow rows of
+// each book to a query on books b, aggregated in one pass rather than per
+// book.
+const bookStockColumns = "COALESCE(copies.total, 0), COALESCE(copies.free, 0), COALESCE(copies.lost, 0), COALESCE(loans.on_loan, 0), loans.next_due, COALESCE(loans.reserved, 0), COALESCE(c.status = 'lost', FALSE)"
 
-	var query string
-	if page != 0 && pageSize != 0 {
-		offset := (page - 1) * pageSize
-		query = fmt.Sprintf("SELECT * FROM books LIMIT %d OFFSET %d", pageSize, offset)
-	} else {
-		query = "SELECT * FROM books"
+const bookStockJoins = " LEFT JOIN card_rfid c ON c.id = b.card_id" +
+	" LEFT JOIN (SELECT i.book_id, COUNT(*) AS total, SUM(i.status = 'available' AND NOT EXISTS(" + openBorrowOfItem + ")) AS free, SUM(i.status = 'lost') AS lost FROM book_items i GROUP BY i.book_id) copies ON copies.book_id = b.id" +
+	" LEFT JOIN (SELECT book_id, SUM(status = 'borrowed') AS on_loan, MIN(IF(status = 'borrowed', due_date, NULL)) AS next_due, SUM(status = 'pending') AS reserved FROM borrows WHERE status IN ('pending', 'borrowed') GROUP BY book_id) loans ON loans.book_id = b.id"
+
+// bookAvailableCondition matches the books that BookServices reports as
+// available: a free copy, or no copies, no open borrow and no lost tag.
+const bookAvailableCondition = "(COALESCE(copies.free, 0) > 0 OR (copies.book_id IS NULL AND loans.book_id IS NULL AND COALESCE(c.status, '') <> 'lost'))"
+
+func (*BookRepository) GetBooks(ctx context.Context, db *sql.DB, filter *entity.BookFilter) ([]*entity.Book, *entity.ErrorResponse) {
+	query := "SELECT b.*, " + bookStockColumns + " FROM books b" + bookStockJoins
+	if filter.Available {
+		query += " WHERE " + bookAvailableCondition
+	}
+	query += " ORDER BY b.id"
+	if filter.Page != 0 && filter.PageSize != 0 {
+		offset := (filter.Page - 1) * filter.PageSize
+		query += fmt.Sprintf(" LIMIT %d OFFSET %d", filter.PageSize, offset)
 	}
 
 	rows, err := db.QueryContext(ctx, query)
@@ -46,26 +65,11 @@ func (*BookRepository) GetBooks(ctx context.Context, db *sql.DB, page, pageSize
 
 	var books []*entity.Book
 	for rows.Next() {
-		var book entity.Book
-		var cardID sql.NullInt64
-		err := rows.Scan(
-			&book.ID,
-			&book.Title,
-			&book.Author,
-			&book.Publisher,
-			&book.PublishedDate,
-			&book.ISBN,
-			&book.Pages,
-			&book.Language,
-			&book.Genre,
-			&book.Description,
-			&cardID,
-		)
-		if err != nil {
-			return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to scan book")
+		book, errorResponse := scanBookWithStock(rows)
+		if errorResponse != nil {
+			return nil, errorResponse
 		}
-		book.CardID = int(cardID.Int64)
-		books = append(books, &book)
+		books = append(books, book)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to read books")
@@ -73,6 +77,57 @@ func (*BookRepository) GetBooks(ctx context.Context, db *sql.DB, page, pageSize
 	return books, nil
 }
 
+func (*BookRepository) GetBookWithStockByID(ctx context.Context, db *sql.DB, bookID int) (*entity.Book, *entity.ErrorResponse) {
+	row := db.QueryRowContext(ctx, "SELECT b.*, "+bookStockColumns+" FROM books b"+bookStockJoins+" WHERE b.id = ?", bookID)
+
+	book, errorResponse := scanBookWithStock(row)
+	if errorResponse != nil {
+		if errorResponse.Code == http.StatusNotFound {
+			return nil, helper.ErrorResponse(http.StatusNotFound, fmt.Sprintf("book id %d not found", bookID))
+		}
+		return nil, errorResponse
+	}
+	return book, nil
+}
+
+func scanBookWithStock(row rowScanner) (*entity.Book, *entity.ErrorResponse) {
+	var book entity.Book
+	var stock entity.BookStock
+	var cardID sql.NullInt64
+	var nextDueDate sql.NullString
+	err := row.Scan(
+		&book.ID,
+		&book.Title,
+		&book.Author,
+		&book.Publisher,
+		&book.PublishedDate,
+		&book.ISBN,
+		&book.Pages,
+		&book.Language,
+		&book.Genre,
+		&book.Description,
+		&cardID,
+		&stock.TotalCopies,
+		&stock.FreeCopies,
+		&stock.LostCopies,
+		&stock.OnLoan,
+		&nextDueDate,
+		&stock.Reserved,
+		&stock.CardLost,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, helper.ErrorResponse(http.StatusNotFound, "book not found")
+		}
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to scan book")
+	}
+	book.CardID = int(cardID.Int64)
+	stock.NextDueDate = nextDueDate.String
+	book.Stock = &stock
+
+	return &book, nil
+}
+
 func (*BookRepository) GetBookByID(ctx context.Context, db *sql.DB, bookID int) (*entity.Book, *entity.ErrorResponse) {
 	result := db.QueryRowContext(ctx, "SELECT * FROM books WHERE id = ?", bookID)
 
@@ -119,6 +174,34 @@ func (*BookRepository) DeleteCardIDFromBook(ctx context.Context, tx *sql.Tx, car
 	return nil
 }
 
+func (*BookRepository) SetBookCardID(ctx context.Context, tx *sql.Tx, bookID, cardID int) *entity.ErrorResponse {
+	_, err := tx.ExecContext(ctx, "UPDATE books SET card_id = ? WHERE id = ?", cardID, bookID)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "failed to set book card")
+	}
+	return nil
+}
+
+// LockBooks locks the rows of the books in id order, so transactions that
+// lock overlapping sets of books wait for each other instead of deadlocking.
+func (*BookRepository) LockBooks(ctx context.Context, tx *sql.Tx, bookIDs []int) *entity.ErrorResponse {
+	ids := append([]int(nil), bookIDs...)
+	sort.Ints(ids)
+
+	for _, bookID := range ids {
+		var id int
+		err := tx.QueryRowContext(ctx, "SELECT id FROM books WHERE id = ? FOR UPDATE", bookID).Scan(&id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return helper.ErrorResponse(http.StatusNotFound, fmt.Sprintf("book id %d not found", bookID))
+			}
+			return helper.ErrorResponse(http.StatusInternalServerError, "failed to lock book")
+		}
+	}
+
+	return nil
+}
+
 func (*BookRepository) UpdateBook(ctx context.Context, tx *sql.Tx, book *entity.Book) *entity.ErrorResponse {
 	_, err := tx.ExecContext(ctx, "UPDATE books SET title=?, author=?, publisher=?, published_date=?, isbn=?, pages=?, language=?, genre=?, description=?, card_id=? WHERE id=?",
 		book.Title,
@@ -139,8 +222,10 @@ func (*BookRepository) UpdateBook(ctx context.Context, tx *sql.Tx, book *entity.
 	return nil
 }
 
+// GetBookByCardID finds the book a tag belongs to, whether the tag is on the
+// book itself or on one of its copies.
 func (*BookRepository) GetBookByCardID(ctx context.Context, db *sql.DB, cardID int) (*entity.Book, *entity.ErrorResponse) {
-	result := db.QueryRowContext(ctx, "SELECT * FROM books WHERE card_id = ?", cardID)
+	result := db.QueryRowContext(ctx, "SELECT * FROM books WHERE card_id = ? OR id = (SELECT book_id FROM book_items WHERE card_id = ?) LIMIT 1", cardID, cardID)
 
 	var book entity.Book
 	var cardId sql.NullInt64
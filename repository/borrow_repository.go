@@ -17,6 +17,11 @@ type BorrowRepositoryInterface interface {
 	GetBorrowByBookID(ctx context.Context, db *sql.DB, bookID int) ([]*entity.Borrow, *entity.ErrorResponse)
 	InsertBorrow(ctx context.Context, tx *sql.Tx, borrow *entity.Borrow) *entity.ErrorResponse
 	UpdateBorrow(ctx context.Context, tx *sql.Tx, borrow *entity.BorrowUpdate) *entity.ErrorResponse
+	GetOpenBorrowForUpdate(ctx context.Context, tx *sql.Tx, bookID, itemID int) (*entity.Borrow, *entity.ErrorResponse)
+	MarkBorrowReturned(ctx context.Context, tx *sql.Tx, borrow *entity.Borrow, returnedAt time.Time) *entity.ErrorResponse
+	CountUnreturnedBorrows(ctx context.Context, tx *sql.Tx, transactionID string) (int, *entity.ErrorResponse)
+	CountOpenBorrowsByBookID(ctx context.Context, tx *sql.Tx, bookID int) (int, *entity.ErrorResponse)
+	CountConflictingBorrows(ctx context.Context, tx *sql.Tx, transactionID string, bookID int) (int, *entity.ErrorResponse)
 }
 
 type BorrowRepository struct{}
@@ -112,7 +117,7 @@ func (*BorrowRepository) GetBorrowByTransactionID(ctx context.Context, db *sql.D
 }
 
 func (*BorrowRepository) GetBorrowByBookID(ctx context.Context, db *sql.DB, bookID int) ([]*entity.Borrow, *entity.ErrorResponse) {
-	rows, err := db.QueryContext(ctx, "SELECT transaction_id, borrow_date, due_date, return_date, status, student_id FROM borrows WHERE book_id = ?", bookID)
+	rows, err := db.QueryContext(ctx, "SELECT transaction_id, item_id, borrow_date, due_date, return_date, status, student_id FROM borrows WHERE book_id = ?", bookID)
 	if err != nil {
 		return nil, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
 	}
@@ -121,11 +126,14 @@ func (*BorrowRepository) GetBorrowByBookID(ctx context.Context, db *sql.DB, book
 	var borrows []*entity.Borrow
 	for rows.Next() {
 		var borrow entity.Borrow
+		var itemID sql.NullInt64
 		var returnDate sql.NullString
-		err := rows.Scan(&borrow.TransactionID, &borrow.BorrowDate, &borrow.DueDate, &returnDate, &borrow.Status, &borrow.StudentID)
+		err := rows.Scan(&borrow.TransactionID, &itemID, &borrow.BorrowDate, &borrow.DueDate, &returnDate, &borrow.Status, &borrow.StudentID)
 		if err != nil {
 			return nil, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
 		}
+		borrow.BookID = bookID
+		borrow.ItemID = int(itemID.Int64)
 		borrow.ReturnDate = returnDate.String
 		borrows = append(borrows, &borrow)
 	}
@@ -186,13 +194,25 @@ func (*BorrowRepository) InsertBorrow(ctx context.Context, tx *sql.Tx, borrow *e
 	borrowDate := time.Now()
 	dueDate := time.Now().AddDate(0, 0, 7)
 
-	for _, bookID := range borrow.BookIDS {
-		_, err := tx.ExecContext(ctx, "INSERT INTO borrows (student_id, transaction_id, book_id, borrow_date, due_date) VALUES (?, ?, ?, ?, ?)",
+	status := borrow.Status
+	if status == "" {
+		status = "pending"
+	}
+
+	for i, bookID := range borrow.BookIDS {
+		var itemID int
+		if i < len(borrow.ItemIDS) {
+			itemID = borrow.ItemIDS[i]
+		}
+
+		_, err := tx.ExecContext(ctx, "INSERT INTO borrows (student_id, transaction_id, book_id, item_id, borrow_date, due_date, status) VALUES (?, ?, ?, ?, ?, ?, ?)",
 			borrow.StudentID,
 			borrow.TransactionID,
 			bookID,
+			nullInt(itemID),
 			borrowDate,
 			dueDate,
+			status,
 		)
 		if err != nil {
 			return helper.ErrorResponse(http.StatusInternalServerError, "failed to insert borrow")
@@ -221,3 +241,77 @@ func (*BorrowRepository) UpdateBorrow(ctx context.Context, tx *sql.Tx, borrow *e
 
 	return nil
 }
+
+// GetOpenBorrowForUpdate locks the borrowed row of a book, so two scans of
+// the same book cannot both return it. With an itemID, only the row of that
+// copy is considered.
+func (*BorrowRepository) GetOpenBorrowForUpdate(ctx context.Context, tx *sql.Tx, bookID, itemID int) (*entity.Borrow, *entity.ErrorResponse) {
+	query := "SELECT transaction_id, student_id, book_id, item_id, borrow_date, due_date, status FROM borrows WHERE book_id = ? AND status = 'borrowed'"
+	params := []any{bookID}
+	if itemID != 0 {
+		query += " AND item_id = ?"
+		params = append(params, itemID)
+	}
+	query += " ORDER BY borrow_date DESC LIMIT 1 FOR UPDATE"
+
+	var borrow entity.Borrow
+	var borrowItemID sql.NullInt64
+	err := tx.QueryRowContext(ctx, query, params...).Scan(&borrow.TransactionID, &borrow.StudentID, &borrow.BookID, &borrowItemID, &borrow.BorrowDate, &borrow.DueDate, &borrow.Status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, helper.ErrorResponse(http.StatusNotFound, "book has no open borrow")
+		}
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+	borrow.ItemID = int(borrowItemID.Int64)
+
+	return &borrow, nil
+}
+
+func (*BorrowRepository) MarkBorrowReturned(ctx context.Context, tx *sql.Tx, borrow *entity.Borrow, returnedAt time.Time) *entity.ErrorResponse {
+	_, err := tx.ExecContext(ctx, "UPDATE borrows SET status = 'returned', return_date = ? WHERE transaction_id = ? AND book_id = ? AND IFNULL(item_id, 0) = ? AND status = 'borrowed'", returnedAt, borrow.TransactionID, borrow.BookID, borrow.ItemID)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return nil
+}
+
+func (*BorrowRepository) CountUnreturnedBorrows(ctx context.Context, tx *sql.Tx, transactionID string) (int, *entity.ErrorResponse) {
+	var count int
+	err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM borrows WHERE transaction_id = ? AND status <> 'returned'", transactionID).Scan(&count)
+	if err != nil {
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return count, nil
+}
+
+// CountOpenBorrowsByBookID counts the pending and borrowed rows of a book.
+func (*BorrowRepository) CountOpenBorrowsByBookID(ctx context.Context, tx *sql.Tx, bookID int) (int, *entity.ErrorResponse) {
+	var count int
+	err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM borrows WHERE book_id = ? AND status IN ('pending', 'borrowed')", bookID).Scan(&count)
+	if err != nil {
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return count, nil
+}
+
+// CountConflictingBorrows counts the pending and borrowed rows of other
+// transactions that hold the same book, or the same copy of it, as the row
+// of transactionID.
+func (*BorrowRepository) CountConflictingBorrows(ctx context.Context, tx *sql.Tx, transactionID string, bookID int) (int, *entity.ErrorResponse) {
+	var count int
+	err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM borrows WHERE book_id = ? AND transaction_id <> ? AND status IN ('pending', 'borrowed') AND IFNULL(item_id, 0) = (SELECT IFNULL(MAX(item_id), 0) FROM borrows WHERE transaction_id = ? AND book_id = ?)",
+		bookID,
+		transactionID,
+		transactionID,
+		bookID,
+	).Scan(&count)
+	if err != nil {
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return count, nil
+}
@@ -18,8 +18,15 @@ type CardRepositoryInterface interface {
 	InsertCard(ctx context.Context, db *sql.DB, rfid *entity.Card) (int, *entity.ErrorResponse)
 	UpdateCard(ctx context.Context, db *sql.DB, rfid *entity.Card) *entity.ErrorResponse
 	DeleteCard(ctx context.Context, db *sql.DB, id int) *entity.ErrorResponse
-	InsertContainerCard(ctx context.Context, tx *sql.Tx, uid string) *entity.ErrorResponse
-	GetOnceContainerCard(ctx context.Context, tx *sql.Tx) (string, *entity.ErrorResponse)
+	GetCardByIDForUpdate(ctx context.Context, tx *sql.Tx, id int) (*entity.Card, *entity.ErrorResponse)
+	GetCardByUIDTx(ctx context.Context, tx *sql.Tx, uid string) (*entity.Card, *entity.ErrorResponse)
+	InsertCardTx(ctx context.Context, tx *sql.Tx, card *entity.Card) (int, *entity.ErrorResponse)
+	UpdateCardStatus(ctx context.Context, tx *sql.Tx, id int, status string) *entity.ErrorResponse
+	UpdateCardUID(ctx context.Context, tx *sql.Tx, id int, uid string) *entity.ErrorResponse
+	GetCardsForUpdate(ctx context.Context, tx *sql.Tx) ([]*entity.Card, *entity.ErrorResponse)
+	MoveCardLinks(ctx context.Context, tx *sql.Tx, fromCardID, toCardID int) *entity.ErrorResponse
+	InsertCardStatusHistory(ctx context.Context, tx *sql.Tx, history *entity.CardStatusHistory) *entity.ErrorResponse
+	GetCardStatusHistory(ctx context.Context, db *sql.DB, cardID int) ([]*entity.CardStatusHistory, *entity.ErrorResponse)
 }
 
 type CardRepository struct{}
@@ -33,9 +40,9 @@ func (r *CardRepository) GetCards(ctx context.Context, db *sql.DB, page, pageSiz
 	var query string
 	if page != 0 && pageSize != 0 {
 		offset := (page - 1) * pageSize
-		query = fmt.Sprintf("SELECT * FROM card_rfid LIMIT %d OFFSET %d", pageSize, offset)
+		query = fmt.Sprintf("SELECT id, uid, type, status FROM card_rfid LIMIT %d OFFSET %d", pageSize, offset)
 	} else {
-		query = "SELECT * FROM card_rfid"
+		query = "SELECT id, uid, type, status FROM card_rfid"
 	}
 
 	rows, err := db.QueryContext(ctx, query)
@@ -47,7 +54,7 @@ func (r *CardRepository) GetCards(ctx context.Context, db *sql.DB, page, pageSiz
 	var cards []*entity.Card
 	for rows.Next() {
 		var card entity.Card
-		err := rows.Scan(&card.ID, &card.UID, &card.Type)
+		err := rows.Scan(&card.ID, &card.UID, &card.Type, &card.Status)
 		if err != nil {
 			return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to scan card")
 		}
@@ -58,10 +65,10 @@ func (r *CardRepository) GetCards(ctx context.Context, db *sql.DB, page, pageSiz
 }
 
 func (r *CardRepository) GetCardByID(ctx context.Context, db *sql.DB, id int) (*entity.Card, *entity.ErrorResponse) {
-	row := db.QueryRowContext(ctx, "SELECT * FROM card_rfid WHERE id = ?", id)
+	row := db.QueryRowContext(ctx, "SELECT id, uid, type, status FROM card_rfid WHERE id = ?", id)
 
 	var rfid entity.Card
-	err := row.Scan(&rfid.ID, &rfid.UID, &rfid.Type)
+	err := row.Scan(&rfid.ID, &rfid.UID, &rfid.Type, &rfid.Status)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, helper.ErrorResponse(http.StatusNotFound, "card not found")
@@ -72,11 +79,13 @@ func (r *CardRepository) GetCardByID(ctx context.Context, db *sql.DB, id int) (*
 	return &rfid, nil
 }
 
+// GetCardByUID prefers the active card should a uid still be shared, as it
+// can be in a database that has not been through normalize-uids yet.
 func (r *CardRepository) GetCardByUID(ctx context.Context, db *sql.DB, uid string) (*entity.Card, *entity.ErrorResponse) {
-	row := db.QueryRowContext(ctx, "SELECT * FROM card_rfid WHERE uid = ?", uid)
+	row := db.QueryRowContext(ctx, "SELECT id, uid, type, status FROM card_rfid WHERE uid = ? ORDER BY status = 'active' DESC, id LIMIT 1", uid)
 
 	var rfid entity.Card
-	err := row.Scan(&rfid.ID, &rfid.UID, &rfid.Type)
+	err := row.Scan(&rfid.ID, &rfid.UID, &rfid.Type, &rfid.Status)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, helper.ErrorResponse(http.StatusNotFound, "card not found")
@@ -119,31 +128,153 @@ func (r *CardRepository) DeleteCard(ctx context.Context, db *sql.DB, id int) *en
 
 	return nil
 }
-func (r *CardRepository) InsertContainerCard(ctx context.Context, tx *sql.Tx, uid string) *entity.ErrorResponse {
-	_, err := tx.ExecContext(ctx, "INSERT INTO card_container(uid) VALUES(?)", uid)
+
+func (r *CardRepository) GetCardByIDForUpdate(ctx context.Context, tx *sql.Tx, id int) (*entity.Card, *entity.ErrorResponse) {
+	row := tx.QueryRowContext(ctx, "SELECT id, uid, type, status FROM card_rfid WHERE id = ? FOR UPDATE", id)
+
+	var rfid entity.Card
+	err := row.Scan(&rfid.ID, &rfid.UID, &rfid.Type, &rfid.Status)
 	if err != nil {
-		return helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+		if err == sql.ErrNoRows {
+			return nil, helper.ErrorResponse(http.StatusNotFound, "card not found")
+		}
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to scan card")
 	}
 
-	return nil
+	return &rfid, nil
 }
 
-func (r *CardRepository) GetOnceContainerCard(ctx context.Context, tx *sql.Tx) (string, *entity.ErrorResponse) {
-	row := tx.QueryRowContext(ctx, "SELECT uid FROM card_container")
+func (r *CardRepository) GetCardByUIDTx(ctx context.Context, tx *sql.Tx, uid string) (*entity.Card, *entity.ErrorResponse) {
+	row := tx.QueryRowContext(ctx, "SELECT id, uid, type, status FROM card_rfid WHERE uid = ? LIMIT 1", uid)
 
-	var uid string
-	err := row.Scan(&uid)
+	var card entity.Card
+	err := row.Scan(&card.ID, &card.UID, &card.Type, &card.Status)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return "", helper.ErrorResponse(http.StatusNotFound, "Card UID container no data")
+			return nil, helper.ErrorResponse(http.StatusNotFound, "card not found")
+		}
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to scan card")
+	}
+
+	return &card, nil
+}
+
+func (r *CardRepository) InsertCardTx(ctx context.Context, tx *sql.Tx, card *entity.Card) (int, *entity.ErrorResponse) {
+	result, err := tx.ExecContext(ctx, "INSERT INTO card_rfid (uid, type) VALUES (?, ?)", card.UID, card.Type)
+	if err != nil {
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "failed to insert card")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return int(id), nil
+}
+
+func (r *CardRepository) UpdateCardStatus(ctx context.Context, tx *sql.Tx, id int, status string) *entity.ErrorResponse {
+	_, err := tx.ExecContext(ctx, "UPDATE card_rfid SET status = ? WHERE id = ?", status, id)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "failed to update card status")
+	}
+
+	return nil
+}
+
+func (r *CardRepository) UpdateCardUID(ctx context.Context, tx *sql.Tx, id int, uid string) *entity.ErrorResponse {
+	_, err := tx.ExecContext(ctx, "UPDATE card_rfid SET uid = ? WHERE id = ?", uid, id)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "failed to update card uid")
+	}
+
+	return nil
+}
+
+func (r *CardRepository) GetCardsForUpdate(ctx context.Context, tx *sql.Tx) ([]*entity.Card, *entity.ErrorResponse) {
+	rows, err := tx.QueryContext(ctx, "SELECT id, uid, type, status FROM card_rfid ORDER BY id FOR UPDATE")
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to get cards")
+	}
+	defer rows.Close()
+
+	var cards []*entity.Card
+	for rows.Next() {
+		var card entity.Card
+		var uid, cardType sql.NullString
+		if err := rows.Scan(&card.ID, &uid, &cardType, &card.Status); err != nil {
+			return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to scan card")
 		}
-		return "", helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+		card.UID = uid.String
+		card.Type = cardType.String
+		cards = append(cards, &card)
+	}
+
+	return cards, nil
+}
+
+// MoveCardLinks points whatever student, book or book copy used fromCardID
+// at toCardID.
+func (r *CardRepository) MoveCardLinks(ctx context.Context, tx *sql.Tx, fromCardID, toCardID int) *entity.ErrorResponse {
+	_, err := tx.ExecContext(ctx, "UPDATE students SET card_id = ? WHERE card_id = ?", toCardID, fromCardID)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "failed to move student card")
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE books SET card_id = ? WHERE card_id = ?", toCardID, fromCardID)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "failed to move book card")
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE book_items SET card_id = ? WHERE card_id = ?", toCardID, fromCardID)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "failed to move book item card")
+	}
+
+	return nil
+}
+
+func (r *CardRepository) InsertCardStatusHistory(ctx context.Context, tx *sql.Tx, history *entity.CardStatusHistory) *entity.ErrorResponse {
+	var accountID sql.NullInt64
+	if history.AccountID > 0 {
+		accountID = sql.NullInt64{Int64: int64(history.AccountID), Valid: true}
 	}
 
-	_, err = tx.ExecContext(ctx, "DELETE FROM card_container WHERE uid = ?", uid)
+	_, err := tx.ExecContext(ctx, "INSERT INTO card_status_history (card_id, from_status, to_status, reason, account_id) VALUES (?, ?, ?, ?, ?)",
+		history.CardID,
+		sql.NullString{String: history.FromStatus, Valid: history.FromStatus != ""},
+		history.ToStatus,
+		sql.NullString{String: history.Reason, Valid: history.Reason != ""},
+		accountID,
+	)
 	if err != nil {
-		return "", helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+		return helper.ErrorResponse(http.StatusInternalServerError, "failed to insert card status history")
+	}
+
+	return nil
+}
+
+func (r *CardRepository) GetCardStatusHistory(ctx context.Context, db *sql.DB, cardID int) ([]*entity.CardStatusHistory, *entity.ErrorResponse) {
+	rows, err := db.QueryContext(ctx, "SELECT id, card_id, from_status, to_status, reason, account_id, created_at FROM card_status_history WHERE card_id = ? ORDER BY id DESC", cardID)
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to get card status history")
+	}
+	defer rows.Close()
+
+	var histories []*entity.CardStatusHistory
+	for rows.Next() {
+		var history entity.CardStatusHistory
+		var fromStatus, reason sql.NullString
+		var accountID sql.NullInt64
+		err := rows.Scan(&history.ID, &history.CardID, &fromStatus, &history.ToStatus, &reason, &accountID, &history.CreatedAt)
+		if err != nil {
+			return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to scan card status history")
+		}
+		history.FromStatus = fromStatus.String
+		history.Reason = reason.String
+		history.AccountID = int(accountID.Int64)
+		histories = append(histories, &history)
 	}
 
-	return uid, nil
+	return histories, nil
 }
@@ -0,0 +1,350 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+)
+
+type DeviceRepositoryInterface interface {
+	InsertDevice(ctx context.Context, tx *sql.Tx, device *entity.Device) (int, *entity.ErrorResponse)
+	GetDevices(ctx context.Context, db *sql.DB) ([]*entity.Device, *entity.ErrorResponse)
+	GetDeviceByID(ctx context.Context, db *sql.DB, deviceID int) (*entity.Device, *entity.ErrorResponse)
+	GetDeviceByName(ctx context.Context, db *sql.DB, name string) (*entity.Device, *entity.ErrorResponse)
+	UpdateDevice(ctx context.Context, tx *sql.Tx, device *entity.Device) *entity.ErrorResponse
+	DeleteDevice(ctx context.Context, tx *sql.Tx, deviceID int) *entity.ErrorResponse
+	InsertDeviceAPIKey(ctx context.Context, tx *sql.Tx, deviceID int, prefix, keyHash string, scopes []string, signingSecret string) (int, *entity.ErrorResponse)
+	GetDeviceAPIKeys(ctx context.Context, db *sql.DB, deviceID int) ([]*entity.DeviceAPIKey, *entity.ErrorResponse)
+	RevokeDeviceAPIKey(ctx context.Context, tx *sql.Tx, deviceID, keyID int) (bool, *entity.ErrorResponse)
+	GetAuthDeviceByKeyHash(ctx context.Context, db *sql.DB, keyHash string) (*entity.AuthDevice, *entity.ErrorResponse)
+	InsertDeviceCall(ctx context.Context, db *sql.DB, call *entity.DeviceCall) *entity.ErrorResponse
+	GetDeviceCalls(ctx context.Context, db *sql.DB, deviceID, page, pageSize int) ([]*entity.DeviceCall, *entity.ErrorResponse)
+	UpdateDeviceHeartbeat(ctx context.Context, db *sql.DB, deviceID int, heartbeat *entity.DeviceHeartbeat) *entity.ErrorResponse
+	GetDevicesHealth(ctx context.Context, db *sql.DB) ([]*entity.DeviceHealth, *entity.ErrorResponse)
+	GetUnalertedOfflineDevices(ctx context.Context, db *sql.DB, timeout time.Duration) ([]*entity.DeviceHealth, *entity.ErrorResponse)
+	MarkDeviceHealthAlerted(ctx context.Context, db *sql.DB, deviceID int) (bool, *entity.ErrorResponse)
+}
+
+type DeviceRepository struct{}
+
+func NewDeviceRepository() *DeviceRepository {
+	return &DeviceRepository{}
+}
+
+func (*DeviceRepository) InsertDevice(ctx context.Context, tx *sql.Tx, device *entity.Device) (int, *entity.ErrorResponse) {
+	result, err := tx.ExecContext(ctx, "INSERT INTO devices (name, location, uid_format) VALUES (?, ?, ?)", device.Name, device.Location, device.UIDFormat)
+	if err != nil {
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "failed to insert device")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return int(id), nil
+}
+
+func (*DeviceRepository) GetDevices(ctx context.Context, db *sql.DB) ([]*entity.Device, *entity.ErrorResponse) {
+	rows, err := db.QueryContext(ctx, "SELECT id, name, location, uid_format, created_at, last_seen_at FROM devices ORDER BY name")
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to get devices")
+	}
+	defer rows.Close()
+
+	var devices []*entity.Device
+	for rows.Next() {
+		device, errorResponse := scanDevice(rows)
+		if errorResponse != nil {
+			return nil, errorResponse
+		}
+		devices = append(devices, device)
+	}
+
+	return devices, nil
+}
+
+func (*DeviceRepository) GetDeviceByID(ctx context.Context, db *sql.DB, deviceID int) (*entity.Device, *entity.ErrorResponse) {
+	row := db.QueryRowContext(ctx, "SELECT id, name, location, uid_format, created_at, last_seen_at FROM devices WHERE id = ?", deviceID)
+
+	device, errorResponse := scanDevice(row)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	return device, nil
+}
+
+func (*DeviceRepository) GetDeviceByName(ctx context.Context, db *sql.DB, name string) (*entity.Device, *entity.ErrorResponse) {
+	row := db.QueryRowContext(ctx, "SELECT id, name, location, uid_format, created_at, last_seen_at FROM devices WHERE name = ?", name)
+
+	device, errorResponse := scanDevice(row)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	return device, nil
+}
+
+func (*DeviceRepository) UpdateDevice(ctx context.Context, tx *sql.Tx, device *entity.Device) *entity.ErrorResponse {
+	_, err := tx.ExecContext(ctx, "UPDATE devices SET name = ?, location = ?, uid_format = ? WHERE id = ?", device.Name, device.Location, device.UIDFormat, device.ID)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "failed to update device")
+	}
+
+	return nil
+}
+
+func (*DeviceRepository) DeleteDevice(ctx context.Context, tx *sql.Tx, deviceID int) *entity.ErrorResponse {
+	_, err := tx.ExecContext(ctx, "DELETE FROM devices WHERE id = ?", deviceID)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "failed to delete device")
+	}
+
+	return nil
+}
+
+func (*DeviceRepository) InsertDeviceAPIKey(ctx context.Context, tx *sql.Tx, deviceID int, prefix, keyHash string, scopes []string, signingSecret string) (int, *entity.ErrorResponse) {
+	result, err := tx.ExecContext(ctx, "INSERT INTO device_api_keys (device_id, key_prefix, key_hash, scopes, signing_secret) VALUES (?, ?, ?, ?, ?)", deviceID, prefix, keyHash, strings.Join(scopes, ","), signingSecret)
+	if err != nil {
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "failed to insert device api key")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return int(id), nil
+}
+
+func (*DeviceRepository) GetDeviceAPIKeys(ctx context.Context, db *sql.DB, deviceID int) ([]*entity.DeviceAPIKey, *entity.ErrorResponse) {
+	rows, err := db.QueryContext(ctx, "SELECT id, device_id, key_prefix, scopes, created_at, last_used_at, revoked_at FROM device_api_keys WHERE device_id = ? ORDER BY id DESC", deviceID)
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to get device api keys")
+	}
+	defer rows.Close()
+
+	var keys []*entity.DeviceAPIKey
+	for rows.Next() {
+		var key entity.DeviceAPIKey
+		var scopes string
+		var lastUsedAt, revokedAt sql.NullString
+		err := rows.Scan(&key.ID, &key.DeviceID, &key.Prefix, &scopes, &key.CreatedAt, &lastUsedAt, &revokedAt)
+		if err != nil {
+			return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to scan device api key")
+		}
+		key.Scopes = strings.Split(scopes, ",")
+		key.LastUsedAt = lastUsedAt.String
+		key.RevokedAt = revokedAt.String
+		keys = append(keys, &key)
+	}
+
+	return keys, nil
+}
+
+func (*DeviceRepository) RevokeDeviceAPIKey(ctx context.Context, tx *sql.Tx, deviceID, keyID int) (bool, *entity.ErrorResponse) {
+	result, err := tx.ExecContext(ctx, "UPDATE device_api_keys SET revoked_at = NOW() WHERE id = ? AND device_id = ? AND revoked_at IS NULL", keyID, deviceID)
+	if err != nil {
+		return false, helper.ErrorResponse(http.StatusInternalServerError, "failed to revoke device api key")
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return affected == 1, nil
+}
+
+func (*DeviceRepository) GetAuthDeviceByKeyHash(ctx context.Context, db *sql.DB, keyHash string) (*entity.AuthDevice, *entity.ErrorResponse) {
+	row := db.QueryRowContext(ctx, "SELECT d.id, k.id, d.name, d.uid_format, k.scopes, k.signing_secret FROM device_api_keys k JOIN devices d ON d.id = k.device_id WHERE k.key_hash = ? AND k.revoked_at IS NULL", keyHash)
+
+	var device entity.AuthDevice
+	var scopes string
+	var signingSecret sql.NullString
+	if err := row.Scan(&device.DeviceID, &device.APIKeyID, &device.Name, &device.UIDFormat, &scopes, &signingSecret); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, helper.ErrorResponse(http.StatusNotFound, "device api key not found")
+		}
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+	device.Scopes = strings.Split(scopes, ",")
+	device.SigningSecret = signingSecret.String
+
+	return &device, nil
+}
+
+// InsertDeviceCall logs the call and bumps the last-use timestamps of the key
+// and the device in one round of statements.
+func (*DeviceRepository) InsertDeviceCall(ctx context.Context, db *sql.DB, call *entity.DeviceCall) *entity.ErrorResponse {
+	_, err := db.ExecContext(ctx, "INSERT INTO device_calls (device_id, api_key_id, method, path, status_code, ip_address) VALUES (?, ?, ?, ?, ?, ?)",
+		call.DeviceID,
+		call.APIKeyID,
+		call.Method,
+		call.Path,
+		call.StatusCode,
+		call.IPAddress,
+	)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "failed to insert device call")
+	}
+
+	_, err = db.ExecContext(ctx, "UPDATE device_api_keys SET last_used_at = NOW() WHERE id = ?", call.APIKeyID)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	_, err = db.ExecContext(ctx, "UPDATE devices SET last_seen_at = NOW() WHERE id = ?", call.DeviceID)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return nil
+}
+
+func (*DeviceRepository) GetDeviceCalls(ctx context.Context, db *sql.DB, deviceID, page, pageSize int) ([]*entity.DeviceCall, *entity.ErrorResponse) {
+	query := "SELECT id, device_id, api_key_id, method, path, status_code, ip_address, created_at FROM device_calls WHERE device_id = ? ORDER BY created_at DESC, id DESC"
+	if page != 0 && pageSize != 0 {
+		offset := (page - 1) * pageSize
+		query += fmt.Sprintf(" LIMIT %d OFFSET %d", pageSize, offset)
+	}
+
+	rows, err := db.QueryContext(ctx, query, deviceID)
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to get device calls")
+	}
+	defer rows.Close()
+
+	var calls []*entity.DeviceCall
+	for rows.Next() {
+		var call entity.DeviceCall
+		var ipAddress sql.NullString
+		err := rows.Scan(&call.ID, &call.DeviceID, &call.APIKeyID, &call.Method, &call.Path, &call.StatusCode, &ipAddress, &call.CreatedAt)
+		if err != nil {
+			return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to scan device call")
+		}
+		call.IPAddress = ipAddress.String
+		calls = append(calls, &call)
+	}
+
+	return calls, nil
+}
+
+// UpdateDeviceHeartbeat stores what the reader reported and clears any
+// offline alert, so the next outage alerts again.
+func (*DeviceRepository) UpdateDeviceHeartbeat(ctx context.Context, db *sql.DB, deviceID int, heartbeat *entity.DeviceHeartbeat) *entity.ErrorResponse {
+	var signalStrength sql.NullInt64
+	if heartbeat.SignalStrength != nil {
+		signalStrength = sql.NullInt64{Int64: int64(*heartbeat.SignalStrength), Valid: true}
+	}
+
+	_, err := db.ExecContext(ctx, "UPDATE devices SET firmware_version = ?, ip_address = ?, uptime_seconds = ?, signal_strength = ?, last_heartbeat_at = NOW(), health_alerted_at = NULL WHERE id = ?",
+		sql.NullString{String: heartbeat.FirmwareVersion, Valid: heartbeat.FirmwareVersion != ""},
+		sql.NullString{String: heartbeat.IPAddress, Valid: heartbeat.IPAddress != ""},
+		heartbeat.UptimeSeconds,
+		signalStrength,
+		deviceID,
+	)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "failed to update device heartbeat")
+	}
+
+	return nil
+}
+
+const deviceHealthColumns = "id, name, location, firmware_version, ip_address, uptime_seconds, signal_strength, last_heartbeat_at, TIMESTAMPDIFF(SECOND, last_heartbeat_at, NOW()), health_alerted_at"
+
+func (*DeviceRepository) GetDevicesHealth(ctx context.Context, db *sql.DB) ([]*entity.DeviceHealth, *entity.ErrorResponse) {
+	rows, err := db.QueryContext(ctx, "SELECT "+deviceHealthColumns+" FROM devices ORDER BY name")
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to get device health")
+	}
+	defer rows.Close()
+
+	return scanDevicesHealth(rows)
+}
+
+// GetUnalertedOfflineDevices lists readers whose last heartbeat is older than
+// timeout and that have not been alerted about yet. Readers that never sent a
+// heartbeat are left out.
+func (*DeviceRepository) GetUnalertedOfflineDevices(ctx context.Context, db *sql.DB, timeout time.Duration) ([]*entity.DeviceHealth, *entity.ErrorResponse) {
+	rows, err := db.QueryContext(ctx, "SELECT "+deviceHealthColumns+" FROM devices WHERE last_heartbeat_at < DATE_SUB(NOW(), INTERVAL ? SECOND) AND health_alerted_at IS NULL ORDER BY last_heartbeat_at", int(timeout.Seconds()))
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to get offline devices")
+	}
+	defer rows.Close()
+
+	return scanDevicesHealth(rows)
+}
+
+// MarkDeviceHealthAlerted claims the alert for the device. It reports false
+// when another instance already did, so each outage is alerted once.
+func (*DeviceRepository) MarkDeviceHealthAlerted(ctx context.Context, db *sql.DB, deviceID int) (bool, *entity.ErrorResponse) {
+	result, err := db.ExecContext(ctx, "UPDATE devices SET health_alerted_at = NOW() WHERE id = ? AND health_alerted_at IS NULL", deviceID)
+	if err != nil {
+		return false, helper.ErrorResponse(http.StatusInternalServerError, "failed to mark device alerted")
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return affected == 1, nil
+}
+
+func scanDevicesHealth(rows *sql.Rows) ([]*entity.DeviceHealth, *entity.ErrorResponse) {
+	devices := []*entity.DeviceHealth{}
+	for rows.Next() {
+		var health entity.DeviceHealth
+		var location, firmwareVersion, ipAddress, lastHeartbeatAt, alertedAt sql.NullString
+		var uptimeSeconds, signalStrength, sinceHeartbeat sql.NullInt64
+		err := rows.Scan(&health.DeviceID, &health.Name, &location, &firmwareVersion, &ipAddress, &uptimeSeconds, &signalStrength, &lastHeartbeatAt, &sinceHeartbeat, &alertedAt)
+		if err != nil {
+			return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to scan device health")
+		}
+		health.Location = location.String
+		health.FirmwareVersion = firmwareVersion.String
+		health.IPAddress = ipAddress.String
+		health.UptimeSeconds = uptimeSeconds.Int64
+		health.LastHeartbeatAt = lastHeartbeatAt.String
+		health.AlertedAt = alertedAt.String
+		if signalStrength.Valid {
+			signal := int(signalStrength.Int64)
+			health.SignalStrength = &signal
+		}
+		if sinceHeartbeat.Valid {
+			health.SecondsSinceHeartbeat = &sinceHeartbeat.Int64
+		}
+		devices = append(devices, &health)
+	}
+
+	return devices, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanDevice(row rowScanner) (*entity.Device, *entity.ErrorResponse) {
+	var device entity.Device
+	var location, lastSeenAt sql.NullString
+	err := row.Scan(&device.ID, &device.Name, &location, &device.UIDFormat, &device.CreatedAt, &lastSeenAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, helper.ErrorResponse(http.StatusNotFound, "device not found")
+		}
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to scan device")
+	}
+	device.Location = location.String
+	device.LastSeenAt = lastSeenAt.String
+
+	return &device, nil
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+)
+
+type DeviceScanRepositoryInterface interface {
+	InsertDeviceScan(ctx context.Context, tx *sql.Tx, deviceID int, uid string, ttl time.Duration) (int, *entity.ErrorResponse)
+	PopDeviceScan(ctx context.Context, tx *sql.Tx, deviceID int) (*entity.DeviceScan, *entity.ErrorResponse)
+	DeleteExpiredDeviceScans(ctx context.Context, tx *sql.Tx, deviceID int) *entity.ErrorResponse
+}
+
+type DeviceScanRepository struct{}
+
+func NewDeviceScanRepository() *DeviceScanRepository {
+	return &DeviceScanRepository{}
+}
+
+func (*DeviceScanRepository) InsertDeviceScan(ctx context.Context, tx *sql.Tx, deviceID int, uid string, ttl time.Duration) (int, *entity.ErrorResponse) {
+	result, err := tx.ExecContext(ctx, "INSERT INTO device_scans (device_id, uid, expires_at) VALUES (?, ?, DATE_ADD(NOW(), INTERVAL ? SECOND))", deviceID, uid, int(ttl.Seconds()))
+	if err != nil {
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "failed to insert device scan")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return int(id), nil
+}
+
+// PopDeviceScan takes the oldest unexpired scan of the device off its queue.
+// SKIP LOCKED lets two consumers of the same queue each get a different scan
+// instead of waiting on one another.
+func (*DeviceScanRepository) PopDeviceScan(ctx context.Context, tx *sql.Tx, deviceID int) (*entity.DeviceScan, *entity.ErrorResponse) {
+	row := tx.QueryRowContext(ctx, "SELECT id, device_id, uid, created_at FROM device_scans WHERE device_id = ? AND expires_at > NOW() ORDER BY id LIMIT 1 FOR UPDATE SKIP LOCKED", deviceID)
+
+	var scan entity.DeviceScan
+	err := row.Scan(&scan.ID, &scan.DeviceID, &scan.UID, &scan.ScannedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, helper.ErrorResponse(http.StatusNotFound, "No scan available for this device")
+		}
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM device_scans WHERE id = ?", scan.ID)
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return &scan, nil
+}
+
+func (*DeviceScanRepository) DeleteExpiredDeviceScans(ctx context.Context, tx *sql.Tx, deviceID int) *entity.ErrorResponse {
+	_, err := tx.ExecContext(ctx, "DELETE FROM device_scans WHERE device_id = ? AND expires_at <= NOW()", deviceID)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "failed to delete expired device scans")
+	}
+
+	return nil
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+)
+
+type EnrollmentRepositoryInterface interface {
+	InsertEnrollmentSession(ctx context.Context, tx *sql.Tx, session *entity.EnrollmentSession) (int, *entity.ErrorResponse)
+	GetEnrollmentSessionByID(ctx context.Context, db *sql.DB, id int) (*entity.EnrollmentSession, *entity.ErrorResponse)
+	GetOpenEnrollmentSessionByDeviceID(ctx context.Context, db *sql.DB, deviceID int) (*entity.EnrollmentSession, *entity.ErrorResponse)
+	GetOpenEnrollmentSessionForUpdate(ctx context.Context, tx *sql.Tx, deviceID int) (*entity.EnrollmentSession, *entity.ErrorResponse)
+	CloseEnrollmentSession(ctx context.Context, tx *sql.Tx, id int) (bool, *entity.ErrorResponse)
+	InsertEnrollmentItem(ctx context.Context, tx *sql.Tx, item *entity.EnrollmentItem) (int, *entity.ErrorResponse)
+	GetEnrollmentItems(ctx context.Context, db *sql.DB, sessionID int) ([]*entity.EnrollmentItem, *entity.ErrorResponse)
+}
+
+type EnrollmentRepository struct{}
+
+func NewEnrollmentRepository() *EnrollmentRepository {
+	return &EnrollmentRepository{}
+}
+
+const enrollmentSessionColumns = "id, device_id, card_type, status, account_id, created_at, closed_at"
+
+func (*EnrollmentRepository) InsertEnrollmentSession(ctx context.Context, tx *sql.Tx, session *entity.EnrollmentSession) (int, *entity.ErrorResponse) {
+	result, err := tx.ExecContext(ctx, "INSERT INTO enrollment_sessions (device_id, card_type, account_id) VALUES (?, ?, ?)",
+		session.DeviceID,
+		session.CardType,
+		sql.NullInt64{Int64: int64(session.AccountID), Valid: session.AccountID > 0},
+	)
+	if err != nil {
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "failed to insert enrollment session")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return int(id), nil
+}
+
+func (*EnrollmentRepository) GetEnrollmentSessionByID(ctx context.Context, db *sql.DB, id int) (*entity.EnrollmentSession, *entity.ErrorResponse) {
+	row := db.QueryRowContext(ctx, "SELECT "+enrollmentSessionColumns+" FROM enrollment_sessions WHERE id = ?", id)
+	return scanEnrollmentSession(row)
+}
+
+func (*EnrollmentRepository) GetOpenEnrollmentSessionByDeviceID(ctx context.Context, db *sql.DB, deviceID int) (*entity.EnrollmentSession, *entity.ErrorResponse) {
+	row := db.QueryRowContext(ctx, "SELECT "+enrollmentSessionColumns+" FROM enrollment_sessions WHERE device_id = ? AND status = 'open' ORDER BY id DESC LIMIT 1", deviceID)
+	return scanEnrollmentSession(row)
+}
+
+// GetOpenEnrollmentSessionForUpdate locks the open session of the device so
+// closing it cannot race with a scan being enrolled into it.
+func (*EnrollmentRepository) GetOpenEnrollmentSessionForUpdate(ctx context.Context, tx *sql.Tx, deviceID int) (*entity.EnrollmentSession, *entity.ErrorResponse) {
+	row := tx.QueryRowContext(ctx, "SELECT "+enrollmentSessionColumns+" FROM enrollment_sessions WHERE device_id = ? AND status = 'open' ORDER BY id DESC LIMIT 1 FOR UPDATE", deviceID)
+	return scanEnrollmentSession(row)
+}
+
+func (*EnrollmentRepository) CloseEnrollmentSession(ctx context.Context, tx *sql.Tx, id int) (bool, *entity.ErrorResponse) {
+	result, err := tx.ExecContext(ctx, "UPDATE enrollment_sessions SET status = 'closed', closed_at = NOW() WHERE id = ? AND status = 'open'", id)
+	if err != nil {
+		return false, helper.ErrorResponse(http.StatusInternalServerError, "failed to close enrollment session")
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return affected == 1, nil
+}
+
+func (*EnrollmentRepository) InsertEnrollmentItem(ctx context.Context, tx *sql.Tx, item *entity.EnrollmentItem) (int, *entity.ErrorResponse) {
+	result, err := tx.ExecContext(ctx, "INSERT INTO enrollment_items (session_id, uid, card_id, result, message) VALUES (?, ?, ?, ?, ?)",
+		item.SessionID,
+		item.UID,
+		sql.NullInt64{Int64: int64(item.CardID), Valid: item.CardID > 0},
+		item.Result,
+		sql.NullString{String: item.Message, Valid: item.Message != ""},
+	)
+	if err != nil {
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "failed to insert enrollment item")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return int(id), nil
+}
+
+func (*EnrollmentRepository) GetEnrollmentItems(ctx context.Context, db *sql.DB, sessionID int) ([]*entity.EnrollmentItem, *entity.ErrorResponse) {
+	rows, err := db.QueryContext(ctx, "SELECT id, session_id, uid, card_id, result, message, created_at FROM enrollment_items WHERE session_id = ? ORDER BY id", sessionID)
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to get enrollment items")
+	}
+	defer rows.Close()
+
+	items := []*entity.EnrollmentItem{}
+	for rows.Next() {
+		var item entity.EnrollmentItem
+		var cardID sql.NullInt64
+		var message sql.NullString
+		err := rows.Scan(&item.ID, &item.SessionID, &item.UID, &cardID, &item.Result, &message, &item.CreatedAt)
+		if err != nil {
+			return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to scan enrollment item")
+		}
+		item.CardID = int(cardID.Int64)
+		item.Message = message.String
+		items = append(items, &item)
+	}
+
+	return items, nil
+}
+
+func scanEnrollmentSession(row rowScanner) (*entity.EnrollmentSession, *entity.ErrorResponse) {
+	var session entity.EnrollmentSession
+	var accountID sql.NullInt64
+	var closedAt sql.NullString
+	err := row.Scan(&session.ID, &session.DeviceID, &session.CardType, &session.Status, &accountID, &session.CreatedAt, &closedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, helper.ErrorResponse(http.StatusNotFound, "enrollment session not found")
+		}
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to scan enrollment session")
+	}
+	session.AccountID = int(accountID.Int64)
+	session.ClosedAt = closedAt.String
+
+	return &session, nil
+}
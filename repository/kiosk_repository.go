@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+)
+
+type KioskRepositoryInterface interface {
+	InsertKioskSession(ctx context.Context, tx *sql.Tx, deviceID, studentID int, ttl time.Duration) (int, *entity.ErrorResponse)
+	CloseOpenKioskSessions(ctx context.Context, tx *sql.Tx, deviceID int) *entity.ErrorResponse
+	GetKioskSessionByID(ctx context.Context, db *sql.DB, id int) (*entity.KioskSession, *entity.ErrorResponse)
+	GetKioskSessionForUpdate(ctx context.Context, tx *sql.Tx, id int) (*entity.KioskSession, *entity.ErrorResponse)
+	TouchKioskSession(ctx context.Context, tx *sql.Tx, id int, ttl time.Duration) *entity.ErrorResponse
+	CloseKioskSession(ctx context.Context, tx *sql.Tx, id int, status, transactionID string) *entity.ErrorResponse
+	InsertKioskSessionBook(ctx context.Context, tx *sql.Tx, sessionID, bookID, itemID int) *entity.ErrorResponse
+	GetKioskSessionBooks(ctx context.Context, db *sql.DB, sessionID int) ([]*entity.KioskBook, *entity.ErrorResponse)
+}
+
+type KioskRepository struct{}
+
+func NewKioskRepository() *KioskRepository {
+	return &KioskRepository{}
+}
+
+const kioskSessionQuery = "SELECT k.id, k.device_id, k.student_id, s.name, k.status, k.transaction_id, k.created_at, k.expires_at, k.expires_at <= NOW() FROM kiosk_sessions k JOIN students s ON s.id = k.student_id WHERE k.id = ?"
+
+func (*KioskRepository) InsertKioskSession(ctx context.Context, tx *sql.Tx, deviceID, studentID int, ttl time.Duration) (int, *entity.ErrorResponse) {
+	result, err := tx.ExecContext(ctx, "INSERT INTO kiosk_sessions (device_id, student_id, expires_at) VALUES (?, ?, DATE_ADD(NOW(), INTERVAL ? SECOND))", deviceID, studentID, int(ttl.Seconds()))
+	if err != nil {
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "failed to insert kiosk session")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return int(id), nil
+}
+
+// CloseOpenKioskSessions ends whatever session the kiosk still has open, as
+// expired if it timed out and as cancelled otherwise.
+func (*KioskRepository) CloseOpenKioskSessions(ctx context.Context, tx *sql.Tx, deviceID int) *entity.ErrorResponse {
+	_, err := tx.ExecContext(ctx, "UPDATE kiosk_sessions SET status = IF(expires_at <= NOW(), 'expired', 'cancelled'), closed_at = NOW() WHERE device_id = ? AND status = 'open'", deviceID)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "failed to close kiosk sessions")
+	}
+
+	return nil
+}
+
+func (*KioskRepository) GetKioskSessionByID(ctx context.Context, db *sql.DB, id int) (*entity.KioskSession, *entity.ErrorResponse) {
+	row := db.QueryRowContext(ctx, kioskSessionQuery, id)
+	return scanKioskSession(row)
+}
+
+func (*KioskRepository) GetKioskSessionForUpdate(ctx context.Context, tx *sql.Tx, id int) (*entity.KioskSession, *entity.ErrorResponse) {
+	row := tx.QueryRowContext(ctx, kioskSessionQuery+" FOR UPDATE", id)
+	return scanKioskSession(row)
+}
+
+func (*KioskRepository) TouchKioskSession(ctx context.Context, tx *sql.Tx, id int, ttl time.Duration) *entity.ErrorResponse {
+	_, err := tx.ExecContext(ctx, "UPDATE kiosk_sessions SET expires_at = DATE_ADD(NOW(), INTERVAL ? SECOND) WHERE id = ?", int(ttl.Seconds()), id)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "failed to update kiosk session")
+	}
+
+	return nil
+}
+
+func (*KioskRepository) CloseKioskSession(ctx context.Context, tx *sql.Tx, id int, status, transactionID string) *entity.ErrorResponse {
+	_, err := tx.ExecContext(ctx, "UPDATE kiosk_sessions SET status = ?, transaction_id = ?, closed_at = NOW() WHERE id = ?",
+		status,
+		sql.NullString{String: transactionID, Valid: transactionID != ""},
+		id,
+	)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "failed to close kiosk session")
+	}
+
+	return nil
+}
+
+// InsertKioskSessionBook adds the book to the session, with the copy that was
+// tapped when the tag is on a copy. A session holds one copy of each book, so
+// tapping a book it already has is a conflict.
+func (*KioskRepository) InsertKioskSessionBook(ctx context.Context, tx *sql.Tx, sessionID, bookID, itemID int) *entity.ErrorResponse {
+	_, err := tx.ExecContext(ctx, "INSERT INTO kiosk_session_books (session_id, book_id, item_id) VALUES (?, ?, ?)", sessionID, bookID, nullInt(itemID))
+	if err != nil {
+		if strings.Contains(err.Error(), "Duplicate entry") {
+			return helper.ErrorResponse(http.StatusConflict, "book is already in the kiosk session")
+		}
+		return helper.ErrorResponse(http.StatusInternalServerError, "failed to add book to kiosk session")
+	}
+
+	return nil
+}
+
+func (*KioskRepository) GetKioskSessionBooks(ctx context.Context, db *sql.DB, sessionID int) ([]*entity.KioskBook, *entity.ErrorResponse) {
+	rows, err := db.QueryContext(ctx, "SELECT b.id, k.item_id, b.title FROM kiosk_session_books k JOIN books b ON b.id = k.book_id WHERE k.session_id = ? ORDER BY k.id", sessionID)
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to get kiosk session books")
+	}
+	defer rows.Close()
+
+	books := []*entity.KioskBook{}
+	for rows.Next() {
+		var book entity.KioskBook
+		var itemID sql.NullInt64
+		if err := rows.Scan(&book.ID, &itemID, &book.Title); err != nil {
+			return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to scan kiosk session book")
+		}
+		book.ItemID = int(itemID.Int64)
+		books = append(books, &book)
+	}
+
+	return books, nil
+}
+
+func scanKioskSession(row rowScanner) (*entity.KioskSession, *entity.ErrorResponse) {
+	var session entity.KioskSession
+	var transactionID sql.NullString
+	err := row.Scan(&session.ID, &session.DeviceID, &session.StudentID, &session.StudentName, &session.Status, &transactionID, &session.CreatedAt, &session.ExpiresAt, &session.Expired)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, helper.ErrorResponse(http.StatusNotFound, "kiosk session not found")
+		}
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to scan kiosk session")
+	}
+	session.TransactionID = transactionID.String
+
+	return &session, nil
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+)
+
+type LoginAttemptRepositoryInterface interface {
+	InsertLoginAttempt(ctx context.Context, db *sql.DB, attempt *entity.LoginAttempt) *entity.ErrorResponse
+	GetRecentFailedAttemptsByIP(ctx context.Context, db *sql.DB, ipAddress string, window time.Duration) (count int, sinceLast time.Duration, err *entity.ErrorResponse)
+	GetLoginAttemptsByAccountID(ctx context.Context, db *sql.DB, accountID, page, pageSize int) ([]*entity.LoginAttempt, *entity.ErrorResponse)
+	GetLockRemaining(ctx context.Context, db *sql.DB, accountID int) (time.Duration, *entity.ErrorResponse)
+	IncrementFailedAttempts(ctx context.Context, tx *sql.Tx, accountID int) (int, *entity.ErrorResponse)
+	LockAccount(ctx context.Context, tx *sql.Tx, accountID int, duration time.Duration) *entity.ErrorResponse
+	ResetFailedAttempts(ctx context.Context, tx *sql.Tx, accountID int) *entity.ErrorResponse
+	GetLockedAccounts(ctx context.Context, db *sql.DB) ([]*entity.LockedAccount, *entity.ErrorResponse)
+}
+
+type LoginAttemptRepository struct{}
+
+func NewLoginAttemptRepository() *LoginAttemptRepository {
+	return &LoginAttemptRepository{}
+}
+
+func (*LoginAttemptRepository) InsertLoginAttempt(ctx context.Context, db *sql.DB, attempt *entity.LoginAttempt) *entity.ErrorResponse {
+	var accountID sql.NullInt64
+	if attempt.AccountID > 0 {
+		accountID = sql.NullInt64{Int64: int64(attempt.AccountID), Valid: true}
+	}
+
+	_, err := db.ExecContext(ctx, "INSERT INTO login_attempts (account_id, email, ip_address, user_agent, success, reason) VALUES (?, ?, ?, ?, ?, ?)",
+		accountID,
+		attempt.Email,
+		attempt.IPAddress,
+		attempt.UserAgent,
+		attempt.Success,
+		attempt.Reason,
+	)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "failed to insert login attempt")
+	}
+
+	return nil
+}
+
+// GetRecentFailedAttemptsByIP counts the failed logins from ipAddress within
+// window and reports how long ago the latest of them happened.
+func (*LoginAttemptRepository) GetRecentFailedAttemptsByIP(ctx context.Context, db *sql.DB, ipAddress string, window time.Duration) (int, time.Duration, *entity.ErrorResponse) {
+	row := db.QueryRowContext(ctx, "SELECT COUNT(*), COALESCE(TIMESTAMPDIFF(SECOND, MAX(created_at), NOW()), 0) FROM login_attempts WHERE ip_address = ? AND success = 0 AND created_at > DATE_SUB(NOW(), INTERVAL ? SECOND)", ipAddress, int(window.Seconds()))
+
+	var count, seconds int
+	if err := row.Scan(&count, &seconds); err != nil {
+		return 0, 0, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return count, time.Duration(seconds) * time.Second, nil
+}
+
+func (*LoginAttemptRepository) GetLoginAttemptsByAccountID(ctx context.Context, db *sql.DB, accountID, page, pageSize int) ([]*entity.LoginAttempt, *entity.ErrorResponse) {
+	query := "SELECT id, account_id, email, ip_address, user_agent, success, reason, created_at FROM login_attempts WHERE account_id = ? ORDER BY created_at DESC, id DESC"
+	if page != 0 && pageSize != 0 {
+		offset := (page - 1) * pageSize
+		query += fmt.Sprintf(" LIMIT %d OFFSET %d", pageSize, offset)
+	}
+
+	rows, err := db.QueryContext(ctx, query, accountID)
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+	defer rows.Close()
+
+	var attempts []*entity.LoginAttempt
+	for rows.Next() {
+		var attempt entity.LoginAttempt
+		var userAgent, reason sql.NullString
+		err := rows.Scan(&attempt.ID, &attempt.AccountID, &attempt.Email, &attempt.IPAddress, &userAgent, &attempt.Success, &reason, &attempt.CreatedAt)
+		if err != nil {
+			return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to scan login attempt")
+		}
+		attempt.UserAgent = userAgent.String
+		attempt.Reason = reason.String
+		attempts = append(attempts, &attempt)
+	}
+
+	return attempts, nil
+}
+
+func (*LoginAttemptRepository) GetLockRemaining(ctx context.Context, db *sql.DB, accountID int) (time.Duration, *entity.ErrorResponse) {
+	row := db.QueryRowContext(ctx, "SELECT GREATEST(COALESCE(TIMESTAMPDIFF(SECOND, NOW(), locked_until), 0), 0) FROM accounts WHERE id = ?", accountID)
+
+	var seconds int
+	if err := row.Scan(&seconds); err != nil {
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return time.Duration(seconds) * time.Second, nil
+}
+
+func (*LoginAttemptRepository) IncrementFailedAttempts(ctx context.Context, tx *sql.Tx, accountID int) (int, *entity.ErrorResponse) {
+	row := tx.QueryRowContext(ctx, "SELECT failed_login_attempts FROM accounts WHERE id = ? FOR UPDATE", accountID)
+
+	var attempts int
+	if err := row.Scan(&attempts); err != nil {
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+	attempts++
+
+	_, err := tx.ExecContext(ctx, "UPDATE accounts SET failed_login_attempts = ? WHERE id = ?", attempts, accountID)
+	if err != nil {
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return attempts, nil
+}
+
+func (*LoginAttemptRepository) LockAccount(ctx context.Context, tx *sql.Tx, accountID int, duration time.Duration) *entity.ErrorResponse {
+	_, err := tx.ExecContext(ctx, "UPDATE accounts SET locked_until = DATE_ADD(NOW(), INTERVAL ? SECOND) WHERE id = ?", int(duration.Seconds()), accountID)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return nil
+}
+
+func (*LoginAttemptRepository) ResetFailedAttempts(ctx context.Context, tx *sql.Tx, accountID int) *entity.ErrorResponse {
+	_, err := tx.ExecContext(ctx, "UPDATE accounts SET failed_login_attempts = 0, locked_until = NULL WHERE id = ?", accountID)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return nil
+}
+
+func (*LoginAttemptRepository) GetLockedAccounts(ctx context.Context, db *sql.DB) ([]*entity.LockedAccount, *entity.ErrorResponse) {
+	rows, err := db.QueryContext(ctx, "SELECT id, email, level, failed_login_attempts, locked_until FROM accounts WHERE locked_until > NOW() ORDER BY locked_until DESC")
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+	defer rows.Close()
+
+	var accounts []*entity.LockedAccount
+	for rows.Next() {
+		var account entity.LockedAccount
+		err := rows.Scan(&account.ID, &account.Email, &account.Level, &account.FailedLoginAttempts, &account.LockedUntil)
+		if err != nil {
+			return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to scan account")
+		}
+		accounts = append(accounts, &account)
+	}
+
+	return accounts, nil
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+)
+
+type NotificationQueueRepositoryInterface interface {
+	QueueNotification(ctx context.Context, tx *sql.Tx, email, subject, body string) *entity.ErrorResponse
+	GetPendingNotifications(ctx context.Context, db *sql.DB, limit int) ([]*entity.QueuedNotification, *entity.ErrorResponse)
+	MarkNotificationSent(ctx context.Context, db *sql.DB, id int64) *entity.ErrorResponse
+	MarkNotificationAttemptFailed(ctx context.Context, db *sql.DB, id int64, lastError string, maxAttempts int) *entity.ErrorResponse
+}
+
+type NotificationQueueRepository struct{}
+
+func NewNotificationQueueRepository() *NotificationQueueRepository {
+	return &NotificationQueueRepository{}
+}
+
+// QueueNotification adds an email to the queue inside the caller's
+// transaction, so it is only sent if the change it reports is committed.
+func (*NotificationQueueRepository) QueueNotification(ctx context.Context, tx *sql.Tx, email, subject, body string) *entity.ErrorResponse {
+	_, err := tx.ExecContext(ctx, "INSERT INTO notification_queue (email, subject, body) VALUES (?, ?, ?)", email, subject, body)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "failed to queue notification")
+	}
+
+	return nil
+}
+
+func (*NotificationQueueRepository) GetPendingNotifications(ctx context.Context, db *sql.DB, limit int) ([]*entity.QueuedNotification, *entity.ErrorResponse) {
+	rows, err := db.QueryContext(ctx, "SELECT id, email, subject, body, attempts FROM notification_queue WHERE status = 'pending' ORDER BY id LIMIT ?", limit)
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+	defer rows.Close()
+
+	var notifications []*entity.QueuedNotification
+	for rows.Next() {
+		var notification entity.QueuedNotification
+		err := rows.Scan(&notification.ID, &notification.Email, &notification.Subject, &notification.Body, &notification.Attempts)
+		if err != nil {
+			return nil, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+		}
+		notifications = append(notifications, &notification)
+	}
+
+	return notifications, nil
+}
+
+func (*NotificationQueueRepository) MarkNotificationSent(ctx context.Context, db *sql.DB, id int64) *entity.ErrorResponse {
+	_, err := db.ExecContext(ctx, "UPDATE notification_queue SET status = 'sent', attempts = attempts + 1, last_error = NULL, sent_at = NOW() WHERE id = ?", id)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return nil
+}
+
+// MarkNotificationAttemptFailed records a failed delivery. The notification
+// stays pending until it has failed maxAttempts times.
+func (*NotificationQueueRepository) MarkNotificationAttemptFailed(ctx context.Context, db *sql.DB, id int64, lastError string, maxAttempts int) *entity.ErrorResponse {
+	if len(lastError) > 255 {
+		lastError = lastError[:255]
+	}
+
+	_, err := db.ExecContext(ctx, "UPDATE notification_queue SET attempts = attempts + 1, last_error = ?, status = IF(attempts >= ?, 'failed', status) WHERE id = ?", lastError, maxAttempts, id)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+)
+
+type PasswordHistoryRepositoryInterface interface {
+	InsertPasswordHistory(ctx context.Context, tx *sql.Tx, accountID int, passwordHash string) *entity.ErrorResponse
+	GetRecentPasswordHashes(ctx context.Context, db *sql.DB, accountID, limit int) ([]string, *entity.ErrorResponse)
+	PrunePasswordHistory(ctx context.Context, tx *sql.Tx, accountID, keep int) *entity.ErrorResponse
+}
+
+type PasswordHistoryRepository struct{}
+
+func NewPasswordHistoryRepository() *PasswordHistoryRepository {
+	return &PasswordHistoryRepository{}
+}
+
+func (*PasswordHistoryRepository) InsertPasswordHistory(ctx context.Context, tx *sql.Tx, accountID int, passwordHash string) *entity.ErrorResponse {
+	_, err := tx.ExecContext(ctx, "INSERT INTO password_history (account_id, password_hash) VALUES (?, ?)", accountID, passwordHash)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "failed to insert password history")
+	}
+
+	return nil
+}
+
+func (*PasswordHistoryRepository) GetRecentPasswordHashes(ctx context.Context, db *sql.DB, accountID, limit int) ([]string, *entity.ErrorResponse) {
+	rows, err := db.QueryContext(ctx, "SELECT password_hash FROM password_history WHERE account_id = ? ORDER BY created_at DESC, id DESC LIMIT ?", accountID, limit)
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to scan password history")
+		}
+		hashes = append(hashes, hash)
+	}
+
+	return hashes, nil
+}
+
+// PrunePasswordHistory drops everything but the newest keep entries. The
+// derived table is needed because MySQL can't select from the table it is
+// deleting from.
+func (*PasswordHistoryRepository) PrunePasswordHistory(ctx context.Context, tx *sql.Tx, accountID, keep int) *entity.ErrorResponse {
+	_, err := tx.ExecContext(ctx, `DELETE FROM password_history WHERE account_id = ? AND id NOT IN (
+		SELECT id FROM (SELECT id FROM password_history WHERE account_id = ? ORDER BY created_at DESC, id DESC LIMIT ?) AS recent
+	)`, accountID, accountID, keep)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "failed to prune password history")
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+)
+
+type PasswordResetRepositoryInterface interface {
+	CountRecentResetTokens(ctx context.Context, tx *sql.Tx, accountID int, window time.Duration) (int, *entity.ErrorResponse)
+	InsertResetToken(ctx context.Context, tx *sql.Tx, accountID int, tokenHash string, ttl time.Duration) *entity.ErrorResponse
+	GetAccountIDByResetTokenForUpdate(ctx context.Context, tx *sql.Tx, tokenHash string) (int, *entity.ErrorResponse)
+	UseResetTokensByAccountID(ctx context.Context, tx *sql.Tx, accountID int) *entity.ErrorResponse
+}
+
+type PasswordResetRepository struct{}
+
+func NewPasswordResetRepository() *PasswordResetRepository {
+	return &PasswordResetRepository{}
+}
+
+func (*PasswordResetRepository) CountRecentResetTokens(ctx context.Context, tx *sql.Tx, accountID int, window time.Duration) (int, *entity.ErrorResponse) {
+	row := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM password_reset_tokens WHERE account_id = ? AND created_at > DATE_SUB(NOW(), INTERVAL ? SECOND)", accountID, int(window.Seconds()))
+
+	var count int
+	if err := row.Scan(&count); err != nil {
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return count, nil
+}
+
+func (*PasswordResetRepository) InsertResetToken(ctx context.Context, tx *sql.Tx, accountID int, tokenHash string, ttl time.Duration) *entity.ErrorResponse {
+	_, err := tx.ExecContext(ctx, "INSERT INTO password_reset_tokens (account_id, token_hash, expires_at) VALUES (?, ?, DATE_ADD(NOW(), INTERVAL ? SECOND))", accountID, tokenHash, int(ttl.Seconds()))
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "failed to insert reset token")
+	}
+
+	return nil
+}
+
+func (*PasswordResetRepository) GetAccountIDByResetTokenForUpdate(ctx context.Context, tx *sql.Tx, tokenHash string) (int, *entity.ErrorResponse) {
+	row := tx.QueryRowContext(ctx, "SELECT account_id FROM password_reset_tokens WHERE token_hash = ? AND used_at IS NULL AND expires_at > NOW() FOR UPDATE", tokenHash)
+
+	var accountID int
+	if err := row.Scan(&accountID); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, helper.ErrorResponse(http.StatusNotFound, "reset token not found")
+		}
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return accountID, nil
+}
+
+func (*PasswordResetRepository) UseResetTokensByAccountID(ctx context.Context, tx *sql.Tx, accountID int) *entity.ErrorResponse {
+	_, err := tx.ExecContext(ctx, "UPDATE password_reset_tokens SET used_at = NOW() WHERE account_id = ? AND used_at IS NULL", accountID)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "failed to update reset token")
+	}
+
+	return nil
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+)
+
+type ScanEventRepositoryInterface interface {
+	InsertScanEvent(ctx context.Context, tx *sql.Tx, event *entity.ScanEvent) (int64, *entity.ErrorResponse)
+	GetScanEvents(ctx context.Context, db *sql.DB, filter *entity.ScanEventFilter) ([]*entity.ScanEvent, *entity.ErrorResponse)
+	DeleteScanEventsOlderThan(ctx context.Context, db *sql.DB, age time.Duration, limit int) (int64, *entity.ErrorResponse)
+}
+
+// ScanEventRepository has no update method on purpose: scan_events is an
+// append-only log and the table rejects updates as well.
+type ScanEventRepository struct{}
+
+func NewScanEventRepository() *ScanEventRepository {
+	return &ScanEventRepository{}
+}
+
+// InsertScanEvent logs the event at CreatedAt when it is set, for scans a
+// reader buffered offline, and at the current time otherwise.
+func (*ScanEventRepository) InsertScanEvent(ctx context.Context, tx *sql.Tx, event *entity.ScanEvent) (int64, *entity.ErrorResponse) {
+	result, err := tx.ExecContext(ctx, "INSERT INTO scan_events (device_id, uid, card_id, card_type, entity_id, purpose, created_at) VALUES (?, ?, ?, ?, ?, ?, COALESCE(?, NOW()))",
+		nullInt(event.DeviceID),
+		event.UID,
+		nullInt(event.CardID),
+		sql.NullString{String: event.CardType, Valid: event.CardType != ""},
+		nullInt(event.EntityID),
+		event.Purpose,
+		sql.NullString{String: event.CreatedAt, Valid: event.CreatedAt != ""},
+	)
+	if err != nil {
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "failed to insert scan event")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return id, nil
+}
+
+func (*ScanEventRepository) GetScanEvents(ctx context.Context, db *sql.DB, filter *entity.ScanEventFilter) ([]*entity.ScanEvent, *entity.ErrorResponse) {
+	var conditions []string
+	var args []any
+
+	if filter.DeviceID != 0 {
+		conditions = append(conditions, "device_id = ?")
+		args = append(args, filter.DeviceID)
+	}
+	if filter.UID != "" {
+		conditions = append(conditions, "uid = ?")
+		args = append(args, filter.UID)
+	}
+	if filter.CardType != "" {
+		conditions = append(conditions, "card_type = ?")
+		args = append(args, filter.CardType)
+	}
+	if filter.EntityID != 0 {
+		conditions = append(conditions, "entity_id = ?")
+		args = append(args, filter.EntityID)
+	}
+	if filter.Purpose != "" {
+		conditions = append(conditions, "purpose = ?")
+		args = append(args, filter.Purpose)
+	}
+	if filter.From != "" {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.From)
+	}
+	if filter.To != "" {
+		conditions = append(conditions, "created_at < DATE_ADD(?, INTERVAL 1 DAY)")
+		args = append(args, filter.To)
+	}
+
+	query := "SELECT id, device_id, uid, card_id, card_type, entity_id, purpose, created_at FROM scan_events"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id DESC"
+
+	if filter.Page != 0 && filter.PageSize != 0 {
+		offset := (filter.Page - 1) * filter.PageSize
+		query += fmt.Sprintf(" LIMIT %d OFFSET %d", filter.PageSize, offset)
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to get scan events")
+	}
+	defer rows.Close()
+
+	var events []*entity.ScanEvent
+	for rows.Next() {
+		var event entity.ScanEvent
+		var deviceID, cardID, entityID sql.NullInt64
+		var cardType sql.NullString
+		err := rows.Scan(&event.ID, &deviceID, &event.UID, &cardID, &cardType, &entityID, &event.Purpose, &event.CreatedAt)
+		if err != nil {
+			return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to scan scan event")
+		}
+		event.DeviceID = int(deviceID.Int64)
+		event.CardID = int(cardID.Int64)
+		event.CardType = cardType.String
+		event.EntityID = int(entityID.Int64)
+		events = append(events, &event)
+	}
+
+	return events, nil
+}
+
+func (*ScanEventRepository) DeleteScanEventsOlderThan(ctx context.Context, db *sql.DB, age time.Duration, limit int) (int64, *entity.ErrorResponse) {
+	result, err := db.ExecContext(ctx, "DELETE FROM scan_events WHERE created_at < DATE_SUB(NOW(), INTERVAL ? SECOND) ORDER BY id LIMIT ?", int(age.Seconds()), limit)
+	if err != nil {
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "failed to prune scan events")
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return affected, nil
+}
+
+func nullInt(value int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(value), Valid: value != 0}
+}
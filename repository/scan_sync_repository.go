@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strings"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+)
+
+type ScanSyncRepositoryInterface interface {
+	GetScanSyncEvent(ctx context.Context, db *sql.DB, deviceID int, eventID string) (*entity.ScanSyncResult, *entity.ErrorResponse)
+	InsertScanSyncEvent(ctx context.Context, tx *sql.Tx, deviceID int, result *entity.ScanSyncResult, scannedAt string) (bool, *entity.ErrorResponse)
+	UpdateScanSyncAction(ctx context.Context, db *sql.DB, deviceID int, result *entity.ScanSyncResult) *entity.ErrorResponse
+}
+
+type ScanSyncRepository struct{}
+
+func NewScanSyncRepository() *ScanSyncRepository {
+	return &ScanSyncRepository{}
+}
+
+func (*ScanSyncRepository) GetScanSyncEvent(ctx context.Context, db *sql.DB, deviceID int, eventID string) (*entity.ScanSyncResult, *entity.ErrorResponse) {
+	row := db.QueryRowContext(ctx, "SELECT event_id, status, scan_event_id, action, message FROM scan_sync_events WHERE device_id = ? AND event_id = ?", deviceID, eventID)
+
+	var result entity.ScanSyncResult
+	var scanEventID sql.NullInt64
+	var action, message sql.NullString
+	err := row.Scan(&result.EventID, &result.Status, &scanEventID, &action, &message)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, helper.ErrorResponse(http.StatusNotFound, "sync event not found")
+		}
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to scan sync event")
+	}
+	result.ScanEventID = scanEventID.Int64
+	result.Action = action.String
+	result.Message = message.String
+
+	return &result, nil
+}
+
+// InsertScanSyncEvent records the event id for the device. It reports false
+// when the id was already recorded, which the unique key decides so that
+// concurrent uploads of the same buffer cannot both apply an event.
+func (*ScanSyncRepository) InsertScanSyncEvent(ctx context.Context, tx *sql.Tx, deviceID int, result *entity.ScanSyncResult, scannedAt string) (bool, *entity.ErrorResponse) {
+	_, err := tx.ExecContext(ctx, "INSERT INTO scan_sync_events (device_id, event_id, scan_event_id, status, message, scanned_at) VALUES (?, ?, ?, ?, ?, ?)",
+		deviceID,
+		result.EventID,
+		sql.NullInt64{Int64: result.ScanEventID, Valid: result.ScanEventID > 0},
+		result.Status,
+		sql.NullString{String: result.Message, Valid: result.Message != ""},
+		sql.NullString{String: scannedAt, Valid: scannedAt != ""},
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "Duplicate entry") {
+			return false, nil
+		}
+		return false, helper.ErrorResponse(http.StatusInternalServerError, "failed to insert sync event")
+	}
+
+	return true, nil
+}
+
+func (*ScanSyncRepository) UpdateScanSyncAction(ctx context.Context, db *sql.DB, deviceID int, result *entity.ScanSyncResult) *entity.ErrorResponse {
+	_, err := db.ExecContext(ctx, "UPDATE scan_sync_events SET action = ?, message = ? WHERE device_id = ? AND event_id = ?",
+		sql.NullString{String: result.Action, Valid: result.Action != ""},
+		sql.NullString{String: result.Message, Valid: result.Message != ""},
+		deviceID,
+		result.EventID,
+	)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "failed to update sync event")
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+)
+
+type SecurityEventRepositoryInterface interface {
+	InsertSecurityEvent(ctx context.Context, tx *sql.Tx, event *entity.SecurityEvent) *entity.ErrorResponse
+	GetSecurityEvents(ctx context.Context, db *sql.DB, filter *entity.SecurityEventFilter) ([]*entity.SecurityEvent, *entity.ErrorResponse)
+}
+
+type SecurityEventRepository struct{}
+
+func NewSecurityEventRepository() *SecurityEventRepository {
+	return &SecurityEventRepository{}
+}
+
+func (*SecurityEventRepository) InsertSecurityEvent(ctx context.Context, tx *sql.Tx, event *entity.SecurityEvent) *entity.ErrorResponse {
+	_, err := tx.ExecContext(ctx, "INSERT INTO security_events (device_id, uid, card_id, book_id, reason) VALUES (?, ?, ?, ?, ?)",
+		nullInt(event.DeviceID),
+		event.UID,
+		nullInt(event.CardID),
+		nullInt(event.BookID),
+		event.Reason,
+	)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "failed to insert security event")
+	}
+
+	return nil
+}
+
+func (*SecurityEventRepository) GetSecurityEvents(ctx context.Context, db *sql.DB, filter *entity.SecurityEventFilter) ([]*entity.SecurityEvent, *entity.ErrorResponse) {
+	var conditions []string
+	var args []any
+
+	if filter.DeviceID != 0 {
+		conditions = append(conditions, "device_id = ?")
+		args = append(args, filter.DeviceID)
+	}
+	if filter.BookID != 0 {
+		conditions = append(conditions, "book_id = ?")
+		args = append(args, filter.BookID)
+	}
+	if filter.From != "" {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.From)
+	}
+	if filter.To != "" {
+		conditions = append(conditions, "created_at < DATE_ADD(?, INTERVAL 1 DAY)")
+		args = append(args, filter.To)
+	}
+
+	query := "SELECT id, device_id, uid, card_id, book_id, reason, created_at FROM security_events"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id DESC"
+
+	if filter.Page != 0 && filter.PageSize != 0 {
+		offset := (filter.Page - 1) * filter.PageSize
+		query += fmt.Sprintf(" LIMIT %d OFFSET %d", filter.PageSize, offset)
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to get security events")
+	}
+	defer rows.Close()
+
+	var events []*entity.SecurityEvent
+	for rows.Next() {
+		var event entity.SecurityEvent
+		var deviceID, cardID, bookID sql.NullInt64
+		err := rows.Scan(&event.ID, &deviceID, &event.UID, &cardID, &bookID, &event.Reason, &event.CreatedAt)
+		if err != nil {
+			return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to scan security event")
+		}
+		event.DeviceID = int(deviceID.Int64)
+		event.CardID = int(cardID.Int64)
+		event.BookID = int(bookID.Int64)
+		events = append(events, &event)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+)
+
+type SessionRepositoryInterface interface {
+	InsertSession(ctx context.Context, tx *sql.Tx, session *entity.Session, refreshTokenHash string, ttl time.Duration) (int, *entity.ErrorResponse)
+	GetActiveSessionByRefreshTokenHash(ctx context.Context, db *sql.DB, refreshTokenHash string) (*entity.Session, *entity.ErrorResponse)
+	GetSessionByPreviousRefreshTokenHash(ctx context.Context, db *sql.DB, refreshTokenHash string) (*entity.Session, *entity.ErrorResponse)
+	RotateRefreshToken(ctx context.Context, tx *sql.Tx, sessionID int, oldHash, newHash string, ttl time.Duration) (bool, *entity.ErrorResponse)
+	IsSessionActive(ctx context.Context, db *sql.DB, sessionID, accountID int) (bool, *entity.ErrorResponse)
+	RevokeSession(ctx context.Context, tx *sql.Tx, sessionID, accountID int) *entity.ErrorResponse
+	RevokeSessionsByAccountID(ctx context.Context, tx *sql.Tx, accountID int) *entity.ErrorResponse
+}
+
+type SessionRepository struct{}
+
+func NewSessionRepository() *SessionRepository {
+	return &SessionRepository{}
+}
+
+func (*SessionRepository) InsertSession(ctx context.Context, tx *sql.Tx, session *entity.Session, refreshTokenHash string, ttl time.Duration) (int, *entity.ErrorResponse) {
+	result, err := tx.ExecContext(ctx, "INSERT INTO sessions (account_id, refresh_token_hash, user_agent, ip_address, expires_at) VALUES (?, ?, ?, ?, DATE_ADD(NOW(), INTERVAL ? SECOND))",
+		session.AccountID,
+		refreshTokenHash,
+		session.UserAgent,
+		session.IPAddress,
+		int(ttl.Seconds()),
+	)
+	if err != nil {
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "failed to insert session")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return int(id), nil
+}
+
+func (*SessionRepository) GetActiveSessionByRefreshTokenHash(ctx context.Context, db *sql.DB, refreshTokenHash string) (*entity.Session, *entity.ErrorResponse) {
+	row := db.QueryRowContext(ctx, "SELECT id, account_id, user_agent, ip_address, created_at, last_used_at, expires_at FROM sessions WHERE refresh_token_hash = ? AND revoked_at IS NULL AND expires_at > NOW()", refreshTokenHash)
+	return scanSession(row)
+}
+
+func (*SessionRepository) GetSessionByPreviousRefreshTokenHash(ctx context.Context, db *sql.DB, refreshTokenHash string) (*entity.Session, *entity.ErrorResponse) {
+	row := db.QueryRowContext(ctx, "SELECT id, account_id, user_agent, ip_address, created_at, last_used_at, expires_at FROM sessions WHERE previous_refresh_token_hash = ?", refreshTokenHash)
+	return scanSession(row)
+}
+
+func (*SessionRepository) RotateRefreshToken(ctx context.Context, tx *sql.Tx, sessionID int, oldHash, newHash string, ttl time.Duration) (bool, *entity.ErrorResponse) {
+	result, err := tx.ExecContext(ctx, "UPDATE sessions SET previous_refresh_token_hash = refresh_token_hash, refresh_token_hash = ?, last_used_at = NOW(), expires_at = DATE_ADD(NOW(), INTERVAL ? SECOND) WHERE id = ? AND refresh_token_hash = ? AND revoked_at IS NULL",
+		newHash,
+		int(ttl.Seconds()),
+		sessionID,
+		oldHash,
+	)
+	if err != nil {
+		return false, helper.ErrorResponse(http.StatusInternalServerError, "failed to rotate session")
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return affected == 1, nil
+}
+
+func (*SessionRepository) IsSessionActive(ctx context.Context, db *sql.DB, sessionID, accountID int) (bool, *entity.ErrorResponse) {
+	row := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sessions WHERE id = ? AND account_id = ? AND revoked_at IS NULL AND expires_at > NOW()", sessionID, accountID)
+
+	var count int
+	if err := row.Scan(&count); err != nil {
+		return false, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return count > 0, nil
+}
+
+func (*SessionRepository) RevokeSession(ctx context.Context, tx *sql.Tx, sessionID, accountID int) *entity.ErrorResponse {
+	_, err := tx.ExecContext(ctx, "UPDATE sessions SET revoked_at = NOW() WHERE id = ? AND account_id = ? AND revoked_at IS NULL", sessionID, accountID)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "failed to revoke session")
+	}
+
+	return nil
+}
+
+func (*SessionRepository) RevokeSessionsByAccountID(ctx context.Context, tx *sql.Tx, accountID int) *entity.ErrorResponse {
+	_, err := tx.ExecContext(ctx, "UPDATE sessions SET revoked_at = NOW() WHERE account_id = ? AND revoked_at IS NULL", accountID)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "failed to revoke sessions")
+	}
+
+	return nil
+}
+
+func scanSession(row *sql.Row) (*entity.Session, *entity.ErrorResponse) {
+	var session entity.Session
+	var userAgent, ipAddress, lastUsedAt sql.NullString
+	err := row.Scan(&session.ID, &session.AccountID, &userAgent, &ipAddress, &session.CreatedAt, &lastUsedAt, &session.ExpiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, helper.ErrorResponse(http.StatusNotFound, "session not found")
+		}
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "failed to scan session")
+	}
+	session.UserAgent = userAgent.String
+	session.IPAddress = ipAddress.String
+	session.LastUsedAt = lastUsedAt.String
+
+	return &session, nil
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+)
+
+type TwoFactorRepositoryInterface interface {
+	IsTwoFactorEnabled(ctx context.Context, db *sql.DB, accountID int) (bool, *entity.ErrorResponse)
+	GetTwoFactorForUpdate(ctx context.Context, tx *sql.Tx, accountID int) (*entity.TwoFactor, *entity.ErrorResponse)
+	SetTwoFactorSecret(ctx context.Context, tx *sql.Tx, accountID int, secret string) *entity.ErrorResponse
+	EnableTwoFactor(ctx context.Context, tx *sql.Tx, accountID int, step int64) *entity.ErrorResponse
+	DisableTwoFactor(ctx context.Context, tx *sql.Tx, accountID int) *entity.ErrorResponse
+	UpdateTwoFactorLastStep(ctx context.Context, tx *sql.Tx, accountID int, step int64) *entity.ErrorResponse
+	InsertRecoveryCodes(ctx context.Context, tx *sql.Tx, accountID int, codeHashes []string) *entity.ErrorResponse
+	DeleteRecoveryCodes(ctx context.Context, tx *sql.Tx, accountID int) *entity.ErrorResponse
+	UseRecoveryCode(ctx context.Context, tx *sql.Tx, accountID int, codeHash string) (bool, *entity.ErrorResponse)
+}
+
+type TwoFactorRepository struct{}
+
+func NewTwoFactorRepository() *TwoFactorRepository {
+	return &TwoFactorRepository{}
+}
+
+func (*TwoFactorRepository) IsTwoFactorEnabled(ctx context.Context, db *sql.DB, accountID int) (bool, *entity.ErrorResponse) {
+	row := db.QueryRowContext(ctx, "SELECT totp_enabled_at IS NOT NULL FROM accounts WHERE id = ?", accountID)
+
+	var enabled bool
+	if err := row.Scan(&enabled); err != nil {
+		if err == sql.ErrNoRows {
+			return false, helper.ErrorResponse(http.StatusNotFound, "account not found")
+		}
+		return false, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return enabled, nil
+}
+
+func (*TwoFactorRepository) GetTwoFactorForUpdate(ctx context.Context, tx *sql.Tx, accountID int) (*entity.TwoFactor, *entity.ErrorResponse) {
+	row := tx.QueryRowContext(ctx, "SELECT totp_secret, totp_enabled_at IS NOT NULL, totp_last_step FROM accounts WHERE id = ? FOR UPDATE", accountID)
+
+	var twoFactor entity.TwoFactor
+	var secret sql.NullString
+	if err := row.Scan(&secret, &twoFactor.Enabled, &twoFactor.LastStep); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, helper.ErrorResponse(http.StatusNotFound, "account not found")
+		}
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+	twoFactor.Secret = secret.String
+
+	return &twoFactor, nil
+}
+
+func (*TwoFactorRepository) SetTwoFactorSecret(ctx context.Context, tx *sql.Tx, accountID int, secret string) *entity.ErrorResponse {
+	_, err := tx.ExecContext(ctx, "UPDATE accounts SET totp_secret = ?, totp_enabled_at = NULL, totp_last_step = 0 WHERE id = ?", secret, accountID)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return nil
+}
+
+func (*TwoFactorRepository) EnableTwoFactor(ctx context.Context, tx *sql.Tx, accountID int, step int64) *entity.ErrorResponse {
+	_, err := tx.ExecContext(ctx, "UPDATE accounts SET totp_enabled_at = NOW(), totp_last_step = ? WHERE id = ?", step, accountID)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return nil
+}
+
+func (*TwoFactorRepository) DisableTwoFactor(ctx context.Context, tx *sql.Tx, accountID int) *entity.ErrorResponse {
+	_, err := tx.ExecContext(ctx, "UPDATE accounts SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0 WHERE id = ?", accountID)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return nil
+}
+
+func (*TwoFactorRepository) UpdateTwoFactorLastStep(ctx context.Context, tx *sql.Tx, accountID int, step int64) *entity.ErrorResponse {
+	_, err := tx.ExecContext(ctx, "UPDATE accounts SET totp_last_step = ? WHERE id = ?", step, accountID)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return nil
+}
+
+func (*TwoFactorRepository) InsertRecoveryCodes(ctx context.Context, tx *sql.Tx, accountID int, codeHashes []string) *entity.ErrorResponse {
+	for _, codeHash := range codeHashes {
+		_, err := tx.ExecContext(ctx, "INSERT INTO recovery_codes (account_id, code_hash) VALUES (?, ?)", accountID, codeHash)
+		if err != nil {
+			return helper.ErrorResponse(http.StatusInternalServerError, "failed to insert recovery code")
+		}
+	}
+
+	return nil
+}
+
+func (*TwoFactorRepository) DeleteRecoveryCodes(ctx context.Context, tx *sql.Tx, accountID int) *entity.ErrorResponse {
+	_, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE account_id = ?", accountID)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "failed to delete recovery codes")
+	}
+
+	return nil
+}
+
+func (*TwoFactorRepository) UseRecoveryCode(ctx context.Context, tx *sql.Tx, accountID int, codeHash string) (bool, *entity.ErrorResponse) {
+	result, err := tx.ExecContext(ctx, "UPDATE recovery_codes SET used_at = NOW() WHERE account_id = ? AND code_hash = ? AND used_at IS NULL", accountID, codeHash)
+	if err != nil {
+		return false, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return affected == 1, nil
+}
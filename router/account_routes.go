@@ -3,16 +3,32 @@ package router
 import (
 	"fmt"
 	"github.com/dimassfeb-09/smart-library-be/controllers"
+	"github.com/dimassfeb-09/smart-library-be/middleware"
 	"github.com/gofiber/fiber/v2"
 	"log"
 )
 
-func RegisterAccountRoutes(path string, app *fiber.App, controller *controllers.AccountController, nc *controllers.NotificationController) {
-	app.Get(fmt.Sprintf("/%s/:accountId", path), controller.GetAccountByID)
-	app.Get(fmt.Sprintf("/%s/changePassword", path), controller.ChangePassword)
-	app.Delete(fmt.Sprintf("/%s/:accountId", path), controller.DeleteAccount)
-	app.Put(fmt.Sprintf("/%s/:accountId", path), controller.UpdateAccount)
-	app.Get(fmt.Sprintf("/%s/:accountId/notifications", path), nc.GetNotificationByAccountID)
+func RegisterAccountRoutes(path string, app *fiber.App, auth *middleware.Auth, controller *controllers.AccountController, nc *controllers.NotificationController, tfc *controllers.TwoFactorController) {
+	app.Use(fmt.Sprintf("/%s", path), auth.Authenticate())
+
+	app.Get(fmt.Sprintf("/%s", path), auth.Authorize(middleware.Admin), controller.GetAccounts)
+	app.Post(fmt.Sprintf("/%s/admins", path), auth.Authorize(middleware.Admin), controller.CreateAdminAccount)
+	app.Get(fmt.Sprintf("/%s/search", path), auth.Authorize(middleware.Admin), controller.GetAccountByName)
+	app.Get(fmt.Sprintf("/%s/locked", path), auth.Authorize(middleware.Admin), controller.GetLockedAccounts)
+	app.Get(fmt.Sprintf("/%s/:accountId", path), auth.Authorize(middleware.Admin, middleware.AccountOwner("accountId")), controller.GetAccountByID)
+	app.Delete(fmt.Sprintf("/%s/:accountId", path), auth.Authorize(middleware.Admin), controller.DeleteAccount)
+	app.Put(fmt.Sprintf("/%s/:accountId", path), auth.Authorize(middleware.Admin), controller.UpdateAccount)
+	app.Post(fmt.Sprintf("/%s/:accountId/verification-email", path), auth.Authorize(middleware.Admin, middleware.AccountOwner("accountId")), controller.SendVerificationEmail)
+	app.Put(fmt.Sprintf("/%s/:accountId/verify", path), auth.Authorize(middleware.Admin), controller.MarkEmailVerified)
+	app.Put(fmt.Sprintf("/%s/:accountId/password", path), auth.Authorize(middleware.AccountOwner("accountId")), controller.ChangePassword)
+	app.Put(fmt.Sprintf("/%s/:accountId/level", path), auth.Authorize(middleware.Admin), controller.ChangeAccountLevel)
+	app.Post(fmt.Sprintf("/%s/:accountId/unlock", path), auth.Authorize(middleware.Admin), controller.UnlockAccount)
+	app.Get(fmt.Sprintf("/%s/:accountId/login-history", path), auth.Authorize(middleware.Admin, middleware.AccountOwner("accountId")), controller.GetLoginHistory)
+	app.Post(fmt.Sprintf("/%s/:accountId/two-factor", path), auth.Authorize(middleware.AccountOwner("accountId")), tfc.SetupTwoFactor)
+	app.Post(fmt.Sprintf("/%s/:accountId/two-factor/confirm", path), auth.Authorize(middleware.AccountOwner("accountId")), tfc.ConfirmTwoFactor)
+	app.Delete(fmt.Sprintf("/%s/:accountId/two-factor", path), auth.Authorize(middleware.AccountOwner("accountId")), tfc.DisableTwoFactor)
+	app.Post(fmt.Sprintf("/%s/:accountId/two-factor/recovery-codes", path), auth.Authorize(middleware.AccountOwner("accountId")), tfc.RegenerateRecoveryCodes)
+	app.Get(fmt.Sprintf("/%s/:accountId/notifications", path), auth.Authorize(middleware.Admin, middleware.AccountOwner("accountId")), nc.GetNotificationByAccountID)
 
 	err := nc.SendEmailNotification()
 	if err != nil {
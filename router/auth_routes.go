@@ -3,10 +3,19 @@ package router
 import (
 	"fmt"
 	"github.com/dimassfeb-09/smart-library-be/controllers"
+	"github.com/dimassfeb-09/smart-library-be/middleware"
 	"github.com/gofiber/fiber/v2"
 )
 
-func RegisterAuthRoutes(path string, app *fiber.App, controller *controllers.AccountController) {
+func RegisterAuthRoutes(path string, app *fiber.App, auth *middleware.Auth, controller *controllers.AccountController, prc *controllers.PasswordResetController, tfc *controllers.TwoFactorController) {
 	app.Post(fmt.Sprintf("/%s/register", path), controller.RegisterAccount)
 	app.Post(fmt.Sprintf("/%s/login", path), controller.LoginAccount)
+	app.Post(fmt.Sprintf("/%s/login/two-factor", path), tfc.LoginTwoFactor)
+	app.Post(fmt.Sprintf("/%s/login/two-factor/setup", path), tfc.BeginLoginTwoFactorSetup)
+	app.Post(fmt.Sprintf("/%s/refresh", path), controller.RefreshToken)
+	app.Get(fmt.Sprintf("/%s/verify-email", path), controller.VerifyEmail)
+	app.Post(fmt.Sprintf("/%s/logout", path), auth.Authenticate(), controller.Logout)
+	app.Post(fmt.Sprintf("/%s/logout-all", path), auth.Authenticate(), controller.LogoutAll)
+	app.Post(fmt.Sprintf("/%s/forgot-password", path), prc.ForgotPassword)
+	app.Post(fmt.Sprintf("/%s/reset-password", path), prc.ResetPassword)
 }
@@ -0,0 +1,397 @@
+package router
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dimassfeb-09/smart-library-be/controllers"
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/middleware"
+	"github.com/dimassfeb-09/smart-library-be/repository"
+	"github.com/dimassfeb-09/smart-library-be/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// access is the set of policies guarding a route.
+type access int
+
+const (
+	anyAccount access = iota
+	adminOnly
+	adminOrStudent
+	accountOwnerOnly
+	adminOrAccountOwner
+	adminOrStudentOwner
+	adminOrTransactionOwner
+)
+
+// The owner's account, student and transaction are the ones in the request
+// paths; the other student owns none of them.
+const (
+	adminAccountID        = 1
+	ownerAccountID        = 7
+	ownerStudentID        = 70
+	otherAccountID        = 8
+	otherStudentID        = 80
+	ownerTransactionID    = "TRX-70"
+	testAccessTokenSecret = "test-secret"
+)
+
+// routeParams fills in the route params of the requests. Student ids are
+// only ever taken from :id and :studentId.
+var routeParams = map[string]string{
+	"id":            "70",
+	"accountId":     "7",
+	"studentId":     "70",
+	"transactionId": ownerTransactionID,
+	"bookId":        "5",
+	"itemId":        "9",
+	"deviceId":      "3",
+	"keyId":         "2",
+}
+
+// protectedRoutes lists every route that takes an access token, by the path
+// it is registered with.
+var protectedRoutes = []struct {
+	method string
+	path   string
+	access access
+}{
+	{http.MethodGet, "/books", adminOrStudent},
+	{http.MethodPost, "/books", adminOnly},
+	{http.MethodGet, "/books/:id", adminOrStudent},
+	{http.MethodPut, "/books/:id", adminOnly},
+	{http.MethodDelete, "/books/:id", adminOnly},
+	{http.MethodGet, "/books/:id/items", adminOrStudent},
+	{http.MethodPost, "/books/:id/items", adminOnly},
+	{http.MethodPut, "/books/:id/items/:itemId", adminOnly},
+	{http.MethodDelete, "/books/:id/items/:itemId", adminOnly},
+
+	{http.MethodGet, "/cards", adminOnly},
+	{http.MethodPost, "/cards", adminOnly},
+	{http.MethodGet, "/cards/:id", adminOnly},
+	{http.MethodPut, "/cards/:id", adminOnly},
+	{http.MethodDelete, "/cards/:id", adminOnly},
+	{http.MethodPut, "/cards/:id/status", adminOnly},
+	{http.MethodPost, "/cards/:id/replace", adminOnly},
+	{http.MethodGet, "/cards/:id/history", adminOnly},
+
+	{http.MethodGet, "/students", adminOnly},
+	{http.MethodPost, "/students", adminOnly},
+	{http.MethodGet, "/students/:id", adminOrStudentOwner},
+	{http.MethodPut, "/students/:id", adminOnly},
+	{http.MethodDelete, "/students/:id", adminOnly},
+	{http.MethodPost, "/students/:id/card/lost", adminOrStudentOwner},
+
+	{http.MethodGet, "/borrows", adminOnly},
+	{http.MethodPost, "/borrows", adminOnly},
+	{http.MethodGet, "/borrows/student/:studentId", adminOrStudentOwner},
+	{http.MethodGet, "/borrows/book/:bookId", adminOnly},
+	{http.MethodGet, "/borrows/:transactionId", adminOrTransactionOwner},
+	{http.MethodPut, "/borrows/:transactionId", adminOnly},
+
+	{http.MethodGet, "/accounts/:accountId", adminOrAccountOwner},
+	{http.MethodGet, "/accounts", adminOnly},
+	{http.MethodPost, "/accounts/admins", adminOnly},
+	{http.MethodGet, "/accounts/search", adminOnly},
+	{http.MethodGet, "/accounts/locked", adminOnly},
+	{http.MethodPut, "/accounts/:accountId", adminOnly},
+	{http.MethodDelete, "/accounts/:accountId", adminOnly},
+	{http.MethodPost, "/accounts/:accountId/verification-email", adminOrAccountOwner},
+	{http.MethodPut, "/accounts/:accountId/verify", adminOnly},
+	{http.MethodPut, "/accounts/:accountId/password", accountOwnerOnly},
+	{http.MethodPut, "/accounts/:accountId/level", adminOnly},
+	{http.MethodPost, "/accounts/:accountId/unlock", adminOnly},
+	{http.MethodGet, "/accounts/:accountId/login-history", adminOrAccountOwner},
+	{http.MethodPost, "/accounts/:accountId/two-factor", accountOwnerOnly},
+	{http.MethodPost, "/accounts/:accountId/two-factor/confirm", accountOwnerOnly},
+	{http.MethodDelete, "/accounts/:accountId/two-factor", accountOwnerOnly},
+	{http.MethodPost, "/accounts/:accountId/two-factor/recovery-codes", accountOwnerOnly},
+	{http.MethodGet, "/accounts/:accountId/notifications", adminOrAccountOwner},
+
+	{http.MethodGet, "/devices/health", adminOnly},
+	{http.MethodGet, "/devices", adminOnly},
+	{http.MethodPost, "/devices", adminOnly},
+	{http.MethodGet, "/devices/:deviceId", adminOnly},
+	{http.MethodPut, "/devices/:deviceId", adminOnly},
+	{http.MethodDelete, "/devices/:deviceId", adminOnly},
+	{http.MethodGet, "/devices/:deviceId/keys", adminOnly},
+	{http.MethodPost, "/devices/:deviceId/keys", adminOnly},
+	{http.MethodDelete, "/devices/:deviceId/keys/:keyId", adminOnly},
+	{http.MethodGet, "/devices/:deviceId/calls", adminOnly},
+	{http.MethodGet, "/devices/:deviceId/scans/next", adminOnly},
+	{http.MethodGet, "/devices/:deviceId/scans/stream", adminOnly},
+
+	{http.MethodGet, "/scans", adminOnly},
+	{http.MethodGet, "/security/events", adminOnly},
+
+	{http.MethodPost, "/enrollments", adminOnly},
+	{http.MethodGet, "/enrollments/:id", adminOnly},
+	{http.MethodPost, "/enrollments/:id/close", adminOnly},
+	{http.MethodPost, "/enrollments/:id/books", adminOnly},
+
+	{http.MethodPost, "/auth/logout", anyAccount},
+	{http.MethodPost, "/auth/logout-all", anyAccount},
+}
+
+// newTestApp registers every route the way main does. The controllers are
+// never reached: the last handler of each route is swapped for one that
+// answers 200, so the tests only exercise authentication and the policies.
+func newTestApp(t *testing.T) (*fiber.App, sqlmock.Sqlmock, *helper.EnvJWT) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	envJWT := &helper.EnvJWT{Algorithm: "HS256", Secret: []byte(testAccessTokenSecret), Issuer: "smart-library", AccessTokenTTL: time.Minute}
+
+	studentServices := services.NewStudentServices(db, repository.NewStudentRepository())
+	borrowServices := services.NewBorrowServices(db, repository.NewBorrowRepository(), studentServices, nil, nil, nil)
+	sessionServices := services.NewSessionServices(db, repository.NewSessionRepository(), envJWT)
+	auth := middleware.NewAuth(envJWT, sessionServices, studentServices, borrowServices)
+	deviceAuth := middleware.NewDeviceAuth(services.NewDeviceServices(db, repository.NewDeviceRepository()))
+	notificationController := controllers.NewNotificationController(services.NewNotificationServices(db, studentServices, nil, borrowServices))
+
+	// RegisterAccountRoutes sends the due date notifications once.
+	mock.ExpectQuery(regexp.QuoteMeta("FROM borrows")).WillReturnError(sql.ErrConnDone)
+
+	app := fiber.New()
+	RegisterBookRoutes("books", app, auth, &controllers.BookController{}, &controllers.BookCardController{}, &controllers.BookItemController{})
+	RegisterCardRoutes("cards", app, auth, deviceAuth, &controllers.CardController{}, &controllers.DeviceScanController{}, &controllers.ScanSyncController{})
+	RegisterStudentRoutes("students", app, auth, &controllers.StudentController{}, &controllers.StudentCardController{})
+	RegisterBorrowRoutes("borrows", app, auth, &controllers.BorrowController{})
+	RegisterAccountRoutes("accounts", app, auth, &controllers.AccountController{}, notificationController, &controllers.TwoFactorController{})
+	RegisterDeviceHealthRoutes("devices", app, auth, deviceAuth, &controllers.DeviceHealthController{})
+	RegisterDeviceRoutes("devices", app, auth, &controllers.DeviceController{}, &controllers.DeviceScanController{})
+	RegisterScanRoutes("scans", app, auth, &controllers.ScanEventController{})
+	RegisterSecurityRoutes("security", app, auth, deviceAuth, &controllers.SecurityGateController{})
+	RegisterKioskRoutes("kiosk", app, deviceAuth, &controllers.KioskController{})
+	RegisterBookReturnRoutes("returns", app, deviceAuth, &controllers.BookReturnController{})
+	RegisterEnrollmentRoutes("enrollments", app, auth, &controllers.EnrollmentController{})
+	RegisterAuthRoutes("auth", app, auth, &controllers.AccountController{}, &controllers.PasswordResetController{}, &controllers.TwoFactorController{})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	// GetRoutes copies the routes but not their handler slices, so this
+	// replaces the handler the app will run.
+	for _, route := range app.GetRoutes(true) {
+		route.Handlers[len(route.Handlers)-1] = func(ctx *fiber.Ctx) error {
+			return ctx.SendStatus(http.StatusOK)
+		}
+	}
+
+	return app, mock, envJWT
+}
+
+func requestPath(t *testing.T, route string) string {
+	t.Helper()
+
+	segments := strings.Split(route, "/")
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, ":") {
+			continue
+		}
+		value, ok := routeParams[segment[1:]]
+		if !ok {
+			t.Fatalf("no value for route param %s", segment)
+		}
+		segments[i] = value
+	}
+	return strings.Join(segments, "/")
+}
+
+type testAccount struct {
+	name      string
+	accountID int
+	studentID int
+	level     string
+}
+
+var (
+	adminAccount = testAccount{"admin", adminAccountID, 0, middleware.LevelAdmin}
+	ownerAccount = testAccount{"owner", ownerAccountID, ownerStudentID, middleware.LevelStudent}
+	otherAccount = testAccount{"other student", otherAccountID, otherStudentID, middleware.LevelStudent}
+	guestAccount = testAccount{"unknown level", 9, 0, "guest"}
+)
+
+func accessToken(t *testing.T, envJWT *helper.EnvJWT, account testAccount) string {
+	t.Helper()
+
+	token, _, err := helper.GenerateAccessToken(envJWT, &entity.AccountResponse{ID: account.accountID, Level: account.level}, account.accountID*10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// expectAuthorization queues the queries the middleware makes for a request
+// by account to a route guarded by policies.
+func expectAuthorization(mock sqlmock.Sqlmock, policies access, account testAccount) {
+	mock.ExpectQuery(regexp.QuoteMeta("FROM sessions WHERE id = ? AND account_id = ?")).
+		WithArgs(account.accountID*10, account.accountID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	if account.level == middleware.LevelAdmin {
+		return
+	}
+
+	if policies == adminOrStudentOwner || policies == adminOrTransactionOwner {
+		mock.ExpectQuery(regexp.QuoteMeta("FROM students WHERE account_id = ?")).
+			WithArgs(account.accountID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "npm", "card_id"}).AddRow(account.studentID, account.name, "1234", nil))
+	}
+
+	if policies == adminOrTransactionOwner {
+		mock.ExpectQuery(regexp.QuoteMeta("FROM borrows WHERE transaction_id = ?")).
+			WithArgs(ownerTransactionID).
+			WillReturnRows(sqlmock.NewRows([]string{"book_id", "student_id", "transaction_id", "borrow_date", "due_date", "return_date"}).
+				AddRow(5, ownerStudentID, ownerTransactionID, "2024-05-01 10:00:00", "2024-05-08 10:00:00", nil))
+	}
+}
+
+func TestProtectedRoutes(t *testing.T) {
+	app, mock, envJWT := newTestApp(t)
+
+	type accessCase struct {
+		account testAccount
+		want    int
+	}
+	cases := map[access][]accessCase{
+		anyAccount: {
+			{guestAccount, http.StatusOK},
+			{ownerAccount, http.StatusOK},
+		},
+		adminOnly: {
+			{ownerAccount, http.StatusForbidden},
+			{adminAccount, http.StatusOK},
+		},
+		adminOrStudent: {
+			{guestAccount, http.StatusForbidden},
+			{ownerAccount, http.StatusOK},
+			{adminAccount, http.StatusOK},
+		},
+		accountOwnerOnly: {
+			{adminAccount, http.StatusForbidden},
+			{otherAccount, http.StatusForbidden},
+			{ownerAccount, http.StatusOK},
+		},
+		adminOrAccountOwner: {
+			{otherAccount, http.StatusForbidden},
+			{ownerAccount, http.StatusOK},
+			{adminAccount, http.StatusOK},
+		},
+		adminOrStudentOwner: {
+			{otherAccount, http.StatusForbidden},
+			{ownerAccount, http.StatusOK},
+			{adminAccount, http.StatusOK},
+		},
+		adminOrTransactionOwner: {
+			{otherAccount, http.StatusForbidden},
+			{ownerAccount, http.StatusOK},
+			{adminAccount, http.StatusOK},
+		},
+	}
+
+	for _, route := range protectedRoutes {
+		t.Run(route.method+" "+route.path, func(t *testing.T) {
+			t.Run("no token", func(t *testing.T) {
+				resp, err := app.Test(httptest.NewRequest(route.method, requestPath(t, route.path), nil))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if resp.StatusCode != http.StatusUnauthorized {
+					t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+				}
+			})
+
+			for _, tt := range cases[route.access] {
+				t.Run(tt.account.name, func(t *testing.T) {
+					expectAuthorization(mock, route.access, tt.account)
+
+					req := httptest.NewRequest(route.method, requestPath(t, route.path), nil)
+					req.Header.Set(fiber.HeaderAuthorization, "Bearer "+accessToken(t, envJWT, tt.account))
+					resp, err := app.Test(req)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if resp.StatusCode != tt.want {
+						t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+					}
+					if err := mock.ExpectationsWereMet(); err != nil {
+						t.Fatal(err)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestRevokedSessionIsRejected(t *testing.T) {
+	app, mock, envJWT := newTestApp(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM sessions WHERE id = ? AND account_id = ?")).
+		WithArgs(adminAccountID*10, adminAccountID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	req := httptest.NewRequest(http.MethodGet, "/books", nil)
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer "+accessToken(t, envJWT, adminAccount))
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestProtectedRoutesAreListed finds every registered route that asks for an
+// access token and fails when protectedRoutes has no cases for it, so a new
+// route cannot ship without its policy being tested.
+func TestProtectedRoutesAreListed(t *testing.T) {
+	app, _, _ := newTestApp(t)
+
+	listed := make(map[string]bool)
+	for _, route := range protectedRoutes {
+		listed[route.method+" "+route.path] = true
+	}
+
+	for _, route := range app.GetRoutes(true) {
+		if route.Method == http.MethodHead {
+			continue
+		}
+
+		resp, err := app.Test(httptest.NewRequest(route.Method, requestPath(t, route.Path), nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body entity.ErrorResponse
+		json.NewDecoder(resp.Body).Decode(&body)
+
+		key := route.Method + " " + route.Path
+		takesAccessToken := resp.StatusCode == http.StatusUnauthorized && body.Message == "Missing or malformed access token"
+		if takesAccessToken && !listed[key] {
+			t.Errorf("%s takes an access token but is not in protectedRoutes", key)
+		}
+		delete(listed, key)
+	}
+
+	for key := range listed {
+		t.Errorf("%s is in protectedRoutes but not registered", key)
+	}
+}
@@ -0,0 +1,14 @@
+package router
+
+import (
+	"fmt"
+
+	"github.com/dimassfeb-09/smart-library-be/controllers"
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/middleware"
+	"github.com/gofiber/fiber/v2"
+)
+
+func RegisterBookReturnRoutes(path string, app *fiber.App, deviceAuth *middleware.DeviceAuth, controller *controllers.BookReturnController) {
+	app.Post(fmt.Sprintf("/%s", path), deviceAuth.Authenticate(entity.ScopeBookReturn), controller.ReturnBook)
+}
@@ -3,13 +3,21 @@ package router
 import (
 	"fmt"
 	"github.com/dimassfeb-09/smart-library-be/controllers"
+	"github.com/dimassfeb-09/smart-library-be/middleware"
 	"github.com/gofiber/fiber/v2"
 )
 
-func RegisterBookRoutes(path string, app *fiber.App, bc *controllers.BookController, bcc *controllers.BookCardController) {
-	app.Get(fmt.Sprintf("/%s", path), bc.GetBooks)
-	app.Get(fmt.Sprintf("/%s/:id", path), bc.GetBookByID)
-	app.Delete(fmt.Sprintf("/%s/:id", path), bc.DeleteBookByID)
-	app.Put(fmt.Sprintf("/%s/:id", path), bcc.UpdateBook)
-	app.Post(fmt.Sprintf("/%s", path), bcc.InsertBook)
+func RegisterBookRoutes(path string, app *fiber.App, auth *middleware.Auth, bc *controllers.BookController, bcc *controllers.BookCardController, bic *controllers.BookItemController) {
+	app.Use(fmt.Sprintf("/%s", path), auth.Authenticate())
+
+	app.Get(fmt.Sprintf("/%s", path), auth.Authorize(middleware.Admin, middleware.Student), bc.GetBooks)
+	app.Get(fmt.Sprintf("/%s/:id", path), auth.Authorize(middleware.Admin, middleware.Student), bc.GetBookByID)
+	app.Delete(fmt.Sprintf("/%s/:id", path), auth.Authorize(middleware.Admin), bc.DeleteBookByID)
+	app.Put(fmt.Sprintf("/%s/:id", path), auth.Authorize(middleware.Admin), bcc.UpdateBook)
+	app.Post(fmt.Sprintf("/%s", path), auth.Authorize(middleware.Admin), bcc.InsertBook)
+
+	app.Get(fmt.Sprintf("/%s/:id/items", path), auth.Authorize(middleware.Admin, middleware.Student), bic.GetBookItems)
+	app.Post(fmt.Sprintf("/%s/:id/items", path), auth.Authorize(middleware.Admin), bic.InsertBookItem)
+	app.Put(fmt.Sprintf("/%s/:id/items/:itemId", path), auth.Authorize(middleware.Admin), bic.UpdateBookItem)
+	app.Delete(fmt.Sprintf("/%s/:id/items/:itemId", path), auth.Authorize(middleware.Admin), bic.DeleteBookItem)
 }
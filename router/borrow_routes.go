@@ -4,14 +4,17 @@ import (
 	"fmt"
 
 	"github.com/dimassfeb-09/smart-library-be/controllers"
+	"github.com/dimassfeb-09/smart-library-be/middleware"
 	"github.com/gofiber/fiber/v2"
 )
 
-func RegisterBorrowRoutes(path string, app *fiber.App, controller *controllers.BorrowController) {
-	app.Get(fmt.Sprintf("/%s/student/:studentId", path), controller.GetTransactionsByStudentID)
-	app.Get(fmt.Sprintf("/%s", path), controller.GetBorrows)
-	app.Get(fmt.Sprintf("/%s/book/:bookId", path), controller.GetBorrowsByBookID)
-	app.Post(fmt.Sprintf("/%s", path), controller.InsertBorrow)
-	app.Get(fmt.Sprintf("/%s/:transactionId", path), controller.GetBorrowByTransactionID)
-	app.Put(fmt.Sprintf("/%s/:transactionId", path), controller.UpdateBorrow)
+func RegisterBorrowRoutes(path string, app *fiber.App, auth *middleware.Auth, controller *controllers.BorrowController) {
+	app.Use(fmt.Sprintf("/%s", path), auth.Authenticate())
+
+	app.Get(fmt.Sprintf("/%s/student/:studentId", path), auth.Authorize(middleware.Admin, middleware.StudentOwner("studentId")), controller.GetTransactionsByStudentID)
+	app.Get(fmt.Sprintf("/%s", path), auth.Authorize(middleware.Admin), controller.GetBorrows)
+	app.Get(fmt.Sprintf("/%s/book/:bookId", path), auth.Authorize(middleware.Admin), controller.GetBorrowsByBookID)
+	app.Post(fmt.Sprintf("/%s", path), auth.Authorize(middleware.Admin), controller.InsertBorrow)
+	app.Get(fmt.Sprintf("/%s/:transactionId", path), auth.Authorize(middleware.Admin, middleware.TransactionOwner("transactionId")), controller.GetBorrowByTransactionID)
+	app.Put(fmt.Sprintf("/%s/:transactionId", path), auth.Authorize(middleware.Admin), controller.UpdateBorrow)
 }
@@ -4,16 +4,26 @@ import (
 	"fmt"
 
 	"github.com/dimassfeb-09/smart-library-be/controllers"
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/middleware"
 	"github.com/gofiber/fiber/v2"
 )
 
-func RegisterCardRoutes(path string, app *fiber.App, controller *controllers.CardController) {
-	app.Get(fmt.Sprintf("/%s/check_card", path), controller.GetCardTypeByUID)
-	app.Post(fmt.Sprintf("/%s/container_card", path), controller.InsertContainerCard)
-	app.Get(fmt.Sprintf("/%s/container_card", path), controller.GetOnceContainerCardByUID)
+func RegisterCardRoutes(path string, app *fiber.App, auth *middleware.Auth, deviceAuth *middleware.DeviceAuth, controller *controllers.CardController, dsc *controllers.DeviceScanController, ssc *controllers.ScanSyncController) {
+	// Reader endpoints are registered before the admin middleware below so
+	// they are matched first and only ever see device api keys.
+	app.Get(fmt.Sprintf("/%s/check_card", path), deviceAuth.Authenticate(entity.ScopeCardLookup), controller.GetCardTypeByUID)
+	app.Post(fmt.Sprintf("/%s/container_card", path), deviceAuth.Authenticate(entity.ScopeScanWrite), dsc.InsertScan)
+	app.Post(fmt.Sprintf("/%s/container_card/batch", path), deviceAuth.Authenticate(entity.ScopeScanWrite), ssc.SyncScans)
+
+	app.Use(fmt.Sprintf("/%s", path), auth.Authenticate(), auth.Authorize(middleware.Admin))
+
 	app.Get(fmt.Sprintf("/%s", path), controller.GetCards)
 	app.Get(fmt.Sprintf("/%s/:id", path), controller.GetCardByID)
 	app.Delete(fmt.Sprintf("/%s/:id", path), controller.DeleteCard)
 	app.Post(fmt.Sprintf("/%s", path), controller.InsertCard)
 	app.Put(fmt.Sprintf("/%s/:id", path), controller.UpdateCard)
+	app.Put(fmt.Sprintf("/%s/:id/status", path), controller.ChangeCardStatus)
+	app.Post(fmt.Sprintf("/%s/:id/replace", path), controller.ReplaceCard)
+	app.Get(fmt.Sprintf("/%s/:id/history", path), controller.GetCardStatusHistory)
 }
@@ -0,0 +1,184 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/middleware"
+)
+
+const (
+	testDeviceID    = 3
+	testDeviceKeyID = 2
+	testDeviceKey   = "sl_test_device_key"
+)
+
+var allDeviceScopes = []string{
+	entity.ScopeScanWrite,
+	entity.ScopeCardLookup,
+	entity.ScopeHeartbeat,
+	entity.ScopeGateCheck,
+	entity.ScopeKiosk,
+	entity.ScopeBookReturn,
+}
+
+// deviceRoutes lists every route that takes a device api key, by the path it
+// is registered with, and the scope the key needs.
+var deviceRoutes = []struct {
+	method string
+	path   string
+	scope  string
+}{
+	{http.MethodGet, "/cards/check_card", entity.ScopeCardLookup},
+	{http.MethodPost, "/cards/container_card", entity.ScopeScanWrite},
+	{http.MethodPost, "/cards/container_card/batch", entity.ScopeScanWrite},
+	{http.MethodPost, "/devices/heartbeat", entity.ScopeHeartbeat},
+	{http.MethodPost, "/security/gate_check", entity.ScopeGateCheck},
+	{http.MethodPost, "/kiosk/sessions", entity.ScopeKiosk},
+	{http.MethodGet, "/kiosk/sessions/:id", entity.ScopeKiosk},
+	{http.MethodPost, "/kiosk/sessions/:id/books", entity.ScopeKiosk},
+	{http.MethodPost, "/kiosk/sessions/:id/confirm", entity.ScopeKiosk},
+	{http.MethodPost, "/kiosk/sessions/:id/cancel", entity.ScopeKiosk},
+	{http.MethodPost, "/returns", entity.ScopeBookReturn},
+}
+
+// expectDeviceKey answers the api key lookup with the given scopes, or with
+// no key at all when revoked, since revoked keys are filtered out by the query.
+func expectDeviceKey(mock sqlmock.Sqlmock, scopes []string, revoked bool) {
+	rows := sqlmock.NewRows([]string{"device_id", "key_id", "name", "uid_format", "scopes", "signing_secret"})
+	if !revoked {
+		rows.AddRow(testDeviceID, testDeviceKeyID, "reader", helper.UIDFormatHex, strings.Join(scopes, ","), nil)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("FROM device_api_keys k JOIN devices d ON d.id = k.device_id WHERE k.key_hash = ? AND k.revoked_at IS NULL")).
+		WithArgs(helper.HashToken(testDeviceKey)).
+		WillReturnRows(rows)
+}
+
+func expectDeviceCall(mock sqlmock.Sqlmock, method, path string, status int) {
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO device_calls")).
+		WithArgs(testDeviceID, testDeviceKeyID, method, path, status, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE device_api_keys SET last_used_at = NOW()")).
+		WithArgs(testDeviceKeyID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE devices SET last_seen_at = NOW()")).
+		WithArgs(testDeviceID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func scopesWithout(scope string) []string {
+	var scopes []string
+	for _, s := range allDeviceScopes {
+		if s != scope {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+func TestDeviceRoutes(t *testing.T) {
+	app, mock, _ := newTestApp(t)
+
+	for _, route := range deviceRoutes {
+		t.Run(route.method+" "+route.path, func(t *testing.T) {
+			path := requestPath(t, route.path)
+
+			tests := []struct {
+				name    string
+				key     string
+				scopes  []string
+				revoked bool
+				want    int
+			}{
+				{name: "no key", want: http.StatusUnauthorized},
+				{name: "revoked key", key: testDeviceKey, scopes: allDeviceScopes, revoked: true, want: http.StatusUnauthorized},
+				{name: "key without the scope", key: testDeviceKey, scopes: scopesWithout(route.scope), want: http.StatusForbidden},
+				{name: "key with only the scope", key: testDeviceKey, scopes: []string{route.scope}, want: http.StatusOK},
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					if tt.key != "" {
+						expectDeviceKey(mock, tt.scopes, tt.revoked)
+					}
+					// Calls are only logged once the key is known.
+					if tt.key != "" && !tt.revoked {
+						expectDeviceCall(mock, route.method, path, tt.want)
+					}
+
+					req := httptest.NewRequest(route.method, path, nil)
+					if tt.key != "" {
+						req.Header.Set(middleware.HeaderDeviceKey, tt.key)
+					}
+					resp, err := app.Test(req)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if resp.StatusCode != tt.want {
+						t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+					}
+					if err := mock.ExpectationsWereMet(); err != nil {
+						t.Fatal(err)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestDeviceKeyIsNotAnAccessToken(t *testing.T) {
+	app, mock, _ := newTestApp(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/cards", nil)
+	req.Header.Set(middleware.HeaderDeviceKey, testDeviceKey)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeviceRoutesAreListed(t *testing.T) {
+	app, _, _ := newTestApp(t)
+
+	listed := make(map[string]bool)
+	for _, route := range deviceRoutes {
+		listed[route.method+" "+route.path] = true
+	}
+
+	for _, route := range app.GetRoutes(true) {
+		if route.Method == http.MethodHead {
+			continue
+		}
+
+		resp, err := app.Test(httptest.NewRequest(route.Method, requestPath(t, route.Path), nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body entity.ErrorResponse
+		json.NewDecoder(resp.Body).Decode(&body)
+
+		key := route.Method + " " + route.Path
+		takesDeviceKey := resp.StatusCode == http.StatusUnauthorized && body.Message == "Missing device api key"
+		if takesDeviceKey && !listed[key] {
+			t.Errorf("%s takes a device api key but is not in deviceRoutes", key)
+		}
+		delete(listed, key)
+	}
+
+	for key := range listed {
+		t.Errorf("%s is in deviceRoutes but not registered", key)
+	}
+}
@@ -0,0 +1,17 @@
+package router
+
+import (
+	"fmt"
+
+	"github.com/dimassfeb-09/smart-library-be/controllers"
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/middleware"
+	"github.com/gofiber/fiber/v2"
+)
+
+// RegisterDeviceHealthRoutes must run before RegisterDeviceRoutes so that
+// /health is not taken as a device id.
+func RegisterDeviceHealthRoutes(path string, app *fiber.App, auth *middleware.Auth, deviceAuth *middleware.DeviceAuth, controller *controllers.DeviceHealthController) {
+	app.Post(fmt.Sprintf("/%s/heartbeat", path), deviceAuth.Authenticate(entity.ScopeHeartbeat), controller.RecordHeartbeat)
+	app.Get(fmt.Sprintf("/%s/health", path), auth.Authenticate(), auth.Authorize(middleware.Admin), controller.GetDevicesHealth)
+}
@@ -0,0 +1,25 @@
+package router
+
+import (
+	"fmt"
+
+	"github.com/dimassfeb-09/smart-library-be/controllers"
+	"github.com/dimassfeb-09/smart-library-be/middleware"
+	"github.com/gofiber/fiber/v2"
+)
+
+func RegisterDeviceRoutes(path string, app *fiber.App, auth *middleware.Auth, controller *controllers.DeviceController, dsc *controllers.DeviceScanController) {
+	admin := auth.Authorize(middleware.Admin)
+
+	app.Get(fmt.Sprintf("/%s", path), auth.Authenticate(), admin, controller.GetDevices)
+	app.Post(fmt.Sprintf("/%s", path), auth.Authenticate(), admin, controller.CreateDevice)
+	app.Get(fmt.Sprintf("/%s/:deviceId", path), auth.Authenticate(), admin, controller.GetDeviceByID)
+	app.Put(fmt.Sprintf("/%s/:deviceId", path), auth.Authenticate(), admin, controller.UpdateDevice)
+	app.Delete(fmt.Sprintf("/%s/:deviceId", path), auth.Authenticate(), admin, controller.DeleteDevice)
+	app.Get(fmt.Sprintf("/%s/:deviceId/keys", path), auth.Authenticate(), admin, controller.GetDeviceAPIKeys)
+	app.Post(fmt.Sprintf("/%s/:deviceId/keys", path), auth.Authenticate(), admin, controller.CreateDeviceAPIKey)
+	app.Delete(fmt.Sprintf("/%s/:deviceId/keys/:keyId", path), auth.Authenticate(), admin, controller.RevokeDeviceAPIKey)
+	app.Get(fmt.Sprintf("/%s/:deviceId/calls", path), auth.Authenticate(), admin, controller.GetDeviceCalls)
+	app.Get(fmt.Sprintf("/%s/:deviceId/scans/next", path), auth.Authenticate(), admin, dsc.GetNextScan)
+	app.Get(fmt.Sprintf("/%s/:deviceId/scans/stream", path), auth.AuthenticateEventStream(), admin, dsc.StreamScans)
+}
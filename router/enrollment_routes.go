@@ -0,0 +1,16 @@
+package router
+
+import (
+	"fmt"
+
+	"github.com/dimassfeb-09/smart-library-be/controllers"
+	"github.com/dimassfeb-09/smart-library-be/middleware"
+	"github.com/gofiber/fiber/v2"
+)
+
+func RegisterEnrollmentRoutes(path string, app *fiber.App, auth *middleware.Auth, controller *controllers.EnrollmentController) {
+	app.Post(fmt.Sprintf("/%s", path), auth.Authenticate(), auth.Authorize(middleware.Admin), controller.OpenEnrollment)
+	app.Get(fmt.Sprintf("/%s/:id", path), auth.Authenticate(), auth.Authorize(middleware.Admin), controller.GetEnrollment)
+	app.Post(fmt.Sprintf("/%s/:id/close", path), auth.Authenticate(), auth.Authorize(middleware.Admin), controller.CloseEnrollment)
+	app.Post(fmt.Sprintf("/%s/:id/books", path), auth.Authenticate(), auth.Authorize(middleware.Admin), controller.MapEnrollmentBooks)
+}
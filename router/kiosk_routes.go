@@ -0,0 +1,20 @@
+package router
+
+import (
+	"fmt"
+
+	"github.com/dimassfeb-09/smart-library-be/controllers"
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/middleware"
+	"github.com/gofiber/fiber/v2"
+)
+
+func RegisterKioskRoutes(path string, app *fiber.App, deviceAuth *middleware.DeviceAuth, controller *controllers.KioskController) {
+	kiosk := deviceAuth.Authenticate(entity.ScopeKiosk)
+
+	app.Post(fmt.Sprintf("/%s/sessions", path), kiosk, controller.StartKioskSession)
+	app.Get(fmt.Sprintf("/%s/sessions/:id", path), kiosk, controller.GetKioskSession)
+	app.Post(fmt.Sprintf("/%s/sessions/:id/books", path), kiosk, controller.AddKioskBook)
+	app.Post(fmt.Sprintf("/%s/sessions/:id/confirm", path), kiosk, controller.ConfirmKioskSession)
+	app.Post(fmt.Sprintf("/%s/sessions/:id/cancel", path), kiosk, controller.CancelKioskSession)
+}
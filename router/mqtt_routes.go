@@ -0,0 +1,20 @@
+package router
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/dimassfeb-09/smart-library-be/controllers"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// RegisterMQTTRoutes subscribes the reader scan topics. It is meant to run
+// from the client's connect handler so it is repeated after a reconnect.
+func RegisterMQTTRoutes(prefix string, qos byte, client mqtt.Client, controller *controllers.MQTTScanController) {
+	topic := fmt.Sprintf("%s/+/scan", prefix)
+	if token := client.Subscribe(topic, qos, controller.HandleScan); token.Wait() && token.Error() != nil {
+		log.Println("mqtt: subscribe", topic, "failed:", token.Error())
+		return
+	}
+	log.Println("mqtt: subscribed to", topic)
+}
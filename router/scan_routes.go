@@ -0,0 +1,13 @@
+package router
+
+import (
+	"fmt"
+
+	"github.com/dimassfeb-09/smart-library-be/controllers"
+	"github.com/dimassfeb-09/smart-library-be/middleware"
+	"github.com/gofiber/fiber/v2"
+)
+
+func RegisterScanRoutes(path string, app *fiber.App, auth *middleware.Auth, controller *controllers.ScanEventController) {
+	app.Get(fmt.Sprintf("/%s", path), auth.Authenticate(), auth.Authorize(middleware.Admin), controller.GetScanEvents)
+}
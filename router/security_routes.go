@@ -0,0 +1,15 @@
+package router
+
+import (
+	"fmt"
+
+	"github.com/dimassfeb-09/smart-library-be/controllers"
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/middleware"
+	"github.com/gofiber/fiber/v2"
+)
+
+func RegisterSecurityRoutes(path string, app *fiber.App, auth *middleware.Auth, deviceAuth *middleware.DeviceAuth, controller *controllers.SecurityGateController) {
+	app.Post(fmt.Sprintf("/%s/gate_check", path), deviceAuth.Authenticate(entity.ScopeGateCheck), controller.CheckGate)
+	app.Get(fmt.Sprintf("/%s/events", path), auth.Authenticate(), auth.Authorize(middleware.Admin), controller.GetSecurityEvents)
+}
@@ -3,13 +3,17 @@ package router
 import (
 	"fmt"
 	"github.com/dimassfeb-09/smart-library-be/controllers"
+	"github.com/dimassfeb-09/smart-library-be/middleware"
 	"github.com/gofiber/fiber/v2"
 )
 
-func RegisterStudentRoutes(path string, app *fiber.App, controller *controllers.StudentController, controllerStudentCard *controllers.StudentCardController) {
-	app.Get(fmt.Sprintf("/%s", path), controller.GetStudents)
-	app.Get(fmt.Sprintf("/%s/:id", path), controller.GetStudentByID)
-	app.Delete(fmt.Sprintf("/%s/:id", path), controller.DeleteStudent)
-	app.Put(fmt.Sprintf("/%s/:id", path), controllerStudentCard.UpdateStudent)
-	app.Post(fmt.Sprintf("/%s", path), controllerStudentCard.InsertStudent)
+func RegisterStudentRoutes(path string, app *fiber.App, auth *middleware.Auth, controller *controllers.StudentController, controllerStudentCard *controllers.StudentCardController) {
+	app.Use(fmt.Sprintf("/%s", path), auth.Authenticate())
+
+	app.Get(fmt.Sprintf("/%s", path), auth.Authorize(middleware.Admin), controller.GetStudents)
+	app.Get(fmt.Sprintf("/%s/:id", path), auth.Authorize(middleware.Admin, middleware.StudentOwner("id")), controller.GetStudentByID)
+	app.Delete(fmt.Sprintf("/%s/:id", path), auth.Authorize(middleware.Admin), controller.DeleteStudent)
+	app.Put(fmt.Sprintf("/%s/:id", path), auth.Authorize(middleware.Admin), controllerStudentCard.UpdateStudent)
+	app.Post(fmt.Sprintf("/%s", path), auth.Authorize(middleware.Admin), controllerStudentCard.InsertStudent)
+	app.Post(fmt.Sprintf("/%s/:id/card/lost", path), auth.Authorize(middleware.Admin, middleware.StudentOwner("id")), controllerStudentCard.ReportCardLost)
 }
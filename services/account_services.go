@@ -4,19 +4,37 @@ import (
 	"context"
 	"database/sql"
 	"github.com/dimassfeb-09/smart-library-be/helper"
+	"log"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/dimassfeb-09/smart-library-be/entity"
 	"github.com/dimassfeb-09/smart-library-be/repository"
 )
 
 type AccountServicesInterface interface {
-	LoginAccount(ctx context.Context, account *entity.Login) (*entity.AccountResponse, *entity.ErrorResponse)
+	LoginAccount(ctx context.Context, account *entity.Login) (*entity.LoginResponse, *entity.ErrorResponse)
+	LoginTwoFactor(ctx context.Context, request *entity.TwoFactorLoginRequest) (*entity.LoginResponse, *entity.ErrorResponse)
+	BeginLoginTwoFactorSetup(ctx context.Context, request *entity.TwoFactorSetupRequest) (*entity.TwoFactorSetupResponse, *entity.ErrorResponse)
+	SetupTwoFactor(ctx context.Context, accountID int) (*entity.TwoFactorSetupResponse, *entity.ErrorResponse)
+	RemoveTwoFactor(ctx context.Context, accountID int, code string) *entity.ErrorResponse
+	RefreshToken(ctx context.Context, request *entity.RefreshTokenRequest) (*entity.LoginResponse, *entity.ErrorResponse)
+	Logout(ctx context.Context, accountID, sessionID int) *entity.ErrorResponse
+	LogoutAll(ctx context.Context, accountID int) *entity.ErrorResponse
 	RegisterAccount(ctx context.Context, account *entity.AccountRequest) *entity.ErrorResponse
+	CreateAdminAccount(ctx context.Context, account *entity.AccountRequest) *entity.ErrorResponse
+	ChangeAccountLevel(ctx context.Context, actorID, accountID int, level string) *entity.ErrorResponse
+	GetAccounts(ctx context.Context, filter *entity.AccountFilter) ([]*entity.AccountSummary, *entity.ErrorResponse)
+	SendVerificationEmail(ctx context.Context, accountID int) *entity.ErrorResponse
+	VerifyEmail(ctx context.Context, token string) *entity.ErrorResponse
+	MarkEmailVerified(ctx context.Context, accountID int) *entity.ErrorResponse
 	InsertAccount(ctx context.Context, account *entity.AccountRequest) *entity.ErrorResponse
 	UpdateAccount(ctx context.Context, account *entity.AccountRequest) *entity.ErrorResponse
 	DeleteAccount(ctx context.Context, accountID int) *entity.ErrorResponse
 	ChangePassword(ctx context.Context, account *entity.AccountChangePasswordRequest, accountID int) *entity.ErrorResponse
+	UnlockAccount(ctx context.Context, accountID int) *entity.ErrorResponse
 	GetAccountByID(ctx context.Context, accountID int) (*entity.AccountResponse, *entity.ErrorResponse)
 	GetAccountByName(ctx context.Context, name string) ([]*entity.AccountResponse, *entity.ErrorResponse)
 	GetAccountByEmail(ctx context.Context, email string) (*entity.AccountResponse, *entity.ErrorResponse)
@@ -26,35 +44,284 @@ type AccountServicesInterface interface {
 type AccountServices struct {
 	*sql.DB
 	*repository.AccountsRepository
+	*repository.PasswordHistoryRepository
 	*StudentServices
+	*SessionServices
+	*LoginAttemptServices
+	*TwoFactorServices
+	JWT            *helper.EnvJWT
+	Mail           helper.Mailer
+	Verification   *helper.EnvEmailVerification
+	PasswordPolicy *helper.EnvPasswordPolicy
 }
 
-func NewAccountServices(DB *sql.DB, ar *repository.AccountsRepository, ss *StudentServices) *AccountServices {
+func NewAccountServices(DB *sql.DB, ar *repository.AccountsRepository, phr *repository.PasswordHistoryRepository, ss *StudentServices, sess *SessionServices, las *LoginAttemptServices, tfs *TwoFactorServices, envJWT *helper.EnvJWT, mailer helper.Mailer, envVerification *helper.EnvEmailVerification, envPasswordPolicy *helper.EnvPasswordPolicy) *AccountServices {
 	return &AccountServices{
-		DB:                 DB,
-		AccountsRepository: ar,
-		StudentServices:    ss,
+		DB:                        DB,
+		AccountsRepository:        ar,
+		PasswordHistoryRepository: phr,
+		StudentServices:           ss,
+		SessionServices:           sess,
+		LoginAttemptServices:      las,
+		TwoFactorServices:         tfs,
+		JWT:                       envJWT,
+		Mail:                      mailer,
+		Verification:              envVerification,
+		PasswordPolicy:            envPasswordPolicy,
 	}
 }
 
-func (s *AccountServices) LoginAccount(ctx context.Context, account *entity.Login) (*entity.AccountResponse, *entity.ErrorResponse) {
-	accountDetail, err := s.GetAccountByEmail(ctx, account.Email)
+func (s *AccountServices) LoginAccount(ctx context.Context, account *entity.Login) (*entity.LoginResponse, *entity.ErrorResponse) {
+	invalidCredentials := helper.ErrorResponse(http.StatusBadRequest, "Email or Password is invalid")
+	attempt := entity.LoginAttempt{
+		Email:     account.Email,
+		IPAddress: account.IPAddress,
+		UserAgent: account.UserAgent,
+	}
+
+	if errorResponse := s.LoginAttemptServices.CheckIPAllowed(ctx, account.IPAddress); errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	accountDetail, errorResponse := s.GetAccountByEmail(ctx, account.Email)
+	if errorResponse != nil {
+		if errorResponse.Code != http.StatusNotFound {
+			return nil, errorResponse
+		}
+		attempt.Reason = "unknown_email"
+		s.LoginAttemptServices.RecordLoginAttempt(ctx, &attempt)
+		return nil, invalidCredentials
+	}
+	attempt.AccountID = accountDetail.ID
+
+	// A locked account gets the same answer as an unknown email, so the lock
+	// does not reveal that the address is registered.
+	if errorResponse := s.LoginAttemptServices.CheckAccountLocked(ctx, accountDetail.ID); errorResponse != nil {
+		if errorResponse.Code != http.StatusTooManyRequests {
+			return nil, errorResponse
+		}
+		attempt.Reason = "locked"
+		s.LoginAttemptServices.RecordLoginAttempt(ctx, &attempt)
+		return nil, invalidCredentials
+	}
+
+	passwordHash, errorResponse := s.getAccountPasswordHash(ctx, account.Email)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+	if isValid := helper.CheckPasswordHash(account.Password, passwordHash); !isValid {
+		attempt.Reason = "invalid_password"
+		s.LoginAttemptServices.RecordLoginAttempt(ctx, &attempt)
+		if errorResponse := s.LoginAttemptServices.RegisterFailedLogin(ctx, accountDetail.ID); errorResponse != nil {
+			return nil, errorResponse
+		}
+		return nil, invalidCredentials
+	}
+
+	if challenge, errorResponse := s.twoFactorChallenge(ctx, accountDetail); challenge != nil || errorResponse != nil {
+		return challenge, errorResponse
+	}
+
+	if errorResponse := s.LoginAttemptServices.ResetFailedLogins(ctx, accountDetail.ID); errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	sessionID, refreshToken, errorResponse := s.SessionServices.CreateSession(ctx, &entity.Session{
+		AccountID: accountDetail.ID,
+		UserAgent: account.UserAgent,
+		IPAddress: account.IPAddress,
+	})
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	attempt.Success = true
+	s.LoginAttemptServices.RecordLoginAttempt(ctx, &attempt)
+
+	return s.loginResponse(accountDetail, sessionID, refreshToken)
+}
+
+// twoFactorChallenge returns the response for the second login step, or nil
+// when the account can sign in with its password alone.
+func (s *AccountServices) twoFactorChallenge(ctx context.Context, account *entity.AccountResponse) (*entity.LoginResponse, *entity.ErrorResponse) {
+	enabled, errorResponse := s.TwoFactorServices.TwoFactorEnabled(ctx, account.ID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	setup := !enabled && account.Level == "admin" && s.TwoFactorServices.Config.RequiredForAdmin
+	if !enabled && !setup {
+		return nil, nil
+	}
+
+	challengeToken, err := helper.GenerateTwoFactorChallengeToken(s.JWT, account.ID, setup, s.TwoFactorServices.Config.ChallengeTTL)
 	if err != nil {
-		return nil, err
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return &entity.LoginResponse{
+		TwoFactorRequired:      enabled,
+		TwoFactorSetupRequired: setup,
+		ChallengeToken:         challengeToken,
+	}, nil
+}
+
+// BeginLoginTwoFactorSetup lets an admin who must use two-factor
+// authentication enroll before their first full sign-in.
+func (s *AccountServices) BeginLoginTwoFactorSetup(ctx context.Context, request *entity.TwoFactorSetupRequest) (*entity.TwoFactorSetupResponse, *entity.ErrorResponse) {
+	claims, err := helper.ParseTwoFactorChallengeToken(s.JWT, request.ChallengeToken)
+	if err != nil || !claims.Setup {
+		return nil, helper.ErrorResponse(http.StatusUnauthorized, "Two-factor challenge is invalid or has expired")
+	}
+
+	accountDetail, errorResponse := s.GetAccountByID(ctx, claims.AccountID)
+	if errorResponse != nil {
+		return nil, errorResponse
 	}
 
-	passwordHash, err := s.getAccountPasswordHash(ctx, account.Email)
+	return s.TwoFactorServices.BeginTwoFactorSetup(ctx, accountDetail)
+}
+
+func (s *AccountServices) LoginTwoFactor(ctx context.Context, request *entity.TwoFactorLoginRequest) (*entity.LoginResponse, *entity.ErrorResponse) {
+	claims, err := helper.ParseTwoFactorChallengeToken(s.JWT, request.ChallengeToken)
 	if err != nil {
-		return nil, err
+		return nil, helper.ErrorResponse(http.StatusUnauthorized, "Two-factor challenge is invalid or has expired")
 	}
-	if isValid := helper.CheckPasswordHash(account.Password, passwordHash); !isValid {
-		return nil, helper.ErrorResponse(http.StatusBadRequest, "Email or Password is invalid")
+
+	accountDetail, errorResponse := s.GetAccountByID(ctx, claims.AccountID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	attempt := entity.LoginAttempt{
+		AccountID: accountDetail.ID,
+		Email:     accountDetail.Email,
+		IPAddress: request.IPAddress,
+		UserAgent: request.UserAgent,
+	}
+
+	if errorResponse := s.LoginAttemptServices.CheckAccountLocked(ctx, accountDetail.ID); errorResponse != nil {
+		attempt.Reason = "locked"
+		s.LoginAttemptServices.RecordLoginAttempt(ctx, &attempt)
+		return nil, errorResponse
+	}
+
+	var recoveryCodes []string
+	if claims.Setup {
+		recoveryCodes, errorResponse = s.TwoFactorServices.ConfirmTwoFactorSetup(ctx, accountDetail.ID, request.Code)
+	} else {
+		errorResponse = s.TwoFactorServices.VerifyTwoFactorCode(ctx, accountDetail.ID, request.Code)
+	}
+	if errorResponse != nil {
+		if errorResponse.Code == http.StatusBadRequest {
+			attempt.Reason = "invalid_two_factor_code"
+			s.LoginAttemptServices.RecordLoginAttempt(ctx, &attempt)
+			if errorResponse := s.LoginAttemptServices.RegisterFailedLogin(ctx, accountDetail.ID); errorResponse != nil {
+				return nil, errorResponse
+			}
+		}
+		return nil, errorResponse
+	}
+
+	if errorResponse := s.LoginAttemptServices.ResetFailedLogins(ctx, accountDetail.ID); errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	sessionID, refreshToken, errorResponse := s.SessionServices.CreateSession(ctx, &entity.Session{
+		AccountID: accountDetail.ID,
+		UserAgent: request.UserAgent,
+		IPAddress: request.IPAddress,
+	})
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	attempt.Success = true
+	s.LoginAttemptServices.RecordLoginAttempt(ctx, &attempt)
+
+	response, errorResponse := s.loginResponse(accountDetail, sessionID, refreshToken)
+	if errorResponse != nil {
+		return nil, errorResponse
 	}
+	response.RecoveryCodes = recoveryCodes
 
-	return accountDetail, nil
+	return response, nil
 }
 
+func (s *AccountServices) SetupTwoFactor(ctx context.Context, accountID int) (*entity.TwoFactorSetupResponse, *entity.ErrorResponse) {
+	accountDetail, errorResponse := s.GetAccountByID(ctx, accountID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	return s.TwoFactorServices.BeginTwoFactorSetup(ctx, accountDetail)
+}
+
+func (s *AccountServices) RemoveTwoFactor(ctx context.Context, accountID int, code string) *entity.ErrorResponse {
+	accountDetail, errorResponse := s.GetAccountByID(ctx, accountID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	return s.TwoFactorServices.DisableTwoFactor(ctx, accountDetail, code)
+}
+
+func (s *AccountServices) RefreshToken(ctx context.Context, request *entity.RefreshTokenRequest) (*entity.LoginResponse, *entity.ErrorResponse) {
+	session, refreshToken, errorResponse := s.SessionServices.RefreshSession(ctx, request.RefreshToken)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	accountDetail, errorResponse := s.GetAccountByID(ctx, session.AccountID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	return s.loginResponse(accountDetail, session.ID, refreshToken)
+}
+
+func (s *AccountServices) Logout(ctx context.Context, accountID, sessionID int) *entity.ErrorResponse {
+	return s.SessionServices.RevokeSession(ctx, sessionID, accountID)
+}
+
+func (s *AccountServices) LogoutAll(ctx context.Context, accountID int) *entity.ErrorResponse {
+	return s.SessionServices.RevokeAllSessions(ctx, accountID)
+}
+
+func (s *AccountServices) loginResponse(account *entity.AccountResponse, sessionID int, refreshToken string) (*entity.LoginResponse, *entity.ErrorResponse) {
+	accessToken, expiresAt, err := helper.GenerateAccessToken(s.JWT, account, sessionID)
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return &entity.LoginResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(time.Until(expiresAt).Seconds()),
+		Account:      account,
+	}, nil
+}
+
+// RegisterAccount is the public sign-up, so it only creates student accounts.
+// Admin accounts are created by another admin through CreateAdminAccount.
 func (s *AccountServices) RegisterAccount(ctx context.Context, r *entity.AccountRequest) *entity.ErrorResponse {
+	if r.Level != "student" {
+		return helper.ErrorResponse(http.StatusForbidden, "Only student accounts can be registered.")
+	}
+
+	return s.registerAccount(ctx, r)
+}
+
+func (s *AccountServices) CreateAdminAccount(ctx context.Context, r *entity.AccountRequest) *entity.ErrorResponse {
+	r.Level = "admin"
+	return s.registerAccount(ctx, r)
+}
+
+func (s *AccountServices) registerAccount(ctx context.Context, r *entity.AccountRequest) *entity.ErrorResponse {
+	if errorResponse := s.checkPasswordPolicy(r.Password); errorResponse != nil {
+		return errorResponse
+	}
 
 	hashPassword, err := helper.HashPassword(r.Password)
 	if err != nil {
@@ -63,13 +330,97 @@ func (s *AccountServices) RegisterAccount(ctx context.Context, r *entity.Account
 
 	account := entity.AccountRequest{
 		Name:     r.Name,
-		NPM:      r.Name,
+		NPM:      r.NPM,
 		Email:    r.Email,
 		Password: hashPassword,
 		Level:    r.Level,
 	}
 
-	return s.InsertAccount(ctx, &account)
+	errorResponse := s.InsertAccount(ctx, &account)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	registered, errorResponse := s.GetAccountByEmail(ctx, account.Email)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	go s.sendVerificationEmail(registered)
+
+	return nil
+}
+
+func (s *AccountServices) SendVerificationEmail(ctx context.Context, accountID int) *entity.ErrorResponse {
+	account, errorResponse := s.AccountsRepository.GetAccountByID(ctx, s.DB, accountID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	if account.EmailVerified {
+		return helper.ErrorResponse(http.StatusConflict, "Email already verified.")
+	}
+
+	if err := s.sendVerificationEmail(account); err != nil {
+		return helper.ErrorResponse(http.StatusBadGateway, "Failed to send verification email")
+	}
+
+	return nil
+}
+
+func (s *AccountServices) sendVerificationEmail(account *entity.AccountResponse) error {
+	token, err := helper.GenerateEmailVerificationToken(s.JWT, account, s.Verification.TokenTTL)
+	if err != nil {
+		log.Println("email verification:", err)
+		return err
+	}
+
+	link := s.Verification.URL + "?token=" + url.QueryEscape(token)
+	body := helper.ActionMailBody("Terima kasih telah mendaftar di Smart Library. Silakan verifikasi email kamu sebelum meminjam buku.", "Verifikasi Email", link)
+	if err := s.Mail.SendHTMLMail(account.Email, "Verifikasi email Smart Library", body); err != nil {
+		log.Println("email verification:", err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *AccountServices) VerifyEmail(ctx context.Context, token string) *entity.ErrorResponse {
+	claims, err := helper.ParseEmailVerificationToken(s.JWT, token)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusBadRequest, "Verification link is invalid or has expired")
+	}
+
+	account, errorResponse := s.AccountsRepository.GetAccountByID(ctx, s.DB, claims.AccountID)
+	if errorResponse != nil || account.Email != claims.Email {
+		return helper.ErrorResponse(http.StatusBadRequest, "Verification link is invalid or has expired")
+	}
+
+	return s.MarkEmailVerified(ctx, account.ID)
+}
+
+func (s *AccountServices) MarkEmailVerified(ctx context.Context, accountID int) *entity.ErrorResponse {
+	_, errorResponse := s.AccountsRepository.GetAccountByID(ctx, s.DB, accountID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	errorResponse = s.AccountsRepository.MarkEmailVerified(ctx, tx, accountID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	if err := tx.Commit(); err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return nil
 }
 
 func (s *AccountServices) InsertAccount(ctx context.Context, account *entity.AccountRequest) *entity.ErrorResponse {
@@ -97,15 +448,17 @@ func (s *AccountServices) InsertAccount(ctx context.Context, account *entity.Acc
 		return errorResponse
 	}
 
-	var student entity.Student
-	student.AccountID = accountId
-	student.Name = account.Name
-	student.NPM = account.NPM
-
-	errorResponse = s.StudentServices.InsertStudent(ctx, tx, &student)
-	if errorResponse != nil {
-		tx.Rollback()
-		return errorResponse
+	if account.Level == "student" {
+		var student entity.Student
+		student.AccountID = accountId
+		student.Name = account.Name
+		student.NPM = account.NPM
+
+		errorResponse = s.StudentServices.InsertStudent(ctx, tx, &student)
+		if errorResponse != nil {
+			tx.Rollback()
+			return errorResponse
+		}
 	}
 
 	return nil
@@ -123,7 +476,7 @@ func (s *AccountServices) UpdateAccount(ctx context.Context, account *entity.Acc
 	}
 	defer tx.Commit()
 
-	_, errorResponse = s.AccountsRepository.InsertAccount(ctx, tx, account)
+	errorResponse = s.AccountsRepository.UpdateAccount(ctx, tx, account)
 	if errorResponse != nil {
 		tx.Rollback()
 		return errorResponse
@@ -144,6 +497,12 @@ func (s *AccountServices) DeleteAccount(ctx context.Context, accountID int) *ent
 	}
 	defer tx.Commit()
 
+	errorResponse = s.SessionRepository.RevokeSessionsByAccountID(ctx, tx, accountID)
+	if errorResponse != nil {
+		tx.Rollback()
+		return errorResponse
+	}
+
 	errorResponse = s.AccountsRepository.DeleteAccount(ctx, tx, accountID)
 	if errorResponse != nil {
 		tx.Rollback()
@@ -153,27 +512,157 @@ func (s *AccountServices) DeleteAccount(ctx context.Context, accountID int) *ent
 	return nil
 }
 
-func (s *AccountServices) ChangePassword(ctx context.Context, account *entity.AccountChangePasswordRequest, accountID int) *entity.ErrorResponse {
-	_, errorResponse := s.AccountsRepository.GetAccountByID(ctx, s.DB, accountID)
+func (s *AccountServices) ChangePassword(ctx context.Context, request *entity.AccountChangePasswordRequest, accountID int) *entity.ErrorResponse {
+	currentHash, errorResponse := s.AccountsRepository.GetAccountPasswordHashByID(ctx, s.DB, accountID)
 	if errorResponse != nil {
 		return errorResponse
 	}
+	if !helper.CheckPasswordHash(request.Password, currentHash) {
+		return helper.ErrorResponse(http.StatusBadRequest, "Current password is invalid")
+	}
 
-	tx, err := s.DB.Begin()
+	if errorResponse := s.checkPasswordPolicy(request.NewPassword); errorResponse != nil {
+		return errorResponse
+	}
+	if errorResponse := s.checkPasswordReuse(ctx, accountID, currentHash, request.NewPassword); errorResponse != nil {
+		return errorResponse
+	}
+
+	passwordHash, err := helper.HashPassword(request.NewPassword)
 	if err != nil {
 		return helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
 	}
-	defer tx.Commit()
 
-	errorResponse = s.AccountsRepository.ChangePassword(ctx, tx, account, accountID)
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	errorResponse = s.replacePassword(ctx, tx, accountID, currentHash, passwordHash)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	errorResponse = s.SessionRepository.RevokeSessionsByAccountID(ctx, tx, accountID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	if err := tx.Commit(); err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return nil
+}
+
+func (s *AccountServices) checkPasswordPolicy(password string) *entity.ErrorResponse {
+	violations := helper.CheckPasswordPolicy(s.PasswordPolicy, password)
+	if len(violations) > 0 {
+		return helper.ErrorResponse(http.StatusBadRequest, "Password "+strings.Join(violations, ", "))
+	}
+
+	return nil
+}
+
+// checkPasswordReuse rejects a password matching the current one or any of
+// the previous ones kept in history, PasswordPolicy.HistorySize in total.
+func (s *AccountServices) checkPasswordReuse(ctx context.Context, accountID int, currentHash, password string) *entity.ErrorResponse {
+	if s.PasswordPolicy.HistorySize == 0 {
+		return nil
+	}
+
+	previousHashes, errorResponse := s.PasswordHistoryRepository.GetRecentPasswordHashes(ctx, s.DB, accountID, s.PasswordPolicy.HistorySize-1)
 	if errorResponse != nil {
-		tx.Rollback()
 		return errorResponse
 	}
 
+	for _, hash := range append([]string{currentHash}, previousHashes...) {
+		if helper.CheckPasswordHash(password, hash) {
+			return helper.ErrorResponse(http.StatusBadRequest, "Password was used recently, choose a different one")
+		}
+	}
+
 	return nil
 }
 
+// replacePassword stores the new hash and moves the old one into history.
+func (s *AccountServices) replacePassword(ctx context.Context, tx *sql.Tx, accountID int, currentHash, passwordHash string) *entity.ErrorResponse {
+	errorResponse := s.AccountsRepository.UpdatePasswordHash(ctx, tx, accountID, passwordHash)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	if s.PasswordPolicy.HistorySize == 0 {
+		return nil
+	}
+
+	errorResponse = s.PasswordHistoryRepository.InsertPasswordHistory(ctx, tx, accountID, currentHash)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	return s.PasswordHistoryRepository.PrunePasswordHistory(ctx, tx, accountID, s.PasswordPolicy.HistorySize-1)
+}
+
+func (s *AccountServices) UnlockAccount(ctx context.Context, accountID int) *entity.ErrorResponse {
+	_, errorResponse := s.AccountsRepository.GetAccountByID(ctx, s.DB, accountID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	return s.LoginAttemptServices.ResetFailedLogins(ctx, accountID)
+}
+
+// ChangeAccountLevel revokes the account's sessions, because access tokens
+// carry the level and would otherwise keep the old permissions until expiry.
+func (s *AccountServices) ChangeAccountLevel(ctx context.Context, actorID, accountID int, level string) *entity.ErrorResponse {
+	if actorID == accountID {
+		return helper.ErrorResponse(http.StatusForbidden, "You can't change the level of your own account.")
+	}
+
+	account, errorResponse := s.AccountsRepository.GetAccountByID(ctx, s.DB, accountID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+	if account.Level == level {
+		return nil
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	errorResponse = s.AccountsRepository.UpdateAccountLevel(ctx, tx, accountID, level)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	errorResponse = s.SessionRepository.RevokeSessionsByAccountID(ctx, tx, accountID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	if err := tx.Commit(); err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return nil
+}
+
+func (s *AccountServices) GetAccounts(ctx context.Context, filter *entity.AccountFilter) ([]*entity.AccountSummary, *entity.ErrorResponse) {
+	return s.AccountsRepository.GetAccounts(ctx, s.DB, filter)
+}
+
+func (s *AccountServices) GetAccountByName(ctx context.Context, name string) ([]*entity.AccountResponse, *entity.ErrorResponse) {
+	if name == "" {
+		return nil, helper.ErrorResponse(http.StatusBadRequest, "name is required")
+	}
+	return s.AccountsRepository.GetAccountByName(ctx, s.DB, name)
+}
+
 func (s *AccountServices) GetAccountByID(ctx context.Context, accountID int) (*entity.AccountResponse, *entity.ErrorResponse) {
 	return s.AccountsRepository.GetAccountByID(ctx, s.DB, accountID)
 }
@@ -0,0 +1,441 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/repository"
+)
+
+var testAccountColumns = []string{"id", "email", "level", "email_verified"}
+
+// passwordHashOf matches a bcrypt hash argument of the given plain password.
+type passwordHashOf string
+
+func (p passwordHashOf) Match(v driver.Value) bool {
+	hash, ok := v.(string)
+	return ok && helper.CheckPasswordHash(string(p), hash)
+}
+
+type testMail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// testMailer records mail instead of sending it.
+type testMailer struct {
+	mu   sync.Mutex
+	sent []testMail
+}
+
+func (m *testMailer) SendHTMLMail(to string, subject string, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, testMail{To: to, Subject: subject, Body: body})
+	return nil
+}
+
+func (m *testMailer) Sent() []testMail {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]testMail(nil), m.sent...)
+}
+
+// waitForMail waits until at least n messages went out, for mail sent from
+// a goroutine.
+func (m *testMailer) waitForMail(t *testing.T, n int) []testMail {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for len(m.Sent()) < n && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	return m.Sent()
+}
+
+var mailLinkPattern = regexp.MustCompile(`href="([^"]+)"`)
+
+// verificationToken checks that mail carries a link to the verification page
+// whose token belongs to the account, and returns that token.
+func verificationToken(t *testing.T, s *AccountServices, mail testMail, accountID int, email string) string {
+	t.Helper()
+
+	if mail.To != email {
+		t.Fatalf("mail sent to %q, want %q", mail.To, email)
+	}
+
+	match := mailLinkPattern.FindStringSubmatch(mail.Body)
+	if match == nil {
+		t.Fatalf("mail has no link: %s", mail.Body)
+	}
+	link, err := url.Parse(html.UnescapeString(match[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := link.Query().Get("token")
+	link.RawQuery = ""
+	if link.String() != s.Verification.URL {
+		t.Fatalf("link points to %q, want %q", link.String(), s.Verification.URL)
+	}
+
+	claims, err := helper.ParseEmailVerificationToken(s.JWT, token)
+	if err != nil {
+		t.Fatalf("token in link is invalid: %v", err)
+	}
+	if claims.AccountID != accountID || claims.Email != email {
+		t.Fatalf("token is for account %d %q, want %d %q", claims.AccountID, claims.Email, accountID, email)
+	}
+
+	return token
+}
+
+func mustHashPassword(t *testing.T, password string) string {
+	t.Helper()
+	hash, err := helper.HashPassword(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+// newTestAccountServices builds AccountServices through its constructor, the
+// way main.go does, on top of a mocked database.
+func newTestAccountServices(t *testing.T) (*AccountServices, sqlmock.Sqlmock, *testMailer) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	envJWT := &helper.EnvJWT{
+		Algorithm:       "HS256",
+		Secret:          []byte("test-secret"),
+		Issuer:          "smart-library-test",
+		AccessTokenTTL:  time.Minute,
+		RefreshTokenTTL: time.Hour,
+	}
+	envPasswordPolicy := &helper.EnvPasswordPolicy{
+		MinLength:        8,
+		RequireUppercase: true,
+		RequireLowercase: true,
+		RequireDigit:     true,
+		HistorySize:      3,
+	}
+
+	studentServices := NewStudentServices(db, repository.NewStudentRepository())
+	sessionServices := NewSessionServices(db, repository.NewSessionRepository(), envJWT)
+	loginAttemptServices := NewLoginAttemptServices(db, repository.NewLoginAttemptRepository(), helper.GetEnvLoginProtection())
+	twoFactorServices := NewTwoFactorServices(db, repository.NewTwoFactorRepository(), helper.GetEnvTwoFactor())
+
+	mailer := &testMailer{}
+
+	s := NewAccountServices(db, repository.NewAccountsRepository(), repository.NewPasswordHistoryRepository(), studentServices, sessionServices, loginAttemptServices, twoFactorServices, envJWT,
+		mailer, &helper.EnvEmailVerification{URL: "https://library.test/verify", TokenTTL: time.Hour}, envPasswordPolicy)
+
+	return s, mock, mailer
+}
+
+func TestRegisterAccount(t *testing.T) {
+	s, mock, mailer := newTestAccountServices(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM accounts WHERE email = ?")).
+		WithArgs("budi@student.test").
+		WillReturnRows(sqlmock.NewRows(testAccountColumns))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM students WHERE npm = ?")).
+		WithArgs("50421001").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "npm", "card_id"}))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO accounts(email, password, level)")).
+		WithArgs("budi@student.test", passwordHashOf("Correct-Horse-42"), "student").
+		WillReturnResult(sqlmock.NewResult(7, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO students (name, npm, account_id)")).
+		WithArgs("Budi", "50421001", 7).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(regexp.QuoteMeta("FROM accounts WHERE email = ?")).
+		WithArgs("budi@student.test").
+		WillReturnRows(sqlmock.NewRows(testAccountColumns).AddRow(7, "budi@student.test", "student", false))
+
+	errorResponse := s.RegisterAccount(context.Background(), &entity.AccountRequest{
+		Name:     "Budi",
+		NPM:      "50421001",
+		Email:    "budi@student.test",
+		Password: "Correct-Horse-42",
+		Level:    "student",
+	})
+	if errorResponse != nil {
+		t.Fatalf("RegisterAccount: %d %s", errorResponse.Code, errorResponse.Message)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := mailer.waitForMail(t, 1)
+	if len(sent) != 1 {
+		t.Fatalf("sent %d mails, want 1", len(sent))
+	}
+	verificationToken(t, s, sent[0], 7, "budi@student.test")
+}
+
+func TestCreateAdminAccount(t *testing.T) {
+	s, mock, mailer := newTestAccountServices(t)
+
+	// Admins have no student row, so there is no NPM lookup or insert.
+	mock.ExpectQuery(regexp.QuoteMeta("FROM accounts WHERE email = ?")).
+		WithArgs("sari@library.test").
+		WillReturnRows(sqlmock.NewRows(testAccountColumns))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO accounts(email, password, level)")).
+		WithArgs("sari@library.test", passwordHashOf("Correct-Horse-42"), "admin").
+		WillReturnResult(sqlmock.NewResult(8, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(regexp.QuoteMeta("FROM accounts WHERE email = ?")).
+		WithArgs("sari@library.test").
+		WillReturnRows(sqlmock.NewRows(testAccountColumns).AddRow(8, "sari@library.test", "admin", false))
+
+	errorResponse := s.CreateAdminAccount(context.Background(), &entity.AccountRequest{
+		Name:     "Sari",
+		Email:    "sari@library.test",
+		Password: "Correct-Horse-42",
+	})
+	if errorResponse != nil {
+		t.Fatalf("CreateAdminAccount: %d %s", errorResponse.Code, errorResponse.Message)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := mailer.waitForMail(t, 1)
+	if len(sent) != 1 {
+		t.Fatalf("sent %d mails, want 1", len(sent))
+	}
+	verificationToken(t, s, sent[0], 8, "sari@library.test")
+}
+
+func TestUpdateAccount(t *testing.T) {
+	s, mock, _ := newTestAccountServices(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM accounts WHERE id = ?")).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows(testAccountColumns).AddRow(7, "budi@student.test", "student", true))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE accounts SET email = ?, level = ? WHERE id = ?")).
+		WithArgs("budi.baru@student.test", "student", 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	errorResponse := s.UpdateAccount(context.Background(), &entity.AccountRequest{
+		ID:    7,
+		Name:  "Budi",
+		NPM:   "50421001",
+		Email: "budi.baru@student.test",
+		Level: "student",
+	})
+	if errorResponse != nil {
+		t.Fatalf("UpdateAccount: %d %s", errorResponse.Code, errorResponse.Message)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegisterAccountRejectsWeakPassword(t *testing.T) {
+	s, mock, mailer := newTestAccountServices(t)
+
+	errorResponse := s.RegisterAccount(context.Background(), &entity.AccountRequest{
+		Name:     "Budi",
+		NPM:      "50421001",
+		Email:    "budi@student.test",
+		Password: "password",
+		Level:    "student",
+	})
+	if errorResponse == nil || errorResponse.Code != http.StatusBadRequest {
+		t.Fatalf("RegisterAccount = %v, want 400", errorResponse)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if sent := mailer.Sent(); len(sent) != 0 {
+		t.Fatalf("sent %d mails, want none", len(sent))
+	}
+}
+
+func TestLoginLockedAccountLooksLikeUnknownEmail(t *testing.T) {
+	login := func(t *testing.T, registered bool) *entity.ErrorResponse {
+		s, mock, _ := newTestAccountServices(t)
+
+		mock.ExpectQuery(regexp.QuoteMeta("FROM login_attempts WHERE ip_address = ?")).
+			WithArgs("10.0.0.1", sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"count", "since_last"}).AddRow(0, 0))
+		accounts := sqlmock.NewRows(testAccountColumns)
+		if registered {
+			accounts.AddRow(7, "budi@student.test", "student", true)
+		}
+		mock.ExpectQuery(regexp.QuoteMeta("FROM accounts WHERE email = ?")).
+			WithArgs("budi@student.test").
+			WillReturnRows(accounts)
+		reason := "unknown_email"
+		if registered {
+			reason = "locked"
+			mock.ExpectQuery(regexp.QuoteMeta("locked_until")).
+				WithArgs(7).
+				WillReturnRows(sqlmock.NewRows([]string{"remaining"}).AddRow(600))
+		}
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO login_attempts")).
+			WithArgs(sqlmock.AnyArg(), "budi@student.test", "10.0.0.1", "test", false, reason).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		_, errorResponse := s.LoginAccount(context.Background(), &entity.Login{
+			Email:     "budi@student.test",
+			Password:  "Correct-Horse-42",
+			IPAddress: "10.0.0.1",
+			UserAgent: "test",
+		})
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+		return errorResponse
+	}
+
+	unknown := login(t, false)
+	locked := login(t, true)
+	if unknown == nil || locked == nil {
+		t.Fatalf("LoginAccount = %v and %v, want errors", unknown, locked)
+	}
+	if *unknown != *locked {
+		t.Fatalf("locked account answered %+v, unknown email %+v", *locked, *unknown)
+	}
+}
+
+func TestSendVerificationEmail(t *testing.T) {
+	tests := []struct {
+		name     string
+		verified bool
+		wantCode int
+		wantSent int
+	}{
+		{name: "unverified", wantSent: 1},
+		{name: "already verified", verified: true, wantCode: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock, mailer := newTestAccountServices(t)
+
+			mock.ExpectQuery(regexp.QuoteMeta("FROM accounts WHERE id = ?")).
+				WithArgs(7).
+				WillReturnRows(sqlmock.NewRows(testAccountColumns).AddRow(7, "budi@student.test", "student", tt.verified))
+
+			errorResponse := s.SendVerificationEmail(context.Background(), 7)
+			switch {
+			case tt.wantCode == 0 && errorResponse != nil:
+				t.Fatalf("SendVerificationEmail: %d %s", errorResponse.Code, errorResponse.Message)
+			case tt.wantCode != 0 && (errorResponse == nil || errorResponse.Code != tt.wantCode):
+				t.Fatalf("SendVerificationEmail = %v, want %d", errorResponse, tt.wantCode)
+			}
+
+			sent := mailer.Sent()
+			if len(sent) != tt.wantSent {
+				t.Fatalf("sent %d mails, want %d", len(sent), tt.wantSent)
+			}
+			if tt.wantSent == 0 {
+				return
+			}
+
+			// The link from the resent mail has to verify the account.
+			token := verificationToken(t, s, sent[0], 7, "budi@student.test")
+			mock.ExpectQuery(regexp.QuoteMeta("FROM accounts WHERE id = ?")).
+				WithArgs(7).
+				WillReturnRows(sqlmock.NewRows(testAccountColumns).AddRow(7, "budi@student.test", "student", false))
+			mock.ExpectQuery(regexp.QuoteMeta("FROM accounts WHERE id = ?")).
+				WithArgs(7).
+				WillReturnRows(sqlmock.NewRows(testAccountColumns).AddRow(7, "budi@student.test", "student", false))
+			mock.ExpectBegin()
+			mock.ExpectExec(regexp.QuoteMeta("UPDATE accounts SET email_verified_at = NOW()")).
+				WithArgs(7).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			if errorResponse := s.VerifyEmail(context.Background(), token); errorResponse != nil {
+				t.Fatalf("VerifyEmail: %d %s", errorResponse.Code, errorResponse.Message)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	currentHash := mustHashPassword(t, "Current-Pass-1")
+	previousHash := mustHashPassword(t, "Previous-Pass-2")
+
+	tests := []struct {
+		name        string
+		newPassword string
+		wantCode    int
+	}{
+		{name: "accepted", newPassword: "Brand-New-Pass-3"},
+		{name: "too weak", newPassword: "weak", wantCode: http.StatusBadRequest},
+		{name: "same as current", newPassword: "Current-Pass-1", wantCode: http.StatusBadRequest},
+		{name: "in history", newPassword: "Previous-Pass-2", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock, _ := newTestAccountServices(t)
+
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT password FROM accounts WHERE id = ?")).
+				WithArgs(7).
+				WillReturnRows(sqlmock.NewRows([]string{"password"}).AddRow(currentHash))
+			if tt.newPassword != "weak" {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT password_hash FROM password_history")).
+					WithArgs(7, 2).
+					WillReturnRows(sqlmock.NewRows([]string{"password_hash"}).AddRow(previousHash))
+			}
+			if tt.wantCode == 0 {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE accounts SET password = ?")).
+					WithArgs(passwordHashOf(tt.newPassword), 7).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO password_history")).
+					WithArgs(7, currentHash).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(regexp.QuoteMeta("DELETE FROM password_history")).
+					WithArgs(7, 7, 2).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE sessions SET revoked_at = NOW()")).
+					WithArgs(7).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectCommit()
+			}
+
+			errorResponse := s.ChangePassword(context.Background(), &entity.AccountChangePasswordRequest{
+				Password:    "Current-Pass-1",
+				NewPassword: tt.newPassword,
+			}, 7)
+			switch {
+			case tt.wantCode == 0 && errorResponse != nil:
+				t.Fatalf("ChangePassword: %d %s", errorResponse.Code, errorResponse.Message)
+			case tt.wantCode != 0 && (errorResponse == nil || errorResponse.Code != tt.wantCode):
+				t.Fatalf("ChangePassword = %v, want %d", errorResponse, tt.wantCode)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+)
+
+type BookItemServicesInterface interface {
+	GetBookItems(ctx context.Context, bookID int) ([]*entity.BookItem, *entity.ErrorResponse)
+	InsertBookItem(ctx context.Context, bookID int, request *entity.BookItemRequest) (*entity.BookItem, *entity.ErrorResponse)
+	UpdateBookItem(ctx context.Context, bookID, itemID int, request *entity.BookItemRequest) (*entity.BookItem, *entity.ErrorResponse)
+	DeleteBookItem(ctx context.Context, bookID, itemID int) *entity.ErrorResponse
+}
+
+type BookItemServices struct {
+	DB *sql.DB
+	*BookServices
+	*CardServices
+}
+
+func NewBookItemServices(db *sql.DB, bs *BookServices, cs *CardServices) *BookItemServices {
+	return &BookItemServices{
+		DB:           db,
+		BookServices: bs,
+		CardServices: cs,
+	}
+}
+
+func (s *BookItemServices) GetBookItems(ctx context.Context, bookID int) ([]*entity.BookItem, *entity.ErrorResponse) {
+	_, errorResponse := s.BookServices.GetBookByID(ctx, bookID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	return s.BookServices.BookItemRepository.GetBookItemsByBookID(ctx, s.DB, bookID)
+}
+
+func (s *BookItemServices) InsertBookItem(ctx context.Context, bookID int, request *entity.BookItemRequest) (*entity.BookItem, *entity.ErrorResponse) {
+	_, errorResponse := s.BookServices.GetBookByID(ctx, bookID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	if request.Condition == "" {
+		request.Condition = entity.BookItemConditionGood
+	}
+	if request.Status == "" {
+		request.Status = entity.BookItemAvailable
+	}
+	if request.CardID != 0 {
+		if errorResponse := s.checkBookItemCard(ctx, request.CardID, 0); errorResponse != nil {
+			return nil, errorResponse
+		}
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	itemID, errorResponse := s.BookServices.BookItemRepository.InsertBookItem(ctx, tx, bookID, request)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return s.BookServices.BookItemRepository.GetBookItemByID(ctx, s.DB, itemID)
+}
+
+// UpdateBookItem replaces the copy's details. A condition or status left
+// empty keeps its current value.
+func (s *BookItemServices) UpdateBookItem(ctx context.Context, bookID, itemID int, request *entity.BookItemRequest) (*entity.BookItem, *entity.ErrorResponse) {
+	item, errorResponse := s.getBookItem(ctx, bookID, itemID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	if request.Condition == "" {
+		request.Condition = item.Condition
+	}
+	if request.Status == "" {
+		request.Status = item.Status
+	}
+	if request.CardID != 0 {
+		if errorResponse := s.checkBookItemCard(ctx, request.CardID, itemID); errorResponse != nil {
+			return nil, errorResponse
+		}
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	errorResponse = s.BookServices.BookItemRepository.UpdateBookItem(ctx, tx, itemID, request)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return s.BookServices.BookItemRepository.GetBookItemByID(ctx, s.DB, itemID)
+}
+
+// DeleteBookItem removes a copy. A copy that is out on a borrow has to come
+// back first; a copy that will not come back is marked lost instead.
+func (s *BookItemServices) DeleteBookItem(ctx context.Context, bookID, itemID int) *entity.ErrorResponse {
+	item, errorResponse := s.getBookItem(ctx, bookID, itemID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+	if item.OnLoan {
+		return helper.ErrorResponse(http.StatusConflict, fmt.Sprintf("item id %d is borrowed and cannot be deleted", itemID))
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	errorResponse = s.BookServices.BookItemRepository.DeleteBookItem(ctx, tx, itemID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	if err := tx.Commit(); err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return nil
+}
+
+func (s *BookItemServices) getBookItem(ctx context.Context, bookID, itemID int) (*entity.BookItem, *entity.ErrorResponse) {
+	item, errorResponse := s.BookServices.BookItemRepository.GetBookItemByID(ctx, s.DB, itemID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+	if item.BookID != bookID {
+		return nil, helper.ErrorResponse(http.StatusNotFound, "book item not found")
+	}
+
+	return item, nil
+}
+
+// checkBookItemCard makes sure the card can be put on the copy itemID, or on
+// a new copy when itemID is 0. It must be a book card that no book record or
+// other copy uses.
+func (s *BookItemServices) checkBookItemCard(ctx context.Context, cardID, itemID int) *entity.ErrorResponse {
+	card, errorResponse := s.CardServices.GetCardByID(ctx, cardID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+	if card.Type != "book" {
+		return helper.ErrorResponse(http.StatusBadRequest, fmt.Sprintf("card id %d is not a book card", cardID))
+	}
+
+	owner, errorResponse := s.BookServices.BookItemRepository.GetBookItemByCardID(ctx, s.DB, cardID)
+	switch {
+	case errorResponse == nil && owner.ID == itemID:
+		return nil
+	case errorResponse == nil:
+		return helper.ErrorResponse(http.StatusConflict, fmt.Sprintf("card id %d is already on item id %d", cardID, owner.ID))
+	case errorResponse.Code != http.StatusNotFound:
+		return errorResponse
+	}
+
+	book, errorResponse := s.BookServices.BookRepository.GetBookByCardID(ctx, s.DB, cardID)
+	switch {
+	case errorResponse == nil:
+		return helper.ErrorResponse(http.StatusConflict, fmt.Sprintf("card id %d is already on book id %d", cardID, book.ID))
+	case errorResponse.Code != http.StatusNotFound:
+		return errorResponse
+	}
+
+	return nil
+}
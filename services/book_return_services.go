@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+)
+
+type BookReturnServicesInterface interface {
+	ReturnBook(ctx context.Context, device *entity.AuthDevice, uid string) (*entity.BookReturn, *entity.ErrorResponse)
+}
+
+type BookReturnServices struct {
+	DB *sql.DB
+	*CardServices
+	*BorrowServices
+	*NotificationQueueServices
+	*ScanEventServices
+}
+
+func NewBookReturnServices(db *sql.DB, cs *CardServices, bs *BorrowServices, nqs *NotificationQueueServices, ses *ScanEventServices) *BookReturnServices {
+	return &BookReturnServices{
+		DB:                        db,
+		CardServices:              cs,
+		BorrowServices:            bs,
+		NotificationQueueServices: nqs,
+		ScanEventServices:         ses,
+	}
+}
+
+// ReturnBook returns the book whose tag was scanned at a drop box. The open
+// borrow row is marked returned at the server's time, and a return receipt
+// for the student and the return scan event are written in the same
+// transaction. The transaction is closed once none of its books are still out.
+func (s *BookReturnServices) ReturnBook(ctx context.Context, device *entity.AuthDevice, uid string) (*entity.BookReturn, *entity.ErrorResponse) {
+	uid, errorResponse := normalizeCardUID(uid, device.UIDFormat)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	event := &entity.ScanEvent{
+		DeviceID: device.DeviceID,
+		UID:      uid,
+		Purpose:  entity.ScanPurposeReturn,
+	}
+	errorResponse = s.ScanEventServices.ResolveScanEvent(ctx, event)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+	if event.CardType != "book" {
+		return nil, helper.ErrorResponse(http.StatusBadRequest, fmt.Sprintf("expected a book card but a %s card was tapped", event.CardType))
+	}
+	bookID := event.EntityID
+
+	var itemID int
+	item, errorResponse := s.CardServices.GetBookItemByUID(ctx, uid)
+	if errorResponse != nil && errorResponse.Code != http.StatusNotFound {
+		return nil, errorResponse
+	}
+	if item != nil {
+		itemID = item.ID
+	}
+
+	book, errorResponse := s.BorrowServices.BookServices.GetBookByID(ctx, bookID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	borrow, errorResponse := s.BorrowServices.BorrowRepository.GetOpenBorrowForUpdate(ctx, tx, bookID, itemID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	returnedAt := time.Now().UTC()
+	errorResponse = s.BorrowServices.BorrowRepository.MarkBorrowReturned(ctx, tx, borrow, returnedAt)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	remaining, errorResponse := s.BorrowServices.BorrowRepository.CountUnreturnedBorrows(ctx, tx, borrow.TransactionID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	result := &entity.BookReturn{
+		TransactionID:     borrow.TransactionID,
+		BookID:            bookID,
+		ItemID:            borrow.ItemID,
+		BookTitle:         book.Title,
+		StudentID:         borrow.StudentID,
+		DueDate:           borrow.DueDate,
+		ReturnedAt:        returnedAt.Format(time.DateTime),
+		RemainingBooks:    remaining,
+		TransactionClosed: remaining == 0,
+	}
+	if dueDate, err := time.Parse(time.DateTime, borrow.DueDate); err == nil {
+		result.Overdue = returnedAt.After(dueDate)
+	}
+
+	errorResponse = s.queueReturnReceipt(ctx, tx, result)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	_, errorResponse = s.ScanEventServices.ScanEventRepository.InsertScanEvent(ctx, tx, event)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return result, nil
+}
+
+// queueReturnReceipt queues the receipt email for the student. A student
+// without an account gets no receipt, which does not hold up the return.
+func (s *BookReturnServices) queueReturnReceipt(ctx context.Context, tx *sql.Tx, result *entity.BookReturn) *entity.ErrorResponse {
+	account, errorResponse := s.BorrowServices.AccountServices.AccountsRepository.GetAccountByStudentID(ctx, s.DB, result.StudentID)
+	if errorResponse != nil {
+		if errorResponse.Code == http.StatusNotFound {
+			log.Printf("book return: student %d has no account, receipt skipped", result.StudentID)
+			return nil
+		}
+		return errorResponse
+	}
+
+	message := fmt.Sprintf("Buku \"%s\" telah dikembalikan pada %s.", result.BookTitle, result.ReturnedAt)
+	if result.Overdue {
+		message += fmt.Sprintf(" Pengembalian ini melewati batas waktu %s.", result.DueDate)
+	}
+	if result.TransactionClosed {
+		message += fmt.Sprintf(" Semua buku pada transaksi %s sudah dikembalikan.", result.TransactionID)
+	} else {
+		message += fmt.Sprintf(" Masih ada %d buku pada transaksi %s yang belum dikembalikan.", result.RemainingBooks, result.TransactionID)
+	}
+
+	return s.NotificationQueueServices.NotificationQueueRepository.QueueNotification(ctx, tx, account.Email, "Bukti Pengembalian Buku", helper.MessageMailBody(message))
+}
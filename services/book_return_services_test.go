@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/repository"
+)
+
+// countScanEvents counts the scan events of a device with the given purpose
+// and entity.
+func countScanEvents(t *testing.T, db *sql.DB, deviceID int, purpose string, entityID int) int {
+	t.Helper()
+
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM scan_events WHERE device_id = ? AND purpose = ? AND entity_id = ?", deviceID, purpose, entityID).Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+// TestReturnBookRecordsScanEvent checks that a return logs its scan together
+// with the return, and that a refused return logs nothing.
+func TestReturnBookRecordsScanEvent(t *testing.T) {
+	db := openTestMySQL(t)
+	borrowServices := newTestBorrowServices(db)
+	scanEventServices := NewScanEventServices(db, repository.NewScanEventRepository(), borrowServices.CardServices, helper.GetEnvScanEvents())
+	notificationQueueServices := NewNotificationQueueServices(db, repository.NewNotificationQueueRepository(), helper.GetEnvNotificationQueue(), nil)
+	s := NewBookReturnServices(db, borrowServices.CardServices, borrowServices, notificationQueueServices, scanEventServices)
+
+	deviceID := mustInsert(t, db, "INSERT INTO devices (name) VALUES ('drop box')")
+	device := &entity.AuthDevice{DeviceID: deviceID, UIDFormat: helper.UIDFormatHex}
+
+	cardID := mustInsert(t, db, "INSERT INTO card_rfid (uid, type) VALUES ('0A0B0C0D', 'book')")
+	bookID := seedBook(t, db, "Returned Book")
+	if _, err := db.Exec("UPDATE books SET card_id = ? WHERE id = ?", cardID, bookID); err != nil {
+		t.Fatal(err)
+	}
+	mustInsert(t, db, "INSERT INTO borrows (student_id, transaction_id, book_id, due_date, status) VALUES (?, 'to-return', ?, NOW() + INTERVAL 7 DAY, 'borrowed')", seedStudent(t, db, "dave"), bookID)
+
+	result, errorResponse := s.ReturnBook(context.Background(), device, "0a:0b:0c:0d")
+	if errorResponse != nil {
+		t.Fatalf("ReturnBook: %d %s", errorResponse.Code, errorResponse.Message)
+	}
+	if !result.TransactionClosed {
+		t.Error("transaction is still open after its only book came back")
+	}
+	if count := countScanEvents(t, db, deviceID, entity.ScanPurposeReturn, bookID); count != 1 {
+		t.Fatalf("%d return scan events after the return, want 1", count)
+	}
+
+	_, errorResponse = s.ReturnBook(context.Background(), device, "0A0B0C0D")
+	if errorResponse == nil || errorResponse.Code != http.StatusNotFound {
+		t.Fatalf("second ReturnBook = %v, want 404", errorResponse)
+	}
+	if count := countScanEvents(t, db, deviceID, entity.ScanPurposeReturn, bookID); count != 1 {
+		t.Errorf("%d return scan events after a refused return, want 1", count)
+	}
+}
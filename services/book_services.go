@@ -11,8 +11,9 @@ import (
 )
 
 type BookServicesInterface interface {
-	GetBooks(ctx context.Context, page, pageSize int) ([]*entity.Book, *entity.ErrorResponse)
+	GetBooks(ctx context.Context, filter *entity.BookFilter) ([]*entity.Book, *entity.ErrorResponse)
 	GetBookByID(ctx context.Context, bookID int) (*entity.Book, *entity.ErrorResponse)
+	GetBookWithAvailability(ctx context.Context, bookID int) (*entity.Book, *entity.ErrorResponse)
 	GetBookByCardID(ctx context.Context, cardID int) (*entity.Book, *entity.ErrorResponse)
 	DeleteBookByID(ctx context.Context, bookID int) *entity.ErrorResponse
 	DeleteCardIDFromBook(ctx context.Context, cardID int) *entity.ErrorResponse
@@ -22,18 +23,31 @@ type BookServicesInterface interface {
 
 type BookServices struct {
 	*repository.BookRepository
+	*repository.BookItemRepository
 	*sql.DB
 }
 
-func NewBookServices(br *repository.BookRepository, db *sql.DB) *BookServices {
+func NewBookServices(br *repository.BookRepository, bir *repository.BookItemRepository, db *sql.DB) *BookServices {
 	return &BookServices{
-		BookRepository: br,
-		DB:             db,
+		BookRepository:     br,
+		BookItemRepository: bir,
+		DB:                 db,
 	}
 }
 
-func (s *BookServices) GetBooks(ctx context.Context, page, pageSize int) ([]*entity.Book, *entity.ErrorResponse) {
-	return s.BookRepository.GetBooks(ctx, s.DB, page, pageSize)
+// GetBooks lists books with their availability. With filter.Available only
+// books that can be borrowed now are listed.
+func (s *BookServices) GetBooks(ctx context.Context, filter *entity.BookFilter) ([]*entity.Book, *entity.ErrorResponse) {
+	books, errorResponse := s.BookRepository.GetBooks(ctx, s.DB, filter)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	for _, book := range books {
+		setBookAvailability(book)
+	}
+
+	return books, nil
 }
 
 func (s *BookServices) GetBookByID(ctx context.Context, bookID int) (*entity.Book, *entity.ErrorResponse) {
@@ -43,6 +57,50 @@ func (s *BookServices) GetBookByID(ctx context.Context, bookID int) (*entity.Boo
 	return s.BookRepository.GetBookByID(ctx, s.DB, bookID)
 }
 
+// GetBookWithAvailability returns the book with its availability and how
+// many copies the library owns and can lend now.
+func (s *BookServices) GetBookWithAvailability(ctx context.Context, bookID int) (*entity.Book, *entity.ErrorResponse) {
+	if bookID <= 0 {
+		return nil, helper.ErrorResponse(http.StatusBadRequest, "invalid book id")
+	}
+
+	book, errorResponse := s.BookRepository.GetBookWithStockByID(ctx, s.DB, bookID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	setBookAvailability(book)
+	return book, nil
+}
+
+// setBookAvailability works out the book's availability from its stock. A
+// free copy makes it available; otherwise a loan wins over a reservation,
+// and a book whose copies or tag are all lost is lost. A book with no copies
+// registered is available while it has no open borrow. The available case
+// must match bookAvailableCondition in the repository.
+func setBookAvailability(book *entity.Book) {
+	stock := book.Stock
+	availability := &entity.BookAvailability{}
+
+	switch {
+	case stock.FreeCopies > 0,
+		stock.TotalCopies == 0 && stock.OnLoan == 0 && stock.Reserved == 0 && !stock.CardLost:
+		availability.Status = entity.BookAvailable
+	case stock.OnLoan > 0:
+		availability.Status = entity.BookOnLoan
+		availability.DueDate = stock.NextDueDate
+	case stock.Reserved > 0:
+		availability.Status = entity.BookReserved
+	case stock.TotalCopies == 0 || stock.LostCopies == stock.TotalCopies:
+		availability.Status = entity.BookLost
+	default:
+		availability.Status = entity.BookUnavailable
+	}
+
+	book.Availability = availability
+	book.Copies = &entity.BookCopies{Total: stock.TotalCopies, Available: stock.FreeCopies}
+}
+
 func (s *BookServices) GetBookByCardID(ctx context.Context, cardID int) (*entity.Book, *entity.ErrorResponse) {
 	if cardID <= 0 {
 		return nil, helper.ErrorResponse(http.StatusBadRequest, "invalid card id")
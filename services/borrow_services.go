@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"net/http"
 
 	"github.com/dimassfeb-09/smart-library-be/entity"
@@ -25,10 +26,12 @@ type BorrowServices struct {
 	*repository.BorrowRepository
 	*StudentServices
 	*BookServices
+	*AccountServices
+	*CardServices
 }
 
-func NewBorrowServices(db *sql.DB, borrowRepo *repository.BorrowRepository, studentService *StudentServices, bookService *BookServices) *BorrowServices {
-	return &BorrowServices{DB: db, BorrowRepository: borrowRepo, StudentServices: studentService, BookServices: bookService}
+func NewBorrowServices(db *sql.DB, borrowRepo *repository.BorrowRepository, studentService *StudentServices, bookService *BookServices, accountService *AccountServices, cardService *CardServices) *BorrowServices {
+	return &BorrowServices{DB: db, BorrowRepository: borrowRepo, StudentServices: studentService, BookServices: bookService, AccountServices: accountService, CardServices: cardService}
 }
 
 func (s *BorrowServices) GetBorrowsByStudentID(ctx context.Context, studentId int) (*entity.BorrowList, *entity.ErrorResponse) {
@@ -48,33 +51,185 @@ func (s *BorrowServices) GetBorrows(ctx context.Context) (*entity.BorrowList, *e
 }
 
 func (s *BorrowServices) InsertBorrow(ctx context.Context, borrow *entity.Borrow) *entity.ErrorResponse {
+	if errorResponse := s.checkBorrowEligibility(ctx, borrow); errorResponse != nil {
+		return errorResponse
+	}
+
+	uuid := uuid.New().String()
+	borrow.TransactionID = uuid
+
+	tx, err := s.DB.BeginTx(ctx, borrowTxOptions)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	errorResponse := s.lockBorrowBooks(ctx, tx, borrow)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	errorResponse = s.BorrowRepository.InsertBorrow(ctx, tx, borrow)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	if err := tx.Commit(); err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return nil
+}
+
+// borrowTxOptions is used by every transaction that opens borrows. Under
+// read committed, the checks made after lockBorrowBooks has waited on the
+// book rows see the borrows that the transaction holding them committed.
+var borrowTxOptions = &sql.TxOptions{Isolation: sql.LevelReadCommitted}
+
+// lockBorrowBooks locks the books of a new borrow and picks the copy each one
+// is lent as. Concurrent checkouts of the same book queue up on the book row,
+// so only the first can take it. A book without registered copies is refused
+// while it is in a pending or borrowed borrow. For a book with copies, a
+// copy named in ItemIDS, at the same position as its book, must belong to
+// that book and be free; otherwise the first free copy is taken.
+func (s *BorrowServices) lockBorrowBooks(ctx context.Context, tx *sql.Tx, borrow *entity.Borrow) *entity.ErrorResponse {
+	if len(borrow.ItemIDS) > len(borrow.BookIDS) {
+		return helper.ErrorResponse(http.StatusBadRequest, "item_ids must not be longer than book_ids")
+	}
+
+	errorResponse := s.BookServices.BookRepository.LockBooks(ctx, tx, borrow.BookIDS)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	itemIDs := make([]int, len(borrow.BookIDS))
+	copy(itemIDs, borrow.ItemIDS)
+	taken := make(map[int]bool)
+	takenBooks := make(map[int]bool)
+
+	for i, bookID := range borrow.BookIDS {
+		if itemIDs[i] != 0 {
+			item, errorResponse := s.BookServices.BookItemRepository.GetBookItemForUpdate(ctx, tx, itemIDs[i])
+			if errorResponse != nil {
+				return errorResponse
+			}
+			if errorResponse := checkBookItemLendable(item, bookID); errorResponse != nil {
+				return errorResponse
+			}
+			if taken[item.ID] {
+				return helper.ErrorResponse(http.StatusBadRequest, fmt.Sprintf("item id %d is listed twice", item.ID))
+			}
+			taken[item.ID] = true
+			continue
+		}
+
+		free, hasItems, errorResponse := s.BookServices.BookItemRepository.GetFreeBookItemsForUpdate(ctx, tx, bookID)
+		if errorResponse != nil {
+			return errorResponse
+		}
+		if !hasItems {
+			open, errorResponse := s.BorrowRepository.CountOpenBorrowsByBookID(ctx, tx, bookID)
+			if errorResponse != nil {
+				return errorResponse
+			}
+			if open > 0 || takenBooks[bookID] {
+				return helper.ErrorResponse(http.StatusConflict, fmt.Sprintf("book id %d is already borrowed", bookID))
+			}
+			takenBooks[bookID] = true
+			continue
+		}
+		for _, itemID := range free {
+			if !taken[itemID] {
+				itemIDs[i] = itemID
+				taken[itemID] = true
+				break
+			}
+		}
+		if itemIDs[i] == 0 {
+			return helper.ErrorResponse(http.StatusConflict, fmt.Sprintf("no copy of book id %d is available", bookID))
+		}
+	}
+
+	borrow.ItemIDS = itemIDs
+	return nil
+}
 
+// checkBookAvailable tells whether a tapped book can still be borrowed. For a
+// tag on a copy only that copy is checked. A book with copies needs one of
+// them free, and a book without copies must not be in an open borrow.
+func (s *BorrowServices) checkBookAvailable(ctx context.Context, bookID, itemID int) *entity.ErrorResponse {
+	if itemID != 0 {
+		item, errorResponse := s.BookServices.BookItemRepository.GetBookItemByID(ctx, s.DB, itemID)
+		if errorResponse != nil {
+			return errorResponse
+		}
+		return checkBookItemLendable(item, bookID)
+	}
+
+	copies, errorResponse := s.BookServices.BookItemRepository.CountBookCopies(ctx, s.DB, bookID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+	if copies.Total > 0 {
+		if copies.Available == 0 {
+			return helper.ErrorResponse(http.StatusConflict, fmt.Sprintf("no copy of book id %d is available", bookID))
+		}
+		return nil
+	}
+
+	borrows, errorResponse := s.GetBorrowByBookID(ctx, bookID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+	for _, borrow := range borrows {
+		if borrow.Status == "borrowed" || borrow.Status == "pending" {
+			return helper.ErrorResponse(http.StatusConflict, fmt.Sprintf("book id %d is already borrowed", bookID))
+		}
+	}
+
+	return nil
+}
+
+func checkBookItemLendable(item *entity.BookItem, bookID int) *entity.ErrorResponse {
+	switch {
+	case item.BookID != bookID:
+		return helper.ErrorResponse(http.StatusBadRequest, fmt.Sprintf("item id %d is not a copy of book id %d", item.ID, bookID))
+	case item.Status != entity.BookItemAvailable:
+		return helper.ErrorResponse(http.StatusConflict, fmt.Sprintf("item id %d is %s", item.ID, item.Status))
+	case item.OnLoan:
+		return helper.ErrorResponse(http.StatusConflict, fmt.Sprintf("item id %d is already borrowed", item.ID))
+	}
+	return nil
+}
+
+// checkBorrowEligibility makes sure every book and the student can take part
+// in a borrow: their cards must be usable and the student's email verified.
+func (s *BorrowServices) checkBorrowEligibility(ctx context.Context, borrow *entity.Borrow) *entity.ErrorResponse {
 	for _, bookID := range borrow.BookIDS {
-		_, err := s.BookServices.GetBookByID(ctx, bookID)
+		book, err := s.BookServices.GetBookByID(ctx, bookID)
 		if err != nil {
 			return err
 		}
+		if err := s.CardServices.CheckCardUsable(ctx, book.CardID); err != nil {
+			return helper.ErrorResponse(err.Code, fmt.Sprintf("book id %d: %s", bookID, err.Message))
+		}
 	}
 
-	_, errorResponse := s.StudentServices.GetStudentByID(ctx, borrow.StudentID)
+	student, errorResponse := s.StudentServices.GetStudentByID(ctx, borrow.StudentID)
 	if errorResponse != nil {
 		return errorResponse
 	}
-
-	uuid := uuid.New().String()
-	borrow.TransactionID = uuid
-
-	tx, err := s.DB.Begin()
-	if err != nil {
-		return nil
+	if errorResponse := s.CardServices.CheckCardUsable(ctx, student.CardID); errorResponse != nil {
+		return helper.ErrorResponse(errorResponse.Code, "student "+errorResponse.Message)
 	}
-	defer tx.Commit()
 
-	errorResponse = s.BorrowRepository.InsertBorrow(ctx, tx, borrow)
+	account, errorResponse := s.AccountsRepository.GetAccountByStudentID(ctx, s.DB, borrow.StudentID)
 	if errorResponse != nil {
-		tx.Rollback()
 		return errorResponse
 	}
+	if !account.EmailVerified {
+		return helper.ErrorResponse(http.StatusForbidden, "Student email is not verified yet")
+	}
 
 	return nil
 }
@@ -85,17 +240,37 @@ func (s *BorrowServices) UpdateBorrow(ctx context.Context, borrow *entity.Borrow
 		return errorResponse
 	}
 
-	tx, err := s.DB.Begin()
+	tx, err := s.DB.BeginTx(ctx, borrowTxOptions)
 	if err != nil {
-		return helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	// Reopening a row must not hand out a book, or a copy, that another
+	// borrow holds in the meantime.
+	if borrow.Status == "pending" || borrow.Status == "borrowed" {
+		errorResponse = s.BookServices.BookRepository.LockBooks(ctx, tx, []int{borrow.BookID})
+		if errorResponse != nil {
+			return errorResponse
+		}
+
+		conflicts, errorResponse := s.BorrowRepository.CountConflictingBorrows(ctx, tx, borrow.TransactionID, borrow.BookID)
+		if errorResponse != nil {
+			return errorResponse
+		}
+		if conflicts > 0 {
+			return helper.ErrorResponse(http.StatusConflict, fmt.Sprintf("book id %d is already borrowed", borrow.BookID))
+		}
 	}
-	defer tx.Commit()
 
 	errorResponse = s.BorrowRepository.UpdateBorrow(ctx, tx, borrow)
 	if errorResponse != nil {
-		tx.Rollback()
 		return errorResponse
 	}
 
+	if err := tx.Commit(); err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
 	return nil
 }
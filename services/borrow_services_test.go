@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/repository"
+	"github.com/go-sql-driver/mysql"
+)
+
+// openTestMySQL loads smart_library.sql into a fresh database on the server
+// in TEST_MYSQL_DSN, for tests that depend on real row locks. The database is
+// dropped when the test ends.
+func openTestMySQL(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("TEST_MYSQL_DSN is not set, e.g. root:secret@tcp(127.0.0.1:3306)/")
+	}
+
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	name := fmt.Sprintf("smart_library_test_%d", time.Now().UnixNano())
+	if _, err := server.Exec("CREATE DATABASE " + name); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Exec("DROP DATABASE " + name) })
+
+	cfg.DBName = name
+	cfg.MultiStatements = true
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	schema, err := os.ReadFile("../smart_library.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(string(schema)); err != nil {
+		t.Fatal("loading smart_library.sql: ", err)
+	}
+
+	return db
+}
+
+func mustInsert(t *testing.T, db *sql.DB, query string, args ...any) int {
+	t.Helper()
+
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return int(id)
+}
+
+// seedStudent adds a student whose verified account may borrow.
+func seedStudent(t *testing.T, db *sql.DB, name string) int {
+	t.Helper()
+
+	accountID := mustInsert(t, db, "INSERT INTO accounts (email, password, level, email_verified_at) VALUES (?, '', 'student', NOW())", name+"@library.test")
+	return mustInsert(t, db, "INSERT INTO students (name, npm, account_id) VALUES (?, ?, ?)", name, fmt.Sprintf("%08d", accountID), accountID)
+}
+
+func seedBook(t *testing.T, db *sql.DB, title string) int {
+	t.Helper()
+
+	return mustInsert(t, db, "INSERT INTO books (title, author, publisher, published_date, isbn, pages, language, genre, description) VALUES (?, 'Author', 'Publisher', '2020-01-01', '9780000000000', 100, 'en', 'fiction', '')", title)
+}
+
+func seedBookItem(t *testing.T, db *sql.DB, bookID int, barcode string) int {
+	t.Helper()
+
+	return mustInsert(t, db, "INSERT INTO book_items (book_id, barcode) VALUES (?, ?)", bookID, barcode)
+}
+
+func countOpenBorrows(t *testing.T, db *sql.DB, bookID int) int {
+	t.Helper()
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM borrows WHERE book_id = ? AND status IN ('pending', 'borrowed')", bookID).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func newTestBorrowServices(db *sql.DB) *BorrowServices {
+	studentServices := NewStudentServices(db, repository.NewStudentRepository())
+	bookServices := NewBookServices(repository.NewBookRepository(), repository.NewBookItemRepository(), db)
+	cardServices := NewCardServices(db, repository.NewCardRepository(), studentServices, bookServices)
+	accountServices := NewAccountServices(db, repository.NewAccountsRepository(), repository.NewPasswordHistoryRepository(), studentServices, nil, nil, nil, nil, nil, nil, nil)
+
+	return NewBorrowServices(db, repository.NewBorrowRepository(), studentServices, bookServices, accountServices, cardServices)
+}
+
+// runTogether calls checkout for every student at once and returns what
+// each call returned.
+func runTogether(studentIDs []int, checkout func(i, studentID int) *entity.ErrorResponse) []*entity.ErrorResponse {
+	results := make([]*entity.ErrorResponse, len(studentIDs))
+	start := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i, studentID := range studentIDs {
+		wg.Add(1)
+		go func(i, studentID int) {
+			defer wg.Done()
+			<-start
+			results[i] = checkout(i, studentID)
+		}(i, studentID)
+	}
+	close(start)
+	wg.Wait()
+
+	return results
+}
+
+// checkOneCheckoutWins fails unless exactly one result is a success and the
+// rest are refused with 409.
+func checkOneCheckoutWins(t *testing.T, results []*entity.ErrorResponse) {
+	t.Helper()
+
+	succeeded := 0
+	for _, errorResponse := range results {
+		switch {
+		case errorResponse == nil:
+			succeeded++
+		case errorResponse.Code != http.StatusConflict:
+			t.Errorf("checkout failed with %d %q, want %d", errorResponse.Code, errorResponse.Message, http.StatusConflict)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("%d checkouts succeeded, want 1", succeeded)
+	}
+}
+
+func TestInsertBorrowConcurrently(t *testing.T) {
+	db := openTestMySQL(t)
+	s := newTestBorrowServices(db)
+	students := []int{seedStudent(t, db, "alice"), seedStudent(t, db, "bob")}
+
+	t.Run("book without copies", func(t *testing.T) {
+		bookID := seedBook(t, db, "Single Copy")
+
+		results := runTogether(students, func(_, studentID int) *entity.ErrorResponse {
+			return s.InsertBorrow(context.Background(), &entity.Borrow{StudentID: studentID, BookIDS: []int{bookID}})
+		})
+
+		checkOneCheckoutWins(t, results)
+		if open := countOpenBorrows(t, db, bookID); open != 1 {
+			t.Errorf("book has %d open borrows, want 1", open)
+		}
+	})
+
+	t.Run("last free copy", func(t *testing.T) {
+		bookID := seedBook(t, db, "Two Copies")
+		lentItemID := seedBookItem(t, db, bookID, "TC-1")
+		seedBookItem(t, db, bookID, "TC-2")
+		mustInsert(t, db, "INSERT INTO borrows (student_id, transaction_id, book_id, item_id, due_date, status) VALUES (?, 'lent-before', ?, ?, NOW() + INTERVAL 7 DAY, 'borrowed')", seedStudent(t, db, "carol"), bookID, lentItemID)
+
+		results := runTogether(students, func(_, studentID int) *entity.ErrorResponse {
+			return s.InsertBorrow(context.Background(), &entity.Borrow{StudentID: studentID, BookIDS: []int{bookID}})
+		})
+
+		checkOneCheckoutWins(t, results)
+		if open := countOpenBorrows(t, db, bookID); open != 2 {
+			t.Errorf("book has %d open borrows, want 2", open)
+		}
+	})
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"net/http"
 
 	"github.com/dimassfeb-09/smart-library-be/entity"
@@ -14,12 +15,24 @@ type CardServiceInterface interface {
 	GetCards(ctx context.Context, page, pageSize int) ([]*entity.Card, *entity.ErrorResponse)
 	GetCardTypeByUID(ctx context.Context, uid string) (id int, cardType string, err *entity.ErrorResponse)
 	GetCardByUID(ctx context.Context, uid string) (*entity.Card, *entity.ErrorResponse)
+	GetBookItemByUID(ctx context.Context, uid string) (*entity.BookItem, *entity.ErrorResponse)
 	GetCardByID(ctx context.Context, id int) (*entity.Card, *entity.ErrorResponse)
 	InsertCard(ctx context.Context, card *entity.Card) (int, *entity.ErrorResponse)
 	UpdateCard(ctx context.Context, id int, card *entity.Card) *entity.ErrorResponse
 	DeleteCard(ctx context.Context, id int) *entity.ErrorResponse
-	InsertContainerCard(ctx context.Context, uid string) *entity.ErrorResponse
-	GetOnceContainerCard(ctx context.Context) (string, *entity.ErrorResponse)
+	CheckCardUsable(ctx context.Context, cardID int) *entity.ErrorResponse
+	ChangeCardStatus(ctx context.Context, cardID, accountID int, request *entity.CardStatusRequest) *entity.ErrorResponse
+	ReplaceCard(ctx context.Context, cardID, accountID int, request *entity.CardReplaceRequest) (int, *entity.ErrorResponse)
+	ReportStudentCardLost(ctx context.Context, studentID, accountID int, reason string) *entity.ErrorResponse
+	GetCardStatusHistory(ctx context.Context, cardID int) ([]*entity.CardStatusHistory, *entity.ErrorResponse)
+}
+
+// cardTransitions lists the states each card state may move to through
+// ChangeCardStatus. Replaced is terminal and only set by ReplaceCard.
+var cardTransitions = map[string][]string{
+	entity.CardStatusActive:  {entity.CardStatusLost, entity.CardStatusBlocked},
+	entity.CardStatusLost:    {entity.CardStatusActive, entity.CardStatusBlocked},
+	entity.CardStatusBlocked: {entity.CardStatusActive},
 }
 
 type CardServices struct {
@@ -43,11 +56,20 @@ func (s *CardServices) GetCards(ctx context.Context, page, pageSize int) ([]*ent
 }
 
 func (s *CardServices) GetCardTypeByUID(ctx context.Context, uid string) (id int, cardType string, err *entity.ErrorResponse) {
+	uid, err = normalizeCardUID(uid, helper.UIDFormatHex)
+	if err != nil {
+		return 0, "", err
+	}
+
 	result, err := s.CardRepository.GetCardByUID(ctx, s.DB, uid)
 	if err != nil {
 		return 0, "", err
 	}
 
+	if err := checkCardUsable(result); err != nil {
+		return 0, "", err
+	}
+
 	switch result.Type {
 	case "student":
 		result, err := s.StudentServices.GetStudentByCardID(ctx, result.ID)
@@ -67,6 +89,11 @@ func (s *CardServices) GetCardTypeByUID(ctx context.Context, uid string) (id int
 }
 
 func (s *CardServices) GetCardByUID(ctx context.Context, uid string) (*entity.Card, *entity.ErrorResponse) {
+	uid, err := normalizeCardUID(uid, helper.UIDFormatHex)
+	if err != nil {
+		return nil, err
+	}
+
 	result, err := s.CardRepository.GetCardByUID(ctx, s.DB, uid)
 	if err != nil {
 		return nil, err
@@ -75,6 +102,17 @@ func (s *CardServices) GetCardByUID(ctx context.Context, uid string) (*entity.Ca
 	return result, nil
 }
 
+// GetBookItemByUID returns the copy a tag is on. It answers 404 for a tag that
+// is on a book record rather than on a copy.
+func (s *CardServices) GetBookItemByUID(ctx context.Context, uid string) (*entity.BookItem, *entity.ErrorResponse) {
+	card, errorResponse := s.GetCardByUID(ctx, uid)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	return s.BookServices.BookItemRepository.GetBookItemByCardID(ctx, s.DB, card.ID)
+}
+
 func (s *CardServices) GetCardByID(ctx context.Context, id int) (*entity.Card, *entity.ErrorResponse) {
 	result, err := s.CardRepository.GetCardByID(ctx, s.DB, id)
 	if err != nil {
@@ -85,6 +123,12 @@ func (s *CardServices) GetCardByID(ctx context.Context, id int) (*entity.Card, *
 }
 
 func (s *CardServices) InsertCard(ctx context.Context, card *entity.Card) (int, *entity.ErrorResponse) {
+	uid, errorResponse := normalizeCardUID(card.UID, helper.UIDFormatHex)
+	if errorResponse != nil {
+		return 0, errorResponse
+	}
+	card.UID = uid
+
 	existingCard, _ := s.GetCardByUID(ctx, card.UID)
 	if existingCard != nil {
 		return 0, helper.ErrorResponse(http.StatusConflict, "UID already registered.")
@@ -103,6 +147,16 @@ func (s *CardServices) UpdateCard(ctx context.Context, id int, card *entity.Card
 		return helper.ErrorResponse(http.StatusNotFound, "Card not found")
 	}
 
+	card.UID, err = normalizeCardUID(card.UID, helper.UIDFormatHex)
+	if err != nil {
+		return err
+	}
+
+	conflictingCard, _ := s.CardRepository.GetCardByUID(ctx, s.DB, card.UID)
+	if conflictingCard != nil && conflictingCard.ID != id {
+		return helper.ErrorResponse(http.StatusConflict, "UID already registered.")
+	}
+
 	err = s.CardRepository.UpdateCard(ctx, s.DB, card)
 	if err != nil {
 		return err
@@ -139,22 +193,195 @@ func (s *CardServices) DeleteCard(ctx context.Context, id int) *entity.ErrorResp
 	return nil
 }
 
-func (s *CardServices) InsertContainerCard(ctx context.Context, uid string) *entity.ErrorResponse {
-	tx, err := s.DB.Begin()
+// CheckCardUsable rejects a card that is not active. A zero id means no card
+// is linked and is accepted.
+func (s *CardServices) CheckCardUsable(ctx context.Context, cardID int) *entity.ErrorResponse {
+	if cardID == 0 {
+		return nil
+	}
+
+	card, errorResponse := s.CardRepository.GetCardByID(ctx, s.DB, cardID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	return checkCardUsable(card)
+}
+
+func checkCardUsable(card *entity.Card) *entity.ErrorResponse {
+	switch card.Status {
+	case entity.CardStatusLost:
+		return helper.ErrorResponse(http.StatusForbidden, "card has been reported lost")
+	case entity.CardStatusBlocked:
+		return helper.ErrorResponse(http.StatusForbidden, "card is blocked")
+	case entity.CardStatusReplaced:
+		return helper.ErrorResponse(http.StatusForbidden, "card has been replaced by a new card")
+	}
+	return nil
+}
+
+func (s *CardServices) ChangeCardStatus(ctx context.Context, cardID, accountID int, request *entity.CardStatusRequest) *entity.ErrorResponse {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	errorResponse := s.changeCardStatus(ctx, tx, cardID, accountID, request.Status, request.Reason)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	if err := tx.Commit(); err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return nil
+}
+
+func (s *CardServices) changeCardStatus(ctx context.Context, tx *sql.Tx, cardID, accountID int, status, reason string) *entity.ErrorResponse {
+	card, errorResponse := s.CardRepository.GetCardByIDForUpdate(ctx, tx, cardID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	if !canTransitionCard(card.Status, status) {
+		message := fmt.Sprintf("card cannot go from %s to %s", card.Status, status)
+		return helper.ErrorResponse(http.StatusConflict, message)
+	}
+
+	errorResponse = s.CardRepository.UpdateCardStatus(ctx, tx, cardID, status)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	return s.CardRepository.InsertCardStatusHistory(ctx, tx, &entity.CardStatusHistory{
+		CardID:     cardID,
+		FromStatus: card.Status,
+		ToStatus:   status,
+		Reason:     reason,
+		AccountID:  accountID,
+	})
+}
+
+func canTransitionCard(from, to string) bool {
+	for _, allowed := range cardTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ReplaceCard registers a new UID for the same owner, moves the student or
+// book link over to it and retires the old card, all in one transaction.
+func (s *CardServices) ReplaceCard(ctx context.Context, cardID, accountID int, request *entity.CardReplaceRequest) (int, *entity.ErrorResponse) {
+	uid, errorResponse := normalizeCardUID(request.UID, helper.UIDFormatHex)
+	if errorResponse != nil {
+		return 0, errorResponse
+	}
+	request.UID = uid
+
+	existingCard, _ := s.CardRepository.GetCardByUID(ctx, s.DB, request.UID)
+	if existingCard != nil {
+		return 0, helper.ErrorResponse(http.StatusConflict, "UID already registered.")
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	card, errorResponse := s.CardRepository.GetCardByIDForUpdate(ctx, tx, cardID)
+	if errorResponse != nil {
+		return 0, errorResponse
+	}
+	if card.Status == entity.CardStatusReplaced {
+		return 0, helper.ErrorResponse(http.StatusConflict, "card has already been replaced")
+	}
+
+	newCardID, errorResponse := s.CardRepository.InsertCardTx(ctx, tx, &entity.Card{UID: request.UID, Type: card.Type})
+	if errorResponse != nil {
+		return 0, errorResponse
+	}
+
+	errorResponse = s.CardRepository.MoveCardLinks(ctx, tx, card.ID, newCardID)
+	if errorResponse != nil {
+		return 0, errorResponse
+	}
+
+	errorResponse = s.CardRepository.UpdateCardStatus(ctx, tx, card.ID, entity.CardStatusReplaced)
+	if errorResponse != nil {
+		return 0, errorResponse
 	}
-	defer tx.Commit()
 
-	return s.CardRepository.InsertContainerCard(ctx, tx, uid)
+	reason := request.Reason
+	if reason == "" {
+		reason = fmt.Sprintf("replaced by card %d", newCardID)
+	}
+	histories := []*entity.CardStatusHistory{
+		{CardID: card.ID, FromStatus: card.Status, ToStatus: entity.CardStatusReplaced, Reason: reason, AccountID: accountID},
+		{CardID: newCardID, ToStatus: entity.CardStatusActive, Reason: fmt.Sprintf("replacement for card %d", card.ID), AccountID: accountID},
+	}
+	for _, history := range histories {
+		if errorResponse := s.CardRepository.InsertCardStatusHistory(ctx, tx, history); errorResponse != nil {
+			return 0, errorResponse
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return newCardID, nil
 }
 
-func (s *CardServices) GetOnceContainerCard(ctx context.Context) (string, *entity.ErrorResponse) {
-	tx, err := s.DB.Begin()
+func (s *CardServices) ReportStudentCardLost(ctx context.Context, studentID, accountID int, reason string) *entity.ErrorResponse {
+	student, errorResponse := s.StudentServices.GetStudentByID(ctx, studentID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+	if student.CardID == 0 {
+		return helper.ErrorResponse(http.StatusNotFound, "Student has no card")
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return "", helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	if reason == "" {
+		reason = "reported lost by student"
 	}
-	defer tx.Commit()
+	errorResponse = s.changeCardStatus(ctx, tx, student.CardID, accountID, entity.CardStatusLost, reason)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	if err := tx.Commit(); err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return nil
+}
 
-	return s.CardRepository.GetOnceContainerCard(ctx, tx)
+func (s *CardServices) GetCardStatusHistory(ctx context.Context, cardID int) ([]*entity.CardStatusHistory, *entity.ErrorResponse) {
+	_, errorResponse := s.CardRepository.GetCardByID(ctx, s.DB, cardID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	return s.CardRepository.GetCardStatusHistory(ctx, s.DB, cardID)
+}
+
+// normalizeCardUID turns a UID in the given reader format into the canonical
+// form stored in card_rfid, so every reader finds the same card.
+func normalizeCardUID(uid, format string) (string, *entity.ErrorResponse) {
+	normalized, err := helper.NormalizeUID(uid, format)
+	if err != nil {
+		return "", helper.ErrorResponse(http.StatusBadRequest, "invalid uid "+uid)
+	}
+	return normalized, nil
 }
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/repository"
+)
+
+type DeviceHealthServicesInterface interface {
+	RecordHeartbeat(ctx context.Context, deviceID int, heartbeat *entity.DeviceHeartbeat) *entity.ErrorResponse
+	GetDevicesHealth(ctx context.Context) ([]*entity.DeviceHealth, *entity.ErrorResponse)
+	AlertOfflineDevices(ctx context.Context) *entity.ErrorResponse
+	RunHealthMonitor(ctx context.Context)
+}
+
+type DeviceHealthServices struct {
+	DB *sql.DB
+	*repository.DeviceRepository
+	Config *helper.EnvDeviceHealth
+	Mail   helper.Mailer
+}
+
+func NewDeviceHealthServices(db *sql.DB, dr *repository.DeviceRepository, envDeviceHealth *helper.EnvDeviceHealth, mailer helper.Mailer) *DeviceHealthServices {
+	return &DeviceHealthServices{
+		DB:               db,
+		DeviceRepository: dr,
+		Config:           envDeviceHealth,
+		Mail:             mailer,
+	}
+}
+
+func (s *DeviceHealthServices) RecordHeartbeat(ctx context.Context, deviceID int, heartbeat *entity.DeviceHeartbeat) *entity.ErrorResponse {
+	return s.DeviceRepository.UpdateDeviceHeartbeat(ctx, s.DB, deviceID, heartbeat)
+}
+
+func (s *DeviceHealthServices) GetDevicesHealth(ctx context.Context) ([]*entity.DeviceHealth, *entity.ErrorResponse) {
+	devices, errorResponse := s.DeviceRepository.GetDevicesHealth(ctx, s.DB)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	timeout := int64(s.Config.HeartbeatTimeout.Seconds())
+	for _, device := range devices {
+		switch {
+		case device.SecondsSinceHeartbeat == nil:
+			device.Status = entity.DeviceHealthUnknown
+		case *device.SecondsSinceHeartbeat > timeout:
+			device.Status = entity.DeviceHealthOffline
+		default:
+			device.Status = entity.DeviceHealthOnline
+		}
+	}
+
+	return devices, nil
+}
+
+// AlertOfflineDevices sends one alert per outage for every reader that has
+// missed heartbeats for longer than the timeout. The alert is claimed before
+// it is sent, so a failed delivery is logged and not retried.
+func (s *DeviceHealthServices) AlertOfflineDevices(ctx context.Context) *entity.ErrorResponse {
+	devices, errorResponse := s.DeviceRepository.GetUnalertedOfflineDevices(ctx, s.DB, s.Config.HeartbeatTimeout)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	for _, device := range devices {
+		claimed, errorResponse := s.DeviceRepository.MarkDeviceHealthAlerted(ctx, s.DB, device.DeviceID)
+		if errorResponse != nil {
+			return errorResponse
+		}
+		if !claimed {
+			continue
+		}
+
+		s.sendOfflineAlert(device)
+	}
+
+	return nil
+}
+
+func (s *DeviceHealthServices) sendOfflineAlert(device *entity.DeviceHealth) {
+	var offlineSeconds int64
+	if device.SecondsSinceHeartbeat != nil {
+		offlineSeconds = *device.SecondsSinceHeartbeat
+	}
+	log.Printf("device health: reader %d (%s) missed heartbeats for %ds", device.DeviceID, device.Name, offlineSeconds)
+
+	if s.Config.AlertWebhookURL != "" {
+		err := helper.PostJSON(s.Config.AlertWebhookURL, &entity.DeviceOfflineAlert{
+			Event:           "device.offline",
+			DeviceID:        device.DeviceID,
+			Name:            device.Name,
+			Location:        device.Location,
+			LastHeartbeatAt: device.LastHeartbeatAt,
+			OfflineSeconds:  offlineSeconds,
+		})
+		if err != nil {
+			log.Println("device health webhook:", err)
+		}
+	}
+
+	if len(s.Config.AlertEmails) > 0 {
+		subject := fmt.Sprintf("Reader Smart Library offline: %s", device.Name)
+		message := fmt.Sprintf("Reader %s di %s tidak mengirim heartbeat sejak %s.", device.Name, device.Location, device.LastHeartbeatAt)
+		body := helper.MessageMailBody(message)
+		for _, email := range s.Config.AlertEmails {
+			if err := s.Mail.SendHTMLMail(email, subject, body); err != nil {
+				log.Println("device health email:", err)
+			}
+		}
+	}
+}
+
+// RunHealthMonitor checks for offline readers every CheckInterval until ctx
+// is cancelled. It is meant to run in its own goroutine.
+func (s *DeviceHealthServices) RunHealthMonitor(ctx context.Context) {
+	ticker := time.NewTicker(s.Config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		if errorResponse := s.AlertOfflineDevices(ctx); errorResponse != nil {
+			log.Println("device health:", errorResponse.Message)
+		}
+	}
+}
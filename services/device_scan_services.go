@@ -0,0 +1,299 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/repository"
+)
+
+// scanPollInterval bounds how long a long-poll sleeps between queue checks, so
+// scans enqueued by another server instance are still picked up.
+const scanPollInterval = time.Second
+
+type DeviceScanServicesInterface interface {
+	EnqueueScan(ctx context.Context, device *entity.AuthDevice, uid, purpose string) *entity.ErrorResponse
+	NextScan(ctx context.Context, deviceID int, wait time.Duration) (*entity.DeviceScan, *entity.ErrorResponse)
+	SubscribeScans(ctx context.Context, deviceID int) (<-chan *entity.ResolvedScan, func(), *entity.ErrorResponse)
+}
+
+type DeviceScanServices struct {
+	DB *sql.DB
+	*repository.DeviceScanRepository
+	*DeviceServices
+	*ScanEventServices
+	*EnrollmentServices
+	Config   *helper.EnvScanQueue
+	notifier *scanNotifier
+	hub      *scanHub
+}
+
+func NewDeviceScanServices(db *sql.DB, dsr *repository.DeviceScanRepository, ds *DeviceServices, ses *ScanEventServices, es *EnrollmentServices, envScanQueue *helper.EnvScanQueue) *DeviceScanServices {
+	return &DeviceScanServices{
+		DB:                   db,
+		DeviceScanRepository: dsr,
+		DeviceServices:       ds,
+		ScanEventServices:    ses,
+		EnrollmentServices:   es,
+		Config:               envScanQueue,
+		notifier:             newScanNotifier(),
+		hub:                  newScanHub(),
+	}
+}
+
+// EnqueueScan queues the scan for the device and appends it to the scan log
+// in the same transaction, so every queued scan is also in the history. The
+// UID is normalized from the reader's input format first. While the device has
+// an open enrollment session the scan registers a card instead of queueing.
+func (s *DeviceScanServices) EnqueueScan(ctx context.Context, device *entity.AuthDevice, uid, purpose string) *entity.ErrorResponse {
+	uid, errorResponse := normalizeCardUID(uid, device.UIDFormat)
+	if errorResponse != nil {
+		return errorResponse
+	}
+	deviceID := device.DeviceID
+
+	event := &entity.ScanEvent{
+		DeviceID: deviceID,
+		UID:      uid,
+		Purpose:  purpose,
+	}
+	unresolved := s.ScanEventServices.ResolveScanEvent(ctx, event)
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	session, errorResponse := s.EnrollmentServices.EnrollmentRepository.GetOpenEnrollmentSessionForUpdate(ctx, tx, deviceID)
+	if errorResponse != nil && errorResponse.Code != http.StatusNotFound {
+		return errorResponse
+	}
+
+	var id int
+	var enrollment *entity.EnrollmentItem
+	if session != nil {
+		enrollment, errorResponse = s.EnrollmentServices.enrollScan(ctx, tx, session, uid)
+		if errorResponse != nil {
+			return errorResponse
+		}
+		event.Purpose = entity.ScanPurposeEnroll
+		event.CardID = enrollment.CardID
+		if enrollment.Result == entity.EnrollmentResultEnrolled {
+			event.CardType = session.CardType
+			unresolved = nil
+		}
+	} else {
+		errorResponse = s.DeviceScanRepository.DeleteExpiredDeviceScans(ctx, tx, deviceID)
+		if errorResponse != nil {
+			return errorResponse
+		}
+
+		id, errorResponse = s.DeviceScanRepository.InsertDeviceScan(ctx, tx, deviceID, uid, s.Config.TTL)
+		if errorResponse != nil {
+			return errorResponse
+		}
+	}
+
+	_, errorResponse = s.ScanEventServices.ScanEventRepository.InsertScanEvent(ctx, tx, event)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	if err := tx.Commit(); err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	if enrollment == nil {
+		s.notifier.notify(deviceID)
+	}
+	if s.hub.hasSubscribers(deviceID) {
+		resolved := resolvedScan(&entity.DeviceScan{
+			ID:        id,
+			DeviceID:  deviceID,
+			UID:       uid,
+			ScannedAt: time.Now().Format(time.DateTime),
+		}, event, unresolved)
+		resolved.Enrollment = enrollment
+		s.hub.publish(resolved)
+	}
+	return nil
+}
+
+// SubscribeScans streams every scan of the device as it is enqueued, without
+// taking it off the queue. The returned func must be called to unsubscribe.
+func (s *DeviceScanServices) SubscribeScans(ctx context.Context, deviceID int) (<-chan *entity.ResolvedScan, func(), *entity.ErrorResponse) {
+	_, errorResponse := s.DeviceServices.GetDeviceByID(ctx, deviceID)
+	if errorResponse != nil {
+		return nil, nil, errorResponse
+	}
+
+	scans, unsubscribe := s.hub.subscribe(deviceID)
+	return scans, unsubscribe, nil
+}
+
+// NextScan pops the oldest scan of the device. When the queue is empty it
+// waits up to wait (capped by the configured maximum) for one to arrive.
+func (s *DeviceScanServices) NextScan(ctx context.Context, deviceID int, wait time.Duration) (*entity.DeviceScan, *entity.ErrorResponse) {
+	_, errorResponse := s.DeviceServices.GetDeviceByID(ctx, deviceID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	if wait > s.Config.MaxWait {
+		wait = s.Config.MaxWait
+	}
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+
+	for {
+		// Subscribe before checking the queue so a scan enqueued in between
+		// still wakes this request.
+		enqueued := s.notifier.subscribe(deviceID)
+
+		scan, errorResponse := s.popScan(ctx, deviceID)
+		if errorResponse == nil || errorResponse.Code != http.StatusNotFound || wait <= 0 {
+			return scan, errorResponse
+		}
+
+		select {
+		case <-enqueued:
+		case <-time.After(scanPollInterval):
+		case <-deadline.C:
+			return s.popScan(ctx, deviceID)
+		case <-ctx.Done():
+			return nil, errorResponse
+		}
+	}
+}
+
+func resolvedScan(scan *entity.DeviceScan, event *entity.ScanEvent, unresolved *entity.ErrorResponse) *entity.ResolvedScan {
+	resolved := &entity.ResolvedScan{
+		DeviceScan: *scan,
+		CardType:   event.CardType,
+		EntityID:   event.EntityID,
+		Purpose:    event.Purpose,
+	}
+	if unresolved != nil {
+		resolved.Error = unresolved.Message
+	}
+	return resolved
+}
+
+func (s *DeviceScanServices) popScan(ctx context.Context, deviceID int) (*entity.DeviceScan, *entity.ErrorResponse) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	scan, errorResponse := s.DeviceScanRepository.PopDeviceScan(ctx, tx, deviceID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return scan, nil
+}
+
+// scanNotifier wakes long-polls waiting on a device queue. Each device has one
+// channel that is closed, and so broadcast to every subscriber, when a scan
+// for that device is enqueued.
+type scanNotifier struct {
+	mu       sync.Mutex
+	channels map[int]chan struct{}
+}
+
+func newScanNotifier() *scanNotifier {
+	return &scanNotifier{
+		channels: make(map[int]chan struct{}),
+	}
+}
+
+func (n *scanNotifier) subscribe(deviceID int) <-chan struct{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	channel, ok := n.channels[deviceID]
+	if !ok {
+		channel = make(chan struct{})
+		n.channels[deviceID] = channel
+	}
+	return channel
+}
+
+func (n *scanNotifier) notify(deviceID int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if channel, ok := n.channels[deviceID]; ok {
+		close(channel)
+		delete(n.channels, deviceID)
+	}
+}
+
+// scanHubBuffer is how many scans a slow subscriber may fall behind before
+// further scans are dropped for it.
+const scanHubBuffer = 16
+
+// scanHub fans scans out to the live streams of each device. It only sees
+// scans enqueued on this server instance.
+type scanHub struct {
+	mu          sync.Mutex
+	subscribers map[int]map[chan *entity.ResolvedScan]struct{}
+}
+
+func newScanHub() *scanHub {
+	return &scanHub{
+		subscribers: make(map[int]map[chan *entity.ResolvedScan]struct{}),
+	}
+}
+
+func (h *scanHub) subscribe(deviceID int) (<-chan *entity.ResolvedScan, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	channel := make(chan *entity.ResolvedScan, scanHubBuffer)
+	if h.subscribers[deviceID] == nil {
+		h.subscribers[deviceID] = make(map[chan *entity.ResolvedScan]struct{})
+	}
+	h.subscribers[deviceID][channel] = struct{}{}
+
+	unsubscribe := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		delete(h.subscribers[deviceID], channel)
+		if len(h.subscribers[deviceID]) == 0 {
+			delete(h.subscribers, deviceID)
+		}
+	}
+	return channel, unsubscribe
+}
+
+func (h *scanHub) hasSubscribers(deviceID int) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.subscribers[deviceID]) > 0
+}
+
+func (h *scanHub) publish(scan *entity.ResolvedScan) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for channel := range h.subscribers[scan.DeviceID] {
+		select {
+		case channel <- scan:
+		default:
+		}
+	}
+}
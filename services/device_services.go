@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/repository"
+)
+
+const deviceAPIKeyPrefix = "sl_"
+
+type DeviceServicesInterface interface {
+	CreateDevice(ctx context.Context, device *entity.Device) (int, *entity.ErrorResponse)
+	GetDevices(ctx context.Context) ([]*entity.Device, *entity.ErrorResponse)
+	GetDeviceByID(ctx context.Context, deviceID int) (*entity.Device, *entity.ErrorResponse)
+	UpdateDevice(ctx context.Context, device *entity.Device) *entity.ErrorResponse
+	DeleteDevice(ctx context.Context, deviceID int) *entity.ErrorResponse
+	CreateDeviceAPIKey(ctx context.Context, deviceID int, request *entity.DeviceAPIKeyRequest) (*entity.DeviceAPIKeyResponse, *entity.ErrorResponse)
+	GetDeviceAPIKeys(ctx context.Context, deviceID int) ([]*entity.DeviceAPIKey, *entity.ErrorResponse)
+	RevokeDeviceAPIKey(ctx context.Context, deviceID, keyID int) *entity.ErrorResponse
+	AuthenticateDevice(ctx context.Context, key string) (*entity.AuthDevice, *entity.ErrorResponse)
+	RecordDeviceCall(ctx context.Context, call *entity.DeviceCall)
+	GetDeviceCalls(ctx context.Context, deviceID, page, pageSize int) ([]*entity.DeviceCall, *entity.ErrorResponse)
+}
+
+type DeviceServices struct {
+	DB *sql.DB
+	*repository.DeviceRepository
+}
+
+func NewDeviceServices(db *sql.DB, dr *repository.DeviceRepository) *DeviceServices {
+	return &DeviceServices{
+		DB:               db,
+		DeviceRepository: dr,
+	}
+}
+
+func (s *DeviceServices) CreateDevice(ctx context.Context, device *entity.Device) (int, *entity.ErrorResponse) {
+	existing, _ := s.DeviceRepository.GetDeviceByName(ctx, s.DB, device.Name)
+	if existing != nil {
+		return 0, helper.ErrorResponse(http.StatusConflict, "Device name already exists.")
+	}
+	if device.UIDFormat == "" {
+		device.UIDFormat = helper.UIDFormatHex
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	id, errorResponse := s.DeviceRepository.InsertDevice(ctx, tx, device)
+	if errorResponse != nil {
+		return 0, errorResponse
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return id, nil
+}
+
+func (s *DeviceServices) GetDevices(ctx context.Context) ([]*entity.Device, *entity.ErrorResponse) {
+	return s.DeviceRepository.GetDevices(ctx, s.DB)
+}
+
+func (s *DeviceServices) GetDeviceByID(ctx context.Context, deviceID int) (*entity.Device, *entity.ErrorResponse) {
+	return s.DeviceRepository.GetDeviceByID(ctx, s.DB, deviceID)
+}
+
+func (s *DeviceServices) UpdateDevice(ctx context.Context, device *entity.Device) *entity.ErrorResponse {
+	_, errorResponse := s.DeviceRepository.GetDeviceByID(ctx, s.DB, device.ID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	existing, _ := s.DeviceRepository.GetDeviceByName(ctx, s.DB, device.Name)
+	if existing != nil && existing.ID != device.ID {
+		return helper.ErrorResponse(http.StatusConflict, "Device name already exists.")
+	}
+	if device.UIDFormat == "" {
+		device.UIDFormat = helper.UIDFormatHex
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	errorResponse = s.DeviceRepository.UpdateDevice(ctx, tx, device)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	if err := tx.Commit(); err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return nil
+}
+
+func (s *DeviceServices) DeleteDevice(ctx context.Context, deviceID int) *entity.ErrorResponse {
+	_, errorResponse := s.DeviceRepository.GetDeviceByID(ctx, s.DB, deviceID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	errorResponse = s.DeviceRepository.DeleteDevice(ctx, tx, deviceID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	if err := tx.Commit(); err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return nil
+}
+
+// CreateDeviceAPIKey returns the plain key once; only its hash is stored. The
+// signing secret is stored as is, since verifying a signature needs it.
+func (s *DeviceServices) CreateDeviceAPIKey(ctx context.Context, deviceID int, request *entity.DeviceAPIKeyRequest) (*entity.DeviceAPIKeyResponse, *entity.ErrorResponse) {
+	_, errorResponse := s.DeviceRepository.GetDeviceByID(ctx, s.DB, deviceID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	token, err := helper.GenerateRandomToken(32)
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+	key := deviceAPIKeyPrefix + token
+	prefix := key[:len(deviceAPIKeyPrefix)+8]
+
+	signingSecret, err := helper.GenerateRandomToken(32)
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	keyID, errorResponse := s.DeviceRepository.InsertDeviceAPIKey(ctx, tx, deviceID, prefix, helper.HashToken(key), request.Scopes, signingSecret)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return &entity.DeviceAPIKeyResponse{
+		DeviceAPIKey: entity.DeviceAPIKey{
+			ID:       keyID,
+			DeviceID: deviceID,
+			Prefix:   prefix,
+			Scopes:   request.Scopes,
+		},
+		Key:           key,
+		SigningSecret: signingSecret,
+	}, nil
+}
+
+func (s *DeviceServices) GetDeviceAPIKeys(ctx context.Context, deviceID int) ([]*entity.DeviceAPIKey, *entity.ErrorResponse) {
+	_, errorResponse := s.DeviceRepository.GetDeviceByID(ctx, s.DB, deviceID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	return s.DeviceRepository.GetDeviceAPIKeys(ctx, s.DB, deviceID)
+}
+
+func (s *DeviceServices) RevokeDeviceAPIKey(ctx context.Context, deviceID, keyID int) *entity.ErrorResponse {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	revoked, errorResponse := s.DeviceRepository.RevokeDeviceAPIKey(ctx, tx, deviceID, keyID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+	if !revoked {
+		return helper.ErrorResponse(http.StatusNotFound, "device api key not found")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return nil
+}
+
+func (s *DeviceServices) AuthenticateDevice(ctx context.Context, key string) (*entity.AuthDevice, *entity.ErrorResponse) {
+	device, errorResponse := s.DeviceRepository.GetAuthDeviceByKeyHash(ctx, s.DB, helper.HashToken(key))
+	if errorResponse != nil {
+		if errorResponse.Code == http.StatusNotFound {
+			return nil, helper.ErrorResponse(http.StatusUnauthorized, "Invalid or revoked device api key")
+		}
+		return nil, errorResponse
+	}
+
+	return device, nil
+}
+
+func (s *DeviceServices) RecordDeviceCall(ctx context.Context, call *entity.DeviceCall) {
+	if errorResponse := s.DeviceRepository.InsertDeviceCall(ctx, s.DB, call); errorResponse != nil {
+		log.Println("device call:", errorResponse.Message)
+	}
+}
+
+func (s *DeviceServices) GetDeviceCalls(ctx context.Context, deviceID, page, pageSize int) ([]*entity.DeviceCall, *entity.ErrorResponse) {
+	_, errorResponse := s.DeviceRepository.GetDeviceByID(ctx, s.DB, deviceID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	return s.DeviceRepository.GetDeviceCalls(ctx, s.DB, deviceID, page, pageSize)
+}
@@ -0,0 +1,334 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/repository"
+)
+
+type EnrollmentServicesInterface interface {
+	OpenEnrollment(ctx context.Context, accountID int, request *entity.EnrollmentRequest) (int, *entity.ErrorResponse)
+	GetEnrollment(ctx context.Context, sessionID int) (*entity.EnrollmentSession, *entity.ErrorResponse)
+	CloseEnrollment(ctx context.Context, sessionID int) *entity.ErrorResponse
+	MapBooksInOrder(ctx context.Context, sessionID int, bookIDs []int) ([]*entity.EnrollmentBookAssignment, *entity.ErrorResponse)
+	MapBooksFromCSV(ctx context.Context, sessionID int, file io.Reader) ([]*entity.EnrollmentBookAssignment, *entity.ErrorResponse)
+}
+
+type EnrollmentServices struct {
+	DB *sql.DB
+	*repository.EnrollmentRepository
+	*CardServices
+	*DeviceServices
+}
+
+func NewEnrollmentServices(db *sql.DB, er *repository.EnrollmentRepository, cs *CardServices, ds *DeviceServices) *EnrollmentServices {
+	return &EnrollmentServices{
+		DB:                   db,
+		EnrollmentRepository: er,
+		CardServices:         cs,
+		DeviceServices:       ds,
+	}
+}
+
+// OpenEnrollment starts a batch on the reader. A reader has at most one open
+// session, and while it is open its scans register cards instead of queueing.
+func (s *EnrollmentServices) OpenEnrollment(ctx context.Context, accountID int, request *entity.EnrollmentRequest) (int, *entity.ErrorResponse) {
+	_, errorResponse := s.DeviceServices.GetDeviceByID(ctx, request.DeviceID)
+	if errorResponse != nil {
+		return 0, errorResponse
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	open, errorResponse := s.EnrollmentRepository.GetOpenEnrollmentSessionForUpdate(ctx, tx, request.DeviceID)
+	if errorResponse != nil && errorResponse.Code != http.StatusNotFound {
+		return 0, errorResponse
+	}
+	if open != nil {
+		message := fmt.Sprintf("device already has open enrollment session %d", open.ID)
+		return 0, helper.ErrorResponse(http.StatusConflict, message)
+	}
+
+	id, errorResponse := s.EnrollmentRepository.InsertEnrollmentSession(ctx, tx, &entity.EnrollmentSession{
+		DeviceID:  request.DeviceID,
+		CardType:  request.CardType,
+		AccountID: accountID,
+	})
+	if errorResponse != nil {
+		return 0, errorResponse
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return id, nil
+}
+
+func (s *EnrollmentServices) GetEnrollment(ctx context.Context, sessionID int) (*entity.EnrollmentSession, *entity.ErrorResponse) {
+	session, errorResponse := s.EnrollmentRepository.GetEnrollmentSessionByID(ctx, s.DB, sessionID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	items, errorResponse := s.EnrollmentRepository.GetEnrollmentItems(ctx, s.DB, sessionID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	session.Items = items
+	for _, item := range items {
+		switch item.Result {
+		case entity.EnrollmentResultEnrolled:
+			session.Enrolled++
+		case entity.EnrollmentResultDuplicate:
+			session.Duplicates++
+		}
+	}
+
+	return session, nil
+}
+
+func (s *EnrollmentServices) CloseEnrollment(ctx context.Context, sessionID int) *entity.ErrorResponse {
+	_, errorResponse := s.EnrollmentRepository.GetEnrollmentSessionByID(ctx, s.DB, sessionID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	closed, errorResponse := s.EnrollmentRepository.CloseEnrollmentSession(ctx, tx, sessionID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+	if !closed {
+		return helper.ErrorResponse(http.StatusConflict, "enrollment session is already closed")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return nil
+}
+
+// enrollScan registers the scanned UID as a card of the session's type. A UID
+// that is already registered is kept as a duplicate item instead of failing
+// the scan, so the operator sees it in the batch and moves on.
+func (s *EnrollmentServices) enrollScan(ctx context.Context, tx *sql.Tx, session *entity.EnrollmentSession, uid string) (*entity.EnrollmentItem, *entity.ErrorResponse) {
+	item := &entity.EnrollmentItem{
+		SessionID: session.ID,
+		UID:       uid,
+	}
+
+	existingCard, errorResponse := s.CardServices.CardRepository.GetCardByUIDTx(ctx, tx, uid)
+	if errorResponse != nil && errorResponse.Code != http.StatusNotFound {
+		return nil, errorResponse
+	}
+
+	if existingCard != nil {
+		item.CardID = existingCard.ID
+		item.Result = entity.EnrollmentResultDuplicate
+		item.Message = fmt.Sprintf("uid already registered as card %d", existingCard.ID)
+	} else {
+		cardID, errorResponse := s.CardServices.CardRepository.InsertCardTx(ctx, tx, &entity.Card{UID: uid, Type: session.CardType})
+		if errorResponse != nil {
+			return nil, errorResponse
+		}
+
+		errorResponse = s.CardServices.CardRepository.InsertCardStatusHistory(ctx, tx, &entity.CardStatusHistory{
+			CardID:    cardID,
+			ToStatus:  entity.CardStatusActive,
+			Reason:    fmt.Sprintf("enrolled in session %d", session.ID),
+			AccountID: session.AccountID,
+		})
+		if errorResponse != nil {
+			return nil, errorResponse
+		}
+
+		item.CardID = cardID
+		item.Result = entity.EnrollmentResultEnrolled
+	}
+
+	id, errorResponse := s.EnrollmentRepository.InsertEnrollmentItem(ctx, tx, item)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+	item.ID = id
+
+	return item, nil
+}
+
+// MapBooksInOrder links the enrolled cards of the session to the given books
+// in scan order. The number of books must match the number of enrolled cards.
+func (s *EnrollmentServices) MapBooksInOrder(ctx context.Context, sessionID int, bookIDs []int) ([]*entity.EnrollmentBookAssignment, *entity.ErrorResponse) {
+	enrolled, errorResponse := s.getEnrolledBookItems(ctx, sessionID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	if len(bookIDs) != len(enrolled) {
+		message := fmt.Sprintf("session has %d enrolled cards but %d book ids were given", len(enrolled), len(bookIDs))
+		return nil, helper.ErrorResponse(http.StatusBadRequest, message)
+	}
+
+	assignments := make([]*entity.EnrollmentBookAssignment, len(enrolled))
+	for i, item := range enrolled {
+		assignments[i] = &entity.EnrollmentBookAssignment{
+			Row:    i + 1,
+			UID:    item.UID,
+			CardID: item.CardID,
+			BookID: bookIDs[i],
+		}
+	}
+
+	return assignments, s.assignBooks(ctx, assignments)
+}
+
+// MapBooksFromCSV links enrolled cards to books from rows of "uid,book_id".
+// A header row is skipped, and UIDs may be written in any hex notation.
+func (s *EnrollmentServices) MapBooksFromCSV(ctx context.Context, sessionID int, file io.Reader) ([]*entity.EnrollmentBookAssignment, *entity.ErrorResponse) {
+	enrolled, errorResponse := s.getEnrolledBookItems(ctx, sessionID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	cards := make(map[string]int, len(enrolled))
+	for _, item := range enrolled {
+		cards[item.UID] = item.CardID
+	}
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+
+	var assignments []*entity.EnrollmentBookAssignment
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, helper.ErrorResponse(http.StatusBadRequest, fmt.Sprintf("row %d: %s", row, err))
+		}
+
+		if row == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "uid") {
+			continue
+		}
+
+		uid, errorResponse := normalizeCardUID(record[0], helper.UIDFormatHex)
+		if errorResponse != nil {
+			return nil, helper.ErrorResponse(http.StatusBadRequest, fmt.Sprintf("row %d: %s", row, errorResponse.Message))
+		}
+
+		bookID, err := strconv.Atoi(strings.TrimSpace(record[1]))
+		if err != nil || bookID <= 0 {
+			return nil, helper.ErrorResponse(http.StatusBadRequest, fmt.Sprintf("row %d: invalid book id %q", row, record[1]))
+		}
+
+		cardID, ok := cards[uid]
+		if !ok {
+			return nil, helper.ErrorResponse(http.StatusBadRequest, fmt.Sprintf("row %d: uid %s was not enrolled in this session", row, uid))
+		}
+
+		assignments = append(assignments, &entity.EnrollmentBookAssignment{
+			Row:    row,
+			UID:    uid,
+			CardID: cardID,
+			BookID: bookID,
+		})
+	}
+
+	if len(assignments) == 0 {
+		return nil, helper.ErrorResponse(http.StatusBadRequest, "csv has no rows")
+	}
+
+	return assignments, s.assignBooks(ctx, assignments)
+}
+
+func (s *EnrollmentServices) getEnrolledBookItems(ctx context.Context, sessionID int) ([]*entity.EnrollmentItem, *entity.ErrorResponse) {
+	session, errorResponse := s.GetEnrollment(ctx, sessionID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+	if session.CardType != "book" {
+		return nil, helper.ErrorResponse(http.StatusConflict, "only book enrollment sessions can be mapped to books")
+	}
+
+	var enrolled []*entity.EnrollmentItem
+	for _, item := range session.Items {
+		if item.Result == entity.EnrollmentResultEnrolled {
+			enrolled = append(enrolled, item)
+		}
+	}
+
+	return enrolled, nil
+}
+
+// assignBooks applies the whole mapping or nothing: every book must exist and
+// have no card yet, and neither a card nor a book may appear twice.
+func (s *EnrollmentServices) assignBooks(ctx context.Context, assignments []*entity.EnrollmentBookAssignment) *entity.ErrorResponse {
+	seenCards := make(map[int]int, len(assignments))
+	seenBooks := make(map[int]int, len(assignments))
+	for _, assignment := range assignments {
+		if row, ok := seenCards[assignment.CardID]; ok {
+			return helper.ErrorResponse(http.StatusBadRequest, fmt.Sprintf("row %d: uid %s is already mapped on row %d", assignment.Row, assignment.UID, row))
+		}
+		seenCards[assignment.CardID] = assignment.Row
+
+		if row, ok := seenBooks[assignment.BookID]; ok {
+			return helper.ErrorResponse(http.StatusBadRequest, fmt.Sprintf("row %d: book id %d is already mapped on row %d", assignment.Row, assignment.BookID, row))
+		}
+		seenBooks[assignment.BookID] = assignment.Row
+
+		book, errorResponse := s.CardServices.BookServices.GetBookByID(ctx, assignment.BookID)
+		if errorResponse != nil {
+			return helper.ErrorResponse(errorResponse.Code, fmt.Sprintf("row %d: %s", assignment.Row, errorResponse.Message))
+		}
+		if book.CardID != 0 {
+			return helper.ErrorResponse(http.StatusConflict, fmt.Sprintf("row %d: book id %d already has card %d", assignment.Row, book.ID, book.CardID))
+		}
+
+		linked, _ := s.CardServices.BookServices.GetBookByCardID(ctx, assignment.CardID)
+		if linked != nil {
+			return helper.ErrorResponse(http.StatusConflict, fmt.Sprintf("row %d: uid %s is already linked to book id %d", assignment.Row, assignment.UID, linked.ID))
+		}
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	for _, assignment := range assignments {
+		errorResponse := s.CardServices.BookServices.BookRepository.SetBookCardID(ctx, tx, assignment.BookID, assignment.CardID)
+		if errorResponse != nil {
+			return errorResponse
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return nil
+}
@@ -0,0 +1,315 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/repository"
+	"github.com/google/uuid"
+)
+
+type KioskServicesInterface interface {
+	StartKioskSession(ctx context.Context, device *entity.AuthDevice, uid string) (*entity.KioskSession, *entity.ErrorResponse)
+	GetKioskSession(ctx context.Context, device *entity.AuthDevice, sessionID int) (*entity.KioskSession, *entity.ErrorResponse)
+	AddKioskBook(ctx context.Context, device *entity.AuthDevice, sessionID int, uid string) (*entity.KioskSession, *entity.ErrorResponse)
+	ConfirmKioskSession(ctx context.Context, device *entity.AuthDevice, sessionID int) (*entity.KioskReceipt, *entity.ErrorResponse)
+	CancelKioskSession(ctx context.Context, device *entity.AuthDevice, sessionID int) *entity.ErrorResponse
+}
+
+type KioskServices struct {
+	DB *sql.DB
+	*repository.KioskRepository
+	*CardServices
+	*BorrowServices
+	*ScanEventServices
+	Config *helper.EnvKiosk
+}
+
+func NewKioskServices(db *sql.DB, kr *repository.KioskRepository, cs *CardServices, bs *BorrowServices, ses *ScanEventServices, envKiosk *helper.EnvKiosk) *KioskServices {
+	return &KioskServices{
+		DB:                db,
+		KioskRepository:   kr,
+		CardServices:      cs,
+		BorrowServices:    bs,
+		ScanEventServices: ses,
+		Config:            envKiosk,
+	}
+}
+
+// StartKioskSession opens a self-checkout for the student whose card was
+// tapped. The student's eligibility is checked up front so an ineligible
+// student is turned away before tapping any book. A session the kiosk still
+// had open is closed, and the tap is logged with the new session.
+func (s *KioskServices) StartKioskSession(ctx context.Context, device *entity.AuthDevice, uid string) (*entity.KioskSession, *entity.ErrorResponse) {
+	event, _, errorResponse := s.resolveKioskTap(ctx, device, uid, "student")
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+	studentID := event.EntityID
+
+	errorResponse = s.BorrowServices.checkBorrowEligibility(ctx, &entity.Borrow{StudentID: studentID})
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	errorResponse = s.KioskRepository.CloseOpenKioskSessions(ctx, tx, device.DeviceID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	sessionID, errorResponse := s.KioskRepository.InsertKioskSession(ctx, tx, device.DeviceID, studentID, s.Config.SessionTimeout)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	_, errorResponse = s.ScanEventServices.ScanEventRepository.InsertScanEvent(ctx, tx, event)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return s.GetKioskSession(ctx, device, sessionID)
+}
+
+func (s *KioskServices) GetKioskSession(ctx context.Context, device *entity.AuthDevice, sessionID int) (*entity.KioskSession, *entity.ErrorResponse) {
+	session, errorResponse := s.KioskRepository.GetKioskSessionByID(ctx, s.DB, sessionID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+	if session.DeviceID != device.DeviceID {
+		return nil, helper.ErrorResponse(http.StatusNotFound, "kiosk session not found")
+	}
+	if session.Status == entity.KioskStatusOpen && session.Expired {
+		session.Status = entity.KioskStatusExpired
+	}
+
+	session.Books, errorResponse = s.KioskRepository.GetKioskSessionBooks(ctx, s.DB, sessionID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	return session, nil
+}
+
+// AddKioskBook adds a tapped book to the session. A book that is already
+// borrowed or waiting on a pending borrow is refused right away, and so is a
+// book the session already has, whichever copy was tapped. When the tag is on
+// a copy, that copy is the one lent. The tap is logged with the added book.
+func (s *KioskServices) AddKioskBook(ctx context.Context, device *entity.AuthDevice, sessionID int, uid string) (*entity.KioskSession, *entity.ErrorResponse) {
+	event, itemID, errorResponse := s.resolveKioskTap(ctx, device, uid, "book")
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+	bookID := event.EntityID
+
+	errorResponse = s.BorrowServices.checkBookAvailable(ctx, bookID, itemID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	session, errorResponse := s.lockOpenKioskSession(ctx, tx, device, sessionID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	errorResponse = s.KioskRepository.InsertKioskSessionBook(ctx, tx, session.ID, bookID, itemID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	_, errorResponse = s.ScanEventServices.ScanEventRepository.InsertScanEvent(ctx, tx, event)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	errorResponse = s.KioskRepository.TouchKioskSession(ctx, tx, session.ID, s.Config.SessionTimeout)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return s.GetKioskSession(ctx, device, sessionID)
+}
+
+// ConfirmKioskSession borrows every book in the session for the student in
+// one transaction and returns the receipt. Self-checkout borrows start out as
+// borrowed, since the student leaves with the books.
+func (s *KioskServices) ConfirmKioskSession(ctx context.Context, device *entity.AuthDevice, sessionID int) (*entity.KioskReceipt, *entity.ErrorResponse) {
+	tx, err := s.DB.BeginTx(ctx, borrowTxOptions)
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	session, errorResponse := s.lockOpenKioskSession(ctx, tx, device, sessionID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	books, errorResponse := s.KioskRepository.GetKioskSessionBooks(ctx, s.DB, session.ID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+	if len(books) == 0 {
+		return nil, helper.ErrorResponse(http.StatusBadRequest, "tap at least one book before confirming")
+	}
+
+	borrow := &entity.Borrow{
+		TransactionID: uuid.New().String(),
+		StudentID:     session.StudentID,
+		Status:        "borrowed",
+	}
+	for _, book := range books {
+		borrow.BookIDS = append(borrow.BookIDS, book.ID)
+		borrow.ItemIDS = append(borrow.ItemIDS, book.ItemID)
+	}
+
+	errorResponse = s.BorrowServices.checkBorrowEligibility(ctx, borrow)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	errorResponse = s.BorrowServices.lockBorrowBooks(ctx, tx, borrow)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	errorResponse = s.BorrowServices.BorrowRepository.InsertBorrow(ctx, tx, borrow)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	errorResponse = s.KioskRepository.CloseKioskSession(ctx, tx, session.ID, entity.KioskStatusConfirmed, borrow.TransactionID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	created, errorResponse := s.BorrowServices.GetBorrowByTransactionID(ctx, borrow.TransactionID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	return &entity.KioskReceipt{
+		TransactionID: borrow.TransactionID,
+		StudentID:     session.StudentID,
+		StudentName:   session.StudentName,
+		Books:         books,
+		BorrowDate:    created.BorrowDate,
+		DueDate:       created.DueDate,
+	}, nil
+}
+
+func (s *KioskServices) CancelKioskSession(ctx context.Context, device *entity.AuthDevice, sessionID int) *entity.ErrorResponse {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	session, errorResponse := s.lockOpenKioskSession(ctx, tx, device, sessionID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	errorResponse = s.KioskRepository.CloseKioskSession(ctx, tx, session.ID, entity.KioskStatusCancelled, "")
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	if err := tx.Commit(); err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return nil
+}
+
+// lockOpenKioskSession locks a session of this kiosk that can still be used.
+// A session found past its timeout is marked expired and committed right
+// here, so the caller gets 410 and has nothing left to roll back.
+func (s *KioskServices) lockOpenKioskSession(ctx context.Context, tx *sql.Tx, device *entity.AuthDevice, sessionID int) (*entity.KioskSession, *entity.ErrorResponse) {
+	session, errorResponse := s.KioskRepository.GetKioskSessionForUpdate(ctx, tx, sessionID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+	if session.DeviceID != device.DeviceID {
+		return nil, helper.ErrorResponse(http.StatusNotFound, "kiosk session not found")
+	}
+	if session.Status != entity.KioskStatusOpen {
+		return nil, helper.ErrorResponse(http.StatusConflict, "kiosk session is already "+session.Status)
+	}
+
+	if session.Expired {
+		errorResponse := s.KioskRepository.CloseKioskSession(ctx, tx, session.ID, entity.KioskStatusExpired, "")
+		if errorResponse != nil {
+			return nil, errorResponse
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+		}
+		return nil, helper.ErrorResponse(http.StatusGone, "kiosk session has expired")
+	}
+
+	return session, nil
+}
+
+// resolveKioskTap resolves a tapped UID into a borrow scan event whose entity
+// is the student or book the card belongs to, refusing cards of the other
+// type. The caller logs the event in its own transaction. For a book tag on a
+// copy, the copy's id is returned as well.
+func (s *KioskServices) resolveKioskTap(ctx context.Context, device *entity.AuthDevice, uid, wantType string) (event *entity.ScanEvent, itemID int, errorResponse *entity.ErrorResponse) {
+	uid, errorResponse = normalizeCardUID(uid, device.UIDFormat)
+	if errorResponse != nil {
+		return nil, 0, errorResponse
+	}
+
+	event = &entity.ScanEvent{
+		DeviceID: device.DeviceID,
+		UID:      uid,
+		Purpose:  entity.ScanPurposeBorrow,
+	}
+	errorResponse = s.ScanEventServices.ResolveScanEvent(ctx, event)
+	if errorResponse != nil {
+		return nil, 0, errorResponse
+	}
+	if event.CardType != wantType {
+		return nil, 0, helper.ErrorResponse(http.StatusBadRequest, fmt.Sprintf("expected a %s card but a %s card was tapped", wantType, event.CardType))
+	}
+
+	if event.CardType == "book" {
+		item, errorResponse := s.CardServices.GetBookItemByUID(ctx, uid)
+		if errorResponse != nil && errorResponse.Code != http.StatusNotFound {
+			return nil, 0, errorResponse
+		}
+		if item != nil {
+			itemID = item.ID
+		}
+	}
+
+	return event, itemID, nil
+}
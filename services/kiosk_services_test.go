@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/repository"
+)
+
+// TestKioskTapsAreLogged walks a kiosk session through a student tap and two
+// copies of the same book. Accepted taps are logged as borrow scans; the
+// second copy is refused and leaves neither a session book nor a scan.
+func TestKioskTapsAreLogged(t *testing.T) {
+	db := openTestMySQL(t)
+	borrowServices := newTestBorrowServices(db)
+	scanEventServices := NewScanEventServices(db, repository.NewScanEventRepository(), borrowServices.CardServices, helper.GetEnvScanEvents())
+	s := NewKioskServices(db, repository.NewKioskRepository(), borrowServices.CardServices, borrowServices, scanEventServices, helper.GetEnvKiosk())
+
+	deviceID := mustInsert(t, db, "INSERT INTO devices (name) VALUES ('kiosk')")
+	device := &entity.AuthDevice{DeviceID: deviceID, UIDFormat: helper.UIDFormatHex}
+
+	studentID := seedStudent(t, db, "erin")
+	studentCardID := mustInsert(t, db, "INSERT INTO card_rfid (uid, type) VALUES ('A1A1A1A1', 'student')")
+	if _, err := db.Exec("UPDATE students SET card_id = ? WHERE id = ?", studentCardID, studentID); err != nil {
+		t.Fatal(err)
+	}
+
+	bookID := seedBook(t, db, "Two Copies")
+	var itemIDs []int
+	for _, uid := range []string{"B1B1B1B1", "B2B2B2B2"} {
+		cardID := mustInsert(t, db, "INSERT INTO card_rfid (uid, type) VALUES (?, 'book')", uid)
+		itemID := seedBookItem(t, db, bookID, uid)
+		if _, err := db.Exec("UPDATE book_items SET card_id = ? WHERE id = ?", cardID, itemID); err != nil {
+			t.Fatal(err)
+		}
+		itemIDs = append(itemIDs, itemID)
+	}
+
+	session, errorResponse := s.StartKioskSession(context.Background(), device, "A1A1A1A1")
+	if errorResponse != nil {
+		t.Fatalf("StartKioskSession: %d %s", errorResponse.Code, errorResponse.Message)
+	}
+	if count := countScanEvents(t, db, deviceID, entity.ScanPurposeBorrow, studentID); count != 1 {
+		t.Fatalf("%d borrow scans of the student, want 1", count)
+	}
+
+	_, errorResponse = s.AddKioskBook(context.Background(), device, session.ID, "B1B1B1B1")
+	if errorResponse != nil {
+		t.Fatalf("AddKioskBook: %d %s", errorResponse.Code, errorResponse.Message)
+	}
+	if count := countScanEvents(t, db, deviceID, entity.ScanPurposeBorrow, bookID); count != 1 {
+		t.Fatalf("%d borrow scans of the book, want 1", count)
+	}
+
+	_, errorResponse = s.AddKioskBook(context.Background(), device, session.ID, "B2B2B2B2")
+	if errorResponse == nil || errorResponse.Code != http.StatusConflict {
+		t.Fatalf("AddKioskBook of a second copy = %v, want 409", errorResponse)
+	}
+	if count := countScanEvents(t, db, deviceID, entity.ScanPurposeBorrow, bookID); count != 1 {
+		t.Errorf("%d borrow scans of the book after the refused copy, want 1", count)
+	}
+
+	session, errorResponse = s.GetKioskSession(context.Background(), device, session.ID)
+	if errorResponse != nil {
+		t.Fatalf("GetKioskSession: %d %s", errorResponse.Code, errorResponse.Message)
+	}
+	if len(session.Books) != 1 || session.Books[0].ItemID != itemIDs[0] {
+		t.Errorf("session has %d books, want only copy %d", len(session.Books), itemIDs[0])
+	}
+}
+
+// TestConfirmKioskSessionConcurrently has two students confirm a kiosk
+// checkout of the same book at once. Like InsertBorrow, only one may get it.
+func TestConfirmKioskSessionConcurrently(t *testing.T) {
+	db := openTestMySQL(t)
+	borrowServices := newTestBorrowServices(db)
+	scanEventServices := NewScanEventServices(db, repository.NewScanEventRepository(), borrowServices.CardServices, helper.GetEnvScanEvents())
+	s := NewKioskServices(db, repository.NewKioskRepository(), borrowServices.CardServices, borrowServices, scanEventServices, helper.GetEnvKiosk())
+
+	deviceID := mustInsert(t, db, "INSERT INTO devices (name) VALUES ('kiosk')")
+	device := &entity.AuthDevice{DeviceID: deviceID, UIDFormat: helper.UIDFormatHex}
+	students := []int{seedStudent(t, db, "alice"), seedStudent(t, db, "bob")}
+
+	tests := []struct {
+		name     string
+		seed     func() (bookID int, itemID any)
+		wantOpen int
+	}{
+		{
+			name: "book without copies",
+			seed: func() (int, any) {
+				return seedBook(t, db, "Single Copy"), nil
+			},
+			wantOpen: 1,
+		},
+		{
+			name: "last free copy",
+			seed: func() (int, any) {
+				bookID := seedBook(t, db, "Two Copies")
+				lentItemID := seedBookItem(t, db, bookID, "TC-1")
+				freeItemID := seedBookItem(t, db, bookID, "TC-2")
+				mustInsert(t, db, "INSERT INTO borrows (student_id, transaction_id, book_id, item_id, due_date, status) VALUES (?, 'lent-before', ?, ?, NOW() + INTERVAL 7 DAY, 'borrowed')", seedStudent(t, db, "carol"), bookID, lentItemID)
+				return bookID, freeItemID
+			},
+			wantOpen: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bookID, itemID := tt.seed()
+
+			sessions := make([]int, len(students))
+			for i, studentID := range students {
+				sessions[i] = mustInsert(t, db, "INSERT INTO kiosk_sessions (device_id, student_id, expires_at) VALUES (?, ?, NOW() + INTERVAL 10 MINUTE)", deviceID, studentID)
+				mustInsert(t, db, "INSERT INTO kiosk_session_books (session_id, book_id, item_id) VALUES (?, ?, ?)", sessions[i], bookID, itemID)
+			}
+
+			results := runTogether(students, func(i, _ int) *entity.ErrorResponse {
+				_, errorResponse := s.ConfirmKioskSession(context.Background(), device, sessions[i])
+				return errorResponse
+			})
+
+			checkOneCheckoutWins(t, results)
+			if open := countOpenBorrows(t, db, bookID); open != tt.wantOpen {
+				t.Errorf("book has %d open borrows, want %d", open, tt.wantOpen)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/repository"
+)
+
+type LoginAttemptServicesInterface interface {
+	CheckIPAllowed(ctx context.Context, ipAddress string) *entity.ErrorResponse
+	CheckAccountLocked(ctx context.Context, accountID int) *entity.ErrorResponse
+	RecordLoginAttempt(ctx context.Context, attempt *entity.LoginAttempt)
+	RegisterFailedLogin(ctx context.Context, accountID int) *entity.ErrorResponse
+	ResetFailedLogins(ctx context.Context, accountID int) *entity.ErrorResponse
+	GetLockedAccounts(ctx context.Context) ([]*entity.LockedAccount, *entity.ErrorResponse)
+	GetLoginHistory(ctx context.Context, accountID, page, pageSize int) ([]*entity.LoginAttempt, *entity.ErrorResponse)
+}
+
+type LoginAttemptServices struct {
+	DB *sql.DB
+	*repository.LoginAttemptRepository
+	Config *helper.EnvLoginProtection
+}
+
+func NewLoginAttemptServices(db *sql.DB, lar *repository.LoginAttemptRepository, envLoginProtection *helper.EnvLoginProtection) *LoginAttemptServices {
+	return &LoginAttemptServices{
+		DB:                     db,
+		LoginAttemptRepository: lar,
+		Config:                 envLoginProtection,
+	}
+}
+
+// CheckIPAllowed lets an address through freely until it reaches IPMaxFailed
+// failed logins within IPWindow. After that it has to wait between attempts,
+// and every further failure doubles the wait.
+func (s *LoginAttemptServices) CheckIPAllowed(ctx context.Context, ipAddress string) *entity.ErrorResponse {
+	count, sinceLast, errorResponse := s.LoginAttemptRepository.GetRecentFailedAttemptsByIP(ctx, s.DB, ipAddress, s.Config.IPWindow)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	if count < s.Config.IPMaxFailed {
+		return nil
+	}
+
+	if remaining := s.ipBackoff(count) - sinceLast; remaining > 0 {
+		message := fmt.Sprintf("Too many failed login attempts from this address. Try again in %d seconds.", int(math.Ceil(remaining.Seconds())))
+		return helper.ErrorResponse(http.StatusTooManyRequests, message)
+	}
+
+	return nil
+}
+
+func (s *LoginAttemptServices) CheckAccountLocked(ctx context.Context, accountID int) *entity.ErrorResponse {
+	remaining, errorResponse := s.LoginAttemptRepository.GetLockRemaining(ctx, s.DB, accountID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	if remaining > 0 {
+		message := fmt.Sprintf("Account is temporarily locked. Try again in %d seconds.", int(remaining.Seconds()))
+		return helper.ErrorResponse(http.StatusTooManyRequests, message)
+	}
+
+	return nil
+}
+
+func (s *LoginAttemptServices) RecordLoginAttempt(ctx context.Context, attempt *entity.LoginAttempt) {
+	if errorResponse := s.LoginAttemptRepository.InsertLoginAttempt(ctx, s.DB, attempt); errorResponse != nil {
+		log.Println("login attempt:", errorResponse.Message)
+	}
+}
+
+// RegisterFailedLogin counts a wrong password. Once the account reaches the
+// configured limit it is locked, and every further failure doubles the lock.
+func (s *LoginAttemptServices) RegisterFailedLogin(ctx context.Context, accountID int) *entity.ErrorResponse {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	attempts, errorResponse := s.LoginAttemptRepository.IncrementFailedAttempts(ctx, tx, accountID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	if attempts >= s.Config.MaxFailedAttempts {
+		errorResponse = s.LoginAttemptRepository.LockAccount(ctx, tx, accountID, s.lockoutDuration(attempts))
+		if errorResponse != nil {
+			return errorResponse
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return nil
+}
+
+func (s *LoginAttemptServices) lockoutDuration(attempts int) time.Duration {
+	return backoff(s.Config.LockoutBase, s.Config.LockoutMax, attempts-s.Config.MaxFailedAttempts)
+}
+
+func (s *LoginAttemptServices) ipBackoff(failures int) time.Duration {
+	return backoff(s.Config.IPBackoffBase, s.Config.IPBackoffMax, failures-s.Config.IPMaxFailed)
+}
+
+// backoff doubles base the given number of times, without going over max.
+func backoff(base, max time.Duration, doublings int) time.Duration {
+	duration := base
+	for i := 0; i < doublings && duration < max; i++ {
+		duration *= 2
+	}
+
+	if duration > max {
+		return max
+	}
+	return duration
+}
+
+func (s *LoginAttemptServices) ResetFailedLogins(ctx context.Context, accountID int) *entity.ErrorResponse {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	errorResponse := s.LoginAttemptRepository.ResetFailedAttempts(ctx, tx, accountID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	if err := tx.Commit(); err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return nil
+}
+
+func (s *LoginAttemptServices) GetLockedAccounts(ctx context.Context) ([]*entity.LockedAccount, *entity.ErrorResponse) {
+	return s.LoginAttemptRepository.GetLockedAccounts(ctx, s.DB)
+}
+
+func (s *LoginAttemptServices) GetLoginHistory(ctx context.Context, accountID, page, pageSize int) ([]*entity.LoginAttempt, *entity.ErrorResponse) {
+	return s.LoginAttemptRepository.GetLoginAttemptsByAccountID(ctx, s.DB, accountID, page, pageSize)
+}
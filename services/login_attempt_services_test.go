@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/repository"
+)
+
+func newTestLoginAttemptServices(t *testing.T) (*LoginAttemptServices, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	s := NewLoginAttemptServices(db, repository.NewLoginAttemptRepository(), &helper.EnvLoginProtection{
+		MaxFailedAttempts: 5,
+		LockoutBase:       time.Minute,
+		LockoutMax:        time.Hour,
+		IPMaxFailed:       20,
+		IPWindow:          15 * time.Minute,
+		IPBackoffBase:     time.Second,
+		IPBackoffMax:      5 * time.Minute,
+	})
+	return s, mock
+}
+
+func TestLockoutDuration(t *testing.T) {
+	s, _ := newTestLoginAttemptServices(t)
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 5, want: time.Minute},
+		{attempts: 6, want: 2 * time.Minute},
+		{attempts: 7, want: 4 * time.Minute},
+		{attempts: 10, want: 32 * time.Minute},
+		{attempts: 11, want: time.Hour},
+		{attempts: 1000, want: time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := s.lockoutDuration(tt.attempts); got != tt.want {
+			t.Errorf("lockoutDuration(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestCheckIPAllowed(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int
+		sinceLast int
+		wantCode  int
+	}{
+		{name: "below the limit", failures: 19},
+		{name: "at the limit, waited", failures: 20, sinceLast: 1},
+		{name: "at the limit, too soon", failures: 20, wantCode: http.StatusTooManyRequests},
+		{name: "doubled wait, too soon", failures: 23, sinceLast: 7, wantCode: http.StatusTooManyRequests},
+		{name: "doubled wait, waited", failures: 23, sinceLast: 8},
+		{name: "wait is capped", failures: 100, sinceLast: 300},
+		{name: "wait is capped, too soon", failures: 100, sinceLast: 299, wantCode: http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newTestLoginAttemptServices(t)
+
+			mock.ExpectQuery(regexp.QuoteMeta("FROM login_attempts WHERE ip_address = ? AND success = 0")).
+				WithArgs("10.0.0.1", 900).
+				WillReturnRows(sqlmock.NewRows([]string{"count", "since_last"}).AddRow(tt.failures, tt.sinceLast))
+
+			errorResponse := s.CheckIPAllowed(context.Background(), "10.0.0.1")
+			switch {
+			case tt.wantCode == 0 && errorResponse != nil:
+				t.Fatalf("CheckIPAllowed: %d %s", errorResponse.Code, errorResponse.Message)
+			case tt.wantCode != 0 && (errorResponse == nil || errorResponse.Code != tt.wantCode):
+				t.Fatalf("CheckIPAllowed = %v, want %d", errorResponse, tt.wantCode)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
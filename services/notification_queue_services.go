@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/repository"
+)
+
+// notificationBatchSize caps how many queued emails one send pass handles.
+const notificationBatchSize = 50
+
+type NotificationQueueServicesInterface interface {
+	SendQueuedNotifications(ctx context.Context) *entity.ErrorResponse
+	RunNotificationQueue(ctx context.Context)
+}
+
+type NotificationQueueServices struct {
+	DB *sql.DB
+	*repository.NotificationQueueRepository
+	Config *helper.EnvNotificationQueue
+	Mail   helper.Mailer
+}
+
+func NewNotificationQueueServices(db *sql.DB, nqr *repository.NotificationQueueRepository, envNotificationQueue *helper.EnvNotificationQueue, mailer helper.Mailer) *NotificationQueueServices {
+	return &NotificationQueueServices{
+		DB:                          db,
+		NotificationQueueRepository: nqr,
+		Config:                      envNotificationQueue,
+		Mail:                        mailer,
+	}
+}
+
+// SendQueuedNotifications sends the pending emails in the order they were
+// queued. A failed email is left pending for the next pass.
+func (s *NotificationQueueServices) SendQueuedNotifications(ctx context.Context) *entity.ErrorResponse {
+	notifications, errorResponse := s.NotificationQueueRepository.GetPendingNotifications(ctx, s.DB, notificationBatchSize)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	for _, notification := range notifications {
+		if err := s.Mail.SendHTMLMail(notification.Email, notification.Subject, notification.Body); err != nil {
+			log.Printf("notification queue: sending %d: %v", notification.ID, err)
+			errorResponse = s.NotificationQueueRepository.MarkNotificationAttemptFailed(ctx, s.DB, notification.ID, err.Error(), s.Config.MaxAttempts)
+		} else {
+			errorResponse = s.NotificationQueueRepository.MarkNotificationSent(ctx, s.DB, notification.ID)
+		}
+		if errorResponse != nil {
+			return errorResponse
+		}
+	}
+
+	return nil
+}
+
+// RunNotificationQueue sends queued emails every SendInterval until ctx is
+// cancelled. It is meant to run in its own goroutine.
+func (s *NotificationQueueServices) RunNotificationQueue(ctx context.Context) {
+	ticker := time.NewTicker(s.Config.SendInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		if errorResponse := s.SendQueuedNotifications(ctx); errorResponse != nil {
+			log.Println("notification queue:", errorResponse.Message)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/repository"
+)
+
+type PasswordResetServicesInterface interface {
+	ForgotPassword(ctx context.Context, request *entity.ForgotPasswordRequest)
+	ResetPassword(ctx context.Context, request *entity.ResetPasswordRequest) *entity.ErrorResponse
+}
+
+type PasswordResetServices struct {
+	DB *sql.DB
+	*repository.PasswordResetRepository
+	*AccountServices
+	Mail   helper.Mailer
+	Config *helper.EnvPasswordReset
+}
+
+func NewPasswordResetServices(db *sql.DB, prr *repository.PasswordResetRepository, as *AccountServices, mailer helper.Mailer, envPasswordReset *helper.EnvPasswordReset) *PasswordResetServices {
+	return &PasswordResetServices{
+		DB:                      db,
+		PasswordResetRepository: prr,
+		AccountServices:         as,
+		Mail:                    mailer,
+		Config:                  envPasswordReset,
+	}
+}
+
+// ForgotPassword issues the reset token in the background, so the caller gets
+// the same response and timing whether or not the email is registered.
+func (s *PasswordResetServices) ForgotPassword(_ context.Context, request *entity.ForgotPasswordRequest) {
+	go s.issueResetToken(context.Background(), request.Email)
+}
+
+func (s *PasswordResetServices) issueResetToken(ctx context.Context, email string) {
+	account, errorResponse := s.AccountServices.GetAccountByEmail(ctx, email)
+	if errorResponse != nil {
+		return
+	}
+
+	token, err := helper.GenerateRandomToken(32)
+	if err != nil {
+		log.Println("password reset:", err)
+		return
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("password reset:", err)
+		return
+	}
+	defer tx.Rollback()
+
+	// Concurrent requests for the account wait here, so each one counts the
+	// tokens the others issued and the limit holds.
+	errorResponse = s.AccountsRepository.LockAccountByID(ctx, tx, account.ID)
+	if errorResponse != nil {
+		log.Println("password reset:", errorResponse.Message)
+		return
+	}
+
+	count, errorResponse := s.PasswordResetRepository.CountRecentResetTokens(ctx, tx, account.ID, s.Config.RateWindow)
+	if errorResponse != nil {
+		log.Println("password reset:", errorResponse.Message)
+		return
+	}
+	if count >= s.Config.MaxPerAccount {
+		return
+	}
+
+	errorResponse = s.PasswordResetRepository.InsertResetToken(ctx, tx, account.ID, helper.HashToken(token), s.Config.TokenTTL)
+	if errorResponse != nil {
+		log.Println("password reset:", errorResponse.Message)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println("password reset:", err)
+		return
+	}
+
+	link := s.Config.URL + "?token=" + url.QueryEscape(token)
+	body := helper.ActionMailBody("Kami menerima permintaan untuk mengatur ulang kata sandi akun Smart Library kamu. Abaikan email ini jika kamu tidak memintanya.", "Atur Ulang Kata Sandi", link)
+	if err := s.Mail.SendHTMLMail(account.Email, "Atur ulang kata sandi Smart Library", body); err != nil {
+		log.Println("password reset:", err)
+	}
+}
+
+func (s *PasswordResetServices) ResetPassword(ctx context.Context, request *entity.ResetPasswordRequest) *entity.ErrorResponse {
+	if errorResponse := s.checkPasswordPolicy(request.NewPassword); errorResponse != nil {
+		return errorResponse
+	}
+
+	passwordHash, err := helper.HashPassword(request.NewPassword)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	accountID, errorResponse := s.PasswordResetRepository.GetAccountIDByResetTokenForUpdate(ctx, tx, helper.HashToken(request.Token))
+	if errorResponse != nil {
+		if errorResponse.Code == http.StatusNotFound {
+			return helper.ErrorResponse(http.StatusBadRequest, "Reset token is invalid or has expired")
+		}
+		return errorResponse
+	}
+
+	currentHash, errorResponse := s.AccountsRepository.GetAccountPasswordHashForUpdate(ctx, tx, accountID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+	if errorResponse := s.checkPasswordReuse(ctx, accountID, currentHash, request.NewPassword); errorResponse != nil {
+		return errorResponse
+	}
+
+	errorResponse = s.replacePassword(ctx, tx, accountID, currentHash, passwordHash)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	errorResponse = s.PasswordResetRepository.UseResetTokensByAccountID(ctx, tx, accountID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	errorResponse = s.SessionRepository.RevokeSessionsByAccountID(ctx, tx, accountID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	if err := tx.Commit(); err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/repository"
+)
+
+func newTestPasswordResetServices(t *testing.T) (*PasswordResetServices, sqlmock.Sqlmock, *testMailer) {
+	t.Helper()
+
+	as, mock, mailer := newTestAccountServices(t)
+	s := NewPasswordResetServices(as.DB, repository.NewPasswordResetRepository(), as, mailer, &helper.EnvPasswordReset{
+		URL:           "https://library.test/reset",
+		TokenTTL:      30 * time.Minute,
+		MaxPerAccount: 2,
+		RateWindow:    time.Hour,
+	})
+	return s, mock, mailer
+}
+
+// TestIssueResetToken checks that the recent tokens are counted only after
+// the account row is locked, in the transaction that inserts the new one.
+func TestIssueResetToken(t *testing.T) {
+	tests := []struct {
+		name     string
+		recent   int
+		wantMail bool
+	}{
+		{name: "under the limit", recent: 1, wantMail: true},
+		{name: "at the limit", recent: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock, mailer := newTestPasswordResetServices(t)
+
+			mock.ExpectQuery(regexp.QuoteMeta("FROM accounts WHERE email = ?")).
+				WithArgs("budi@student.test").
+				WillReturnRows(sqlmock.NewRows(testAccountColumns).AddRow(7, "budi@student.test", "student", true))
+			mock.ExpectBegin()
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM accounts WHERE id = ? FOR UPDATE")).
+				WithArgs(7).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM password_reset_tokens")).
+				WithArgs(7, 3600).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(tt.recent))
+			if tt.wantMail {
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO password_reset_tokens")).
+					WithArgs(7, sqlmock.AnyArg(), 1800).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
+
+			s.issueResetToken(context.Background(), "budi@student.test")
+
+			want := 0
+			if tt.wantMail {
+				want = 1
+			}
+			if sent := len(mailer.Sent()); sent != want {
+				t.Errorf("%d reset mails sent, want %d", sent, want)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// TestResetPassword checks that the current hash is read and locked inside
+// the reset transaction, so the history check and the update see one value.
+func TestResetPassword(t *testing.T) {
+	currentHash := mustHashPassword(t, "Current-Pass-1")
+
+	tests := []struct {
+		name        string
+		newPassword string
+		wantCode    int
+	}{
+		{name: "accepted", newPassword: "Brand-New-Pass-3"},
+		{name: "same as current", newPassword: "Current-Pass-1", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock, _ := newTestPasswordResetServices(t)
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT account_id FROM password_reset_tokens WHERE token_hash = ?")).
+				WithArgs(helper.HashToken("reset-token")).
+				WillReturnRows(sqlmock.NewRows([]string{"account_id"}).AddRow(7))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT password FROM accounts WHERE id = ? FOR UPDATE")).
+				WithArgs(7).
+				WillReturnRows(sqlmock.NewRows([]string{"password"}).AddRow(currentHash))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT password_hash FROM password_history")).
+				WithArgs(7, 2).
+				WillReturnRows(sqlmock.NewRows([]string{"password_hash"}))
+			if tt.wantCode == 0 {
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE accounts SET password = ?")).
+					WithArgs(passwordHashOf(tt.newPassword), 7).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO password_history")).
+					WithArgs(7, currentHash).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(regexp.QuoteMeta("DELETE FROM password_history")).
+					WithArgs(7, 7, 2).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE password_reset_tokens SET used_at = NOW()")).
+					WithArgs(7).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE sessions SET revoked_at = NOW()")).
+					WithArgs(7).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
+
+			errorResponse := s.ResetPassword(context.Background(), &entity.ResetPasswordRequest{
+				Token:       "reset-token",
+				NewPassword: tt.newPassword,
+			})
+			switch {
+			case tt.wantCode == 0 && errorResponse != nil:
+				t.Fatalf("ResetPassword: %d %s", errorResponse.Code, errorResponse.Message)
+			case tt.wantCode != 0 && (errorResponse == nil || errorResponse.Code != tt.wantCode):
+				t.Fatalf("ResetPassword = %v, want %d", errorResponse, tt.wantCode)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
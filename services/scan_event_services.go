@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/repository"
+)
+
+// scanEventPruneBatch caps the rows deleted per statement so pruning a large
+// backlog does not hold long locks on scan_events.
+const scanEventPruneBatch = 5000
+
+type ScanEventServicesInterface interface {
+	ResolveScanEvent(ctx context.Context, event *entity.ScanEvent) *entity.ErrorResponse
+	RecordScanEvent(ctx context.Context, event *entity.ScanEvent) *entity.ErrorResponse
+	LookupCard(ctx context.Context, device *entity.AuthDevice, uid string) (id int, cardType string, errorResponse *entity.ErrorResponse)
+	GetScanEvents(ctx context.Context, filter *entity.ScanEventFilter) ([]*entity.ScanEvent, *entity.ErrorResponse)
+	PruneScanEvents(ctx context.Context) (int64, *entity.ErrorResponse)
+	RunRetention(ctx context.Context)
+}
+
+type ScanEventServices struct {
+	DB *sql.DB
+	*repository.ScanEventRepository
+	*CardServices
+	Config *helper.EnvScanEvents
+}
+
+func NewScanEventServices(db *sql.DB, ser *repository.ScanEventRepository, cs *CardServices, envScanEvents *helper.EnvScanEvents) *ScanEventServices {
+	return &ScanEventServices{
+		DB:                  db,
+		ScanEventRepository: ser,
+		CardServices:        cs,
+		Config:              envScanEvents,
+	}
+}
+
+// ResolveScanEvent fills in the card and entity the UID belongs to right now.
+// Unknown, unassigned or unusable cards are still logged, so the returned
+// reason is informational only.
+func (s *ScanEventServices) ResolveScanEvent(ctx context.Context, event *entity.ScanEvent) *entity.ErrorResponse {
+	if event.Purpose == "" {
+		event.Purpose = entity.ScanPurposeUnknown
+	}
+
+	card, errorResponse := s.CardServices.GetCardByUID(ctx, event.UID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+	event.CardID = card.ID
+	event.CardType = card.Type
+
+	entityID, _, errorResponse := s.CardServices.GetCardTypeByUID(ctx, event.UID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+	event.EntityID = entityID
+
+	return nil
+}
+
+// RecordScanEvent resolves and logs a scan that is not part of a larger
+// transaction, and returns the reason the UID did not resolve, if any. A
+// failure to log is only logged so it never fails the scan itself.
+func (s *ScanEventServices) RecordScanEvent(ctx context.Context, event *entity.ScanEvent) *entity.ErrorResponse {
+	unresolved := s.ResolveScanEvent(ctx, event)
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("scan event: transaction start failed")
+		return unresolved
+	}
+	defer tx.Rollback()
+
+	if _, errorResponse := s.ScanEventRepository.InsertScanEvent(ctx, tx, event); errorResponse != nil {
+		log.Println("scan event:", errorResponse.Message)
+		return unresolved
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println("scan event: transaction commit failed")
+	}
+
+	return unresolved
+}
+
+// LookupCard answers a reader's check_card with the student or book the
+// tapped card belongs to. The lookup is logged whether or not it resolves.
+func (s *ScanEventServices) LookupCard(ctx context.Context, device *entity.AuthDevice, uid string) (id int, cardType string, errorResponse *entity.ErrorResponse) {
+	uid, errorResponse = normalizeCardUID(uid, device.UIDFormat)
+	if errorResponse != nil {
+		return 0, "", errorResponse
+	}
+
+	event := &entity.ScanEvent{
+		DeviceID: device.DeviceID,
+		UID:      uid,
+		Purpose:  entity.ScanPurposeLookup,
+	}
+	errorResponse = s.RecordScanEvent(ctx, event)
+	if errorResponse != nil {
+		return 0, "", errorResponse
+	}
+
+	return event.EntityID, event.CardType, nil
+}
+
+func (s *ScanEventServices) GetScanEvents(ctx context.Context, filter *entity.ScanEventFilter) ([]*entity.ScanEvent, *entity.ErrorResponse) {
+	if filter.From != "" && filter.To != "" && filter.From > filter.To {
+		return nil, helper.ErrorResponse(http.StatusBadRequest, "from must not be after to")
+	}
+
+	return s.ScanEventRepository.GetScanEvents(ctx, s.DB, filter)
+}
+
+// PruneScanEvents deletes events older than the configured retention and
+// returns how many were removed.
+func (s *ScanEventServices) PruneScanEvents(ctx context.Context) (int64, *entity.ErrorResponse) {
+	if s.Config.Retention <= 0 {
+		return 0, nil
+	}
+
+	var total int64
+	for {
+		deleted, errorResponse := s.ScanEventRepository.DeleteScanEventsOlderThan(ctx, s.DB, s.Config.Retention, scanEventPruneBatch)
+		if errorResponse != nil {
+			return total, errorResponse
+		}
+		total += deleted
+
+		if deleted < scanEventPruneBatch {
+			return total, nil
+		}
+	}
+}
+
+// RunRetention prunes the scan log once at start and then every
+// PruneInterval until ctx is cancelled. It is meant to run in its own goroutine.
+func (s *ScanEventServices) RunRetention(ctx context.Context) {
+	if s.Config.Retention <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.Config.PruneInterval)
+	defer ticker.Stop()
+
+	for {
+		deleted, errorResponse := s.PruneScanEvents(ctx)
+		if errorResponse != nil {
+			log.Println("scan event retention:", errorResponse.Message)
+		} else if deleted > 0 {
+			log.Printf("scan event retention: pruned %d events", deleted)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/repository"
+)
+
+func newTestScanEventServices(t *testing.T) (*ScanEventServices, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	studentServices := NewStudentServices(db, repository.NewStudentRepository())
+	bookServices := NewBookServices(repository.NewBookRepository(), repository.NewBookItemRepository(), db)
+	cardServices := NewCardServices(db, repository.NewCardRepository(), studentServices, bookServices)
+
+	return NewScanEventServices(db, repository.NewScanEventRepository(), cardServices, helper.GetEnvScanEvents()), mock
+}
+
+func expectCardByUID(mock sqlmock.Sqlmock, uid string, card *entity.Card) {
+	rows := sqlmock.NewRows([]string{"id", "uid", "type", "status"})
+	if card != nil {
+		rows.AddRow(card.ID, card.UID, card.Type, card.Status)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("FROM card_rfid WHERE uid = ?")).
+		WithArgs(uid).
+		WillReturnRows(rows)
+}
+
+// TestLookupCard checks that check_card converts the reader's UID and logs
+// the lookup, including lookups of cards that do not resolve.
+func TestLookupCard(t *testing.T) {
+	studentCard := &entity.Card{ID: 40, UID: "0A0B0C0D", Type: "student", Status: entity.CardStatusActive}
+
+	tests := []struct {
+		name         string
+		uid          string
+		expect       func(mock sqlmock.Sqlmock)
+		wantID       int
+		wantCardType string
+		wantCode     int
+	}{
+		{
+			name: "student card",
+			uid:  "168496141",
+			expect: func(mock sqlmock.Sqlmock) {
+				expectCardByUID(mock, "0A0B0C0D", studentCard)
+				expectCardByUID(mock, "0A0B0C0D", studentCard)
+				mock.ExpectQuery(regexp.QuoteMeta("FROM students WHERE card_id = ?")).
+					WithArgs(40).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "npm", "card_id"}).AddRow(12, "Budi", "12345678", 40))
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO scan_events")).
+					WithArgs(3, "0A0B0C0D", 40, "student", 12, entity.ScanPurposeLookup, nil).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+			wantID:       12,
+			wantCardType: "student",
+		},
+		{
+			name: "unknown card is still logged",
+			uid:  "168496141",
+			expect: func(mock sqlmock.Sqlmock) {
+				expectCardByUID(mock, "0A0B0C0D", nil)
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO scan_events")).
+					WithArgs(3, "0A0B0C0D", nil, nil, nil, entity.ScanPurposeLookup, nil).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "invalid uid",
+			uid:      "not-a-number",
+			expect:   func(mock sqlmock.Sqlmock) {},
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newTestScanEventServices(t)
+			tt.expect(mock)
+
+			device := &entity.AuthDevice{DeviceID: 3, UIDFormat: helper.UIDFormatDecimalBE}
+			id, cardType, errorResponse := s.LookupCard(context.Background(), device, tt.uid)
+			switch {
+			case tt.wantCode == 0 && errorResponse != nil:
+				t.Fatalf("LookupCard: %d %s", errorResponse.Code, errorResponse.Message)
+			case tt.wantCode != 0 && (errorResponse == nil || errorResponse.Code != tt.wantCode):
+				t.Fatalf("LookupCard = %v, want %d", errorResponse, tt.wantCode)
+			}
+			if id != tt.wantID || cardType != tt.wantCardType {
+				t.Errorf("LookupCard = %d %q, want %d %q", id, cardType, tt.wantID, tt.wantCardType)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/repository"
+)
+
+type ScanSyncServicesInterface interface {
+	SyncScans(ctx context.Context, device *entity.AuthDevice, batch *entity.ScanSyncBatch) []*entity.ScanSyncResult
+}
+
+type ScanSyncServices struct {
+	DB *sql.DB
+	*repository.ScanSyncRepository
+	*ScanEventServices
+	*BorrowServices
+	Config *helper.EnvScanSync
+}
+
+func NewScanSyncServices(db *sql.DB, ssr *repository.ScanSyncRepository, ses *ScanEventServices, bs *BorrowServices, envScanSync *helper.EnvScanSync) *ScanSyncServices {
+	return &ScanSyncServices{
+		DB:                 db,
+		ScanSyncRepository: ssr,
+		ScanEventServices:  ses,
+		BorrowServices:     bs,
+		Config:             envScanSync,
+	}
+}
+
+// scanSyncState carries what earlier events of the batch established, such as
+// the student a following borrow scan is for.
+type scanSyncState struct {
+	studentID int
+}
+
+// SyncScans applies a reader's buffered scans in the order they were taken
+// and returns one result per event, in the order they were sent.
+func (s *ScanSyncServices) SyncScans(ctx context.Context, device *entity.AuthDevice, batch *entity.ScanSyncBatch) []*entity.ScanSyncResult {
+	results := make([]*entity.ScanSyncResult, len(batch.Events))
+	scannedAt := make([]time.Time, len(batch.Events))
+	order := make([]int, 0, len(batch.Events))
+
+	latest := time.Now().Add(s.Config.MaxClockSkew)
+	for i, event := range batch.Events {
+		t, err := time.Parse(time.RFC3339, event.ScannedAt)
+		switch {
+		case err != nil:
+			results[i] = s.rejectScan(ctx, device.DeviceID, event, "", "invalid scanned_at")
+		case t.After(latest):
+			results[i] = s.rejectScan(ctx, device.DeviceID, event, "", "scanned_at is in the future")
+		default:
+			scannedAt[i] = t
+			order = append(order, i)
+		}
+	}
+
+	sort.SliceStable(order, func(a, b int) bool {
+		return scannedAt[order[a]].Before(scannedAt[order[b]])
+	})
+
+	var state scanSyncState
+	for _, i := range order {
+		results[i] = s.syncScan(ctx, device, batch.Events[i], scannedAt[i].Local().Format(time.DateTime), &state)
+	}
+
+	return results
+}
+
+func (s *ScanSyncServices) syncScan(ctx context.Context, device *entity.AuthDevice, event *entity.ScanSyncEvent, scannedAt string, state *scanSyncState) *entity.ScanSyncResult {
+	existing, errorResponse := s.ScanSyncRepository.GetScanSyncEvent(ctx, s.DB, device.DeviceID, event.EventID)
+	if errorResponse != nil && errorResponse.Code != http.StatusNotFound {
+		return failedScanSync(event, errorResponse)
+	}
+	if existing != nil {
+		return duplicateScanSync(existing)
+	}
+
+	uid, errorResponse := normalizeCardUID(event.UID, device.UIDFormat)
+	if errorResponse != nil {
+		return s.rejectScan(ctx, device.DeviceID, event, scannedAt, errorResponse.Message)
+	}
+
+	scanEvent := &entity.ScanEvent{
+		DeviceID:  device.DeviceID,
+		UID:       uid,
+		Purpose:   event.Purpose,
+		CreatedAt: scannedAt,
+	}
+	unresolved := s.ScanEventServices.ResolveScanEvent(ctx, scanEvent)
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return failedScanSync(event, helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed"))
+	}
+	defer tx.Rollback()
+
+	scanEventID, errorResponse := s.ScanEventServices.ScanEventRepository.InsertScanEvent(ctx, tx, scanEvent)
+	if errorResponse != nil {
+		return failedScanSync(event, errorResponse)
+	}
+
+	result := &entity.ScanSyncResult{
+		EventID:     event.EventID,
+		Status:      entity.ScanSyncApplied,
+		ScanEventID: scanEventID,
+	}
+	if unresolved != nil {
+		result.Message = unresolved.Message
+	}
+
+	inserted, errorResponse := s.ScanSyncRepository.InsertScanSyncEvent(ctx, tx, device.DeviceID, result, scannedAt)
+	if errorResponse != nil {
+		return failedScanSync(event, errorResponse)
+	}
+	if !inserted {
+		existing, errorResponse := s.ScanSyncRepository.GetScanSyncEvent(ctx, s.DB, device.DeviceID, event.EventID)
+		if errorResponse != nil {
+			return failedScanSync(event, errorResponse)
+		}
+		return duplicateScanSync(existing)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return failedScanSync(event, helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed"))
+	}
+
+	if s.Config.ApplyBorrows && unresolved == nil {
+		s.applyBorrow(ctx, scanEvent, scannedAt, state, result)
+		if result.Action != "" || result.Message != "" {
+			if errorResponse := s.ScanSyncRepository.UpdateScanSyncAction(ctx, s.DB, device.DeviceID, result); errorResponse != nil {
+				log.Println("scan sync:", errorResponse.Message)
+			}
+		}
+	}
+
+	return result
+}
+
+// applyBorrow runs borrow and return processing for a synced scan. A student
+// card scanned for borrowing selects the student for the book scans that
+// follow it in the batch. Failures are reported on the result only, since
+// the scan itself is already in the log.
+func (s *ScanSyncServices) applyBorrow(ctx context.Context, event *entity.ScanEvent, scannedAt string, state *scanSyncState, result *entity.ScanSyncResult) {
+	switch {
+	case event.Purpose == entity.ScanPurposeBorrow && event.CardType == "student":
+		state.studentID = event.EntityID
+	case event.Purpose == entity.ScanPurposeBorrow && event.CardType == "book":
+		if state.studentID == 0 {
+			result.Message = "no student card was scanned before this book"
+			return
+		}
+		errorResponse := s.BorrowServices.InsertBorrow(ctx, &entity.Borrow{
+			StudentID: state.studentID,
+			BookIDS:   []int{event.EntityID},
+		})
+		if errorResponse != nil {
+			result.Message = errorResponse.Message
+			return
+		}
+		result.Action = entity.ScanSyncActionBorrowed
+	case event.Purpose == entity.ScanPurposeReturn && event.CardType == "book":
+		borrows, errorResponse := s.BorrowServices.GetBorrowByBookID(ctx, event.EntityID)
+		if errorResponse != nil {
+			result.Message = errorResponse.Message
+			return
+		}
+
+		for _, borrow := range borrows {
+			if borrow.Status != "borrowed" {
+				continue
+			}
+			errorResponse := s.BorrowServices.UpdateBorrow(ctx, &entity.BorrowUpdate{
+				TransactionID: borrow.TransactionID,
+				BookID:        event.EntityID,
+				StudentID:     borrow.StudentID,
+				ReturnDate:    scannedAt,
+				Status:        "returned",
+			})
+			if errorResponse != nil {
+				result.Message = errorResponse.Message
+				return
+			}
+			result.Action = entity.ScanSyncActionReturned
+			return
+		}
+		result.Message = "book has no open borrow"
+	}
+}
+
+// rejectScan records an event that can never be applied, so re-sending it is
+// answered as a duplicate instead of being rejected again.
+func (s *ScanSyncServices) rejectScan(ctx context.Context, deviceID int, event *entity.ScanSyncEvent, scannedAt, message string) *entity.ScanSyncResult {
+	result := &entity.ScanSyncResult{
+		EventID: event.EventID,
+		Status:  entity.ScanSyncRejected,
+		Message: message,
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return failedScanSync(event, helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed"))
+	}
+	defer tx.Rollback()
+
+	inserted, errorResponse := s.ScanSyncRepository.InsertScanSyncEvent(ctx, tx, deviceID, result, scannedAt)
+	if errorResponse != nil {
+		return failedScanSync(event, errorResponse)
+	}
+	if !inserted {
+		existing, errorResponse := s.ScanSyncRepository.GetScanSyncEvent(ctx, s.DB, deviceID, event.EventID)
+		if errorResponse != nil {
+			return failedScanSync(event, errorResponse)
+		}
+		return duplicateScanSync(existing)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return failedScanSync(event, helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed"))
+	}
+
+	return result
+}
+
+func duplicateScanSync(existing *entity.ScanSyncResult) *entity.ScanSyncResult {
+	existing.Status = entity.ScanSyncDuplicate
+	return existing
+}
+
+func failedScanSync(event *entity.ScanSyncEvent, errorResponse *entity.ErrorResponse) *entity.ScanSyncResult {
+	return &entity.ScanSyncResult{
+		EventID: event.EventID,
+		Status:  entity.ScanSyncFailed,
+		Message: errorResponse.Message,
+	}
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/repository"
+)
+
+func newTestScanSyncServices(t *testing.T) (*ScanSyncServices, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	studentServices := NewStudentServices(db, repository.NewStudentRepository())
+	bookServices := NewBookServices(repository.NewBookRepository(), repository.NewBookItemRepository(), db)
+	cardServices := NewCardServices(db, repository.NewCardRepository(), studentServices, bookServices)
+	scanEventServices := NewScanEventServices(db, repository.NewScanEventRepository(), cardServices, helper.GetEnvScanEvents())
+
+	s := NewScanSyncServices(db, repository.NewScanSyncRepository(), scanEventServices, nil, &helper.EnvScanSync{MaxClockSkew: time.Minute})
+	return s, mock
+}
+
+// TestSyncScans uploads a batch sent out of order with one event the server
+// already has. The mock is ordered, so any other order of applying the
+// events fails the expectations.
+func TestSyncScans(t *testing.T) {
+	s, mock := newTestScanSyncServices(t)
+	device := &entity.AuthDevice{DeviceID: 3, UIDFormat: helper.UIDFormatHex}
+
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	at := func(minutes int) time.Time { return base.Add(time.Duration(minutes) * time.Minute) }
+	dbTime := func(t time.Time) string { return t.Local().Format(time.DateTime) }
+
+	batch := &entity.ScanSyncBatch{Events: []*entity.ScanSyncEvent{
+		{EventID: "r3-000003", UID: "04A1B2C3", Purpose: entity.ScanPurposeReturn, ScannedAt: at(3).Format(time.RFC3339)},
+		{EventID: "r3-000001", UID: "04:a1:b2:c1", Purpose: entity.ScanPurposeReturn, ScannedAt: at(1).Format(time.RFC3339)},
+		{EventID: "r3-000002", UID: "04A1B2C2", Purpose: entity.ScanPurposeReturn, ScannedAt: at(2).Format(time.RFC3339)},
+		{EventID: "r3-000004", UID: "04A1B2C4", ScannedAt: time.Now().Add(time.Hour).Format(time.RFC3339)},
+	}}
+
+	syncEventColumns := []string{"event_id", "status", "scan_event_id", "action", "message"}
+	expectNewEvent := func(eventID, uid string, scannedAt time.Time, scanEventID int64) {
+		mock.ExpectQuery(regexp.QuoteMeta("FROM scan_sync_events WHERE device_id = ? AND event_id = ?")).
+			WithArgs(3, eventID).
+			WillReturnRows(sqlmock.NewRows(syncEventColumns))
+		mock.ExpectQuery(regexp.QuoteMeta("FROM card_rfid WHERE uid = ?")).
+			WithArgs(uid).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "uid", "type", "status"}))
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO scan_events")).
+			WithArgs(3, uid, nil, nil, nil, entity.ScanPurposeReturn, dbTime(scannedAt)).
+			WillReturnResult(sqlmock.NewResult(scanEventID, 1))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO scan_sync_events")).
+			WithArgs(3, eventID, scanEventID, entity.ScanSyncApplied, "card not found", dbTime(scannedAt)).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+	}
+
+	// Events with an unusable timestamp are rejected before anything is applied.
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO scan_sync_events")).
+		WithArgs(3, "r3-000004", nil, entity.ScanSyncRejected, "scanned_at is in the future", nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	expectNewEvent("r3-000001", "04A1B2C1", at(1), 101)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM scan_sync_events WHERE device_id = ? AND event_id = ?")).
+		WithArgs(3, "r3-000002").
+		WillReturnRows(sqlmock.NewRows(syncEventColumns).AddRow("r3-000002", entity.ScanSyncApplied, 77, entity.ScanSyncActionReturned, nil))
+	expectNewEvent("r3-000003", "04A1B2C3", at(3), 102)
+
+	results := s.SyncScans(context.Background(), device, batch)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []entity.ScanSyncResult{
+		{EventID: "r3-000003", Status: entity.ScanSyncApplied, ScanEventID: 102, Message: "card not found"},
+		{EventID: "r3-000001", Status: entity.ScanSyncApplied, ScanEventID: 101, Message: "card not found"},
+		{EventID: "r3-000002", Status: entity.ScanSyncDuplicate, ScanEventID: 77, Action: entity.ScanSyncActionReturned},
+		{EventID: "r3-000004", Status: entity.ScanSyncRejected, Message: "scanned_at is in the future"},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	for i := range want {
+		if *results[i] != want[i] {
+			t.Errorf("result %d = %+v, want %+v", i, *results[i], want[i])
+		}
+	}
+}
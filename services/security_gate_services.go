@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/repository"
+)
+
+// securityEventTimeout bounds recording a gate check, which happens after the
+// gate already has its answer.
+const securityEventTimeout = 5 * time.Second
+
+type SecurityGateServicesInterface interface {
+	CheckGate(ctx context.Context, device *entity.AuthDevice, uid string) (*entity.GateCheckResult, *entity.ErrorResponse)
+	GetSecurityEvents(ctx context.Context, filter *entity.SecurityEventFilter) ([]*entity.SecurityEvent, *entity.ErrorResponse)
+}
+
+type SecurityGateServices struct {
+	DB *sql.DB
+	*repository.SecurityEventRepository
+	*CardServices
+	*BorrowServices
+	*ScanEventServices
+	Config *helper.EnvSecurityGate
+}
+
+func NewSecurityGateServices(db *sql.DB, ser *repository.SecurityEventRepository, cs *CardServices, bs *BorrowServices, ses *ScanEventServices, envSecurityGate *helper.EnvSecurityGate) *SecurityGateServices {
+	return &SecurityGateServices{
+		DB:                      db,
+		SecurityEventRepository: ser,
+		CardServices:            cs,
+		BorrowServices:          bs,
+		ScanEventServices:       ses,
+		Config:                  envSecurityGate,
+	}
+}
+
+// CheckGate decides whether a tag leaving through the exit gate should sound
+// the alarm. Only book tags are checked; a book must be in borrowed status to
+// pass. The lookup runs within the configured latency budget. The scan, and
+// the alarm if there is one, are recorded after the answer is returned.
+func (s *SecurityGateServices) CheckGate(ctx context.Context, device *entity.AuthDevice, uid string) (*entity.GateCheckResult, *entity.ErrorResponse) {
+	start := time.Now()
+
+	uid, errorResponse := normalizeCardUID(uid, device.UIDFormat)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, s.Config.Timeout)
+	defer cancel()
+
+	result, cardID, errorResponse := s.checkTag(checkCtx, uid)
+	if errorResponse != nil {
+		if checkCtx.Err() == nil {
+			return nil, errorResponse
+		}
+		log.Printf("security gate: check of uid %s on device %d exceeded %s", uid, device.DeviceID, s.Config.Timeout)
+		result = &entity.GateCheckResult{
+			Alarm:  s.Config.AlarmOnTimeout,
+			Status: entity.GateTimeout,
+			Reason: "check exceeded the latency budget",
+		}
+	}
+	result.ElapsedMs = time.Since(start).Milliseconds()
+
+	scan := &entity.ScanEvent{
+		DeviceID: device.DeviceID,
+		UID:      uid,
+		Purpose:  entity.ScanPurposeGate,
+	}
+	var alarm *entity.SecurityEvent
+	if result.Alarm {
+		alarm = &entity.SecurityEvent{
+			DeviceID: device.DeviceID,
+			UID:      uid,
+			CardID:   cardID,
+			BookID:   result.BookID,
+			Reason:   result.Reason,
+		}
+	}
+	go s.recordGateCheck(scan, alarm)
+
+	return result, nil
+}
+
+func (s *SecurityGateServices) checkTag(ctx context.Context, uid string) (*entity.GateCheckResult, int, *entity.ErrorResponse) {
+	card, errorResponse := s.CardServices.CardRepository.GetCardByUID(ctx, s.DB, uid)
+	if errorResponse != nil {
+		if errorResponse.Code == http.StatusNotFound {
+			return &entity.GateCheckResult{Status: entity.GateIgnored, Reason: "tag is not registered"}, 0, nil
+		}
+		return nil, 0, errorResponse
+	}
+	if card.Type != "book" {
+		return &entity.GateCheckResult{Status: entity.GateIgnored, Reason: "not a book tag"}, card.ID, nil
+	}
+
+	if errorResponse := checkCardUsable(card); errorResponse != nil {
+		return &entity.GateCheckResult{Alarm: true, Status: entity.GateAlarm, Reason: errorResponse.Message}, card.ID, nil
+	}
+
+	book, errorResponse := s.CardServices.BookServices.GetBookByCardID(ctx, card.ID)
+	if errorResponse != nil {
+		if errorResponse.Code == http.StatusNotFound {
+			return &entity.GateCheckResult{Status: entity.GateIgnored, Reason: "tag is not linked to a book"}, card.ID, nil
+		}
+		return nil, card.ID, errorResponse
+	}
+
+	var itemID int
+	item, errorResponse := s.CardServices.BookServices.BookItemRepository.GetBookItemByCardID(ctx, s.DB, card.ID)
+	if errorResponse != nil && errorResponse.Code != http.StatusNotFound {
+		return nil, card.ID, errorResponse
+	}
+	if item != nil {
+		itemID = item.ID
+	}
+
+	borrows, errorResponse := s.BorrowServices.GetBorrowByBookID(ctx, book.ID)
+	if errorResponse != nil {
+		return nil, card.ID, errorResponse
+	}
+
+	// A tag on a copy only passes with a borrow of that very copy.
+	reason := "book is not borrowed"
+	for _, borrow := range borrows {
+		if itemID != 0 && borrow.ItemID != itemID {
+			continue
+		}
+		switch borrow.Status {
+		case "borrowed":
+			return &entity.GateCheckResult{Status: entity.GateAllowed, BookID: book.ID, TransactionID: borrow.TransactionID}, card.ID, nil
+		case "pending":
+			reason = "book borrow is still pending"
+		}
+	}
+
+	return &entity.GateCheckResult{Alarm: true, Status: entity.GateAlarm, Reason: reason, BookID: book.ID}, card.ID, nil
+}
+
+// recordGateCheck logs the scan and, when the gate sounded, its alarm in one
+// transaction, so an alarm is never stored without the scan that raised it.
+func (s *SecurityGateServices) recordGateCheck(scan *entity.ScanEvent, alarm *entity.SecurityEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), securityEventTimeout)
+	defer cancel()
+
+	s.ScanEventServices.ResolveScanEvent(ctx, scan)
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("security gate: transaction start failed")
+		return
+	}
+	defer tx.Rollback()
+
+	if _, errorResponse := s.ScanEventServices.ScanEventRepository.InsertScanEvent(ctx, tx, scan); errorResponse != nil {
+		log.Println("security gate:", errorResponse.Message)
+		return
+	}
+
+	if alarm != nil {
+		if errorResponse := s.SecurityEventRepository.InsertSecurityEvent(ctx, tx, alarm); errorResponse != nil {
+			log.Println("security gate:", errorResponse.Message)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println("security gate: transaction commit failed")
+	}
+}
+
+func (s *SecurityGateServices) GetSecurityEvents(ctx context.Context, filter *entity.SecurityEventFilter) ([]*entity.SecurityEvent, *entity.ErrorResponse) {
+	if filter.From != "" && filter.To != "" && filter.From > filter.To {
+		return nil, helper.ErrorResponse(http.StatusBadRequest, "from must not be after to")
+	}
+	return s.SecurityEventRepository.GetSecurityEvents(ctx, s.DB, filter)
+}
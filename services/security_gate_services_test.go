@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/repository"
+)
+
+// waitForScanEvents waits until the device has at least n scan events with
+// the purpose, for events recorded from a goroutine.
+func waitForScanEvents(t *testing.T, db *sql.DB, deviceID int, purpose string, entityID, n int) int {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	count := countScanEvents(t, db, deviceID, purpose, entityID)
+	for count < n && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		count = countScanEvents(t, db, deviceID, purpose, entityID)
+	}
+	return count
+}
+
+// TestCheckGateRecordsScanEvent checks that every gate check is logged as a
+// gate scan, and that an alarm is stored alongside its scan.
+func TestCheckGateRecordsScanEvent(t *testing.T) {
+	db := openTestMySQL(t)
+	borrowServices := newTestBorrowServices(db)
+	scanEventServices := NewScanEventServices(db, repository.NewScanEventRepository(), borrowServices.CardServices, helper.GetEnvScanEvents())
+	s := NewSecurityGateServices(db, repository.NewSecurityEventRepository(), borrowServices.CardServices, borrowServices, scanEventServices, &helper.EnvSecurityGate{Timeout: 2 * time.Second})
+
+	deviceID := mustInsert(t, db, "INSERT INTO devices (name) VALUES ('exit gate')")
+	device := &entity.AuthDevice{DeviceID: deviceID, UIDFormat: helper.UIDFormatHex}
+
+	tests := []struct {
+		name      string
+		uid       string
+		borrowed  bool
+		wantAlarm bool
+	}{
+		{name: "borrowed book passes", uid: "1A2B3C4D", borrowed: true},
+		{name: "book on the shelf sounds the alarm", uid: "5E6F7A8B", wantAlarm: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cardID := mustInsert(t, db, "INSERT INTO card_rfid (uid, type) VALUES (?, 'book')", tt.uid)
+			bookID := seedBook(t, db, tt.name)
+			if _, err := db.Exec("UPDATE books SET card_id = ? WHERE id = ?", cardID, bookID); err != nil {
+				t.Fatal(err)
+			}
+			if tt.borrowed {
+				mustInsert(t, db, "INSERT INTO borrows (student_id, transaction_id, book_id, due_date, status) VALUES (?, ?, ?, NOW() + INTERVAL 7 DAY, 'borrowed')", seedStudent(t, db, tt.uid), tt.uid, bookID)
+			}
+
+			result, errorResponse := s.CheckGate(context.Background(), device, tt.uid)
+			if errorResponse != nil {
+				t.Fatalf("CheckGate: %d %s", errorResponse.Code, errorResponse.Message)
+			}
+			if result.Alarm != tt.wantAlarm {
+				t.Fatalf("alarm = %v, want %v", result.Alarm, tt.wantAlarm)
+			}
+
+			if count := waitForScanEvents(t, db, deviceID, entity.ScanPurposeGate, bookID, 1); count != 1 {
+				t.Fatalf("%d gate scan events, want 1", count)
+			}
+
+			var alarms int
+			if err := db.QueryRow("SELECT COUNT(*) FROM security_events WHERE device_id = ? AND book_id = ?", deviceID, bookID).Scan(&alarms); err != nil {
+				t.Fatal(err)
+			}
+			want := 0
+			if tt.wantAlarm {
+				want = 1
+			}
+			if alarms != want {
+				t.Errorf("%d security events, want %d", alarms, want)
+			}
+		})
+	}
+}
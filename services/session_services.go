@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/repository"
+)
+
+type SessionServicesInterface interface {
+	CreateSession(ctx context.Context, session *entity.Session) (sessionID int, refreshToken string, err *entity.ErrorResponse)
+	RefreshSession(ctx context.Context, refreshToken string) (session *entity.Session, newRefreshToken string, err *entity.ErrorResponse)
+	IsSessionActive(ctx context.Context, sessionID, accountID int) bool
+	RevokeSession(ctx context.Context, sessionID, accountID int) *entity.ErrorResponse
+	RevokeAllSessions(ctx context.Context, accountID int) *entity.ErrorResponse
+}
+
+type SessionServices struct {
+	DB *sql.DB
+	*repository.SessionRepository
+	RefreshTokenTTL time.Duration
+}
+
+func NewSessionServices(db *sql.DB, sessionRepository *repository.SessionRepository, envJWT *helper.EnvJWT) *SessionServices {
+	return &SessionServices{
+		DB:                db,
+		SessionRepository: sessionRepository,
+		RefreshTokenTTL:   envJWT.RefreshTokenTTL,
+	}
+}
+
+func (s *SessionServices) CreateSession(ctx context.Context, session *entity.Session) (int, string, *entity.ErrorResponse) {
+	refreshToken, err := helper.GenerateRandomToken(32)
+	if err != nil {
+		return 0, "", helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, "", helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	sessionID, errorResponse := s.SessionRepository.InsertSession(ctx, tx, session, helper.HashToken(refreshToken), s.RefreshTokenTTL)
+	if errorResponse != nil {
+		return 0, "", errorResponse
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, "", helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return sessionID, refreshToken, nil
+}
+
+// RefreshSession swaps a refresh token for a new one. Presenting a token that
+// was already rotated out revokes the whole session, since it means the token
+// has been copied.
+func (s *SessionServices) RefreshSession(ctx context.Context, refreshToken string) (*entity.Session, string, *entity.ErrorResponse) {
+	invalidToken := helper.ErrorResponse(http.StatusUnauthorized, "Invalid or expired refresh token")
+	refreshTokenHash := helper.HashToken(refreshToken)
+
+	session, errorResponse := s.SessionRepository.GetActiveSessionByRefreshTokenHash(ctx, s.DB, refreshTokenHash)
+	if errorResponse != nil {
+		if errorResponse.Code != http.StatusNotFound {
+			return nil, "", errorResponse
+		}
+
+		reused, _ := s.SessionRepository.GetSessionByPreviousRefreshTokenHash(ctx, s.DB, refreshTokenHash)
+		if reused != nil {
+			if errorResponse := s.RevokeSession(ctx, reused.ID, reused.AccountID); errorResponse != nil {
+				return nil, "", errorResponse
+			}
+		}
+		return nil, "", invalidToken
+	}
+
+	newRefreshToken, err := helper.GenerateRandomToken(32)
+	if err != nil {
+		return nil, "", helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, "", helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	rotated, errorResponse := s.SessionRepository.RotateRefreshToken(ctx, tx, session.ID, refreshTokenHash, helper.HashToken(newRefreshToken), s.RefreshTokenTTL)
+	if errorResponse != nil {
+		return nil, "", errorResponse
+	}
+	if !rotated {
+		return nil, "", invalidToken
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, "", helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return session, newRefreshToken, nil
+}
+
+func (s *SessionServices) IsSessionActive(ctx context.Context, sessionID, accountID int) bool {
+	active, errorResponse := s.SessionRepository.IsSessionActive(ctx, s.DB, sessionID, accountID)
+	if errorResponse != nil {
+		return false
+	}
+	return active
+}
+
+func (s *SessionServices) RevokeSession(ctx context.Context, sessionID, accountID int) *entity.ErrorResponse {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	errorResponse := s.SessionRepository.RevokeSession(ctx, tx, sessionID, accountID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	if err := tx.Commit(); err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return nil
+}
+
+func (s *SessionServices) RevokeAllSessions(ctx context.Context, accountID int) *entity.ErrorResponse {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	errorResponse := s.SessionRepository.RevokeSessionsByAccountID(ctx, tx, accountID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	if err := tx.Commit(); err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/repository"
+)
+
+var testSessionColumns = []string{"id", "account_id", "user_agent", "ip_address", "created_at", "last_used_at", "expires_at"}
+
+// capturedArg matches any argument and keeps it, for values such as token
+// hashes that are generated inside the code under test.
+type capturedArg struct {
+	value driver.Value
+}
+
+func (c *capturedArg) Match(v driver.Value) bool {
+	c.value = v
+	return true
+}
+
+func newTestSessionServices(t *testing.T) (*SessionServices, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	s := NewSessionServices(db, repository.NewSessionRepository(), &helper.EnvJWT{RefreshTokenTTL: time.Hour})
+	return s, mock
+}
+
+func sessionRows() *sqlmock.Rows {
+	return sqlmock.NewRows(testSessionColumns).AddRow(70, 7, "test", "127.0.0.1", "2024-05-01 10:00:00", nil, "2024-05-01 11:00:00")
+}
+
+// createTestSession creates session 70 for account 7 and returns its refresh
+// token.
+func createTestSession(t *testing.T, s *SessionServices, mock sqlmock.Sqlmock) string {
+	t.Helper()
+
+	tokenHash := &capturedArg{}
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO sessions")).
+		WithArgs(7, tokenHash, "test", "127.0.0.1", 3600).
+		WillReturnResult(sqlmock.NewResult(70, 1))
+	mock.ExpectCommit()
+
+	sessionID, refreshToken, errorResponse := s.CreateSession(context.Background(), &entity.Session{AccountID: 7, UserAgent: "test", IPAddress: "127.0.0.1"})
+	if errorResponse != nil {
+		t.Fatalf("CreateSession: %d %s", errorResponse.Code, errorResponse.Message)
+	}
+	if sessionID != 70 {
+		t.Fatalf("session id = %d, want 70", sessionID)
+	}
+	if tokenHash.value != helper.HashToken(refreshToken) {
+		t.Fatal("stored hash does not belong to the returned refresh token")
+	}
+
+	return refreshToken
+}
+
+func expectActiveSession(mock sqlmock.Sqlmock, refreshToken string, found bool) {
+	rows := sqlmock.NewRows(testSessionColumns)
+	if found {
+		rows = sessionRows()
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("FROM sessions WHERE refresh_token_hash = ? AND revoked_at IS NULL")).
+		WithArgs(helper.HashToken(refreshToken)).
+		WillReturnRows(rows)
+}
+
+func expectRefreshFails(t *testing.T, s *SessionServices, refreshToken string) {
+	t.Helper()
+
+	_, _, errorResponse := s.RefreshSession(context.Background(), refreshToken)
+	if errorResponse == nil || errorResponse.Code != http.StatusUnauthorized {
+		t.Fatalf("RefreshSession = %v, want 401", errorResponse)
+	}
+}
+
+func TestRefreshSessionRevokesOnReuse(t *testing.T) {
+	s, mock := newTestSessionServices(t)
+	firstToken := createTestSession(t, s, mock)
+
+	// Rotating hands out a new token and keeps the old hash as the previous one.
+	newHash := &capturedArg{}
+	expectActiveSession(mock, firstToken, true)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE sessions SET previous_refresh_token_hash = refresh_token_hash")).
+		WithArgs(newHash, 3600, 70, helper.HashToken(firstToken)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	session, secondToken, errorResponse := s.RefreshSession(context.Background(), firstToken)
+	if errorResponse != nil {
+		t.Fatalf("RefreshSession: %d %s", errorResponse.Code, errorResponse.Message)
+	}
+	if session.ID != 70 || session.AccountID != 7 {
+		t.Fatalf("refreshed session %d of account %d, want 70 of 7", session.ID, session.AccountID)
+	}
+	if secondToken == firstToken || newHash.value != helper.HashToken(secondToken) {
+		t.Fatal("refresh token was not rotated")
+	}
+
+	// Presenting the rotated out token again revokes the session.
+	expectActiveSession(mock, firstToken, false)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM sessions WHERE previous_refresh_token_hash = ?")).
+		WithArgs(helper.HashToken(firstToken)).
+		WillReturnRows(sessionRows())
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE sessions SET revoked_at = NOW() WHERE id = ? AND account_id = ?")).
+		WithArgs(70, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectRefreshFails(t, s, firstToken)
+
+	// The revoked session no longer accepts the current token either.
+	expectActiveSession(mock, secondToken, false)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM sessions WHERE previous_refresh_token_hash = ?")).
+		WithArgs(helper.HashToken(secondToken)).
+		WillReturnRows(sqlmock.NewRows(testSessionColumns))
+	expectRefreshFails(t, s, secondToken)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRefreshSessionLosesRotationRace(t *testing.T) {
+	s, mock := newTestSessionServices(t)
+	refreshToken := createTestSession(t, s, mock)
+
+	// Another request rotated the token between the lookup and the update.
+	expectActiveSession(mock, refreshToken, true)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE sessions SET previous_refresh_token_hash = refresh_token_hash")).
+		WithArgs(sqlmock.AnyArg(), 3600, 70, helper.HashToken(refreshToken)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	expectRefreshFails(t, s, refreshToken)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRevokeAllSessions(t *testing.T) {
+	s, mock := newTestSessionServices(t)
+	refreshToken := createTestSession(t, s, mock)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE sessions SET revoked_at = NOW() WHERE account_id = ? AND revoked_at IS NULL")).
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	if errorResponse := s.RevokeAllSessions(context.Background(), 7); errorResponse != nil {
+		t.Fatalf("RevokeAllSessions: %d %s", errorResponse.Code, errorResponse.Message)
+	}
+
+	expectActiveSession(mock, refreshToken, false)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM sessions WHERE previous_refresh_token_hash = ?")).
+		WithArgs(helper.HashToken(refreshToken)).
+		WillReturnRows(sqlmock.NewRows(testSessionColumns))
+	expectRefreshFails(t, s, refreshToken)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM sessions WHERE id = ? AND account_id = ?")).
+		WithArgs(70, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	if s.IsSessionActive(context.Background(), 70, 7) {
+		t.Fatal("session is still active after logout-all")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
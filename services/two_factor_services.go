@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/repository"
+)
+
+const recoveryCodeCount = 10
+
+type TwoFactorServicesInterface interface {
+	TwoFactorEnabled(ctx context.Context, accountID int) (bool, *entity.ErrorResponse)
+	BeginTwoFactorSetup(ctx context.Context, account *entity.AccountResponse) (*entity.TwoFactorSetupResponse, *entity.ErrorResponse)
+	ConfirmTwoFactorSetup(ctx context.Context, accountID int, code string) ([]string, *entity.ErrorResponse)
+	VerifyTwoFactorCode(ctx context.Context, accountID int, code string) *entity.ErrorResponse
+	DisableTwoFactor(ctx context.Context, account *entity.AccountResponse, code string) *entity.ErrorResponse
+	RegenerateRecoveryCodes(ctx context.Context, accountID int, code string) ([]string, *entity.ErrorResponse)
+}
+
+type TwoFactorServices struct {
+	DB *sql.DB
+	*repository.TwoFactorRepository
+	Config *helper.EnvTwoFactor
+	Now    func() time.Time
+}
+
+func NewTwoFactorServices(db *sql.DB, tfr *repository.TwoFactorRepository, envTwoFactor *helper.EnvTwoFactor) *TwoFactorServices {
+	return &TwoFactorServices{
+		DB:                  db,
+		TwoFactorRepository: tfr,
+		Config:              envTwoFactor,
+		Now:                 time.Now,
+	}
+}
+
+func (s *TwoFactorServices) TwoFactorEnabled(ctx context.Context, accountID int) (bool, *entity.ErrorResponse) {
+	return s.TwoFactorRepository.IsTwoFactorEnabled(ctx, s.DB, accountID)
+}
+
+// BeginTwoFactorSetup stores a fresh pending secret. It only takes effect once
+// ConfirmTwoFactorSetup sees a valid code generated from it.
+func (s *TwoFactorServices) BeginTwoFactorSetup(ctx context.Context, account *entity.AccountResponse) (*entity.TwoFactorSetupResponse, *entity.ErrorResponse) {
+	secret, err := helper.GenerateTOTPSecret()
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	twoFactor, errorResponse := s.TwoFactorRepository.GetTwoFactorForUpdate(ctx, tx, account.ID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+	if twoFactor.Enabled {
+		return nil, helper.ErrorResponse(http.StatusConflict, "Two-factor authentication is already enabled.")
+	}
+
+	errorResponse = s.TwoFactorRepository.SetTwoFactorSecret(ctx, tx, account.ID, secret)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return &entity.TwoFactorSetupResponse{
+		Secret: secret,
+		URI:    helper.TOTPURI(s.Config.Issuer, account.Email, secret),
+	}, nil
+}
+
+func (s *TwoFactorServices) ConfirmTwoFactorSetup(ctx context.Context, accountID int, code string) ([]string, *entity.ErrorResponse) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	twoFactor, errorResponse := s.TwoFactorRepository.GetTwoFactorForUpdate(ctx, tx, accountID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+	if twoFactor.Enabled {
+		return nil, helper.ErrorResponse(http.StatusConflict, "Two-factor authentication is already enabled.")
+	}
+	if twoFactor.Secret == "" {
+		return nil, helper.ErrorResponse(http.StatusConflict, "Two-factor setup has not been started.")
+	}
+
+	step, valid := helper.ValidateTOTP(twoFactor.Secret, code, s.Now())
+	if !valid {
+		return nil, helper.ErrorResponse(http.StatusBadRequest, "Invalid two-factor code")
+	}
+
+	errorResponse = s.TwoFactorRepository.EnableTwoFactor(ctx, tx, accountID, step)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	recoveryCodes, errorResponse := s.issueRecoveryCodes(ctx, tx, accountID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return recoveryCodes, nil
+}
+
+func (s *TwoFactorServices) VerifyTwoFactorCode(ctx context.Context, accountID int, code string) *entity.ErrorResponse {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	errorResponse := s.verifyCode(ctx, tx, accountID, code)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	if err := tx.Commit(); err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return nil
+}
+
+func (s *TwoFactorServices) DisableTwoFactor(ctx context.Context, account *entity.AccountResponse, code string) *entity.ErrorResponse {
+	if s.Config.RequiredForAdmin && account.Level == "admin" {
+		return helper.ErrorResponse(http.StatusForbidden, "Two-factor authentication is required for admin accounts.")
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	errorResponse := s.verifyCode(ctx, tx, account.ID, code)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	errorResponse = s.TwoFactorRepository.DisableTwoFactor(ctx, tx, account.ID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	errorResponse = s.TwoFactorRepository.DeleteRecoveryCodes(ctx, tx, account.ID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+
+	if err := tx.Commit(); err != nil {
+		return helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return nil
+}
+
+func (s *TwoFactorServices) RegenerateRecoveryCodes(ctx context.Context, accountID int, code string) ([]string, *entity.ErrorResponse) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "transaction start failed")
+	}
+	defer tx.Rollback()
+
+	errorResponse := s.verifyCode(ctx, tx, accountID, code)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	recoveryCodes, errorResponse := s.issueRecoveryCodes(ctx, tx, accountID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "transaction commit failed")
+	}
+
+	return recoveryCodes, nil
+}
+
+// verifyCode accepts either an authenticator code or an unused recovery code.
+// An authenticator code is only accepted for a time step newer than the last
+// one used, so an intercepted code cannot be replayed.
+func (s *TwoFactorServices) verifyCode(ctx context.Context, tx *sql.Tx, accountID int, code string) *entity.ErrorResponse {
+	twoFactor, errorResponse := s.TwoFactorRepository.GetTwoFactorForUpdate(ctx, tx, accountID)
+	if errorResponse != nil {
+		return errorResponse
+	}
+	if !twoFactor.Enabled {
+		return helper.ErrorResponse(http.StatusConflict, "Two-factor authentication is not enabled.")
+	}
+
+	if step, valid := helper.ValidateTOTP(twoFactor.Secret, code, s.Now()); valid && step > twoFactor.LastStep {
+		return s.TwoFactorRepository.UpdateTwoFactorLastStep(ctx, tx, accountID, step)
+	}
+
+	used, errorResponse := s.TwoFactorRepository.UseRecoveryCode(ctx, tx, accountID, helper.HashToken(helper.NormalizeRecoveryCode(code)))
+	if errorResponse != nil {
+		return errorResponse
+	}
+	if !used {
+		return helper.ErrorResponse(http.StatusBadRequest, "Invalid two-factor code")
+	}
+
+	return nil
+}
+
+func (s *TwoFactorServices) issueRecoveryCodes(ctx context.Context, tx *sql.Tx, accountID int) ([]string, *entity.ErrorResponse) {
+	recoveryCodes, err := helper.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, helper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	codeHashes := make([]string, 0, len(recoveryCodes))
+	for _, code := range recoveryCodes {
+		codeHashes = append(codeHashes, helper.HashToken(helper.NormalizeRecoveryCode(code)))
+	}
+
+	errorResponse := s.TwoFactorRepository.DeleteRecoveryCodes(ctx, tx, accountID)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	errorResponse = s.TwoFactorRepository.InsertRecoveryCodes(ctx, tx, accountID, codeHashes)
+	if errorResponse != nil {
+		return nil, errorResponse
+	}
+
+	return recoveryCodes, nil
+}
@@ -0,0 +1,318 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dimassfeb-09/smart-library-be/entity"
+	"github.com/dimassfeb-09/smart-library-be/helper"
+	"github.com/dimassfeb-09/smart-library-be/repository"
+)
+
+// The SHA1 secret and codes from the RFC 6238 test vectors, cut to six
+// digits. Both times fall in neighbouring 30 second steps.
+const (
+	rfcTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	rfcStepA      = 1111111109 / 30
+	rfcCodeA      = "081804"
+	rfcStepB      = 1111111111 / 30
+	rfcCodeB      = "050471"
+)
+
+var (
+	rfcTimeA = time.Unix(1111111109, 0)
+	rfcTimeB = time.Unix(1111111111, 0)
+)
+
+var testTwoFactorColumns = []string{"totp_secret", "enabled", "totp_last_step"}
+
+func newTestTwoFactorServices(t *testing.T, now time.Time) (*TwoFactorServices, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	s := NewTwoFactorServices(db, repository.NewTwoFactorRepository(), &helper.EnvTwoFactor{
+		RequiredForAdmin: true,
+		Issuer:           "Smart Library",
+		ChallengeTTL:     time.Minute,
+	})
+	s.Now = func() time.Time { return now }
+
+	return s, mock
+}
+
+func expectTwoFactorRow(mock sqlmock.Sqlmock, secret any, enabled bool, lastStep int64) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT totp_secret, totp_enabled_at IS NOT NULL, totp_last_step FROM accounts WHERE id = ? FOR UPDATE")).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows(testTwoFactorColumns).AddRow(secret, enabled, lastStep))
+}
+
+func TestVerifyTwoFactorCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		now      time.Time
+		code     string
+		lastStep int64
+		wantStep int64
+	}{
+		{name: "current step", now: rfcTimeB, code: rfcCodeB, wantStep: rfcStepB},
+		{name: "one step behind", now: rfcTimeB, code: rfcCodeA, wantStep: rfcStepA},
+		{name: "one step ahead", now: rfcTimeA, code: rfcCodeB, wantStep: rfcStepB},
+		{name: "two steps behind", now: rfcTimeB.Add(30 * time.Second), code: rfcCodeA},
+		{name: "two steps ahead", now: rfcTimeA.Add(-30 * time.Second), code: rfcCodeB},
+		{name: "wrong code", now: rfcTimeB, code: "123456"},
+		{name: "replayed step", now: rfcTimeB, code: rfcCodeB, lastStep: rfcStepB},
+		{name: "step older than last used", now: rfcTimeB, code: rfcCodeA, lastStep: rfcStepB},
+		{name: "newer step after older one", now: rfcTimeB, code: rfcCodeB, lastStep: rfcStepA, wantStep: rfcStepB},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newTestTwoFactorServices(t, tt.now)
+
+			mock.ExpectBegin()
+			expectTwoFactorRow(mock, rfcTOTPSecret, true, tt.lastStep)
+			if tt.wantStep != 0 {
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE accounts SET totp_last_step = ?")).
+					WithArgs(tt.wantStep, 7).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			} else {
+				// A code that is not accepted is tried as a recovery code.
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE recovery_codes SET used_at = NOW()")).
+					WithArgs(7, helper.HashToken(tt.code)).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			}
+
+			errorResponse := s.VerifyTwoFactorCode(context.Background(), 7, tt.code)
+			switch {
+			case tt.wantStep != 0 && errorResponse != nil:
+				t.Fatalf("VerifyTwoFactorCode: %d %s", errorResponse.Code, errorResponse.Message)
+			case tt.wantStep == 0 && (errorResponse == nil || errorResponse.Code != http.StatusBadRequest):
+				t.Fatalf("VerifyTwoFactorCode = %v, want 400", errorResponse)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestRecoveryCodeIsSingleUse(t *testing.T) {
+	s, mock := newTestTwoFactorServices(t, rfcTimeB)
+	codeHash := helper.HashToken("abcdefghij")
+
+	// The first use marks the code used, so the same code typed differently
+	// matches nothing the second time.
+	tests := []struct {
+		code     string
+		used     int64
+		wantCode int
+	}{
+		{code: "abcde-fghij", used: 1},
+		{code: "ABCDE-FGHIJ", used: 0, wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		mock.ExpectBegin()
+		expectTwoFactorRow(mock, rfcTOTPSecret, true, rfcStepB)
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE recovery_codes SET used_at = NOW() WHERE account_id = ? AND code_hash = ? AND used_at IS NULL")).
+			WithArgs(7, codeHash).
+			WillReturnResult(sqlmock.NewResult(0, tt.used))
+		if tt.wantCode == 0 {
+			mock.ExpectCommit()
+		} else {
+			mock.ExpectRollback()
+		}
+
+		errorResponse := s.VerifyTwoFactorCode(context.Background(), 7, tt.code)
+		switch {
+		case tt.wantCode == 0 && errorResponse != nil:
+			t.Fatalf("VerifyTwoFactorCode(%q): %d %s", tt.code, errorResponse.Code, errorResponse.Message)
+		case tt.wantCode != 0 && (errorResponse == nil || errorResponse.Code != tt.wantCode):
+			t.Fatalf("VerifyTwoFactorCode(%q) = %v, want %d", tt.code, errorResponse, tt.wantCode)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfirmTwoFactorSetup(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		wantCode int
+	}{
+		{name: "valid code", code: rfcCodeB},
+		{name: "invalid code", code: "123456", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newTestTwoFactorServices(t, rfcTimeB)
+
+			mock.ExpectBegin()
+			expectTwoFactorRow(mock, rfcTOTPSecret, false, 0)
+			if tt.wantCode == 0 {
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE accounts SET totp_enabled_at = NOW(), totp_last_step = ?")).
+					WithArgs(int64(rfcStepB), 7).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta("DELETE FROM recovery_codes WHERE account_id = ?")).
+					WithArgs(7).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				for i := 0; i < recoveryCodeCount; i++ {
+					mock.ExpectExec(regexp.QuoteMeta("INSERT INTO recovery_codes (account_id, code_hash)")).
+						WithArgs(7, sqlmock.AnyArg()).
+						WillReturnResult(sqlmock.NewResult(int64(i+1), 1))
+				}
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
+
+			recoveryCodes, errorResponse := s.ConfirmTwoFactorSetup(context.Background(), 7, tt.code)
+			switch {
+			case tt.wantCode == 0 && errorResponse != nil:
+				t.Fatalf("ConfirmTwoFactorSetup: %d %s", errorResponse.Code, errorResponse.Message)
+			case tt.wantCode != 0 && (errorResponse == nil || errorResponse.Code != tt.wantCode):
+				t.Fatalf("ConfirmTwoFactorSetup = %v, want %d", errorResponse, tt.wantCode)
+			}
+			if tt.wantCode == 0 && len(recoveryCodes) != recoveryCodeCount {
+				t.Fatalf("got %d recovery codes, want %d", len(recoveryCodes), recoveryCodeCount)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestTwoFactorChallenge(t *testing.T) {
+	tests := []struct {
+		name      string
+		level     string
+		required  bool
+		enabled   bool
+		wantLogin bool
+		wantSetup bool
+	}{
+		{name: "admin must set up", level: "admin", required: true, wantSetup: true},
+		{name: "admin not required", level: "admin"},
+		{name: "student never required", level: "student", required: true},
+		{name: "enabled", level: "student", enabled: true, wantLogin: true},
+		{name: "admin enabled", level: "admin", required: true, enabled: true, wantLogin: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock, _ := newTestAccountServices(t)
+			s.TwoFactorServices.Config.RequiredForAdmin = tt.required
+
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT totp_enabled_at IS NOT NULL FROM accounts WHERE id = ?")).
+				WithArgs(7).
+				WillReturnRows(sqlmock.NewRows([]string{"enabled"}).AddRow(tt.enabled))
+
+			challenge, errorResponse := s.twoFactorChallenge(context.Background(), &entity.AccountResponse{ID: 7, Email: "sari@library.test", Level: tt.level})
+			if errorResponse != nil {
+				t.Fatalf("twoFactorChallenge: %d %s", errorResponse.Code, errorResponse.Message)
+			}
+			if !tt.wantLogin && !tt.wantSetup {
+				if challenge != nil {
+					t.Fatalf("twoFactorChallenge = %+v, want no challenge", challenge)
+				}
+				return
+			}
+			if challenge == nil {
+				t.Fatal("twoFactorChallenge returned no challenge")
+			}
+			if challenge.TwoFactorRequired != tt.wantLogin || challenge.TwoFactorSetupRequired != tt.wantSetup {
+				t.Fatalf("challenge requires login %v, setup %v; want %v, %v", challenge.TwoFactorRequired, challenge.TwoFactorSetupRequired, tt.wantLogin, tt.wantSetup)
+			}
+			if challenge.AccessToken != "" || challenge.RefreshToken != "" {
+				t.Fatal("challenge carries tokens")
+			}
+
+			claims, err := helper.ParseTwoFactorChallengeToken(s.JWT, challenge.ChallengeToken)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.AccountID != 7 || claims.Setup != tt.wantSetup {
+				t.Fatalf("challenge token is for account %d with setup %v", claims.AccountID, claims.Setup)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestBeginLoginTwoFactorSetup(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    bool
+		wantCode int
+	}{
+		{name: "setup challenge", setup: true},
+		{name: "login challenge", wantCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock, _ := newTestAccountServices(t)
+
+			challengeToken, err := helper.GenerateTwoFactorChallengeToken(s.JWT, 7, tt.setup, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.wantCode == 0 {
+				mock.ExpectQuery(regexp.QuoteMeta("FROM accounts WHERE id = ?")).
+					WithArgs(7).
+					WillReturnRows(sqlmock.NewRows(testAccountColumns).AddRow(7, "sari@library.test", "admin", true))
+				mock.ExpectBegin()
+				expectTwoFactorRow(mock, nil, false, 0)
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE accounts SET totp_secret = ?")).
+					WithArgs(sqlmock.AnyArg(), 7).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+
+			setup, errorResponse := s.BeginLoginTwoFactorSetup(context.Background(), &entity.TwoFactorSetupRequest{ChallengeToken: challengeToken})
+			switch {
+			case tt.wantCode == 0 && errorResponse != nil:
+				t.Fatalf("BeginLoginTwoFactorSetup: %d %s", errorResponse.Code, errorResponse.Message)
+			case tt.wantCode != 0 && (errorResponse == nil || errorResponse.Code != tt.wantCode):
+				t.Fatalf("BeginLoginTwoFactorSetup = %v, want %d", errorResponse, tt.wantCode)
+			}
+			if tt.wantCode == 0 && (setup.Secret == "" || setup.URI == "") {
+				t.Fatalf("setup response is missing the secret: %+v", setup)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestDisableTwoFactorRequiredForAdmin(t *testing.T) {
+	s, mock := newTestTwoFactorServices(t, rfcTimeB)
+
+	errorResponse := s.DisableTwoFactor(context.Background(), &entity.AccountResponse{ID: 7, Level: "admin"}, rfcCodeB)
+	if errorResponse == nil || errorResponse.Code != http.StatusForbidden {
+		t.Fatalf("DisableTwoFactor = %v, want 403", errorResponse)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -40,6 +40,12 @@ CREATE TABLE `accounts` (
     `email` varchar(50) DEFAULT NULL,
     `password` varchar(255) DEFAULT NULL,
     `level` enum('admin', 'student') DEFAULT NULL,
+    `email_verified_at` datetime DEFAULT NULL,
+    `failed_login_attempts` int NOT NULL DEFAULT '0',
+    `locked_until` datetime DEFAULT NULL,
+    `totp_secret` varchar(64) DEFAULT NULL,
+    `totp_enabled_at` datetime DEFAULT NULL,
+    `totp_last_step` bigint NOT NULL DEFAULT '0',
     PRIMARY KEY (`ID`)
 ) ENGINE = InnoDB AUTO_INCREMENT = 22 DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_general_ci;
 /*!40101 SET character_set_client = @saved_cs_client */
@@ -58,11 +64,55 @@ VALUES (
         21,
         'admin@gmail.com',
         '$2a$10$jFJmAE8X2xHOt0j3hJ/zz.bEETiJGso6LNfq4qOu1sPyaWJ/I/SwC',
-        'admin'
+        'admin',
+        '2024-05-29 22:52:42',
+        0,
+        NULL,
+        NULL,
+        NULL,
+        0
     );
 /*!40000 ALTER TABLE `accounts` ENABLE KEYS */
 ;
 
+--
+-- Table structure for table `book_items`
+--
+
+DROP TABLE IF EXISTS `book_items`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */
+;
+/*!50503 SET character_set_client = utf8mb4 */
+;
+
+CREATE TABLE `book_items` (
+    `id` int NOT NULL AUTO_INCREMENT,
+    `book_id` int NOT NULL,
+    `card_id` int DEFAULT NULL,
+    `barcode` varchar(64) NOT NULL,
+    `shelf_location` varchar(100) NOT NULL DEFAULT '',
+    `condition` enum('new', 'good', 'fair', 'poor', 'damaged') NOT NULL DEFAULT 'good',
+    `status` enum('available', 'maintenance', 'lost', 'withdrawn') NOT NULL DEFAULT 'available',
+    `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `idx_book_item_barcode` (`barcode`),
+    UNIQUE KEY `idx_book_item_card_id` (`card_id`),
+    KEY `fk_book_item_book_id` (`book_id`),
+    CONSTRAINT `fk_book_item_book_id` FOREIGN KEY (`book_id`) REFERENCES `books` (`id`) ON DELETE CASCADE,
+    CONSTRAINT `fk_book_item_card_id` FOREIGN KEY (`card_id`) REFERENCES `card_rfid` (`id`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_general_ci;
+/*!40101 SET character_set_client = @saved_cs_client */
+;
+
+--
+-- Dumping data for table `book_items`
+--
+
+/*!40000 ALTER TABLE `book_items` DISABLE KEYS */
+;
+/*!40000 ALTER TABLE `book_items` ENABLE KEYS */
+;
+
 --
 -- Table structure for table `books`
 --
@@ -140,11 +190,14 @@ CREATE TABLE `borrows` (
         'borrowed',
         'returned'
     ) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci DEFAULT 'pending',
+    `item_id` int DEFAULT NULL,
     PRIMARY KEY (`id`),
     KEY `fk_book` (`book_id`),
     KEY `fk_student` (`student_id`),
+    KEY `fk_borrow_item_id` (`item_id`),
     CONSTRAINT `fk_book` FOREIGN KEY (`book_id`) REFERENCES `books` (`id`),
-    CONSTRAINT `fk_student` FOREIGN KEY (`student_id`) REFERENCES `students` (`id`)
+    CONSTRAINT `fk_student` FOREIGN KEY (`student_id`) REFERENCES `students` (`id`),
+    CONSTRAINT `fk_borrow_item_id` FOREIGN KEY (`item_id`) REFERENCES `book_items` (`id`) ON DELETE SET NULL
 ) ENGINE = InnoDB AUTO_INCREMENT = 29 DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci;
 /*!40101 SET character_set_client = @saved_cs_client */
 ;
@@ -159,58 +212,686 @@ CREATE TABLE `borrows` (
 ;
 
 --
--- Table structure for table `card_container`
+-- Table structure for table `card_rfid`
+--
+
+DROP TABLE IF EXISTS `card_rfid`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */
+;
+/*!50503 SET character_set_client = utf8mb4 */
+;
+
+CREATE TABLE `card_rfid` (
+    `id` int NOT NULL AUTO_INCREMENT,
+    `uid` varchar(100) DEFAULT NULL,
+    `type` enum('book', 'student') DEFAULT NULL,
+    `status` enum(
+        'active',
+        'lost',
+        'blocked',
+        'replaced'
+    ) NOT NULL DEFAULT 'active',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `idx_card_rfid_uid` (`uid`)
+) ENGINE = InnoDB AUTO_INCREMENT = 46 DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci;
+/*!40101 SET character_set_client = @saved_cs_client */
+;
+
+--
+-- Dumping data for table `card_rfid`
+--
+
+/*!40000 ALTER TABLE `card_rfid` DISABLE KEYS */
+;
+
+INSERT INTO `card_rfid` VALUES ( 44, 'jakhdjkahsejhajwhasda', 'book', 'active' );
+/*!40000 ALTER TABLE `card_rfid` ENABLE KEYS */
+;
+
+--
+-- Table structure for table `card_status_history`
+--
+
+DROP TABLE IF EXISTS `card_status_history`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */
+;
+/*!50503 SET character_set_client = utf8mb4 */
+;
+
+CREATE TABLE `card_status_history` (
+    `id` int NOT NULL AUTO_INCREMENT,
+    `card_id` int NOT NULL,
+    `from_status` varchar(20) DEFAULT NULL,
+    `to_status` varchar(20) NOT NULL,
+    `reason` varchar(255) DEFAULT NULL,
+    `account_id` int DEFAULT NULL,
+    `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    KEY `fk_card_status_history_card_id` (`card_id`, `id`),
+    CONSTRAINT `fk_card_status_history_card_id` FOREIGN KEY (`card_id`) REFERENCES `card_rfid` (`id`) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_general_ci;
+/*!40101 SET character_set_client = @saved_cs_client */
+;
+
+--
+-- Dumping data for table `card_status_history`
+--
+
+/*!40000 ALTER TABLE `card_status_history` DISABLE KEYS */
+;
+/*!40000 ALTER TABLE `card_status_history` ENABLE KEYS */
+;
+
+--
+-- Table structure for table `device_api_keys`
+--
+
+DROP TABLE IF EXISTS `device_api_keys`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */
+;
+/*!50503 SET character_set_client = utf8mb4 */
+;
+
+CREATE TABLE `device_api_keys` (
+    `id` int NOT NULL AUTO_INCREMENT,
+    `device_id` int NOT NULL,
+    `key_prefix` varchar(12) NOT NULL,
+    `key_hash` char(64) NOT NULL,
+    `scopes` varchar(255) NOT NULL,
+    `signing_secret` varchar(64) DEFAULT NULL,
+    `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `last_used_at` datetime DEFAULT NULL,
+    `revoked_at` datetime DEFAULT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `idx_device_api_key_hash` (`key_hash`),
+    KEY `fk_device_api_key_device_id` (`device_id`),
+    CONSTRAINT `fk_device_api_key_device_id` FOREIGN KEY (`device_id`) REFERENCES `devices` (`id`) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_general_ci;
+/*!40101 SET character_set_client = @saved_cs_client */
+;
+
+--
+-- Dumping data for table `device_api_keys`
+--
+
+/*!40000 ALTER TABLE `device_api_keys` DISABLE KEYS */
+;
+/*!40000 ALTER TABLE `device_api_keys` ENABLE KEYS */
+;
+
+--
+-- Table structure for table `device_calls`
+--
+
+DROP TABLE IF EXISTS `device_calls`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */
+;
+/*!50503 SET character_set_client = utf8mb4 */
+;
+
+CREATE TABLE `device_calls` (
+    `id` int NOT NULL AUTO_INCREMENT,
+    `device_id` int NOT NULL,
+    `api_key_id` int NOT NULL,
+    `method` varchar(10) NOT NULL,
+    `path` varchar(255) NOT NULL,
+    `status_code` int NOT NULL,
+    `ip_address` varchar(45) DEFAULT NULL,
+    `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    KEY `fk_device_call_device_id` (`device_id`, `created_at`),
+    KEY `fk_device_call_api_key_id` (`api_key_id`),
+    CONSTRAINT `fk_device_call_api_key_id` FOREIGN KEY (`api_key_id`) REFERENCES `device_api_keys` (`id`) ON DELETE CASCADE,
+    CONSTRAINT `fk_device_call_device_id` FOREIGN KEY (`device_id`) REFERENCES `devices` (`id`) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_general_ci;
+/*!40101 SET character_set_client = @saved_cs_client */
+;
+
+--
+-- Dumping data for table `device_calls`
+--
+
+/*!40000 ALTER TABLE `device_calls` DISABLE KEYS */
+;
+/*!40000 ALTER TABLE `device_calls` ENABLE KEYS */
+;
+
+--
+-- Table structure for table `device_scans`
+--
+
+DROP TABLE IF EXISTS `device_scans`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */
+;
+/*!50503 SET character_set_client = utf8mb4 */
+;
+
+CREATE TABLE `device_scans` (
+    `id` int NOT NULL AUTO_INCREMENT,
+    `device_id` int NOT NULL,
+    `uid` varchar(255) NOT NULL,
+    `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `expires_at` datetime NOT NULL,
+    PRIMARY KEY (`id`),
+    KEY `fk_device_scan_device_id` (`device_id`, `id`),
+    CONSTRAINT `fk_device_scan_device_id` FOREIGN KEY (`device_id`) REFERENCES `devices` (`id`) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_general_ci;
+/*!40101 SET character_set_client = @saved_cs_client */
+;
+
+--
+-- Dumping data for table `device_scans`
+--
+
+/*!40000 ALTER TABLE `device_scans` DISABLE KEYS */
+;
+/*!40000 ALTER TABLE `device_scans` ENABLE KEYS */
+;
+
+--
+-- Table structure for table `devices`
+--
+
+DROP TABLE IF EXISTS `devices`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */
+;
+/*!50503 SET character_set_client = utf8mb4 */
+;
+
+CREATE TABLE `devices` (
+    `id` int NOT NULL AUTO_INCREMENT,
+    `name` varchar(50) NOT NULL,
+    `location` varchar(100) DEFAULT NULL,
+    `uid_format` enum(
+        'hex',
+        'decimal_le',
+        'decimal_be'
+    ) NOT NULL DEFAULT 'hex',
+    `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `last_seen_at` datetime DEFAULT NULL,
+    `firmware_version` varchar(50) DEFAULT NULL,
+    `ip_address` varchar(45) DEFAULT NULL,
+    `uptime_seconds` bigint DEFAULT NULL,
+    `signal_strength` int DEFAULT NULL,
+    `last_heartbeat_at` datetime DEFAULT NULL,
+    `health_alerted_at` datetime DEFAULT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `idx_device_name` (`name`),
+    KEY `idx_device_last_heartbeat_at` (`last_heartbeat_at`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_general_ci;
+/*!40101 SET character_set_client = @saved_cs_client */
+;
+
+--
+-- Dumping data for table `devices`
+--
+
+/*!40000 ALTER TABLE `devices` DISABLE KEYS */
+;
+/*!40000 ALTER TABLE `devices` ENABLE KEYS */
+;
+
+--
+-- Table structure for table `enrollment_items`
+--
+
+DROP TABLE IF EXISTS `enrollment_items`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */
+;
+/*!50503 SET character_set_client = utf8mb4 */
+;
+
+CREATE TABLE `enrollment_items` (
+    `id` int NOT NULL AUTO_INCREMENT,
+    `session_id` int NOT NULL,
+    `uid` varchar(255) NOT NULL,
+    `card_id` int DEFAULT NULL,
+    `result` enum('enrolled', 'duplicate') NOT NULL,
+    `message` varchar(255) DEFAULT NULL,
+    `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    KEY `fk_enrollment_item_session_id` (`session_id`, `id`),
+    CONSTRAINT `fk_enrollment_item_session_id` FOREIGN KEY (`session_id`) REFERENCES `enrollment_sessions` (`id`) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_general_ci;
+/*!40101 SET character_set_client = @saved_cs_client */
+;
+
+--
+-- Dumping data for table `enrollment_items`
+--
+
+/*!40000 ALTER TABLE `enrollment_items` DISABLE KEYS */
+;
+/*!40000 ALTER TABLE `enrollment_items` ENABLE KEYS */
+;
+
+--
+-- Table structure for table `enrollment_sessions`
+--
+
+DROP TABLE IF EXISTS `enrollment_sessions`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */
+;
+/*!50503 SET character_set_client = utf8mb4 */
+;
+
+CREATE TABLE `enrollment_sessions` (
+    `id` int NOT NULL AUTO_INCREMENT,
+    `device_id` int NOT NULL,
+    `card_type` enum('book', 'student') NOT NULL,
+    `status` enum('open', 'closed') NOT NULL DEFAULT 'open',
+    `account_id` int DEFAULT NULL,
+    `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `closed_at` datetime DEFAULT NULL,
+    PRIMARY KEY (`id`),
+    KEY `fk_enrollment_session_device_id` (`device_id`, `status`),
+    CONSTRAINT `fk_enrollment_session_device_id` FOREIGN KEY (`device_id`) REFERENCES `devices` (`id`) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_general_ci;
+/*!40101 SET character_set_client = @saved_cs_client */
+;
+
+--
+-- Dumping data for table `enrollment_sessions`
+--
+
+/*!40000 ALTER TABLE `enrollment_sessions` DISABLE KEYS */
+;
+/*!40000 ALTER TABLE `enrollment_sessions` ENABLE KEYS */
+;
+
+--
+-- Table structure for table `kiosk_session_books`
 --
 
-DROP TABLE IF EXISTS `card_container`;
+DROP TABLE IF EXISTS `kiosk_session_books`;
 /*!40101 SET @saved_cs_client     = @@character_set_client */
 ;
 /*!50503 SET character_set_client = utf8mb4 */
 ;
 
-CREATE TABLE `card_container` (
-    `uid` varchar(255) DEFAULT NULL
+CREATE TABLE `kiosk_session_books` (
+    `id` int NOT NULL AUTO_INCREMENT,
+    `session_id` int NOT NULL,
+    `book_id` int NOT NULL,
+    `item_id` int DEFAULT NULL,
+    `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `idx_kiosk_session_book` (`session_id`, `book_id`),
+    KEY `fk_kiosk_session_book_book_id` (`book_id`),
+    KEY `fk_kiosk_session_book_item_id` (`item_id`),
+    CONSTRAINT `fk_kiosk_session_book_session_id` FOREIGN KEY (`session_id`) REFERENCES `kiosk_sessions` (`id`) ON DELETE CASCADE,
+    CONSTRAINT `fk_kiosk_session_book_book_id` FOREIGN KEY (`book_id`) REFERENCES `books` (`id`) ON DELETE CASCADE,
+    CONSTRAINT `fk_kiosk_session_book_item_id` FOREIGN KEY (`item_id`) REFERENCES `book_items` (`id`) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_general_ci;
+/*!40101 SET character_set_client = @saved_cs_client */
+;
+
+--
+-- Dumping data for table `kiosk_session_books`
+--
+
+/*!40000 ALTER TABLE `kiosk_session_books` DISABLE KEYS */
+;
+/*!40000 ALTER TABLE `kiosk_session_books` ENABLE KEYS */
+;
+
+--
+-- Table structure for table `kiosk_sessions`
+--
+
+DROP TABLE IF EXISTS `kiosk_sessions`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */
+;
+/*!50503 SET character_set_client = utf8mb4 */
+;
+
+CREATE TABLE `kiosk_sessions` (
+    `id` int NOT NULL AUTO_INCREMENT,
+    `device_id` int NOT NULL,
+    `student_id` int NOT NULL,
+    `status` enum(
+        'open',
+        'confirmed',
+        'cancelled',
+        'expired'
+    ) NOT NULL DEFAULT 'open',
+    `transaction_id` varchar(100) DEFAULT NULL,
+    `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `expires_at` datetime NOT NULL,
+    `closed_at` datetime DEFAULT NULL,
+    PRIMARY KEY (`id`),
+    KEY `fk_kiosk_session_device_id` (`device_id`, `status`),
+    KEY `fk_kiosk_session_student_id` (`student_id`),
+    CONSTRAINT `fk_kiosk_session_device_id` FOREIGN KEY (`device_id`) REFERENCES `devices` (`id`) ON DELETE CASCADE,
+    CONSTRAINT `fk_kiosk_session_student_id` FOREIGN KEY (`student_id`) REFERENCES `students` (`id`) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_general_ci;
+/*!40101 SET character_set_client = @saved_cs_client */
+;
+
+--
+-- Dumping data for table `kiosk_sessions`
+--
+
+/*!40000 ALTER TABLE `kiosk_sessions` DISABLE KEYS */
+;
+/*!40000 ALTER TABLE `kiosk_sessions` ENABLE KEYS */
+;
+
+--
+-- Table structure for table `login_attempts`
+--
+
+DROP TABLE IF EXISTS `login_attempts`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */
+;
+/*!50503 SET character_set_client = utf8mb4 */
+;
+
+CREATE TABLE `login_attempts` (
+    `id` int NOT NULL AUTO_INCREMENT,
+    `account_id` int DEFAULT NULL,
+    `email` varchar(50) NOT NULL,
+    `ip_address` varchar(45) NOT NULL,
+    `user_agent` varchar(255) DEFAULT NULL,
+    `success` tinyint(1) NOT NULL DEFAULT '0',
+    `reason` varchar(50) DEFAULT NULL,
+    `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    KEY `idx_login_attempt_ip_created_at` (`ip_address`, `created_at`),
+    KEY `fk_login_attempt_account_id` (`account_id`, `created_at`),
+    CONSTRAINT `fk_login_attempt_account_id` FOREIGN KEY (`account_id`) REFERENCES `accounts` (`ID`) ON DELETE CASCADE
 ) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci;
 /*!40101 SET character_set_client = @saved_cs_client */
 ;
 
 --
--- Dumping data for table `card_container`
+-- Dumping data for table `login_attempts`
 --
 
-/*!40000 ALTER TABLE `card_container` DISABLE KEYS */
+/*!40000 ALTER TABLE `login_attempts` DISABLE KEYS */
 ;
-/*!40000 ALTER TABLE `card_container` ENABLE KEYS */
+/*!40000 ALTER TABLE `login_attempts` ENABLE KEYS */
 ;
 
 --
--- Table structure for table `card_rfid`
+-- Table structure for table `notification_queue`
 --
 
-DROP TABLE IF EXISTS `card_rfid`;
+DROP TABLE IF EXISTS `notification_queue`;
 /*!40101 SET @saved_cs_client     = @@character_set_client */
 ;
 /*!50503 SET character_set_client = utf8mb4 */
 ;
 
-CREATE TABLE `card_rfid` (
+CREATE TABLE `notification_queue` (
+    `id` bigint NOT NULL AUTO_INCREMENT,
+    `email` varchar(255) NOT NULL,
+    `subject` varchar(255) NOT NULL,
+    `body` text NOT NULL,
+    `status` enum('pending', 'sent', 'failed') NOT NULL DEFAULT 'pending',
+    `attempts` int NOT NULL DEFAULT '0',
+    `last_error` varchar(255) DEFAULT NULL,
+    `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `sent_at` datetime DEFAULT NULL,
+    PRIMARY KEY (`id`),
+    KEY `idx_notification_queue_status` (`status`, `id`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_general_ci;
+/*!40101 SET character_set_client = @saved_cs_client */
+;
+
+--
+-- Dumping data for table `notification_queue`
+--
+
+/*!40000 ALTER TABLE `notification_queue` DISABLE KEYS */
+;
+/*!40000 ALTER TABLE `notification_queue` ENABLE KEYS */
+;
+
+--
+-- Table structure for table `password_history`
+--
+
+DROP TABLE IF EXISTS `password_history`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */
+;
+/*!50503 SET character_set_client = utf8mb4 */
+;
+
+CREATE TABLE `password_history` (
     `id` int NOT NULL AUTO_INCREMENT,
-    `uid` varchar(100) DEFAULT NULL,
-    `type` enum('book', 'student') DEFAULT NULL,
-    PRIMARY KEY (`id`)
-) ENGINE = InnoDB AUTO_INCREMENT = 46 DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci;
+    `account_id` int NOT NULL,
+    `password_hash` varchar(255) NOT NULL,
+    `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    KEY `fk_password_history_account_id` (`account_id`, `created_at`),
+    CONSTRAINT `fk_password_history_account_id` FOREIGN KEY (`account_id`) REFERENCES `accounts` (`ID`) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_general_ci;
 /*!40101 SET character_set_client = @saved_cs_client */
 ;
 
 --
--- Dumping data for table `card_rfid`
+-- Dumping data for table `password_history`
 --
 
-/*!40000 ALTER TABLE `card_rfid` DISABLE KEYS */
+/*!40000 ALTER TABLE `password_history` DISABLE KEYS */
+;
+/*!40000 ALTER TABLE `password_history` ENABLE KEYS */
 ;
 
-INSERT INTO `card_rfid` VALUES ( 44, 'jakhdjkahsejhajwhasda', 'book' );
-/*!40000 ALTER TABLE `card_rfid` ENABLE KEYS */
+--
+-- Table structure for table `password_reset_tokens`
+--
+
+DROP TABLE IF EXISTS `password_reset_tokens`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */
+;
+/*!50503 SET character_set_client = utf8mb4 */
+;
+
+CREATE TABLE `password_reset_tokens` (
+    `id` int NOT NULL AUTO_INCREMENT,
+    `account_id` int NOT NULL,
+    `token_hash` char(64) NOT NULL,
+    `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `expires_at` datetime NOT NULL,
+    `used_at` datetime DEFAULT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uq_password_reset_token_hash` (`token_hash`),
+    KEY `fk_password_reset_account_id` (`account_id`),
+    CONSTRAINT `fk_password_reset_account_id` FOREIGN KEY (`account_id`) REFERENCES `accounts` (`ID`) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci;
+/*!40101 SET character_set_client = @saved_cs_client */
+;
+
+--
+-- Dumping data for table `password_reset_tokens`
+--
+
+/*!40000 ALTER TABLE `password_reset_tokens` DISABLE KEYS */
+;
+/*!40000 ALTER TABLE `password_reset_tokens` ENABLE KEYS */
+;
+
+--
+-- Table structure for table `recovery_codes`
+--
+
+DROP TABLE IF EXISTS `recovery_codes`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */
+;
+/*!50503 SET character_set_client = utf8mb4 */
+;
+
+CREATE TABLE `recovery_codes` (
+    `id` int NOT NULL AUTO_INCREMENT,
+    `account_id` int NOT NULL,
+    `code_hash` char(64) NOT NULL,
+    `used_at` datetime DEFAULT NULL,
+    `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `idx_recovery_code_hash` (`account_id`, `code_hash`),
+    CONSTRAINT `fk_recovery_code_account_id` FOREIGN KEY (`account_id`) REFERENCES `accounts` (`ID`) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_general_ci;
+/*!40101 SET character_set_client = @saved_cs_client */
+;
+
+--
+-- Dumping data for table `recovery_codes`
+--
+
+/*!40000 ALTER TABLE `recovery_codes` DISABLE KEYS */
+;
+/*!40000 ALTER TABLE `recovery_codes` ENABLE KEYS */
+;
+
+--
+-- Table structure for table `scan_events`
+--
+
+DROP TABLE IF EXISTS `scan_events`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */
+;
+/*!50503 SET character_set_client = utf8mb4 */
+;
+
+CREATE TABLE `scan_events` (
+    `id` bigint NOT NULL AUTO_INCREMENT,
+    `device_id` int DEFAULT NULL,
+    `uid` varchar(255) NOT NULL,
+    `card_id` int DEFAULT NULL,
+    `card_type` varchar(20) DEFAULT NULL,
+    `entity_id` int DEFAULT NULL,
+    `purpose` varchar(20) NOT NULL DEFAULT 'unknown',
+    `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    KEY `idx_scan_event_created_at` (`created_at`),
+    KEY `idx_scan_event_device_id` (`device_id`, `created_at`),
+    KEY `idx_scan_event_uid` (`uid`, `created_at`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_general_ci;
+/*!40101 SET character_set_client = @saved_cs_client */
+;
+
+--
+-- Dumping data for table `scan_events`
+--
+
+/*!40000 ALTER TABLE `scan_events` DISABLE KEYS */
+;
+/*!40000 ALTER TABLE `scan_events` ENABLE KEYS */
+;
+
+-- scan_events is an append-only log; only the retention job deletes from it.
+CREATE TRIGGER `scan_events_no_update` BEFORE UPDATE ON `scan_events` FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'scan_events is append-only';
+
+--
+-- Table structure for table `scan_sync_events`
+--
+
+DROP TABLE IF EXISTS `scan_sync_events`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */
+;
+/*!50503 SET character_set_client = utf8mb4 */
+;
+
+CREATE TABLE `scan_sync_events` (
+    `id` bigint NOT NULL AUTO_INCREMENT,
+    `device_id` int NOT NULL,
+    `event_id` varchar(64) NOT NULL,
+    `scan_event_id` bigint DEFAULT NULL,
+    `status` enum('applied', 'rejected') NOT NULL,
+    `action` varchar(50) DEFAULT NULL,
+    `message` varchar(255) DEFAULT NULL,
+    `scanned_at` datetime DEFAULT NULL,
+    `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `idx_scan_sync_device_event` (`device_id`, `event_id`),
+    CONSTRAINT `fk_scan_sync_device_id` FOREIGN KEY (`device_id`) REFERENCES `devices` (`id`) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_general_ci;
+/*!40101 SET character_set_client = @saved_cs_client */
+;
+
+--
+-- Dumping data for table `scan_sync_events`
+--
+
+/*!40000 ALTER TABLE `scan_sync_events` DISABLE KEYS */
+;
+/*!40000 ALTER TABLE `scan_sync_events` ENABLE KEYS */
+;
+
+--
+-- Table structure for table `security_events`
+--
+
+DROP TABLE IF EXISTS `security_events`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */
+;
+/*!50503 SET character_set_client = utf8mb4 */
+;
+
+CREATE TABLE `security_events` (
+    `id` bigint NOT NULL AUTO_INCREMENT,
+    `device_id` int DEFAULT NULL,
+    `uid` varchar(255) NOT NULL,
+    `card_id` int DEFAULT NULL,
+    `book_id` int DEFAULT NULL,
+    `reason` varchar(255) NOT NULL,
+    `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    KEY `idx_security_event_created_at` (`created_at`),
+    KEY `fk_security_event_device_id` (`device_id`),
+    CONSTRAINT `fk_security_event_device_id` FOREIGN KEY (`device_id`) REFERENCES `devices` (`id`) ON DELETE SET NULL
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_general_ci;
+/*!40101 SET character_set_client = @saved_cs_client */
+;
+
+--
+-- Dumping data for table `security_events`
+--
+
+/*!40000 ALTER TABLE `security_events` DISABLE KEYS */
+;
+/*!40000 ALTER TABLE `security_events` ENABLE KEYS */
+;
+
+--
+-- Table structure for table `sessions`
+--
+
+DROP TABLE IF EXISTS `sessions`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */
+;
+/*!50503 SET character_set_client = utf8mb4 */
+;
+
+CREATE TABLE `sessions` (
+    `id` int NOT NULL AUTO_INCREMENT,
+    `account_id` int NOT NULL,
+    `refresh_token_hash` char(64) NOT NULL,
+    `previous_refresh_token_hash` char(64) DEFAULT NULL,
+    `user_agent` varchar(255) DEFAULT NULL,
+    `ip_address` varchar(45) DEFAULT NULL,
+    `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `last_used_at` datetime DEFAULT NULL,
+    `expires_at` datetime NOT NULL,
+    `revoked_at` datetime DEFAULT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uq_session_refresh_token_hash` (`refresh_token_hash`),
+    KEY `idx_session_previous_refresh_token_hash` (`previous_refresh_token_hash`),
+    KEY `fk_session_account_id` (`account_id`),
+    CONSTRAINT `fk_session_account_id` FOREIGN KEY (`account_id`) REFERENCES `accounts` (`ID`) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci;
+/*!40101 SET character_set_client = @saved_cs_client */
+;
+
+--
+-- Dumping data for table `sessions`
+--
+
+/*!40000 ALTER TABLE `sessions` DISABLE KEYS */
+;
+/*!40000 ALTER TABLE `sessions` ENABLE KEYS */
 ;
 
 --